@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"chatapp/internal/config"
+)
+
+// 启动时重试连接数据库的间隔，从 initialPingBackoff 开始每次翻倍，最多 maxPingBackoff
+const (
+	initialPingBackoff = 250 * time.Millisecond
+	maxPingBackoff     = 5 * time.Second
+)
+
+func configureDBPool(db *sql.DB, cfg config.DBConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// waitForDB 以指数退避重试 Ping，直到数据库可用或超过 timeout。
+// docker-compose 中数据库通常比应用启动得慢，不能在第一次连接失败时就退出
+func waitForDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := initialPingBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("database is not ready, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("database not reachable after %s (%d attempts): %w", timeout, attempt, err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxPingBackoff)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+)
+
+// fakeConnector 只负责建立连接的驱动，用于观察连接池的行为
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+// TestConfigureDBPoolFromEnv 连接池使用环境变量中配置的上限和连接寿命
+func TestConfigureDBPoolFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/chatapp")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("DB_MAX_OPEN_CONNS", "4")
+	t.Setenv("DB_MAX_IDLE_CONNS", "2")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "200ms")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := config.DBConfig{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: 200 * time.Millisecond}
+	if cfg.DB.MaxOpenConns != want.MaxOpenConns || cfg.DB.MaxIdleConns != want.MaxIdleConns || cfg.DB.ConnMaxLifetime != want.ConnMaxLifetime {
+		t.Fatalf("DB config = %+v, want %+v", cfg.DB, want)
+	}
+
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+	configureDBPool(db, cfg.DB)
+
+	if got := db.Stats().MaxOpenConnections; got != 4 {
+		t.Fatalf("MaxOpenConnections = %d, want 4", got)
+	}
+
+	// 同时取出 4 个连接后全部归还，只保留 2 个空闲连接
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 4; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	stats := db.Stats()
+	if stats.OpenConnections != 4 || stats.InUse != 4 {
+		t.Fatalf("stats = %+v, want 4 open connections in use", stats)
+	}
+	// 达到上限后第 5 个连接要等待
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.Conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fifth Conn error = %v, want deadline exceeded", err)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	stats = db.Stats()
+	if stats.Idle != 2 || stats.MaxIdleClosed != 2 {
+		t.Fatalf("stats = %+v, want 2 idle and 2 closed for max idle", stats)
+	}
+
+	// 超过寿命的连接不再复用
+	time.Sleep(250 * time.Millisecond)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	conn.Close()
+	if got := db.Stats().MaxLifetimeClosed; got == 0 {
+		t.Fatal("no connection was closed for exceeding its max lifetime")
+	}
+}
+
+// dialConnector 每次建立连接时拨号 addr，拨号成功后返回 fakeConn，用来模拟启动较慢的数据库
+type dialConnector struct{ addr string }
+
+func (c dialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return fakeConn{}, nil
+}
+
+func (c dialConnector) Driver() driver.Driver { return fakeDriver{} }
+
+// refusingAddr 返回一个当前没有监听、连接会被拒绝的本地地址
+func refusingAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// TestWaitForDBRetries 数据库在前几秒拒绝连接时继续重试，开始监听后连接成功
+func TestWaitForDBRetries(t *testing.T) {
+	addr := refusingAddr(t)
+	db := sql.OpenDB(dialConnector{addr})
+	defer db.Close()
+
+	const refuseFor = 1500 * time.Millisecond
+	go func() {
+		time.Sleep(refuseFor)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("listen: %v", err)
+			return
+		}
+		t.Cleanup(func() { ln.Close() })
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	if err := waitForDB(context.Background(), db, 10*time.Second); err != nil {
+		t.Fatalf("waitForDB: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < refuseFor {
+		t.Fatalf("waitForDB returned after %s, before the database was listening", elapsed)
+	}
+}
+
+// TestWaitForDBGivesUp 超过 timeout 后返回带有尝试次数和最后一次错误的明确错误
+func TestWaitForDBGivesUp(t *testing.T) {
+	db := sql.OpenDB(dialConnector{refusingAddr(t)})
+	defer db.Close()
+
+	err := waitForDB(context.Background(), db, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "database not reachable after 1s") || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("err = %v, want a timeout error wrapping connection refused", err)
+	}
+}
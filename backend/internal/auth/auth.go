@@ -0,0 +1,206 @@
+// Package auth 签发和验证 JWT，并提供认证中间件
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatapp/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultTokenTTL 未设置 Authenticator.TTL 时 token 的有效期
+const defaultTokenTTL = 24 * time.Hour
+
+// BotTokenPrefix 机器人令牌的前缀，带该前缀的 token 不按 JWT 解析
+const BotTokenPrefix = "bot_"
+
+var (
+	// ErrMissingToken 请求没有携带 token
+	ErrMissingToken = errors.New("authorization header required")
+	// ErrTokenRevoked token 签发之后修改过密码或被管理员强制下线
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrTokenExpired token 已过期，客户端需要重新登录
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrTokenNotYetValid token 的 nbf 或 iat 晚于当前时间，通常是签发方时钟不准
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	// ErrUnknownBotToken 机器人令牌不存在、已轮换或所有者已被停用
+	ErrUnknownBotToken = errors.New("unknown bot token")
+)
+
+type Claims struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// TokenVersion 与数据库中的版本不一致时 token 失效
+	TokenVersion int `json:"token_version"`
+	// BotID 通过机器人令牌认证时不为 0，此时 UserID 等字段为机器人所有者，不出现在 JWT 中
+	BotID int `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// TokenVersions 查询用户当前的 token 版本，store.UserStore 满足该接口
+type TokenVersions interface {
+	GetTokenVersion(ctx context.Context, userID int) (int, error)
+}
+
+// BotTokens 按令牌哈希查找机器人，store.BotStore 满足该接口
+type BotTokens interface {
+	AuthenticateBot(ctx context.Context, tokenHash string) (store.BotIdentity, error)
+}
+
+// Authenticator 用 Keys 签发和验证 token，并通过 Users 检查 token 是否已被撤销。
+// Bots 为 nil 时不接受机器人令牌
+type Authenticator struct {
+	Keys  JWTKeys
+	Users TokenVersions
+	Bots  BotTokens
+	// TTL 新 token 的有效期，为 0 时使用 24 小时
+	TTL time.Duration
+}
+
+func NewAuthenticator(keys JWTKeys, users TokenVersions) *Authenticator {
+	return &Authenticator{Keys: keys, Users: users}
+}
+
+// Issue 为用户签发 token
+func (a *Authenticator) Issue(user store.User) (string, error) {
+	ttl := a.TTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	claims := Claims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(a.Keys.Method, claims)
+	return token.SignedString(a.Keys.SignKey)
+}
+
+// Parse 解析并验证 JWT Token，不检查是否已被撤销。只接受配置的签名算法，
+// 必须带有 exp；过期和尚未生效分别返回 ErrTokenExpired 和 ErrTokenNotYetValid
+func (a *Authenticator) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, a.Keys.keyFunc,
+		jwt.WithValidMethods([]string{a.Keys.Method.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return nil, ErrTokenNotYetValid
+	case err != nil:
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// Authenticate 解析 token 并检查 token_version，修改密码之前签发的 token 不再有效
+func (a *Authenticator) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	if strings.HasPrefix(tokenString, BotTokenPrefix) {
+		return a.authenticateBot(ctx, tokenString)
+	}
+	claims, err := a.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	version, err := a.Users.GetTokenVersion(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if version != claims.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// authenticateBot 机器人令牌不会过期，数据库中只保存它的 SHA-256 哈希
+func (a *Authenticator) authenticateBot(ctx context.Context, tokenString string) (*Claims, error) {
+	if a.Bots == nil {
+		return nil, ErrUnknownBotToken
+	}
+	sum := sha256.Sum256([]byte(tokenString))
+	bot, err := a.Bots.AuthenticateBot(ctx, hex.EncodeToString(sum[:]))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUnknownBotToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Claims{UserID: bot.UserID, Username: bot.Username, Email: bot.Email, BotID: bot.BotID}, nil
+}
+
+// BearerToken 从 Authorization 头中取出 token
+func BearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		return authHeader[7:]
+	}
+	return authHeader
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// WithClaims 把认证通过的用户信息放入 ctx
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, userContextKey, claims)
+}
+
+// FromContext 返回认证中间件放入上下文的用户信息，未认证时为 nil
+func FromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(userContextKey).(*Claims)
+	return claims
+}
+
+// ErrorWriter 认证失败时写错误响应，err 为 ErrMissingToken、ErrTokenRevoked、token 解析错误或数据库错误
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, err error)
+
+// Require 要求请求携带有效 token，失败时交给 fail 处理
+func (a *Authenticator) Require(fail ErrorWriter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenString := BearerToken(r)
+		if tokenString == "" {
+			fail(w, r, ErrMissingToken)
+			return
+		}
+
+		claims, err := a.Authenticate(r.Context(), tokenString)
+		if err != nil {
+			fail(w, r, err)
+			return
+		}
+		next(w, r.WithContext(WithClaims(r.Context(), claims)))
+	}
+}
+
+// Optional 有 token 时解析用户信息，没有 token 或 token 无效也放行
+func (a *Authenticator) Optional(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tokenString := BearerToken(r); tokenString != "" {
+			if claims, err := a.Authenticate(r.Context(), tokenString); err == nil {
+				r = r.WithContext(WithClaims(r.Context(), claims))
+			}
+		}
+		next(w, r)
+	}
+}
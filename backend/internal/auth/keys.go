@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"chatapp/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKeys 签发和验证 token 的算法与密钥。HS256 两者都是共享密钥，RS256 用私钥签发、公钥验证
+type JWTKeys struct {
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// HS256Keys 使用共享密钥签名
+func HS256Keys(secret []byte) JWTKeys {
+	return JWTKeys{Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+}
+
+// LoadRS256Keys 读取 PEM 格式的 RSA 私钥和公钥
+func LoadRS256Keys(privatePath, publicPath string) (JWTKeys, error) {
+	if privatePath == "" || publicPath == "" {
+		return JWTKeys{}, fmt.Errorf("JWT_PRIVATE_KEY_PATH and JWT_PUBLIC_KEY_PATH are required for RS256")
+	}
+	privatePEM, err := os.ReadFile(privatePath)
+	if err != nil {
+		return JWTKeys{}, fmt.Errorf("read private key: %w", err)
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	if err != nil {
+		return JWTKeys{}, fmt.Errorf("parse private key: %w", err)
+	}
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		return JWTKeys{}, fmt.Errorf("read public key: %w", err)
+	}
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+	if err != nil {
+		return JWTKeys{}, fmt.Errorf("parse public key: %w", err)
+	}
+	if !privateKey.PublicKey.Equal(publicKey) {
+		return JWTKeys{}, fmt.Errorf("JWT public key does not match the private key")
+	}
+	return JWTKeys{Method: jwt.SigningMethodRS256, SignKey: privateKey, VerifyKey: publicKey}, nil
+}
+
+// LoadKeys 根据配置的算法（HS256 或 RS256）加载密钥
+func LoadKeys(cfg config.JWTConfig) (JWTKeys, error) {
+	switch cfg.Algorithm {
+	case "HS256":
+		if cfg.Secret == "" {
+			return JWTKeys{}, fmt.Errorf("JWT_SECRET is required for HS256")
+		}
+		return HS256Keys([]byte(cfg.Secret)), nil
+	case "RS256":
+		return LoadRS256Keys(cfg.PrivateKeyPath, cfg.PublicKeyPath)
+	default:
+		return JWTKeys{}, fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.Algorithm)
+	}
+}
+
+// keyFunc 只接受配置的算法，防止 alg=none 和用公钥当作 HMAC 密钥的算法混淆攻击
+func (k JWTKeys) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+	return k.VerifyKey, nil
+}
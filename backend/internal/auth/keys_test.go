@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeRSAKeys 生成 RSA 密钥对并写入 PEM 文件，返回私钥和公钥的路径
+func writeRSAKeys(t *testing.T) (privatePath, publicPath string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	privatePath = filepath.Join(dir, "jwt.key")
+	publicPath = filepath.Join(dir, "jwt.pub")
+	writePEM(t, privatePath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	writePEM(t, publicPath, "PUBLIC KEY", publicDER)
+	return privatePath, publicPath
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRS256IssueAndParse(t *testing.T) {
+	privatePath, publicPath := writeRSAKeys(t)
+	keys, err := LoadKeys(config.JWTConfig{Algorithm: "RS256", PrivateKeyPath: privatePath, PublicKeyPath: publicPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(keys, nil)
+
+	token, err := a.Issue(store.User{ID: 7, Username: "alice", Email: "alice@example.com", TokenVersion: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil || parsed.Method.Alg() != "RS256" {
+		t.Fatalf("token header alg = %v, %v, want RS256", parsed.Header["alg"], err)
+	}
+	claims, err := a.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if claims.UserID != 7 || claims.Username != "alice" || claims.TokenVersion != 2 {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// 另一对密钥签发的 token 无法通过验证
+	otherPrivate, otherPublic := writeRSAKeys(t)
+	otherKeys, err := LoadRS256Keys(otherPrivate, otherPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAuthenticator(otherKeys, nil).Parse(token); err == nil {
+		t.Fatal("token signed with another key was accepted")
+	}
+}
+
+// TestRS256RejectsAlgorithmConfusion 用公钥作为 HMAC 密钥签发的 HS256 token 和 alg=none 的 token 都被拒绝
+func TestRS256RejectsAlgorithmConfusion(t *testing.T) {
+	privatePath, publicPath := writeRSAKeys(t)
+	keys, err := LoadRS256Keys(privatePath, publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(keys, nil)
+	publicPEM, err := os.ReadFile(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"HS256 with public key": hs256, "none": none} {
+		if _, err := a.Parse(token); err == nil {
+			t.Errorf("%s token was accepted", name)
+		}
+	}
+}
+
+func TestLoadKeysErrors(t *testing.T) {
+	privatePath, publicPath := writeRSAKeys(t)
+	_, otherPublic := writeRSAKeys(t)
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+	}{
+		{"missing secret", config.JWTConfig{Algorithm: "HS256"}},
+		{"unknown algorithm", config.JWTConfig{Algorithm: "ES256", Secret: "secret"}},
+		{"missing key paths", config.JWTConfig{Algorithm: "RS256"}},
+		{"missing key file", config.JWTConfig{Algorithm: "RS256", PrivateKeyPath: privatePath, PublicKeyPath: publicPath + ".missing"}},
+		{"public key as private key", config.JWTConfig{Algorithm: "RS256", PrivateKeyPath: publicPath, PublicKeyPath: publicPath}},
+		{"mismatched key pair", config.JWTConfig{Algorithm: "RS256", PrivateKeyPath: privatePath, PublicKeyPath: otherPublic}},
+	}
+	for _, tt := range tests {
+		if _, err := LoadKeys(tt.cfg); err == nil {
+			t.Errorf("%s: LoadKeys succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+// Package config 从环境变量读取服务配置并校验
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DevJWTSecret 开发模式下未设置 JWT_SECRET 时使用的密钥，生产环境必须设置 JWT_SECRET
+const DevJWTSecret = "your-secret-key-change-in-production"
+
+// bcrypt 成本因子允许的范围，开发模式下可以降到 MinDevBcryptCost，让测试跑得更快
+const (
+	MinBcryptCost    = 10
+	MaxBcryptCost    = 14
+	MinDevBcryptCost = 4
+)
+
+// 新密码使用的哈希算法
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// Config 服务的全部配置。字段的默认值见 Default，对应的环境变量见 LoadConfig
+type Config struct {
+	// DevMode 允许任意来源的请求，并允许不设置 JWT_SECRET
+	DevMode bool
+
+	DatabaseURL string
+	Port        int
+	// AppURL 前端地址，用于生成邀请链接
+	AppURL string
+	// AdminEmail 启动时把该邮箱对应的用户设为系统管理员
+	AdminEmail string
+
+	LogFormat string
+	LogLevel  string
+
+	DB    DBConfig
+	JWT   JWTConfig
+	CORS  CORSConfig
+	WS    WSConfig
+	Redis RedisConfig
+
+	Storage StorageConfig
+	SMTP    SMTPConfig
+	Push    PushConfig
+
+	Password PasswordConfig
+
+	ContentFilter ContentFilterConfig
+	// ProfanityWordsFile 聊天室脏话过滤使用的词表，为空时使用内置词表
+	ProfanityWordsFile string
+
+	PasswordResetTTL    time.Duration
+	MaxRequestBodyBytes int64
+	MaxMessageLength    int
+	ValidateEmailMX     bool
+	MetricsEnabled      bool
+
+	// MessageRateLimit 每个用户每秒可以发送的消息数，MessageRateBurst 为允许的突发数，MessageRateLimit 为 0 时不限制
+	MessageRateLimit int
+	MessageRateBurst int
+}
+
+// PasswordConfig 新密码使用的哈希算法和参数。已有的哈希仍然可以验证，
+// 登录成功时升级到当前算法和参数
+type PasswordConfig struct {
+	// Algorithm 为 bcrypt 或 argon2id
+	Algorithm  string
+	BcryptCost int
+	// Argon2Time 迭代次数，Argon2MemoryKiB 内存开销，Argon2Threads 并行度
+	Argon2Time      int
+	Argon2MemoryKiB int
+	Argon2Threads   int
+}
+
+// DBConfig 数据库连接池、启动时的连接重试和查询超时
+type DBConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ConnectTimeout 启动时等待数据库可用的最长时间
+	ConnectTimeout time.Duration
+	QueryTimeout   time.Duration
+}
+
+// JWTConfig HS256 使用 Secret，RS256 使用 PEM 格式的私钥和公钥文件
+type JWTConfig struct {
+	Algorithm      string
+	Secret         string
+	PrivateKeyPath string
+	PublicKeyPath  string
+	TokenTTL       time.Duration
+}
+
+type CORSConfig struct {
+	// AllowedOrigins 逗号分隔的来源，支持 * 和 https://*.example.com 通配
+	AllowedOrigins   string
+	AllowedMethods   string
+	AllowCredentials bool
+}
+
+type WSConfig struct {
+	// UpgradeRate 和 UpgradeBurst 限制 /ws 升级速率
+	UpgradeRate  int
+	UpgradeBurst int
+	// BroadcastBuffer 等待广播的事件数上限
+	BroadcastBuffer int
+	// SendBuffer 每个连接等待写入的事件数上限，写满时断开该连接
+	SendBuffer int
+	// MaxConnsPerUser 每个用户在一个实例上的连接数上限，超出时关闭该用户最早的连接
+	MaxConnsPerUser int
+	// ReadLimit 客户端发送的单个 WebSocket 帧的最大字节数，超出时断开连接
+	ReadLimit int64
+}
+
+// RedisConfig 设置 URL 时通过 Redis 在多个实例之间转发事件
+type RedisConfig struct {
+	URL     string
+	Channel string
+}
+
+type StorageConfig struct {
+	// Backend 为 local 或 s3
+	Backend        string
+	UploadDir      string
+	MaxUploadBytes int64
+	// AllowedTypes 逗号分隔的 MIME 类型
+	AllowedTypes string
+
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// SMTPConfig Host 为空时只把邮件打印到日志
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// PushConfig Provider 为 fcm 时使用 FCMCredentialsFile 中的服务账号，为空时只打印到日志
+type PushConfig struct {
+	Provider           string
+	FCMCredentialsFile string
+}
+
+// ContentFilterConfig Kind 为 wordlist 时启用全局词表过滤
+type ContentFilterConfig struct {
+	Kind string
+	// Mode 为 mask 或 reject
+	Mode      string
+	Words     string
+	WordsFile string
+	Audit     bool
+}
+
+// Default 返回所有配置项的默认值，DatabaseURL 和 JWT.Secret 没有默认值
+func Default() Config {
+	return Config{
+		Port:      8080,
+		AppURL:    "http://localhost:3000",
+		LogFormat: "text",
+		LogLevel:  "info",
+		DB: DBConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+			QueryTimeout:    5 * time.Second,
+		},
+		JWT: JWTConfig{
+			Algorithm: "HS256",
+			TokenTTL:  24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   "http://localhost:3000",
+			AllowedMethods:   "GET,POST,PUT,DELETE,OPTIONS",
+			AllowCredentials: true,
+		},
+		WS: WSConfig{
+			UpgradeRate:     50,
+			UpgradeBurst:    100,
+			BroadcastBuffer: 1024,
+			SendBuffer:      256,
+			MaxConnsPerUser: 5,
+			ReadLimit:       64 << 10,
+		},
+		Redis: RedisConfig{Channel: "chatapp:events"},
+		Storage: StorageConfig{
+			Backend:        "local",
+			UploadDir:      "uploads",
+			MaxUploadBytes: 10 << 20,
+			AllowedTypes:   "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain",
+			S3Region:       "us-east-1",
+		},
+		SMTP: SMTPConfig{
+			Port: "587",
+			From: "no-reply@chatapp.local",
+		},
+		ContentFilter: ContentFilterConfig{Mode: "mask"},
+
+		Password: PasswordConfig{
+			Algorithm:       PasswordHashBcrypt,
+			BcryptCost:      12,
+			Argon2Time:      3,
+			Argon2MemoryKiB: 64 * 1024,
+			Argon2Threads:   2,
+		},
+
+		PasswordResetTTL:    time.Hour,
+		MaxRequestBodyBytes: 1 << 20,
+		MaxMessageLength:    4000,
+		MessageRateLimit:    5,
+		MessageRateBurst:    10,
+	}
+}
+
+// ValidationError 配置中的所有问题，启动时一次性全部报告
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// LoadConfig 从环境变量读取配置，未设置的项使用 Default 中的默认值。
+// 格式错误或取值无效时返回 *ValidationError，其中包含所有问题；返回的 Config 仍然尽可能填充，便于创建日志器
+func LoadConfig() (Config, error) {
+	return load(os.LookupEnv)
+}
+
+func load(lookup func(string) (string, bool)) (Config, error) {
+	l := &loader{lookup: lookup}
+	cfg := Default()
+
+	l.bool("DEV_MODE", &cfg.DevMode)
+	l.string("DATABASE_URL", &cfg.DatabaseURL)
+	l.int("PORT", &cfg.Port)
+	l.string("APP_URL", &cfg.AppURL)
+	cfg.AppURL = strings.TrimRight(cfg.AppURL, "/")
+	l.string("ADMIN_EMAIL", &cfg.AdminEmail)
+	l.string("LOG_FORMAT", &cfg.LogFormat)
+	l.string("LOG_LEVEL", &cfg.LogLevel)
+
+	l.int("DB_MAX_OPEN_CONNS", &cfg.DB.MaxOpenConns)
+	l.int("DB_MAX_IDLE_CONNS", &cfg.DB.MaxIdleConns)
+	l.duration("DB_CONN_MAX_LIFETIME", &cfg.DB.ConnMaxLifetime)
+	l.duration("DB_CONNECT_TIMEOUT", &cfg.DB.ConnectTimeout)
+	l.duration("DB_QUERY_TIMEOUT", &cfg.DB.QueryTimeout)
+
+	l.string("JWT_ALGORITHM", &cfg.JWT.Algorithm)
+	l.string("JWT_SECRET", &cfg.JWT.Secret)
+	l.string("JWT_PRIVATE_KEY_PATH", &cfg.JWT.PrivateKeyPath)
+	l.string("JWT_PUBLIC_KEY_PATH", &cfg.JWT.PublicKeyPath)
+	l.duration("JWT_TTL", &cfg.JWT.TokenTTL)
+
+	// 兼容旧的 CORS_ALLOWED_ORIGINS，两者都设置时以 ALLOWED_ORIGINS 为准
+	l.string("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	l.string("ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	l.string("CORS_ALLOWED_METHODS", &cfg.CORS.AllowedMethods)
+	l.bool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials)
+
+	l.int("WS_UPGRADE_RATE", &cfg.WS.UpgradeRate)
+	l.int("WS_UPGRADE_BURST", &cfg.WS.UpgradeBurst)
+	l.int("WS_BROADCAST_BUFFER", &cfg.WS.BroadcastBuffer)
+	l.int("WS_SEND_BUFFER_SIZE", &cfg.WS.SendBuffer)
+	l.int("WS_MAX_CONNECTIONS_PER_USER", &cfg.WS.MaxConnsPerUser)
+	l.int64("WS_READ_LIMIT_BYTES", &cfg.WS.ReadLimit)
+
+	l.string("REDIS_URL", &cfg.Redis.URL)
+	l.string("REDIS_CHANNEL", &cfg.Redis.Channel)
+
+	l.string("STORAGE_BACKEND", &cfg.Storage.Backend)
+	l.string("UPLOAD_DIR", &cfg.Storage.UploadDir)
+	l.int64("UPLOAD_MAX_BYTES", &cfg.Storage.MaxUploadBytes)
+	l.string("UPLOAD_ALLOWED_TYPES", &cfg.Storage.AllowedTypes)
+	l.string("S3_ENDPOINT", &cfg.Storage.S3Endpoint)
+	l.string("S3_BUCKET", &cfg.Storage.S3Bucket)
+	l.string("S3_REGION", &cfg.Storage.S3Region)
+	l.string("S3_ACCESS_KEY_ID", &cfg.Storage.S3AccessKey)
+	l.string("S3_SECRET_ACCESS_KEY", &cfg.Storage.S3SecretKey)
+
+	l.string("SMTP_HOST", &cfg.SMTP.Host)
+	l.string("SMTP_PORT", &cfg.SMTP.Port)
+	l.string("SMTP_USERNAME", &cfg.SMTP.Username)
+	l.string("SMTP_PASSWORD", &cfg.SMTP.Password)
+	l.string("SMTP_FROM", &cfg.SMTP.From)
+
+	l.string("PUSH_PROVIDER", &cfg.Push.Provider)
+	l.string("FCM_CREDENTIALS_FILE", &cfg.Push.FCMCredentialsFile)
+
+	l.string("CONTENT_FILTER", &cfg.ContentFilter.Kind)
+	l.string("CONTENT_FILTER_MODE", &cfg.ContentFilter.Mode)
+	l.string("CONTENT_FILTER_WORDS", &cfg.ContentFilter.Words)
+	l.string("CONTENT_FILTER_WORDS_FILE", &cfg.ContentFilter.WordsFile)
+	l.bool("CONTENT_FILTER_AUDIT", &cfg.ContentFilter.Audit)
+	l.string("PROFANITY_WORDS_FILE", &cfg.ProfanityWordsFile)
+
+	l.duration("PASSWORD_RESET_TTL", &cfg.PasswordResetTTL)
+	l.string("PASSWORD_HASH", &cfg.Password.Algorithm)
+	l.int("BCRYPT_COST", &cfg.Password.BcryptCost)
+	l.int("ARGON2_TIME", &cfg.Password.Argon2Time)
+	l.int("ARGON2_MEMORY_KIB", &cfg.Password.Argon2MemoryKiB)
+	l.int("ARGON2_THREADS", &cfg.Password.Argon2Threads)
+	l.int64("MAX_REQUEST_BODY_BYTES", &cfg.MaxRequestBodyBytes)
+	l.int("MAX_MESSAGE_LENGTH", &cfg.MaxMessageLength)
+	l.int("MESSAGE_RATE_LIMIT", &cfg.MessageRateLimit)
+	l.int("MESSAGE_RATE_BURST", &cfg.MessageRateBurst)
+	l.bool("VALIDATE_EMAIL_MX", &cfg.ValidateEmailMX)
+	l.bool("METRICS_ENABLED", &cfg.MetricsEnabled)
+
+	cfg.validate(l)
+	if len(l.problems) > 0 {
+		return cfg, &ValidationError{Problems: l.problems}
+	}
+	return cfg, nil
+}
+
+// validate 检查取值范围和配置项之间的依赖，解析失败的项已经由 loader 记录
+func (c *Config) validate(l *loader) {
+	if c.DatabaseURL == "" {
+		l.problem("DATABASE_URL is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		l.problem("PORT must be between 1 and 65535, got %d", c.Port)
+	}
+
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.Secret == "" {
+			if c.DevMode {
+				c.JWT.Secret = DevJWTSecret
+			} else {
+				l.problem("JWT_SECRET is required (set DEV_MODE=true to use an insecure development secret)")
+			}
+		}
+	case "RS256":
+		if c.JWT.PrivateKeyPath == "" || c.JWT.PublicKeyPath == "" {
+			l.problem("JWT_PRIVATE_KEY_PATH and JWT_PUBLIC_KEY_PATH are required for RS256")
+		}
+	default:
+		l.problem("JWT_ALGORITHM must be HS256 or RS256, got %q", c.JWT.Algorithm)
+	}
+
+	l.positive("JWT_TTL", c.JWT.TokenTTL)
+	l.positive("PASSWORD_RESET_TTL", c.PasswordResetTTL)
+	l.positive("DB_CONN_MAX_LIFETIME", c.DB.ConnMaxLifetime)
+	l.positive("DB_CONNECT_TIMEOUT", c.DB.ConnectTimeout)
+	l.positive("DB_QUERY_TIMEOUT", c.DB.QueryTimeout)
+	l.atLeast("DB_MAX_OPEN_CONNS", int64(c.DB.MaxOpenConns), 1)
+	l.atLeast("DB_MAX_IDLE_CONNS", int64(c.DB.MaxIdleConns), 0)
+	l.atLeast("WS_UPGRADE_RATE", int64(c.WS.UpgradeRate), 1)
+	l.atLeast("WS_UPGRADE_BURST", int64(c.WS.UpgradeBurst), 1)
+	l.atLeast("WS_BROADCAST_BUFFER", int64(c.WS.BroadcastBuffer), 1)
+	l.atLeast("WS_SEND_BUFFER_SIZE", int64(c.WS.SendBuffer), 1)
+	l.atLeast("WS_MAX_CONNECTIONS_PER_USER", int64(c.WS.MaxConnsPerUser), 1)
+	l.atLeast("WS_READ_LIMIT_BYTES", c.WS.ReadLimit, 1024)
+	l.atLeast("MAX_REQUEST_BODY_BYTES", c.MaxRequestBodyBytes, 1)
+	l.atLeast("MAX_MESSAGE_LENGTH", int64(c.MaxMessageLength), 1)
+	l.atLeast("MESSAGE_RATE_LIMIT", int64(c.MessageRateLimit), 0)
+	l.atLeast("MESSAGE_RATE_BURST", int64(c.MessageRateBurst), 1)
+	l.atLeast("UPLOAD_MAX_BYTES", c.Storage.MaxUploadBytes, 1)
+
+	minCost := MinBcryptCost
+	if c.DevMode {
+		minCost = MinDevBcryptCost
+	}
+	if c.Password.BcryptCost < minCost || c.Password.BcryptCost > MaxBcryptCost {
+		l.problem("BCRYPT_COST must be between %d and %d, got %d", minCost, MaxBcryptCost, c.Password.BcryptCost)
+	}
+	switch c.Password.Algorithm {
+	case PasswordHashBcrypt:
+	case PasswordHashArgon2id:
+		l.atLeast("ARGON2_TIME", int64(c.Password.Argon2Time), 1)
+		l.atLeast("ARGON2_MEMORY_KIB", int64(c.Password.Argon2MemoryKiB), 8*int64(c.Password.Argon2Threads))
+		if c.Password.Argon2Threads < 1 || c.Password.Argon2Threads > 255 {
+			l.problem("ARGON2_THREADS must be between 1 and 255, got %d", c.Password.Argon2Threads)
+		}
+	default:
+		l.problem("PASSWORD_HASH must be bcrypt or argon2id, got %q", c.Password.Algorithm)
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+	case "s3":
+		if c.Storage.S3Bucket == "" || c.Storage.S3AccessKey == "" || c.Storage.S3SecretKey == "" {
+			l.problem("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when STORAGE_BACKEND=s3")
+		}
+	default:
+		l.problem("STORAGE_BACKEND must be local or s3, got %q", c.Storage.Backend)
+	}
+
+	switch c.Push.Provider {
+	case "":
+	case "fcm":
+		if c.Push.FCMCredentialsFile == "" {
+			l.problem("FCM_CREDENTIALS_FILE is required when PUSH_PROVIDER=fcm")
+		}
+	default:
+		l.problem("PUSH_PROVIDER must be empty or fcm, got %q", c.Push.Provider)
+	}
+
+	switch c.ContentFilter.Kind {
+	case "", "none":
+	case "wordlist":
+		if c.ContentFilter.Words == "" && c.ContentFilter.WordsFile == "" {
+			l.problem("CONTENT_FILTER=wordlist requires CONTENT_FILTER_WORDS_FILE or CONTENT_FILTER_WORDS")
+		}
+	default:
+		l.problem("CONTENT_FILTER must be none or wordlist, got %q", c.ContentFilter.Kind)
+	}
+	if c.ContentFilter.Mode != "mask" && c.ContentFilter.Mode != "reject" {
+		l.problem("CONTENT_FILTER_MODE must be mask or reject, got %q", c.ContentFilter.Mode)
+	}
+}
+
+// loader 读取并解析环境变量，解析失败时保留默认值并记录问题
+type loader struct {
+	lookup   func(string) (string, bool)
+	problems []string
+}
+
+func (l *loader) problem(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// get 未设置或为空字符串时返回 false
+func (l *loader) get(key string) (string, bool) {
+	v, ok := l.lookup(key)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+func (l *loader) string(key string, dst *string) {
+	if v, ok := l.get(key); ok {
+		*dst = v
+	}
+}
+
+func (l *loader) int(key string, dst *int) {
+	if v, ok := l.get(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			l.problem("%s must be an integer, got %q", key, v)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (l *loader) int64(key string, dst *int64) {
+	if v, ok := l.get(key); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			l.problem("%s must be an integer, got %q", key, v)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (l *loader) bool(key string, dst *bool) {
+	if v, ok := l.get(key); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			l.problem("%s must be true or false, got %q", key, v)
+			return
+		}
+		*dst = b
+	}
+}
+
+// duration 接受 time.ParseDuration 的格式，例如 5m、30s
+func (l *loader) duration(key string, dst *time.Duration) {
+	if v, ok := l.get(key); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			l.problem("%s must be a duration such as 30s or 5m, got %q", key, v)
+			return
+		}
+		*dst = d
+	}
+}
+
+func (l *loader) positive(key string, d time.Duration) {
+	if d <= 0 {
+		l.problem("%s must be positive, got %s", key, d)
+	}
+}
+
+func (l *loader) atLeast(key string, n, min int64) {
+	if n < min {
+		l.problem("%s must be at least %d, got %d", key, min, n)
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env 返回只包含 vars 和必填项的环境变量查询函数
+func env(vars map[string]string) func(string) (string, bool) {
+	all := map[string]string{"DATABASE_URL": "postgres://localhost/chat", "JWT_SECRET": "secret"}
+	for k, v := range vars {
+		all[k] = v
+	}
+	return func(key string) (string, bool) {
+		v, ok := all[key]
+		return v, ok
+	}
+}
+
+// problems 返回 load 报告的问题，没有错误时为 nil
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error %v is not a *ValidationError", err)
+	}
+	return verr.Problems
+}
+
+func TestBcryptCost(t *testing.T) {
+	tests := []struct {
+		vars map[string]string
+		want int
+		ok   bool
+	}{
+		{nil, 12, true},
+		{map[string]string{"BCRYPT_COST": "10"}, 10, true},
+		{map[string]string{"BCRYPT_COST": "14"}, 14, true},
+		{map[string]string{"BCRYPT_COST": "9"}, 9, false},
+		{map[string]string{"BCRYPT_COST": "15"}, 15, false},
+		{map[string]string{"BCRYPT_COST": "4", "DEV_MODE": "true"}, 4, true},
+		{map[string]string{"BCRYPT_COST": "3", "DEV_MODE": "true"}, 3, false},
+	}
+	for _, tt := range tests {
+		cfg, err := load(env(tt.vars))
+		p := problems(t, err)
+		if cfg.Password.BcryptCost != tt.want || (len(p) == 0) != tt.ok {
+			t.Errorf("%v: cost %d, problems %v", tt.vars, cfg.Password.BcryptCost, p)
+		}
+		if !tt.ok && (len(p) != 1 || !strings.HasPrefix(p[0], "BCRYPT_COST")) {
+			t.Errorf("%v: problems %v, want one BCRYPT_COST problem", tt.vars, p)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.DatabaseURL = "postgres://localhost/chat"
+	want.JWT.Secret = "secret"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+// TestMissingJWTSecret 生产环境没有 JWT_SECRET 时拒绝启动，开发模式使用不安全的开发密钥
+func TestMissingJWTSecret(t *testing.T) {
+	_, err := load(env(map[string]string{"JWT_SECRET": ""}))
+	if p := problems(t, err); len(p) != 1 || !strings.HasPrefix(p[0], "JWT_SECRET is required") {
+		t.Fatalf("problems = %v, want one JWT_SECRET problem", p)
+	}
+
+	cfg, err := load(env(map[string]string{"JWT_SECRET": "", "DEV_MODE": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.JWT.Secret != DevJWTSecret {
+		t.Fatalf("secret = %q, want the development secret", cfg.JWT.Secret)
+	}
+
+	// RS256 不需要 JWT_SECRET，但必须提供密钥文件
+	_, err = load(env(map[string]string{"JWT_SECRET": "", "JWT_ALGORITHM": "RS256"}))
+	if p := problems(t, err); len(p) != 1 || !strings.HasPrefix(p[0], "JWT_PRIVATE_KEY_PATH") {
+		t.Fatalf("problems = %v, want one key path problem", p)
+	}
+}
+
+func TestLoadParsesValues(t *testing.T) {
+	cfg, err := load(env(map[string]string{
+		"PORT":                        " 9090 ",
+		"APP_URL":                     "https://chat.example.com/",
+		"JWT_TTL":                     "90m",
+		"DB_QUERY_TIMEOUT":            "2s",
+		"CORS_ALLOWED_ORIGINS":        "https://old.example.com",
+		"ALLOWED_ORIGINS":             "https://chat.example.com",
+		"CORS_ALLOW_CREDENTIALS":      "false",
+		"WS_MAX_CONNECTIONS_PER_USER": "2",
+		"WS_READ_LIMIT_BYTES":         "2048",
+		"REDIS_URL":                   "redis://localhost:6379",
+		"STORAGE_BACKEND":             "s3",
+		"S3_BUCKET":                   "uploads",
+		"S3_ACCESS_KEY_ID":            "key",
+		"S3_SECRET_ACCESS_KEY":        "secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"port", cfg.Port, 9090},
+		{"app url", cfg.AppURL, "https://chat.example.com"},
+		{"token ttl", cfg.JWT.TokenTTL, 90 * time.Minute},
+		{"query timeout", cfg.DB.QueryTimeout, 2 * time.Second},
+		{"allowed origins", cfg.CORS.AllowedOrigins, "https://chat.example.com"},
+		{"allow credentials", cfg.CORS.AllowCredentials, false},
+		{"connections per user", cfg.WS.MaxConnsPerUser, 2},
+		{"read limit", cfg.WS.ReadLimit, int64(2048)},
+		{"redis url", cfg.Redis.URL, "redis://localhost:6379"},
+		{"storage backend", cfg.Storage.Backend, "s3"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+// TestLoadReportsAllProblems 所有问题一次性报告，解析失败的项保留默认值
+func TestLoadReportsAllProblems(t *testing.T) {
+	cfg, err := load(func(key string) (string, bool) {
+		v, ok := map[string]string{
+			"PORT":                "http",
+			"JWT_TTL":             "tomorrow",
+			"DEV_MODE":            "maybe",
+			"WS_SEND_BUFFER_SIZE": "0",
+			"STORAGE_BACKEND":     "ftp",
+		}[key]
+		return v, ok
+	})
+	want := []string{"DEV_MODE", "PORT", "JWT_TTL", "DATABASE_URL", "JWT_SECRET", "WS_SEND_BUFFER_SIZE", "STORAGE_BACKEND"}
+	p := problems(t, err)
+	if len(p) != len(want) {
+		t.Fatalf("problems = %q, want %d problems", p, len(want))
+	}
+	for i, key := range want {
+		if !strings.HasPrefix(p[i], key) {
+			t.Errorf("problem %d = %q, want it to be about %s", i, p[i], key)
+		}
+	}
+	if cfg.Port != Default().Port || cfg.JWT.TokenTTL != Default().JWT.TokenTTL {
+		t.Fatalf("invalid values replaced the defaults: port %d, ttl %s", cfg.Port, cfg.JWT.TokenTTL)
+	}
+}
+
+func TestLoadConfigReadsEnvironment(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://db/chat")
+	t.Setenv("JWT_SECRET", "from-env")
+	t.Setenv("PORT", "8181")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabaseURL != "postgres://db/chat" || cfg.JWT.Secret != "from-env" || cfg.Port != 8181 {
+		t.Fatalf("config = %+v", cfg)
+	}
+}
@@ -0,0 +1,192 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultAdminUsersPageSize = 50
+	maxAdminUsersPageSize     = 200
+)
+
+type AdminUser = store.AdminUser
+
+// AdminUserListResponse GET /api/admin/users 的响应，Total 为符合条件的用户总数
+type AdminUserListResponse struct {
+	Users []AdminUser `json:"users"`
+	Total int         `json:"total"`
+}
+
+var errAccountDisabled = apiError(http.StatusForbidden, "Account is disabled")
+
+// adminMiddleware 在 authMiddleware 之后检查当前用户是否为系统管理员，每次请求都从数据库读取
+func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		isAdmin, err := s.admin.IsAdmin(r.Context(), currentUser(r).UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeError(w, r, err)
+			return
+		}
+		if !isAdmin {
+			writeError(w, r, apiError(http.StatusForbidden, "Admin access required"))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// adminTargetUser 解析路径中的用户 ID，管理员不能停用或删除自己
+func adminTargetUser(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid user ID")
+	}
+	if id == currentUser(r).UserID {
+		return 0, apiError(http.StatusBadRequest, "You cannot perform this action on your own account")
+	}
+	return id, nil
+}
+
+func (s *Server) adminListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, defaultAdminUsersPageSize, maxAdminUsersPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+	users, total, err := s.admin.ListUsers(r.Context(), search, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdminUserListResponse{Users: users, Total: total})
+}
+
+func (s *Server) adminDisableUser(w http.ResponseWriter, r *http.Request) {
+	s.setUserDisabled(w, r, true)
+}
+
+func (s *Server) adminEnableUser(w http.ResponseWriter, r *http.Request) {
+	s.setUserDisabled(w, r, false)
+}
+
+// setUserDisabled 停用的用户不能登录，已签发的 token 也会失效，已经建立的连接立即断开
+func (s *Server) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, err := adminTargetUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := s.admin.SetUserDisabled(r.Context(), id, disabled)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if disabled {
+		s.hub.DisconnectUser(id, "account disabled")
+	}
+	loggerFromContext(r.Context()).Info("user disabled state changed",
+		"admin_id", currentUser(r).UserID, "user_id", id, "disabled", disabled)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// adminDeleteUser 处理滥用账号，与用户自己删除账号相同：匿名化用户而不是删除记录，消息和聊天室等外键保持有效
+func (s *Server) adminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := adminTargetUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.deleteAccount(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	loggerFromContext(r.Context()).Info("user deleted", "admin_id", currentUser(r).UserID, "user_id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteAccount 匿名化用户并立即断开其所有连接，重复执行没有副作用
+func (s *Server) deleteAccount(ctx context.Context, userID int) error {
+	if err := s.admin.AnonymizeUser(ctx, userID); err != nil {
+		return err
+	}
+	s.hub.DisconnectUser(userID, "account deleted")
+	return nil
+}
+
+// BroadcastRequest POST /api/admin/broadcast 的请求体
+type BroadcastRequest struct {
+	Content string `json:"content"`
+}
+
+// adminBroadcast 向所有在线连接（无论订阅了哪个聊天室）推送一条系统公告，例如维护通知。
+// 公告不属于任何聊天室，不保存到历史记录，离线用户不会收到。
+func (s *Server) adminBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req BroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	content, err := sanitizeContent(req.Content, s.MaxMessageLength)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	msg := Message{
+		Username:    commandSender,
+		DisplayName: commandSender,
+		Content:     content,
+		CreatedAt:   time.Now().UTC(),
+		MessageType: store.MessageTypeSystem,
+	}
+	s.hub.Publish(ws.Event{Type: ws.EventAnnouncement, Data: msg, Everyone: true})
+	loggerFromContext(r.Context()).Info("announcement broadcast", "admin_id", currentUser(r).UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(msg)
+}
+
+// adminListConnections 返回本实例每个用户的实时连接数（WebSocket 和 SSE），用于排查异常客户端。
+// 多实例部署时只包含处理该请求的实例
+func (s *Server) adminListConnections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.hub.UserConnectionCounts())
+}
+
+// PromoteAdmin 启动时把 ADMIN_EMAIL 对应的用户设为管理员，用于创建第一个管理员
+func PromoteAdmin(ctx context.Context, admin store.AdminStore, email string) {
+	if email == "" {
+		return
+	}
+	ok, err := admin.PromoteAdmin(ctx, email)
+	switch {
+	case err != nil:
+		slog.Error("failed to promote admin", "error", err)
+	case !ok:
+		slog.Warn("ADMIN_EMAIL does not match any user", "email", email)
+	default:
+		slog.Info("promoted admin user", "email", email)
+	}
+}
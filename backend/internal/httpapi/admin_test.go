@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// addAdmin 创建一个站点管理员
+func addAdmin(t *testing.T, ts *testServer) (store.User, string) {
+	t.Helper()
+	admin, token := ts.addUser("admin")
+	if _, err := ts.store.PromoteAdmin(context.Background(), admin.Email); err != nil {
+		t.Fatal(err)
+	}
+	return admin, token
+}
+
+// TestDisableUserDisconnects 停用账号立即断开该用户的连接，token 失效且不能登录，重新启用后可以登录
+func TestDisableUserDisconnects(t *testing.T) {
+	ts := newTestServer(t)
+	_, adminToken := addAdmin(t, ts)
+	alice, aliceToken := ts.addUser("alice")
+	_, bobToken := ts.addUser("bob")
+	conn := dialWebSocket(t, ts, aliceToken)
+	other := dialWebSocket(t, ts, bobToken)
+	path := fmt.Sprintf("/api/admin/users/%d", alice.ID)
+
+	decodeResponse(t, ts.do("POST", path+"/disable", bobToken, nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("POST", path+"/disable", adminToken, nil), http.StatusOK, nil)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var closeErr *websocket.CloseError
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !asCloseError(err, &closeErr) || closeErr.Text != "account disabled" {
+			t.Fatalf("read error = %v, want close with reason account disabled", err)
+		}
+		break
+	}
+	// 其他用户的连接不受影响
+	other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := other.ReadMessage(); asCloseError(err, &closeErr) {
+		t.Fatalf("bob's connection was closed: %v", err)
+	}
+
+	decodeResponse(t, ts.do("GET", "/api/users/me", aliceToken, nil), http.StatusUnauthorized, nil)
+	login := LoginRequest{Email: alice.Email, Password: testPassword}
+	if rec := ts.do("POST", "/api/auth/login", "", login); rec.Code == http.StatusOK {
+		t.Fatal("disabled user logged in")
+	}
+
+	decodeResponse(t, ts.do("POST", path+"/enable", adminToken, nil), http.StatusOK, nil)
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", login), http.StatusOK, nil)
+}
+
+// TestAdminBroadcast 只有管理员可以发送公告，公告送达所有聊天室中的连接
+func TestAdminBroadcast(t *testing.T) {
+	ts := newTestServer(t)
+	_, adminToken := addAdmin(t, ts)
+	alice, aliceToken := ts.addUser("alice")
+	bob, _ := ts.addUser("bob")
+	general := ts.store.AddRoom("general", "", &alice.ID)
+	random := ts.store.AddRoom("random", "", &bob.ID)
+	aliceEvents := ts.subscribe(alice.ID, general.ID)
+	bobEvents := ts.subscribe(bob.ID, random.ID)
+	req := BroadcastRequest{Content: "Maintenance at 22:00"}
+
+	decodeResponse(t, ts.do("POST", "/api/admin/broadcast", "", req), http.StatusUnauthorized, nil)
+	decodeResponse(t, ts.do("POST", "/api/admin/broadcast", aliceToken, req), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("POST", "/api/admin/broadcast", adminToken, BroadcastRequest{}), http.StatusBadRequest, nil)
+
+	var msg Message
+	decodeResponse(t, ts.do("POST", "/api/admin/broadcast", adminToken, req), http.StatusAccepted, &msg)
+	if msg.MessageType != store.MessageTypeSystem || msg.UserID != 0 || msg.Content != req.Content {
+		t.Fatalf("announcement = %+v, want a system message", msg)
+	}
+	for _, c := range []struct {
+		name   string
+		events *eventRecorder
+		roomID int
+	}{{"alice", aliceEvents, general.ID}, {"bob", bobEvents, random.ID}} {
+		received := c.events.drain(t, ts, c.roomID)
+		if len(received) != 1 || received[0].Type != ws.EventAnnouncement {
+			t.Fatalf("%s received %+v, want one announcement", c.name, received)
+		}
+		if got := received[0].Data.(Message); got.Content != req.Content {
+			t.Fatalf("%s received %+v", c.name, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 冷启动连接不能使用的令牌比例，留给带 resume_token 的重连
+	resumeReserveRatio = 0.2
+	resumeTokenTTL     = 5 * time.Minute
+	maxRetryAfter      = 30 * time.Second
+	closeTryAgainLater = 4429
+)
+
+// upgradeAdmission 控制 /ws 升级速率，部署后大量客户端同时重连时避免冲击数据库
+type upgradeAdmission struct {
+	bucket  *tokenBucket
+	reserve float64
+
+	mu           sync.Mutex
+	resumeTokens map[string]resumeEntry
+
+	admittedCold   atomic.Int64
+	admittedResume atomic.Int64
+	deferred       atomic.Int64
+}
+
+type resumeEntry struct {
+	userID  int
+	expires time.Time
+}
+
+// AdmissionStats 升级准入统计
+type AdmissionStats struct {
+	AdmittedCold   int64 `json:"admitted_cold"`
+	AdmittedResume int64 `json:"admitted_resume"`
+	Deferred       int64 `json:"deferred"`
+}
+
+// RetryHint 被拒绝的升级请求返回的退避提示
+type RetryHint struct {
+	RetryAfterMs int64 `json:"retry_after_ms"`
+}
+
+func newUpgradeAdmission(rate float64, burst int) *upgradeAdmission {
+	return &upgradeAdmission{
+		bucket:       newTokenBucket(rate, burst),
+		reserve:      float64(burst) * resumeReserveRatio,
+		resumeTokens: make(map[string]resumeEntry),
+	}
+}
+
+// admit 判断是否允许升级。带有效 resume_token 的重连可以使用预留的令牌。
+func (a *upgradeAdmission) admit(resumeToken string, userID int) (bool, time.Duration) {
+	resumed := resumeToken != "" && a.consumeResumeToken(resumeToken, userID)
+
+	reserve := a.reserve
+	if resumed {
+		reserve = 0
+	}
+
+	ok, wait := a.bucket.takeAbove(reserve)
+	switch {
+	case !ok:
+		a.deferred.Add(1)
+		return false, retryAfterWithJitter(wait)
+	case resumed:
+		a.admittedResume.Add(1)
+	default:
+		a.admittedCold.Add(1)
+	}
+	return true, 0
+}
+
+// retryAfterWithJitter 在等待时间上叠加随机抖动，避免客户端再次同时重连
+func retryAfterWithJitter(wait time.Duration) time.Duration {
+	if wait < time.Second {
+		wait = time.Second
+	}
+	wait += time.Duration(mrand.Int63n(int64(wait)))
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait
+}
+
+// issueResumeToken 为已连接的客户端签发一次性重连令牌
+func (a *upgradeAdmission) issueResumeToken(userID int) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for t, e := range a.resumeTokens {
+		if now.After(e.expires) {
+			delete(a.resumeTokens, t)
+		}
+	}
+	a.resumeTokens[token] = resumeEntry{userID: userID, expires: now.Add(resumeTokenTTL)}
+	return token
+}
+
+func (a *upgradeAdmission) consumeResumeToken(token string, userID int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.resumeTokens[token]
+	if !ok {
+		return false
+	}
+	delete(a.resumeTokens, token)
+	return e.userID == userID && time.Now().Before(e.expires)
+}
+
+func (a *upgradeAdmission) stats() AdmissionStats {
+	return AdmissionStats{
+		AdmittedCold:   a.admittedCold.Load(),
+		AdmittedResume: a.admittedResume.Load(),
+		Deferred:       a.deferred.Load(),
+	}
+}
+
+// rejectUpgrade 拒绝升级请求。浏览器无法读取升级失败时的 HTTP 响应，
+// 所以带 Origin 的请求先完成升级，再用关闭帧（4429 + RetryHint）传递退避提示；
+// 其他客户端直接返回 503 和 Retry-After 头。
+func (s *Server) rejectUpgrade(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	hint := RetryHint{RetryAfterMs: retryAfter.Milliseconds()}
+
+	if r.Header.Get("Origin") != "" {
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		payload, _ := json.Marshal(hint)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeTryAgainLater, string(payload)), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(&APIError{
+		Code:         "try_again_later",
+		Message:      "Too many connections, retry later",
+		RetryAfterMs: hint.RetryAfterMs,
+	})
+}
@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingUsers 统计认证时 token_version 的查询次数
+type countingUsers struct {
+	store.UserStore
+	queries *atomic.Int64
+}
+
+func (c countingUsers) GetTokenVersion(ctx context.Context, id int) (int, error) {
+	c.queries.Add(1)
+	return c.UserStore.GetTokenVersion(ctx, id)
+}
+
+// countingMembers 统计连接时加载聊天室列表的查询次数
+type countingMembers struct {
+	store.MembershipStore
+	queries *atomic.Int64
+}
+
+func (c countingMembers) ListUserRoomIDs(ctx context.Context, userID int) ([]int, error) {
+	c.queries.Add(1)
+	return c.MembershipStore.ListUserRoomIDs(ctx, userID)
+}
+
+// TestUpgradeAdmissionReservesTokensForResume 冷启动连接不能用掉预留给重连的令牌
+func TestUpgradeAdmissionReservesTokensForResume(t *testing.T) {
+	a := newUpgradeAdmission(1, 10)
+	now := time.Now()
+	a.bucket.now = func() time.Time { return now }
+
+	// 10 个令牌中预留 2 个，冷启动连接只能用掉 8 个
+	for i := 0; i < 8; i++ {
+		if ok, _ := a.admit("", 1); !ok {
+			t.Fatalf("cold connect %d was deferred", i+1)
+		}
+	}
+	ok, retryAfter := a.admit("", 1)
+	if ok {
+		t.Fatal("cold connect was admitted from the resume reserve")
+	}
+	if retryAfter < time.Second || retryAfter > maxRetryAfter {
+		t.Fatalf("retry after = %v, want between 1s and %v", retryAfter, maxRetryAfter)
+	}
+
+	token := a.issueResumeToken(1)
+	if ok, _ := a.admit(token, 2); ok {
+		t.Fatal("resume token of another user was accepted")
+	}
+	token = a.issueResumeToken(1)
+	if ok, _ := a.admit(token, 1); !ok {
+		t.Fatal("resumed connection was deferred")
+	}
+	// resume_token 只能使用一次
+	if ok, _ := a.admit(token, 1); ok {
+		t.Fatal("resume token was accepted twice")
+	}
+
+	now = now.Add(2 * time.Second)
+	if ok, _ := a.admit("", 1); !ok {
+		t.Fatal("cold connect was deferred after the bucket refilled")
+	}
+
+	want := AdmissionStats{AdmittedCold: 9, AdmittedResume: 1, Deferred: 3}
+	if got := a.stats(); got != want {
+		t.Fatalf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestRetryAfterWithJitter(t *testing.T) {
+	for _, wait := range []time.Duration{0, 500 * time.Millisecond, 3 * time.Second, time.Minute} {
+		for i := 0; i < 20; i++ {
+			got := retryAfterWithJitter(wait)
+			low := max(wait, time.Second)
+			if got < min(low, maxRetryAfter) || got > maxRetryAfter {
+				t.Fatalf("retryAfterWithJitter(%v) = %v", wait, got)
+			}
+		}
+	}
+}
+
+// TestRejectedUpgradeRetryHints 非浏览器客户端收到 503 和 Retry-After，浏览器收到 4429 关闭帧
+func TestRejectedUpgradeRetryHints(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.WS.UpgradeRate = 0
+		cfg.WS.UpgradeBurst = 0
+	})
+	_, token := ts.addUser("alice")
+
+	rec := ts.do("GET", "/ws?token="+token, "", nil)
+	var body struct {
+		Code         string `json:"code"`
+		RetryAfterMs int64  `json:"retry_after_ms"`
+	}
+	decodeResponse(t, rec, http.StatusServiceUnavailable, &body)
+	seconds, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || seconds < 1 {
+		t.Fatalf("Retry-After = %q", rec.Header().Get("Retry-After"))
+	}
+	if body.Code != "try_again_later" || body.RetryAfterMs <= 0 || int64(seconds)*1000 < body.RetryAfterMs {
+		t.Fatalf("body = %+v with Retry-After %ds", body, seconds)
+	}
+
+	srv := httptest.NewServer(ts.handler)
+	defer srv.Close()
+	header := http.Header{"Origin": {"http://localhost:3000"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?token="+token, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !asCloseError(err, &closeErr) || closeErr.Code != closeTryAgainLater {
+		t.Fatalf("read error = %v, want close %d", err, closeTryAgainLater)
+	}
+	var hint RetryHint
+	if err := json.Unmarshal([]byte(closeErr.Text), &hint); err != nil || hint.RetryAfterMs <= 0 {
+		t.Fatalf("close payload %q is not a retry hint", closeErr.Text)
+	}
+}
+
+func asCloseError(err error, target **websocket.CloseError) bool {
+	ce, ok := err.(*websocket.CloseError)
+	*target = ce
+	return ok
+}
+
+// TestReconnectStormQueryRate 模拟部署后所有客户端同时重连：被推迟的升级请求不访问数据库，
+// 查询速率不超过升级速率允许的上限
+func TestReconnectStormQueryRate(t *testing.T) {
+	const (
+		rate    = 50
+		burst   = 20
+		clients = 40
+		storm   = 300 * time.Millisecond
+		// 每个准入的连接查询 token_version 和聊天室列表
+		queriesPerUpgrade = 2
+	)
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.WS.UpgradeRate = rate
+		cfg.WS.UpgradeBurst = burst
+	})
+	var queries atomic.Int64
+	tokens := make([]string, clients)
+	for i := range tokens {
+		_, tokens[i] = ts.addUser("user" + strconv.Itoa(i))
+	}
+	ts.auth.Users = countingUsers{ts.users, &queries}
+	ts.members = countingMembers{ts.members, &queries}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	var attempts atomic.Int64
+	for _, token := range tokens {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			// 客户端不遵守退避提示，不断重试
+			for time.Since(start) < storm {
+				attempts.Add(1)
+				ts.do("GET", "/ws?token="+token, "", nil)
+			}
+		}(token)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	stats := ts.admission.stats()
+	admitted := stats.AdmittedCold + stats.AdmittedResume
+	ceiling := int64((burst + rate*elapsed.Seconds() + 1) * queriesPerUpgrade)
+	if got := queries.Load(); got > ceiling {
+		t.Fatalf("%d queries during a %v storm of %d attempts, ceiling %d", got, elapsed, attempts.Load(), ceiling)
+	}
+	if got := queries.Load(); got != admitted*queriesPerUpgrade {
+		t.Fatalf("%d queries for %d admitted upgrades, deferred upgrades must not query the database", got, admitted)
+	}
+	if stats.Deferred == 0 || stats.Deferred+admitted != attempts.Load() {
+		t.Fatalf("stats = %+v for %d attempts", stats, attempts.Load())
+	}
+}
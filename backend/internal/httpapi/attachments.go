@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxAttachmentsPerMessage = 10
+	maxFilenameLength        = 255
+)
+
+type Attachment = store.Attachment
+
+// parseContentTypes 解析逗号分隔的 MIME 类型列表
+func parseContentTypes(value string) map[string]bool {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+// sniffContentType 根据文件内容判断类型，不信任客户端提供的 Content-Type
+func sniffContentType(f io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, nil
+}
+
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// uploadFile 接收 multipart 表单中的 file 字段，保存后返回附件信息
+func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, apiError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File cannot exceed %d bytes", s.MaxUploadBytes)))
+			return
+		}
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid multipart form"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "file is required", Field: "file"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > s.MaxUploadBytes {
+		writeError(w, r, apiError(http.StatusRequestEntityTooLarge, fmt.Sprintf("File cannot exceed %d bytes", s.MaxUploadBytes)))
+		return
+	}
+
+	contentType, err := sniffContentType(file)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !s.UploadTypes[contentType] {
+		writeError(w, r, &APIError{Status: http.StatusUnsupportedMediaType, Message: "File type is not allowed", Field: "file"})
+		return
+	}
+
+	var width, height int
+	if strings.HasPrefix(contentType, "image/") {
+		width, height, err = imageDimensions(file)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	filename := strings.TrimSpace(filepath.Base(header.Filename))
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
+		filename = "file"
+	}
+	if len(filename) > maxFilenameLength {
+		filename = filename[len(filename)-maxFilenameLength:]
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.Files.Save(r.Context(), key, file, header.Size, contentType); err != nil {
+		loggerFromContext(r.Context()).Error("failed to store upload", "error", err)
+		writeError(w, r, apiError(http.StatusBadGateway, "Failed to store file"))
+		return
+	}
+
+	att := Attachment{
+		UserID:      currentUser(r).UserID,
+		StorageKey:  key,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        header.Size,
+		Width:       width,
+		Height:      height,
+	}
+	if err := s.attachments.CreateAttachment(r.Context(), &att); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.enqueueThumbnail(r.Context(), att)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(att)
+}
+
+// checkAttachments 限制附件数量并去重，附件归属在保存消息时由 store 校验
+func (s *Server) checkAttachments(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if len(req.Attachments) == 0 {
+		return nil
+	}
+	seen := make(map[int]bool, len(req.Attachments))
+	ids := req.Attachments[:0]
+	for _, id := range req.Attachments {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxAttachmentsPerMessage {
+		return &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("A message can have at most %d attachments", maxAttachmentsPerMessage),
+			Field:   "attachments",
+		}
+	}
+	req.Attachments = ids
+	return nil
+}
+
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
+	s.serveAttachment(w, r, false)
+}
+
+func (s *Server) getThumbnail(w http.ResponseWriter, r *http.Request) {
+	s.serveAttachment(w, r, true)
+}
+
+// serveAttachment 下载附件或其缩略图。附件只对能看到所属消息的用户可见，尚未发送的附件只有上传者可以访问。
+// <img> 等标签无法设置 Authorization 头，因此也接受 ?token= 参数。
+func (s *Server) serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid file ID"))
+		return
+	}
+
+	userID := viewerID(r)
+	if token := r.URL.Query().Get("token"); userID == 0 && token != "" {
+		claims, err := s.auth.Authenticate(r.Context(), token)
+		if err != nil {
+			writeError(w, r, authError(err))
+			return
+		}
+		userID = claims.UserID
+	}
+	if userID == 0 {
+		writeError(w, r, apiError(http.StatusUnauthorized, "Authentication required"))
+		return
+	}
+
+	att, err := s.attachments.GetAttachment(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if att.MessageID == nil {
+		if att.UserID != userID {
+			writeError(w, r, store.ErrNotFound)
+			return
+		}
+	} else if _, _, err := s.visibleMessage(r.Context(), *att.MessageID, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, contentType := att.StorageKey, att.ContentType
+	if thumbnail {
+		if att.ThumbnailKey == "" {
+			writeError(w, r, store.ErrNotFound)
+			return
+		}
+		key, contentType = att.ThumbnailKey, att.ThumbnailContentType
+	}
+
+	body, err := s.Files.Open(r.Context(), key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer body.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if !thumbnail {
+		w.Header().Set("Content-Length", strconv.FormatInt(att.Size, 10))
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	io.Copy(w, body)
+}
@@ -0,0 +1,192 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/store"
+)
+
+// Claims token 中的用户信息
+type Claims = auth.Claims
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type AuthResponse struct {
+	Token   string `json:"token"`
+	User    User   `json:"user"`
+	Message string `json:"message"`
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	// 验证输入
+	if req.Username == "" || req.Email == "" || req.Password == "" {
+		writeError(w, r, apiError(http.StatusBadRequest, "All fields are required"))
+		return
+	}
+
+	if !isValidEmail(r.Context(), req.Email, s.ValidateEmailMX) {
+		writeError(w, r, errInvalidEmail)
+		return
+	}
+
+	if err := validatePassword(req.Password); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 密码加密 bcrypt
+	hashedPassword, err := s.hashPassword(req.Password)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to hash password"))
+		return
+	}
+
+	// 创建用户。不预先检查用户是否存在，并发注册由唯一约束保证只有一个成功
+	user, err := s.users.CreateUser(r.Context(), req.Username, req.Email, hashedPassword)
+	var uniqueErr *store.ErrUniqueViolation
+	if errors.As(err, &uniqueErr) {
+		writeError(w, r, registrationConflict(uniqueErr.Field))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 生成 JWT token
+	token, err := s.auth.Issue(user)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+
+	// 返回 token 和用户信息给前端
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:   token,
+		User:    user,
+		Message: "Registration successful",
+	})
+}
+
+// registrationConflict 邮箱或用户名已被占用时的 409 错误
+func registrationConflict(field string) *APIError {
+	switch field {
+	case "email":
+		return &APIError{Status: http.StatusConflict, Message: "Email is already in use", Field: "email"}
+	case "username":
+		return &APIError{Status: http.StatusConflict, Message: "Username is already taken", Field: "username"}
+	}
+	return apiError(http.StatusConflict, "User already exists")
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	// 查找用户
+	user, hashedPassword, err := s.users.GetUserByEmail(r.Context(), req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, apiError(http.StatusUnauthorized, "Invalid email or password"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 登录时验证密码
+	if !checkPassword(hashedPassword, req.Password) {
+		writeError(w, r, apiError(http.StatusUnauthorized, "Invalid email or password"))
+		return
+	}
+	// 密码正确后才提示账号已停用，避免泄露账号状态
+	if user.Disabled {
+		writeError(w, r, errAccountDisabled)
+		return
+	}
+	s.upgradePasswordHash(r.Context(), user.ID, hashedPassword, req.Password)
+
+	// 生成 JWT token
+	token, err := s.auth.Issue(user)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:   token,
+		User:    user,
+		Message: "Login successful",
+	})
+}
+
+// currentUser 返回认证中间件放入上下文的用户信息，未认证时为 nil
+func currentUser(r *http.Request) *Claims {
+	return auth.FromContext(r.Context())
+}
+
+// authMiddleware 验证 JWT Token，失败时返回 401
+func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.auth.Require(writeAuthError, func(w http.ResponseWriter, r *http.Request) {
+		// 机器人令牌的请求不代表所有者在线
+		if claims := currentUser(r); claims.BotID == 0 {
+			s.updateLastSeen(claims.UserID)
+		}
+		next(w, r)
+	})
+}
+
+// updateLastSeen 在后台更新用户的最后活跃时间，不等待数据库，失败只记录日志
+func (s *Server) updateLastSeen(userID int) {
+	go func() {
+		if err := s.users.TouchLastSeen(context.Background(), userID); err != nil {
+			slog.Warn("failed to update last seen", "user_id", userID, "error", err)
+		}
+	}()
+}
+
+// optionalAuthMiddleware 有 token 时解析用户信息，没有 token 也放行
+func (s *Server) optionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.auth.Optional(next)
+}
+
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	// 缺少 token 是正常的未登录请求，其余的失败原因记录下来便于排查
+	if !errors.Is(err, auth.ErrMissingToken) {
+		loggerFromContext(r.Context()).Info("authentication failed", "reason", err)
+	}
+	writeError(w, r, authError(err))
+}
+
+// viewerID 返回当前用户 ID，未认证时为 0
+func viewerID(r *http.Request) int {
+	if claims := currentUser(r); claims != nil {
+		return claims.UserID
+	}
+	return 0
+}
@@ -0,0 +1,213 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"chatapp/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRegister(t *testing.T) {
+	ts := newTestServer(t)
+
+	var resp AuthResponse
+	decodeResponse(t, ts.do("POST", "/api/auth/register", "", RegisterRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}), http.StatusOK, &resp)
+	if resp.Token == "" || resp.User.Username != "alice" || resp.User.Email != "alice@example.com" {
+		t.Fatalf("response = %+v", resp)
+	}
+	// 注册返回的 token 可以直接使用
+	var me User
+	decodeResponse(t, ts.do("GET", "/api/users/me", resp.Token, nil), http.StatusOK, &me)
+	if me.ID != resp.User.ID {
+		t.Fatalf("me = %+v, want user %d", me, resp.User.ID)
+	}
+}
+
+func TestRegisterRejects(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addUser("alice")
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+		field  string
+	}{
+		{"invalid body", "{", http.StatusBadRequest, ""},
+		{"missing username", RegisterRequest{Email: "bob@example.com", Password: testPassword}, http.StatusBadRequest, ""},
+		{"invalid email", RegisterRequest{Username: "bob", Email: "bob", Password: testPassword}, http.StatusUnprocessableEntity, "email"},
+		{"weak password", RegisterRequest{Username: "bob", Email: "bob@example.com", Password: "short"}, http.StatusBadRequest, "password"},
+		{"email taken", RegisterRequest{Username: "bob", Email: "alice@example.com", Password: testPassword}, http.StatusConflict, "email"},
+		{"username taken", RegisterRequest{Username: "alice", Email: "bob@example.com", Password: testPassword}, http.StatusConflict, "username"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr APIError
+			decodeResponse(t, ts.do("POST", "/api/auth/register", "", tt.body), tt.status, &apiErr)
+			if apiErr.Field != tt.field {
+				t.Fatalf("field = %q, want %q", apiErr.Field, tt.field)
+			}
+		})
+	}
+}
+
+// TestRegisterConflicts 409 响应指出被占用的字段，邮箱和用户名属于不同的已有用户时先报告邮箱
+func TestRegisterConflicts(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addUser("alice")
+	ts.addUser("bob")
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		field    string
+		message  string
+	}{
+		{"email", "carol", "alice@example.com", "email", "Email is already in use"},
+		{"username", "alice", "carol@example.com", "username", "Username is already taken"},
+		{"both on the same user", "alice", "alice@example.com", "email", "Email is already in use"},
+		{"both on different users", "alice", "bob@example.com", "email", "Email is already in use"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr APIError
+			rec := ts.do("POST", "/api/auth/register", "", RegisterRequest{Username: tt.username, Email: tt.email, Password: testPassword})
+			decodeResponse(t, rec, http.StatusConflict, &apiErr)
+			if apiErr.Field != tt.field || apiErr.Message != tt.message {
+				t.Fatalf("error = %+v, want %s: %q", apiErr, tt.field, tt.message)
+			}
+		})
+	}
+	// 冲突的注册不会创建用户
+	decodeResponse(t, ts.do("POST", "/api/auth/register", "", RegisterRequest{Username: "carol", Email: "carol@example.com", Password: testPassword}), http.StatusOK, nil)
+}
+
+// TestConcurrentRegistration 同时用相同的邮箱注册，只有一个成功，其余返回 409 而不是 500
+func TestConcurrentRegistration(t *testing.T) {
+	ts := newTestServer(t)
+	const attempts = 10
+
+	codes := make([]int, attempts)
+	fields := make([]string, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 用户名不同，冲突只来自邮箱
+			req := RegisterRequest{Username: "alice" + strconv.Itoa(i), Email: "alice@example.com", Password: testPassword}
+			rec := ts.do("POST", "/api/auth/register", "", req)
+			codes[i] = rec.Code
+			if rec.Code == http.StatusConflict {
+				var apiErr APIError
+				decodeResponse(t, rec, http.StatusConflict, &apiErr)
+				fields[i] = apiErr.Field
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		case http.StatusConflict:
+			if fields[i] != "email" {
+				t.Errorf("conflict on field %q, want email", fields[i])
+			}
+		default:
+			t.Errorf("registration returned %d", code)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d registrations succeeded, want exactly 1", created)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	user, _ := ts.addUser("alice")
+
+	var resp AuthResponse
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword}), http.StatusOK, &resp)
+	if resp.Token == "" || resp.User.ID != user.ID {
+		t.Fatalf("response = %+v", resp)
+	}
+	decodeResponse(t, ts.do("GET", "/api/users/me", resp.Token, nil), http.StatusOK, nil)
+}
+
+func TestLoginRejects(t *testing.T) {
+	ts := newTestServer(t)
+	user, _ := ts.addUser("alice")
+
+	wrongPassword := ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "wrong-password"})
+	unknownEmail := ts.do("POST", "/api/auth/login", "", LoginRequest{Email: "nobody@example.com", Password: testPassword})
+	decodeResponse(t, wrongPassword, http.StatusUnauthorized, nil)
+	decodeResponse(t, unknownEmail, http.StatusUnauthorized, nil)
+	// 不泄露邮箱是否注册
+	if wrongPassword.Body.String() != unknownEmail.Body.String() {
+		t.Fatalf("responses differ: %s / %s", wrongPassword.Body, unknownEmail.Body)
+	}
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", "{"), http.StatusBadRequest, nil)
+}
+
+// TestRejectsForeignTokens 只接受服务端配置的 HS256 签名，alg=none、RS256 和其他 HMAC 算法的 token 都返回 401；
+// 过期和尚未生效的 token 返回不同的错误信息
+func TestRejectsForeignTokens(t *testing.T) {
+	ts := newTestServer(t)
+	user, _ := ts.addUser("alice")
+	now := time.Now()
+	claims := func(issuedAt, notBefore, expiresAt time.Time) auth.Claims {
+		return auth.Claims{UserID: user.ID, Username: user.Username, Email: user.Email, RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(notBefore),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		}}
+	}
+	valid := claims(now, now, now.Add(time.Hour))
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(method jwt.SigningMethod, c auth.Claims, key interface{}) string {
+		token, err := jwt.NewWithClaims(method, c).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	secret := []byte("test-secret")
+
+	tests := []struct {
+		name    string
+		token   string
+		message string
+	}{
+		{"none", sign(jwt.SigningMethodNone, valid, jwt.UnsafeAllowNoneSignatureType), "Invalid token"},
+		{"RS256", sign(jwt.SigningMethodRS256, valid, rsaKey), "Invalid token"},
+		{"HS512 with the server secret", sign(jwt.SigningMethodHS512, valid, secret), "Invalid token"},
+		{"HS256 with another secret", sign(jwt.SigningMethodHS256, valid, []byte("other-secret")), "Invalid token"},
+		{"expired", sign(jwt.SigningMethodHS256, claims(now.Add(-2*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour)), secret), "Token has expired"},
+		{"not valid yet", sign(jwt.SigningMethodHS256, claims(now, now.Add(time.Hour), now.Add(2*time.Hour)), secret), "Token is not valid yet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr APIError
+			decodeResponse(t, ts.do("GET", "/api/users/me", tt.token, nil), http.StatusUnauthorized, &apiErr)
+			if apiErr.Message != tt.message {
+				t.Fatalf("message = %q, want %q", apiErr.Message, tt.message)
+			}
+		})
+	}
+	// 同样的 claims 用正确的算法和密钥签名可以通过认证
+	decodeResponse(t, ts.do("GET", "/api/users/me", sign(jwt.SigningMethodHS256, valid, secret), nil), http.StatusOK, nil)
+}
@@ -0,0 +1,167 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxAvatarBytes = 2 << 20
+	avatarSize     = 256
+)
+
+// avatarTypes 允许上传的头像类型。标准库无法解码 WebP，WebP 头像按原图保存
+var avatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// uploadAvatar 接收 multipart 表单中的 avatar 字段，裁剪为正方形并缩小到 256×256 后保存
+func (s *Server) uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, apiError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Avatar cannot exceed %d bytes", maxAvatarBytes)))
+			return
+		}
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid multipart form"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "avatar is required", Field: "avatar"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAvatarBytes {
+		writeError(w, r, apiError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Avatar cannot exceed %d bytes", maxAvatarBytes)))
+		return
+	}
+
+	contentType, err := sniffContentType(file)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !avatarTypes[contentType] {
+		writeError(w, r, &APIError{Status: http.StatusUnsupportedMediaType, Message: "Avatar must be a JPEG, PNG or WebP image", Field: "avatar"})
+		return
+	}
+
+	var body io.Reader = file
+	size := header.Size
+	if contentType != "image/webp" {
+		data, err := resizeAvatar(file, contentType)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		body, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	key = "avatar_" + key
+	if err := s.Files.Save(r.Context(), key, body, size, contentType); err != nil {
+		loggerFromContext(r.Context()).Error("failed to store avatar", "error", err)
+		writeError(w, r, apiError(http.StatusBadGateway, "Failed to store avatar"))
+		return
+	}
+
+	// URL 中带上文件版本，头像更新后客户端缓存自然失效
+	userID := currentUser(r).UserID
+	url := fmt.Sprintf("/api/users/%d/avatar?v=%s", userID, key[len("avatar_"):len("avatar_")+8])
+	user, err := s.users.SetAvatar(r.Context(), userID, store.Avatar{StorageKey: key, ContentType: contentType}, url)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// resizeAvatar 居中裁剪为正方形并缩小到 avatarSize，PNG 保持 PNG，JPEG 保持 JPEG
+func resizeAvatar(f io.ReadSeeker, contentType string) ([]byte, error) {
+	width, _, err := imageDimensions(f)
+	if err != nil {
+		return nil, err
+	}
+	if width == 0 {
+		return nil, &APIError{Status: http.StatusUnprocessableEntity, Message: "Avatar is not a valid image", Field: "avatar"}
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, &APIError{Status: http.StatusUnprocessableEntity, Message: "Avatar is not a valid image", Field: "avatar"}
+	}
+
+	img := resizeImage(cropSquare(src), avatarSize)
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), err
+}
+
+// cropSquare 取图片中间最大的正方形
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return img
+	}
+	return sub.SubImage(image.Rect(x0, y0, x0+side, y0+side))
+}
+
+// getAvatar 返回用户上传的头像，头像与用户资料一样公开
+func (s *Server) getAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid user ID"))
+		return
+	}
+
+	avatar, err := s.users.GetAvatar(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	body, err := s.Files.Open(r.Context(), avatar.StorageKey)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", avatar.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	io.Copy(w, body)
+}
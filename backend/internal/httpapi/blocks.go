@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var errBlocked = apiError(http.StatusForbidden, "You cannot send direct messages to this user")
+
+// checkNotBlocked 任意一方屏蔽了另一方时两人之间不能发起或发送私信
+func (s *Server) checkNotBlocked(ctx context.Context, userID, otherID int) error {
+	if s.blocks == nil {
+		return nil
+	}
+	blocked, err := s.blocks.IsBlockedBetween(ctx, userID, otherID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errBlocked
+	}
+	return nil
+}
+
+// blockTarget 解析要屏蔽的用户，不能屏蔽自己，用户不存在时返回 404
+func (s *Server) blockTarget(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid user ID")
+	}
+	if id == currentUser(r).UserID {
+		return 0, apiError(http.StatusBadRequest, "You cannot block yourself")
+	}
+	if _, err := s.users.GetUser(r.Context(), id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// blockUser 屏蔽用户，之后不再收到该用户的聊天室消息，双方也不能互发私信，重复屏蔽没有副作用
+func (s *Server) blockUser(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := s.blockTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	userID := currentUser(r).UserID
+
+	if err := s.blocks.BlockUser(r.Context(), userID, blockedID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.hub.SetBlocked(userID, blockedID, true)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unblockUser(w http.ResponseWriter, r *http.Request) {
+	blockedID, err := s.blockTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	userID := currentUser(r).UserID
+
+	if err := s.blocks.UnblockUser(r.Context(), userID, blockedID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.hub.SetBlocked(userID, blockedID, false)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBlocks 返回当前用户屏蔽的用户
+func (s *Server) getBlocks(w http.ResponseWriter, r *http.Request) {
+	users, err := s.blocks.ListBlockedUsers(r.Context(), currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
@@ -0,0 +1,148 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// blockRoom 创建 alice、bob 和 carol 都加入的聊天室，返回聊天室、用户和 token
+func blockRoom(t *testing.T, ts *testServer) (store.ChatRoom, map[string]store.User, map[string]string) {
+	t.Helper()
+	users := make(map[string]store.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		users[name], tokens[name] = ts.addUser(name)
+	}
+	alice := users["alice"]
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	for _, u := range users {
+		if _, err := ts.store.JoinRoom(context.Background(), room.ID, u.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room, users, tokens
+}
+
+// historySenders 返回聊天室历史中各消息的发送者，以及被标记为已屏蔽的发送者
+func historySenders(t *testing.T, ts *testServer, token string, roomID int, query string) (senders, blocked []string) {
+	t.Helper()
+	var page MessagePage
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages%s", roomID, query), token, nil), http.StatusOK, &page)
+	for _, msg := range page.Messages {
+		senders = append(senders, msg.Username)
+		if msg.Blocked {
+			blocked = append(blocked, msg.Username)
+		}
+	}
+	return senders, blocked
+}
+
+// TestBlockFiltersRoomHistory 屏蔽的用户的消息不出现在历史中，include_blocked=true 时返回并标记
+func TestBlockFiltersRoomHistory(t *testing.T) {
+	ts := newTestServer(t)
+	room, users, tokens := blockRoom(t, ts)
+	postMessage(t, ts, tokens["bob"], room.ID, "from bob")
+	postMessage(t, ts, tokens["carol"], room.ID, "from carol")
+	blockPath := fmt.Sprintf("/api/users/%d/block", users["bob"].ID)
+
+	decodeResponse(t, ts.do("POST", blockPath, tokens["alice"], nil), http.StatusNoContent, nil)
+	decodeResponse(t, ts.do("POST", blockPath, tokens["alice"], nil), http.StatusNoContent, nil)
+	if senders, _ := historySenders(t, ts, tokens["alice"], room.ID, ""); fmt.Sprint(senders) != "[carol]" {
+		t.Fatalf("alice sees messages from %v, want only carol", senders)
+	}
+	senders, blocked := historySenders(t, ts, tokens["alice"], room.ID, "?include_blocked=true")
+	if fmt.Sprint(senders) != "[bob carol]" || fmt.Sprint(blocked) != "[bob]" {
+		t.Fatalf("with include_blocked alice sees %v, blocked %v", senders, blocked)
+	}
+	// 屏蔽只影响屏蔽者
+	if senders, _ := historySenders(t, ts, tokens["carol"], room.ID, ""); fmt.Sprint(senders) != "[bob carol]" {
+		t.Fatalf("carol sees messages from %v", senders)
+	}
+
+	var list []store.BlockedUser
+	decodeResponse(t, ts.do("GET", "/api/users/me/blocks", tokens["alice"], nil), http.StatusOK, &list)
+	if len(list) != 1 || list[0].ID != users["bob"].ID || list[0].Username != "bob" {
+		t.Fatalf("blocks = %+v, want bob", list)
+	}
+
+	decodeResponse(t, ts.do("DELETE", blockPath, tokens["alice"], nil), http.StatusNoContent, nil)
+	if senders, _ := historySenders(t, ts, tokens["alice"], room.ID, ""); fmt.Sprint(senders) != "[bob carol]" {
+		t.Fatalf("after unblocking alice sees messages from %v", senders)
+	}
+}
+
+func TestBlockRejects(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.addUser("alice")
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/users/%d/block", alice.ID), token, nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("POST", "/api/users/999/block", token, nil), http.StatusNotFound, nil)
+	decodeResponse(t, ts.do("POST", "/api/users/999/block", "", nil), http.StatusUnauthorized, nil)
+}
+
+// readMessagesUntil 读取 message 事件直到收到内容为 last 的消息，返回收到的消息内容
+func readMessagesUntil(t *testing.T, conn *websocket.Conn, last string) []string {
+	t.Helper()
+	var contents []string
+	readUntil(t, conn, func(fr wsFrame) bool {
+		if fr.Type != ws.EventMessage {
+			return false
+		}
+		var msg Message
+		if err := json.Unmarshal(fr.Data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, msg.Content)
+		return msg.Content == last
+	})
+	return contents
+}
+
+// TestBlockFiltersWebSocket 屏蔽立即作用于已有的连接，新连接从数据库加载屏蔽列表
+func TestBlockFiltersWebSocket(t *testing.T) {
+	ts := newTestServer(t)
+	room, users, tokens := blockRoom(t, ts)
+	conn := dialWebSocket(t, ts, tokens["alice"])
+	blockPath := fmt.Sprintf("/api/users/%d/block", users["bob"].ID)
+
+	decodeResponse(t, ts.do("POST", blockPath, tokens["alice"], nil), http.StatusNoContent, nil)
+	// hub 按发布顺序投递，收到 carol 的消息时 bob 的消息如果没有被过滤已经收到
+	postMessage(t, ts, tokens["bob"], room.ID, "bob 1")
+	postMessage(t, ts, tokens["carol"], room.ID, "carol 1")
+	if got := readMessagesUntil(t, conn, "carol 1"); fmt.Sprint(got) != "[carol 1]" {
+		t.Fatalf("alice received %v, want only carol's message", got)
+	}
+
+	fresh := dialWebSocket(t, ts, tokens["alice"])
+	postMessage(t, ts, tokens["bob"], room.ID, "bob 2")
+	postMessage(t, ts, tokens["carol"], room.ID, "carol 2")
+	if got := readMessagesUntil(t, fresh, "carol 2"); fmt.Sprint(got) != "[carol 2]" {
+		t.Fatalf("new connection received %v, want only carol's message", got)
+	}
+
+	decodeResponse(t, ts.do("DELETE", blockPath, tokens["alice"], nil), http.StatusNoContent, nil)
+	postMessage(t, ts, tokens["bob"], room.ID, "bob 3")
+	if got := readMessagesUntil(t, conn, "bob 3"); fmt.Sprint(got) != "[carol 2 bob 3]" {
+		t.Fatalf("after unblocking alice received %v", got)
+	}
+}
+
+// TestBlockPreventsDirectMessages 任意一方屏蔽另一方后都不能发起私信
+func TestBlockPreventsDirectMessages(t *testing.T) {
+	ts := newTestServer(t)
+	_, users, tokens := blockRoom(t, ts)
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/users/%d/block", users["bob"].ID), tokens["alice"], nil), http.StatusNoContent, nil)
+
+	decodeResponse(t, ts.do("POST", "/api/conversations", tokens["alice"], CreateConversationRequest{UserID: users["bob"].ID}), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("POST", "/api/conversations", tokens["bob"], CreateConversationRequest{UserID: users["alice"].ID}), http.StatusForbidden, nil)
+	if rec := ts.do("POST", "/api/conversations", tokens["alice"], CreateConversationRequest{UserID: users["carol"].ID}); rec.Code >= 300 {
+		t.Fatalf("conversation with carol: status %d, body %s", rec.Code, rec.Body)
+	}
+}
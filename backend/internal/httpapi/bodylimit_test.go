@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"chatapp/internal/config"
+
+	"github.com/gorilla/mux"
+)
+
+// writeRoutes 返回所有 POST 和 PUT 路由，路径参数替换为 1
+func writeRoutes(t *testing.T, s *Server) [][2]string {
+	t.Helper()
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	var routes [][2]string
+	err := s.routes().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || bodyLimitExempt[tpl] {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if method == "POST" || method == "PUT" {
+				routes = append(routes, [2]string{method, param.ReplaceAllString(tpl, "1")})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return routes
+}
+
+// TestBodyLimitOnWriteRoutes 向每个写接口发送 10 MB 的请求体都返回 413，
+// 无论客户端是否声明 Content-Length
+func TestBodyLimitOnWriteRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("alice")
+	body := bytes.Repeat([]byte("a"), 10<<20)
+
+	routes := writeRoutes(t, ts.Server)
+	if len(routes) < 10 {
+		t.Fatalf("found only %d write routes", len(routes))
+	}
+	for _, route := range routes {
+		for _, chunked := range []bool{false, true} {
+			var reader io.Reader = bytes.NewReader(body)
+			if chunked {
+				// 隐藏长度，请求以 chunked 编码发送
+				reader = io.MultiReader(reader)
+			}
+			req := httptest.NewRequest(route[0], route[1], reader)
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+			var apiErr APIError
+			decodeResponse(t, ts.serve(req), http.StatusRequestEntityTooLarge, &apiErr)
+			if apiErr.Code != "payload_too_large" {
+				t.Fatalf("%s %s: code = %q", route[0], route[1], apiErr.Code)
+			}
+		}
+	}
+}
+
+// TestBodyLimitConfigurable MAX_REQUEST_BODY_BYTES 以内的请求体正常处理
+func TestBodyLimitConfigurable(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.MaxRequestBodyBytes = 200 })
+
+	small := RegisterRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}
+	decodeResponse(t, ts.do("POST", "/api/auth/register", "", small), http.StatusOK, nil)
+	large := RegisterRequest{Username: "bob", Email: "bob@example.com", Password: testPassword + strings.Repeat("1", 200)}
+	decodeResponse(t, ts.do("POST", "/api/auth/register", "", large), http.StatusRequestEntityTooLarge, nil)
+}
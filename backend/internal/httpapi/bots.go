@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+type Bot = store.Bot
+
+// CreateBotRequest POST /api/bots 的请求体
+type CreateBotRequest struct {
+	Name string `json:"name"`
+}
+
+// errBotForbidden 机器人令牌不能管理机器人，泄露的令牌无法用来创建新的令牌
+var errBotForbidden = apiError(http.StatusForbidden, "Bots cannot be managed with a bot token")
+
+func botIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid bot ID")
+	}
+	return id, nil
+}
+
+// generateBotToken 生成带 bot_ 前缀的令牌及其哈希，哈希与 auth 包查找令牌时的计算方式一致
+func generateBotToken() (string, string, error) {
+	token, _, err := generateResetToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = auth.BotTokenPrefix + token
+	return token, hashResetToken(token), nil
+}
+
+// botOwner 返回当前用户，通过机器人令牌认证的请求返回错误
+func botOwner(r *http.Request) (*Claims, error) {
+	user := currentUser(r)
+	if user.BotID != 0 {
+		return nil, errBotForbidden
+	}
+	return user, nil
+}
+
+func (s *Server) listBots(w http.ResponseWriter, r *http.Request) {
+	user, err := botOwner(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	bots, err := s.bots.ListBots(r.Context(), user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bots)
+}
+
+// createBot 生成令牌并在响应中返回，数据库只保存哈希，之后无法再查看
+func (s *Server) createBot(w http.ResponseWriter, r *http.Request) {
+	user, err := botOwner(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CreateBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	name, err := validateWebhookName(req.Name, "name")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, tokenHash, err := generateBotToken()
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+	bot := Bot{Name: name, OwnerUserID: user.UserID}
+	if err := s.bots.CreateBot(r.Context(), &bot, tokenHash); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	bot.Token = token
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bot)
+}
+
+// rotateBotToken 生成新令牌，旧令牌立即失效
+func (s *Server) rotateBotToken(w http.ResponseWriter, r *http.Request) {
+	user, err := botOwner(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := botIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, tokenHash, err := generateBotToken()
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+	bot, err := s.bots.RotateBotToken(r.Context(), id, user.UserID, tokenHash)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	bot.Token = token
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bot)
+}
+
+// deleteBot 撤销机器人，令牌立即失效，已经发送的消息保留
+func (s *Server) deleteBot(w http.ResponseWriter, r *http.Request) {
+	user, err := botOwner(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := botIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.bots.DeleteBot(r.Context(), id, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
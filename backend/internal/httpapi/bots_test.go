@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/ws"
+)
+
+// TestBotTokenAuthentication 机器人令牌以所有者的身份通过认证，可以发送消息但不能管理机器人；
+// 轮换或删除之后旧令牌立即失效
+func TestBotTokenAuthentication(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+
+	var bot Bot
+	decodeResponse(t, ts.do("POST", "/api/bots", token, CreateBotRequest{Name: "deploy"}), http.StatusCreated, &bot)
+	if !strings.HasPrefix(bot.Token, auth.BotTokenPrefix) || bot.OwnerUserID != alice.ID {
+		t.Fatalf("bot = %+v, want a bot_ token owned by alice", bot)
+	}
+
+	var me User
+	decodeResponse(t, ts.do("GET", "/api/users/me", bot.Token, nil), http.StatusOK, &me)
+	if me.ID != alice.ID {
+		t.Fatalf("bot authenticated as user %d, want %d", me.ID, alice.ID)
+	}
+	var msg Message
+	decodeResponse(t, ts.do("POST", "/api/messages", bot.Token, CreateMessageRequest{RoomID: room.ID, Content: "deployed"}), http.StatusOK, &msg)
+	if msg.UserID != alice.ID {
+		t.Fatalf("message = %+v, want it sent as alice", msg)
+	}
+	// WebSocket 同样接受机器人令牌
+	conn := dialWebSocket(t, ts, bot.Token)
+	if err := conn.WriteJSON(CreateMessageRequest{RoomID: room.ID, Content: "over websocket", AckID: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if frame := readUntil(t, conn, func(fr wsFrame) bool { return fr.AckID == "b1" }); frame.Type != ws.EventAck {
+		t.Fatalf("frame = %+v, want ack", frame)
+	}
+
+	decodeResponse(t, ts.do("POST", "/api/bots", bot.Token, CreateBotRequest{Name: "escalate"}), http.StatusForbidden, nil)
+	var bots []Bot
+	decodeResponse(t, ts.do("GET", "/api/bots", token, nil), http.StatusOK, &bots)
+	if len(bots) != 1 || bots[0].Token != "" || bots[0].LastUsedAt == nil {
+		t.Fatalf("bots = %+v, want one bot with last_used_at and no token", bots)
+	}
+
+	var rotated Bot
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/bots/%d/rotate", bot.ID), token, nil), http.StatusOK, &rotated)
+	decodeResponse(t, ts.do("GET", "/api/users/me", bot.Token, nil), http.StatusUnauthorized, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/me", rotated.Token, nil), http.StatusOK, nil)
+
+	decodeResponse(t, ts.do("DELETE", fmt.Sprintf("/api/bots/%d", bot.ID), token, nil), http.StatusNoContent, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/me", rotated.Token, nil), http.StatusUnauthorized, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/me", auth.BotTokenPrefix+"unknown", nil), http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// CommandHandler 处理聊天室中以 / 开头的消息，返回的 reply 只发给发送命令的用户
+type CommandHandler interface {
+	Name() string
+	Handle(ctx context.Context, args []string, room ChatRoom, user User) (reply string, err error)
+}
+
+// commandSender 命令回复的发送者名称
+const commandSender = "system"
+
+// registerCommands 注册斜杠命令，同名命令会被覆盖
+func (s *Server) registerCommands(handlers ...CommandHandler) {
+	for _, h := range handlers {
+		s.commands[h.Name()] = h
+	}
+}
+
+// checkCommand 拦截聊天室中的斜杠命令，命令不保存为消息，回复放在 req.reply 中。
+// 以 // 开头的消息去掉一个 / 后作为普通消息发送。
+func (s *Server) checkCommand(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.room == nil || !strings.HasPrefix(req.Content, "/") {
+		return nil
+	}
+	if strings.HasPrefix(req.Content, "//") {
+		req.Content = req.Content[1:]
+		return nil
+	}
+
+	fields := strings.Fields(req.Content[1:])
+	if len(fields) == 0 {
+		return nil
+	}
+	name, args := strings.ToLower(fields[0]), fields[1:]
+
+	reply := "unknown command"
+	if h, ok := s.commands[name]; ok {
+		user, err := s.users.GetUser(ctx, sender.UserID)
+		if err != nil {
+			return err
+		}
+		if reply, err = h.Handle(ctx, args, *req.room, user); err != nil {
+			return err
+		}
+	}
+
+	req.reply = &Message{
+		RoomID:      req.RoomID,
+		Username:    commandSender,
+		DisplayName: commandSender,
+		Content:     reply,
+		CreatedAt:   time.Now().UTC(),
+		ClientMsgID: req.ClientMsgID,
+		Ephemeral:   true,
+		MessageType: store.MessageTypeSystem,
+	}
+	return nil
+}
+
+// nickCommand /nick <显示名称>，用户名不允许修改，修改的是显示名称
+type nickCommand struct{ s *Server }
+
+func (nickCommand) Name() string { return "nick" }
+
+func (c nickCommand) Handle(ctx context.Context, args []string, room ChatRoom, user User) (string, error) {
+	if len(args) == 0 {
+		return "", &APIError{Status: http.StatusBadRequest, Message: "Usage: /nick <display_name>", Field: "content"}
+	}
+	name := strings.Join(args, " ")
+	req := UpdateProfileRequest{DisplayName: &name}
+	if err := validateProfile(&req); err != nil {
+		return "", err
+	}
+	if _, err := c.s.users.UpdateProfile(ctx, user.ID, store.ProfileUpdate{DisplayName: req.DisplayName}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Your display name is now %s", *req.DisplayName), nil
+}
+
+// topicCommand /topic <描述>，只有聊天室所有者和版主可以使用
+type topicCommand struct{ s *Server }
+
+func (topicCommand) Name() string { return "topic" }
+
+func (c topicCommand) Handle(ctx context.Context, args []string, room ChatRoom, user User) (string, error) {
+	if _, err := c.s.requireModerator(ctx, room.ID, user.ID); err != nil {
+		return "", err
+	}
+	updated, err := c.s.rooms.UpdateRoom(ctx, room.ID, room.Name, strings.Join(args, " "), nil)
+	if err != nil {
+		return "", err
+	}
+	c.s.hub.Publish(ws.Event{Type: ws.EventRoomUpdated, RoomID: updated.ID, Data: updated})
+	if updated.Description == "" {
+		return "Topic cleared", nil
+	}
+	return fmt.Sprintf("Topic changed to: %s", updated.Description), nil
+}
+
+// membersCommand /members 返回聊天室的成员数
+type membersCommand struct{ s *Server }
+
+func (membersCommand) Name() string { return "members" }
+
+func (c membersCommand) Handle(ctx context.Context, args []string, room ChatRoom, user User) (string, error) {
+	count, err := c.s.members.CountRoomMembers(ctx, room.ID)
+	if err != nil {
+		return "", err
+	}
+	if count == 1 {
+		return "This room has 1 member", nil
+	}
+	return fmt.Sprintf("This room has %d members", count), nil
+}
@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// runCommand 发送斜杠命令，检查状态码并返回回复
+func runCommand(t *testing.T, ts *testServer, token string, roomID int, content string, status int) Message {
+	t.Helper()
+	var reply Message
+	rec := ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: roomID, Content: content})
+	if status != http.StatusOK {
+		decodeResponse(t, rec, status, nil)
+		return reply
+	}
+	decodeResponse(t, rec, http.StatusOK, &reply)
+	if !reply.Ephemeral || reply.ID != 0 || reply.MessageType != store.MessageTypeSystem || reply.Username != commandSender {
+		t.Fatalf("reply to %q = %+v, want an ephemeral system message", content, reply)
+	}
+	return reply
+}
+
+// assertNoMessages 命令不保存为消息
+func assertNoMessages(t *testing.T, ts *testServer, roomID int) {
+	t.Helper()
+	if id, err := ts.store.LatestRoomMessageID(context.Background(), roomID); err != nil || id != 0 {
+		t.Fatalf("latest message = %d, %v, want no stored messages", id, err)
+	}
+}
+
+func TestNickCommand(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+
+	reply := runCommand(t, ts, token, room.ID, "/nick Alice Smith", http.StatusOK)
+	if reply.Content != "Your display name is now Alice Smith" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	user, err := ts.store.GetUser(context.Background(), alice.ID)
+	if err != nil || user.DisplayName != "Alice Smith" || user.Username != "alice" {
+		t.Fatalf("user = %+v, %v, want display name Alice Smith", user, err)
+	}
+	runCommand(t, ts, token, room.ID, "/nick", http.StatusBadRequest)
+	assertNoMessages(t, ts, room.ID)
+}
+
+func TestTopicCommand(t *testing.T) {
+	ts := newTestServer(t)
+	room, users, tokens := moderationRoom(t, ts)
+	events := ts.subscribe(users["member1"].ID, room.ID)
+
+	runCommand(t, ts, tokens["member1"], room.ID, "/topic spam", http.StatusForbidden)
+	reply := runCommand(t, ts, tokens["mod1"], room.ID, "/topic Release planning", http.StatusOK)
+	if reply.Content != "Topic changed to: Release planning" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	updated, err := ts.store.GetRoom(context.Background(), room.ID)
+	if err != nil || updated.Description != "Release planning" {
+		t.Fatalf("room = %+v, %v", updated, err)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventRoomUpdated); got != 1 {
+		t.Fatalf("members received %d room_updated events, want 1", got)
+	}
+
+	if reply := runCommand(t, ts, tokens["owner"], room.ID, "/topic", http.StatusOK); reply.Content != "Topic cleared" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	assertNoMessages(t, ts, room.ID)
+}
+
+func TestMembersCommand(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+
+	if reply := runCommand(t, ts, token, room.ID, "/members", http.StatusOK); reply.Content != "This room has 1 member" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	carol, _ := ts.addUser("carol")
+	ts.store.JoinRoom(context.Background(), room.ID, carol.ID)
+	if reply := runCommand(t, ts, token, room.ID, "/MEMBERS", http.StatusOK); reply.Content != "This room has 2 members" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	assertNoMessages(t, ts, room.ID)
+}
+
+// TestUnknownAndEscapedCommands 未知命令只回复发送者，以 // 开头的消息作为普通消息发送
+func TestUnknownAndEscapedCommands(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+
+	if reply := runCommand(t, ts, token, room.ID, "/shrug", http.StatusOK); reply.Content != "unknown command" {
+		t.Fatalf("reply = %q", reply.Content)
+	}
+	assertNoMessages(t, ts, room.ID)
+
+	var msg Message
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "//shrug"}), http.StatusOK, &msg)
+	if msg.ID == 0 || msg.Content != "/shrug" {
+		t.Fatalf("message = %+v, want a stored message /shrug", msg)
+	}
+}
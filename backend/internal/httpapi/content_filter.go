@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+)
+
+// 内容过滤的处理结果
+const (
+	FilterAllow  = "allow"
+	FilterMask   = "mask"
+	FilterReject = "reject"
+)
+
+// FilterResult ContentFilter 的判断。Action 为 FilterMask 时 Content 为替换后的内容，
+// 为 FilterReject 时 Reason 返回给发送者
+type FilterResult struct {
+	Action  string
+	Content string
+	Reason  string
+	// Terms 命中的词（小写），用于记录审计日志
+	Terms []string
+}
+
+// ContentFilter 在消息保存前检查内容，对所有聊天室和私信生效。
+// 与聊天室的脏话过滤（checkProfanity）相互独立，两者都开启时依次执行。
+type ContentFilter interface {
+	Filter(ctx context.Context, content string) (FilterResult, error)
+}
+
+// passThroughFilter 不做任何处理，未配置 CONTENT_FILTER 时使用
+type passThroughFilter struct{}
+
+func (passThroughFilter) Filter(ctx context.Context, content string) (FilterResult, error) {
+	return FilterResult{Action: FilterAllow, Content: content}, nil
+}
+
+// WordListFilter 按词表过滤，Mode 为 FilterMask 时把命中的词替换为 *，为 FilterReject 时拒绝整条消息
+type WordListFilter struct {
+	Words *ProfanityFilter
+	Mode  string
+}
+
+func (f *WordListFilter) Filter(ctx context.Context, content string) (FilterResult, error) {
+	runes := []rune(content)
+	found := f.Words.matches(runes)
+	if len(found) == 0 {
+		return FilterResult{Action: FilterAllow, Content: content}, nil
+	}
+
+	seen := make(map[string]bool)
+	var terms []string
+	for _, m := range found {
+		term := strings.ToLower(string(runes[m[0]:m[1]]))
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	if f.Mode == FilterReject {
+		return FilterResult{Action: FilterReject, Reason: "Message contains words that are not allowed", Terms: terms}, nil
+	}
+	return FilterResult{Action: FilterMask, Content: f.Words.Redact(content), Terms: terms}, nil
+}
+
+// newContentFilter Kind 为 wordlist 时启用词表过滤。词表来自 WordsFile（每行一个词）和 Words（逗号分隔），
+// Mode 为 mask 或 reject，取值已由 config 校验
+func newContentFilter(cfg config.ContentFilterConfig) (ContentFilter, error) {
+	if cfg.Kind != "wordlist" {
+		return passThroughFilter{}, nil
+	}
+
+	var words []string
+	if path := cfg.WordsFile; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		words = parseWordList(string(data))
+	}
+	for _, word := range strings.Split(cfg.Words, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("CONTENT_FILTER=wordlist requires CONTENT_FILTER_WORDS_FILE or CONTENT_FILTER_WORDS")
+	}
+	return &WordListFilter{Words: NewProfanityFilter(words), Mode: cfg.Mode}, nil
+}
+
+// checkContentFilter 执行全局内容过滤：拒绝时返回 422，替换时修改 req.Content 后继续保存和广播。
+// 每次替换或拒绝都计入指标，开启 ContentFilterAudit 时同时写入审计表
+func (s *Server) checkContentFilter(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.Content == "" {
+		return nil
+	}
+	result, err := s.ContentFilter.Filter(ctx, req.Content)
+	if err != nil {
+		return err
+	}
+	metrics.contentFilterDecisions.WithLabelValues(result.Action).Inc()
+	if result.Action == FilterAllow {
+		return nil
+	}
+
+	if s.ContentFilterAudit && s.moderation != nil {
+		err := s.moderation.RecordContentFilterDecision(ctx, store.ContentFilterDecision{
+			UserID:         sender.UserID,
+			RoomID:         req.RoomID,
+			ConversationID: req.ConversationID,
+			Action:         result.Action,
+			MatchedTerms:   result.Terms,
+		})
+		if err != nil {
+			loggerFromContext(ctx).Warn("failed to record content filter decision", "error", err)
+		}
+	}
+
+	if result.Action == FilterReject {
+		return &APIError{Status: http.StatusUnprocessableEntity, Message: result.Reason, Field: "content"}
+	}
+	req.Content = result.Content
+	return nil
+}
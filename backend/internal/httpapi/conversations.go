@@ -0,0 +1,210 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultConversationPageSize = 50
+	maxConversationPageSize     = 100
+)
+
+type CreateConversationRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// conversationForUser 返回 URL 中的会话，当前用户不是参与者时按不存在处理
+func (s *Server) conversationForUser(r *http.Request) (store.Conversation, error) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return store.Conversation{}, apiError(http.StatusBadRequest, "Invalid conversation ID")
+	}
+	conv, err := s.conversations.GetConversation(ctx, id)
+	if err != nil {
+		return store.Conversation{}, err
+	}
+	if !conv.HasParticipant(currentUser(r).UserID) {
+		return store.Conversation{}, store.ErrNotFound
+	}
+	return conv, nil
+}
+
+// visibleMessage 返回用户可以看到的消息及其所属会话（聊天室消息为 nil）。
+// 聊天室消息只对成员可见；私信只对会话参与者可见，其他人看到的结果与消息不存在相同。
+func (s *Server) visibleMessage(ctx context.Context, messageID, userID int) (Message, *store.Conversation, error) {
+	msg, err := s.messages.GetMessage(ctx, messageID)
+	if err != nil {
+		return Message{}, nil, err
+	}
+	conv, err := s.checkMessageAccess(ctx, msg, userID)
+	if err != nil {
+		return Message{}, nil, err
+	}
+	return msg, conv, nil
+}
+
+// checkMessageAccess 检查用户能否看到已加载的消息，返回私信所属的会话
+func (s *Server) checkMessageAccess(ctx context.Context, msg Message, userID int) (*store.Conversation, error) {
+	if msg.ConversationID == nil {
+		return nil, s.requireMember(ctx, msg.RoomID, userID)
+	}
+	conv, err := s.conversations.GetConversation(ctx, *msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !conv.HasParticipant(userID) {
+		return nil, store.ErrNotFound
+	}
+	return &conv, nil
+}
+
+// messageEvent 构造与消息相关的事件：聊天室消息广播给聊天室，私信只发送给会话双方
+func messageEvent(msg Message, conv *store.Conversation, eventType string, data interface{}) ws.Event {
+	if conv != nil {
+		return ws.Event{Type: eventType, Data: data, UserIDs: []int{conv.UserAID, conv.UserBID}}
+	}
+	return ws.Event{Type: eventType, RoomID: msg.RoomID, Data: data}
+}
+
+// createConversation 创建或返回与另一个用户的会话，新建时返回 201
+func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
+	var req CreateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	user := currentUser(r)
+	if req.UserID <= 0 || req.UserID == user.UserID {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "user_id must be another user", Field: "user_id"})
+		return
+	}
+
+	other, err := s.users.GetUser(r.Context(), req.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.checkNotBlocked(r.Context(), user.UserID, other.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	conv, created, err := s.conversations.GetOrCreateConversation(r.Context(), user.UserID, other.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(store.ConversationSummary{
+		Conversation: conv,
+		OtherUser:    store.Participant{ID: other.ID, Username: other.Username},
+	})
+}
+
+func (s *Server) listConversations(w http.ResponseWriter, r *http.Request) {
+	conversations, err := s.conversations.ListConversations(r.Context(), currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+func (s *Server) getConversationMessages(w http.ResponseWriter, r *http.Request) {
+	conv, err := s.conversationForUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, defaultConversationPageSize, maxConversationPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	messages, err := s.conversations.ListConversationMessages(r.Context(), conv.ID, currentUser(r).UserID, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (s *Server) createConversationMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid conversation ID"))
+		return
+	}
+
+	var req CreateMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	req.ConversationID = id
+
+	// 参与者校验在 checkConversation 过滤器中完成
+	msg, _, err := s.saveMessage(r.Context(), currentUser(r), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+func (s *Server) markConversationRead(w http.ResponseWriter, r *http.Request) {
+	conv, err := s.conversationForUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.MessageID <= 0 {
+		writeError(w, r, apiError(http.StatusBadRequest, "message_id is required"))
+		return
+	}
+
+	msg, err := s.messages.GetMessage(r.Context(), req.MessageID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if msg.ConversationID == nil || *msg.ConversationID != conv.ID {
+		writeError(w, r, store.ErrNotFound)
+		return
+	}
+
+	if _, err := s.conversations.MarkConversationRead(r.Context(), currentUser(r).UserID, conv.ID, req.MessageID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
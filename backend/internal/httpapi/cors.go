@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"strings"
+
+	"github.com/rs/cors"
+)
+
+const defaultAllowedMethods = "GET,POST,PUT,DELETE,OPTIONS"
+
+// splitList 按逗号拆分环境变量并去掉空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCORSConfig 根据来源策略和配置生成 CORS 配置。
+// 配置了 * 时按照 CORS 规范强制关闭 AllowCredentials；开发模式下回显任意来源。
+func parseCORSConfig(origins *originPolicy, methods string, allowCredentials bool) cors.Options {
+	opts := cors.Options{
+		AllowedMethods:   splitList(methods),
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{requestIDHeader},
+		AllowCredentials: allowCredentials,
+		AllowOriginFunc:  origins.allowed,
+	}
+
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = splitList(defaultAllowedMethods)
+	}
+	for i, m := range opts.AllowedMethods {
+		opts.AllowedMethods[i] = strings.ToUpper(m)
+	}
+
+	if origins.allowAll && !origins.devMode {
+		opts.AllowCredentials = false
+	}
+
+	return opts
+}
@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"reflect"
+	"testing"
+
+	"chatapp/internal/config"
+)
+
+func TestParseCORSConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         string
+		methods         string
+		credentials     bool
+		devMode         bool
+		allowed         []string
+		denied          []string
+		wantMethods     []string
+		wantCredentials bool
+	}{
+		{
+			name:            "empty allows no origin",
+			denied:          []string{"http://localhost:3000", "https://example.com"},
+			wantMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			credentials:     true,
+			wantCredentials: true,
+		},
+		{
+			name:        "multiple origins",
+			origins:     "https://app.example.com, https://admin.example.com",
+			methods:     "get, post",
+			allowed:     []string{"https://app.example.com", "https://admin.example.com"},
+			denied:      []string{"https://evil.com", "http://app.example.com"},
+			wantMethods: []string{"GET", "POST"},
+		},
+		{
+			name:            "wildcard disables credentials",
+			origins:         "*",
+			credentials:     true,
+			allowed:         []string{"https://anything.test"},
+			wantMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			wantCredentials: false,
+		},
+		{
+			name:            "wildcard among origins disables credentials",
+			origins:         "https://app.example.com,*",
+			credentials:     true,
+			allowed:         []string{"https://app.example.com", "https://other.test"},
+			wantMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			wantCredentials: false,
+		},
+		{
+			name:            "dev mode echoes any origin with credentials",
+			devMode:         true,
+			credentials:     true,
+			allowed:         []string{"http://localhost:5173"},
+			wantMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			wantCredentials: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := parseCORSConfig(newOriginPolicy(tt.origins, tt.devMode), tt.methods, tt.credentials)
+			if !reflect.DeepEqual(opts.AllowedMethods, tt.wantMethods) {
+				t.Errorf("methods = %v, want %v", opts.AllowedMethods, tt.wantMethods)
+			}
+			if opts.AllowCredentials != tt.wantCredentials {
+				t.Errorf("AllowCredentials = %v, want %v", opts.AllowCredentials, tt.wantCredentials)
+			}
+			for _, origin := range tt.allowed {
+				if !opts.AllowOriginFunc(origin) {
+					t.Errorf("origin %s denied", origin)
+				}
+			}
+			for _, origin := range tt.denied {
+				if opts.AllowOriginFunc(origin) {
+					t.Errorf("origin %s allowed", origin)
+				}
+			}
+			if len(opts.ExposedHeaders) != 1 || opts.ExposedHeaders[0] != requestIDHeader {
+				t.Errorf("exposed headers = %v, want %s", opts.ExposedHeaders, requestIDHeader)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := splitList(" a, ,b ,"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("splitList = %v", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Fatalf("splitList(\"\") = %v, want nil", got)
+	}
+}
+
+// TestCORSPreflight 预检请求经过完整的 Handler，只回显允许的来源
+func TestCORSPreflight(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = "https://app.example.com"
+		cfg.CORS.AllowCredentials = true
+	})
+	for origin, want := range map[string]string{
+		"https://app.example.com": "https://app.example.com",
+		"https://evil.com":        "",
+	} {
+		req := ts.request("OPTIONS", "/api/rooms", "", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rec := ts.serve(req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+
+	"chatapp/internal/config"
+)
+
+// EmailSender 发送邮件的接口，便于在测试中替换
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+// SMTPEmailSender 通过 SMTP 服务器发送邮件
+type SMTPEmailSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPEmailSender) SendEmail(to, subject, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.From, to, subject, body)
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{to}, []byte(msg))
+}
+
+// logEmailSender 未配置 SMTP 时只把邮件内容打印到日志（开发环境）
+type logEmailSender struct{}
+
+func (logEmailSender) SendEmail(to, subject, body string) error {
+	slog.Info("email not sent, SMTP is not configured", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// newEmailSender 未设置 SMTP 服务器时只把邮件打印到日志
+func newEmailSender(cfg config.SMTPConfig) EmailSender {
+	if cfg.Host == "" {
+		return logEmailSender{}
+	}
+	return &SMTPEmailSender{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
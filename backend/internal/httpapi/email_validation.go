@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// emailPattern 按 RFC 5321 的大致结构校验：local-part@domain，域名至少包含一个点
+var emailPattern = regexp.MustCompile(`^[A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]{1,64}@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)+$`)
+
+// lookupMX 查询域名的 MX 记录
+var lookupMX = net.DefaultResolver.LookupMX
+
+const mxLookupTimeout = 3 * time.Second
+
+var errInvalidEmail = &APIError{Status: http.StatusUnprocessableEntity, Message: "invalid email address", Field: "email"}
+
+// isValidEmail 校验邮箱格式，checkMX 为 true 时还要求域名有 MX 记录。
+// DNS 临时故障不会导致注册失败，只有确认域名没有 MX 记录时才返回 false。
+func isValidEmail(ctx context.Context, email string, checkMX bool) bool {
+	if len(email) > 254 || !emailPattern.MatchString(email) {
+		return false
+	}
+	local, _, _ := strings.Cut(email, "@")
+	if strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") || strings.Contains(local, "..") {
+		return false
+	}
+	if !checkMX {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mxLookupTimeout)
+	defer cancel()
+	domain := email[strings.LastIndex(email, "@")+1:]
+	records, err := lookupMX(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false
+		}
+		loggerFromContext(ctx).Warn("MX lookup failed, accepting email", "domain", domain, "error", err)
+		return true
+	}
+	return len(records) > 0
+}
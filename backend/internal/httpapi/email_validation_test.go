@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"chatapp/internal/config"
+)
+
+func TestIsValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		ok    bool
+	}{
+		{"alice@example.com", true},
+		{"alice.smith+chat@mail.example.co.uk", true},
+		{"o'brien@example.com", true},
+		{"a@b-c.io", true},
+		{"", false},
+		{"alice", false},
+		{"alice@", false},
+		{"@example.com", false},
+		{"alice@localhost", false},
+		{"alice@@example.com", false},
+		{"alice smith@example.com", false},
+		{".alice@example.com", false},
+		{"alice.@example.com", false},
+		{"al..ice@example.com", false},
+		{"alice@-example.com", false},
+		{"alice@example-.com", false},
+		{"alice@exa_mple.com", false},
+		{strings.Repeat("a", 65) + "@example.com", false},
+		{"alice@" + strings.Repeat("a", 250) + ".com", false},
+	}
+	for _, tt := range tests {
+		if got := isValidEmail(context.Background(), tt.email, false); got != tt.ok {
+			t.Errorf("isValidEmail(%q) = %v, want %v", tt.email, got, tt.ok)
+		}
+	}
+}
+
+// mockLookupMX 在测试期间替换 MX 查询，返回查询过的域名
+func mockLookupMX(t *testing.T, fn func(domain string) ([]*net.MX, error)) *[]string {
+	var domains []string
+	orig := lookupMX
+	lookupMX = func(ctx context.Context, domain string) ([]*net.MX, error) {
+		domains = append(domains, domain)
+		return fn(domain)
+	}
+	t.Cleanup(func() { lookupMX = orig })
+	return &domains
+}
+
+func TestIsValidEmailMX(t *testing.T) {
+	domains := mockLookupMX(t, func(domain string) ([]*net.MX, error) {
+		switch domain {
+		case "example.com":
+			return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil
+		case "nomx.example.com":
+			return nil, nil
+		case "missing.example.com":
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		}
+		return nil, errors.New("i/o timeout")
+	})
+
+	tests := []struct {
+		email string
+		ok    bool
+	}{
+		{"alice@example.com", true},
+		{"alice@nomx.example.com", false},
+		{"alice@missing.example.com", false},
+		// DNS 临时故障时接受
+		{"alice@flaky.example.com", true},
+	}
+	for _, tt := range tests {
+		if got := isValidEmail(context.Background(), tt.email, true); got != tt.ok {
+			t.Errorf("isValidEmail(%q) = %v, want %v", tt.email, got, tt.ok)
+		}
+	}
+
+	// 格式错误时不查询 DNS，不检查 MX 时也不查询
+	*domains = nil
+	isValidEmail(context.Background(), "not-an-email", true)
+	isValidEmail(context.Background(), "alice@nomx.example.com", false)
+	if len(*domains) != 0 {
+		t.Fatalf("looked up %v", *domains)
+	}
+}
+
+func TestRegisterValidatesEmailMX(t *testing.T) {
+	mockLookupMX(t, func(domain string) ([]*net.MX, error) {
+		if domain == "example.com" {
+			return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	})
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.ValidateEmailMX = true })
+
+	var apiErr APIError
+	rec := ts.do("POST", "/api/auth/register", "", RegisterRequest{Username: "bob", Email: "bob@typo.example", Password: testPassword})
+	decodeResponse(t, rec, http.StatusUnprocessableEntity, &apiErr)
+	if apiErr.Field != "email" || apiErr.Message != "invalid email address" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+	decodeResponse(t, ts.do("POST", "/api/auth/register", "", RegisterRequest{Username: "bob", Email: "bob@example.com", Password: testPassword}), http.StatusOK, nil)
+}
@@ -0,0 +1,61 @@
+package httpapi
+
+import "strings"
+
+// reactionShortCodes 允许使用的表情，键为短代码，值为对应的 Unicode 表情。
+// 请求中可以使用任意一种形式，保存时统一转换为 Unicode 表情。
+var reactionShortCodes = map[string]string{
+	":thumbsup:":         "👍",
+	":+1:":               "👍",
+	":thumbsdown:":       "👎",
+	":-1:":               "👎",
+	":heart:":            "❤️",
+	":joy:":              "😂",
+	":smile:":            "😄",
+	":laughing:":         "😆",
+	":wink:":             "😉",
+	":open_mouth:":       "😮",
+	":cry:":              "😢",
+	":angry:":            "😠",
+	":thinking:":         "🤔",
+	":clap:":             "👏",
+	":pray:":             "🙏",
+	":raised_hands:":     "🙌",
+	":ok_hand:":          "👌",
+	":eyes:":             "👀",
+	":fire:":             "🔥",
+	":tada:":             "🎉",
+	":rocket:":           "🚀",
+	":100:":              "💯",
+	":star:":             "⭐",
+	":white_check_mark:": "✅",
+	":x:":                "❌",
+	":pushpin:":          "📌",
+	":wave:":             "👋",
+	":muscle:":           "💪",
+}
+
+// allowedEmoji 允许的 Unicode 表情集合，由 reactionShortCodes 生成
+var allowedEmoji = func() map[string]bool {
+	set := make(map[string]bool, len(reactionShortCodes))
+	for _, emoji := range reactionShortCodes {
+		set[emoji] = true
+	}
+	return set
+}()
+
+// normalizeEmoji 把短代码转换为 Unicode 表情，不在允许列表中时返回 false。
+// 客户端可能省略 ❤️ 末尾的变体选择符（U+FE0F），这里一并兼容。
+func normalizeEmoji(emoji string) (string, bool) {
+	emoji = strings.TrimSpace(emoji)
+	if e, ok := reactionShortCodes[strings.ToLower(emoji)]; ok {
+		return e, true
+	}
+	if allowedEmoji[emoji] {
+		return emoji, true
+	}
+	if allowedEmoji[emoji+"\uFE0F"] {
+		return emoji + "\uFE0F", true
+	}
+	return "", false
+}
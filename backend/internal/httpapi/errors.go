@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/store"
+)
+
+// APIError 所有接口统一的 JSON 错误格式
+type APIError struct {
+	Status         int    `json:"-"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+	Field          string `json:"field,omitempty"`
+	CurrentVersion int    `json:"current_version,omitempty"`
+	// RetryAfterMs 429 响应中需要等待的毫秒数，同时通过 Retry-After 头返回
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+	// RetryAfter 消息速率限制的 429 中需要等待的秒数（可以有小数），同样通过 Retry-After 头返回
+	RetryAfter float64 `json:"retry_after,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func apiError(status int, message string) *APIError {
+	return &APIError{Status: status, Message: message}
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// httpError 把 store 错误及其他任意错误映射为 APIError，未知错误不把细节返回给客户端
+func httpError(err error) *APIError {
+	var apiErr *APIError
+	var fkErr *store.ErrForeignKey
+	var conflictErr *store.ErrConflict
+	var uniqueErr *store.ErrUniqueViolation
+
+	switch {
+	case errors.As(err, &apiErr):
+		e := *apiErr
+		apiErr = &e
+	case errors.Is(err, store.ErrNotFound):
+		apiErr = apiError(http.StatusNotFound, "Resource not found")
+	case errors.As(err, &uniqueErr):
+		apiErr = apiError(http.StatusConflict, "Resource already exists")
+		apiErr.Field = uniqueErr.Field
+	case errors.Is(err, store.ErrDuplicate):
+		apiErr = apiError(http.StatusConflict, "Resource already exists")
+	case errors.Is(err, store.ErrPermission):
+		apiErr = apiError(http.StatusForbidden, "Permission denied")
+	case errors.As(err, &fkErr):
+		apiErr = apiError(http.StatusUnprocessableEntity, fkErr.Error())
+		apiErr.Field = fkErr.Field
+	case errors.Is(err, store.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		apiErr = apiError(http.StatusServiceUnavailable, "Database is temporarily unavailable, please retry")
+	case errors.As(err, &conflictErr):
+		apiErr = apiError(http.StatusConflict, "Resource was modified concurrently")
+		apiErr.CurrentVersion = conflictErr.CurrentVersion
+	default:
+		apiErr = apiError(http.StatusInternalServerError, "Internal server error")
+	}
+
+	if apiErr.Code == "" {
+		apiErr.Code = errorCodes[apiErr.Status]
+	}
+	return apiErr
+}
+
+// writeError 以 JSON 格式返回错误，服务端错误记录到日志
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := httpError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		loggerFromContext(r.Context()).Error("request failed", "error", err)
+	}
+	if apiErr.RetryAfterMs > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt((apiErr.RetryAfterMs+999)/1000, 10))
+	} else if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatFloat(math.Ceil(apiErr.RetryAfter), 'f', 0, 64))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr)
+}
@@ -0,0 +1,184 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chatapp/internal/store"
+)
+
+// storeErrorCases 每种 store 错误及其对应的状态码
+var storeErrorCases = []struct {
+	name   string
+	err    error
+	status int
+}{
+	{"not found", store.ErrNotFound, http.StatusNotFound},
+	{"unique violation", &store.ErrUniqueViolation{Field: "email"}, http.StatusConflict},
+	{"duplicate", store.ErrDuplicate, http.StatusConflict},
+	{"permission", store.ErrPermission, http.StatusForbidden},
+	{"foreign key", &store.ErrForeignKey{Field: "room_id"}, http.StatusUnprocessableEntity},
+	{"timeout", store.ErrTimeout, http.StatusServiceUnavailable},
+	{"conflict", &store.ErrConflict{CurrentVersion: 3}, http.StatusConflict},
+	{"unknown", errors.New("connection reset by peer"), http.StatusInternalServerError},
+}
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		field   string
+		version int
+	}{
+		{"not found", store.ErrNotFound, http.StatusNotFound, "", 0},
+		{"wrapped not found", fmt.Errorf("get room: %w", store.ErrNotFound), http.StatusNotFound, "", 0},
+		{"unique violation", &store.ErrUniqueViolation{Field: "username"}, http.StatusConflict, "username", 0},
+		{"duplicate", store.ErrDuplicate, http.StatusConflict, "", 0},
+		{"permission", store.ErrPermission, http.StatusForbidden, "", 0},
+		{"foreign key", &store.ErrForeignKey{Field: "parent_message_id"}, http.StatusUnprocessableEntity, "parent_message_id", 0},
+		{"timeout", store.ErrTimeout, http.StatusServiceUnavailable, "", 0},
+		{"deadline exceeded", context.DeadlineExceeded, http.StatusServiceUnavailable, "", 0},
+		{"conflict", &store.ErrConflict{CurrentVersion: 7}, http.StatusConflict, "", 7},
+		{"wrapped conflict", fmt.Errorf("update: %w", &store.ErrConflict{CurrentVersion: 2}), http.StatusConflict, "", 2},
+		{"api error", &APIError{Status: http.StatusBadRequest, Message: "bad", Field: "content"}, http.StatusBadRequest, "content", 0},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := httpError(tt.err)
+			if got.Status != tt.status || got.Field != tt.field || got.CurrentVersion != tt.version {
+				t.Fatalf("httpError(%v) = %+v, want status %d field %q version %d", tt.err, got, tt.status, tt.field, tt.version)
+			}
+			if got.Code != errorCodes[tt.status] {
+				t.Fatalf("code = %q, want %q", got.Code, errorCodes[tt.status])
+			}
+		})
+	}
+}
+
+func TestHTTPErrorDoesNotModifyAPIError(t *testing.T) {
+	original := apiError(http.StatusBadRequest, "bad")
+	httpError(original)
+	if original.Code != "" {
+		t.Fatalf("httpError set code %q on the shared error", original.Code)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest("GET", "/", nil), errors.New("pq: password authentication failed"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "password authentication") {
+		t.Fatalf("internal error leaked to the client: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest("GET", "/", nil), &APIError{Status: http.StatusTooManyRequests, Message: "slow down", RetryAfterMs: 1500})
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want 2", got)
+	}
+	rec = httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest("GET", "/", nil), &APIError{Status: http.StatusTooManyRequests, Message: "slow down", RetryAfter: 0.2})
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Fatalf("Retry-After = %q, want 1", got)
+	}
+}
+
+type errRooms struct {
+	store.RoomStore
+	err error
+}
+
+func (f errRooms) GetRoom(context.Context, int) (store.ChatRoom, error) {
+	return store.ChatRoom{}, f.err
+}
+
+func (f errRooms) GetRoomSummary(context.Context, int, int) (store.ChatRoom, error) {
+	return store.ChatRoom{}, f.err
+}
+
+type errMembers struct {
+	store.MembershipStore
+	err error
+}
+
+func (f errMembers) IsRoomMember(context.Context, int, int) (bool, error) {
+	return false, f.err
+}
+
+type errUsers struct {
+	store.UserStore
+	err error
+}
+
+func (f errUsers) GetUser(context.Context, int) (store.User, error) {
+	return store.User{}, f.err
+}
+
+type errMessages struct {
+	store.MessageStore
+	err error
+}
+
+func (f errMessages) GetMessage(context.Context, int) (store.Message, error) {
+	return store.Message{}, f.err
+}
+
+// TestHandlersMapStoreErrors 每个接口在 store 返回各种错误时返回对应的状态码
+func TestHandlersMapStoreErrors(t *testing.T) {
+	endpoints := []struct {
+		name         string
+		method, path string
+		anonymous    bool
+		body         interface{}
+		inject       func(ts *testServer, err error)
+	}{
+		{"get room", "GET", "/api/rooms/1", true, nil, func(ts *testServer, err error) {
+			ts.rooms = errRooms{ts.rooms, err}
+		}},
+		{"get room summary", "GET", "/api/rooms/1", false, nil, func(ts *testServer, err error) {
+			ts.rooms = errRooms{ts.rooms, err}
+		}},
+		{"join room", "POST", "/api/rooms/1/join", false, nil, func(ts *testServer, err error) {
+			ts.rooms = errRooms{ts.rooms, err}
+		}},
+		{"room messages", "GET", "/api/rooms/1/messages", false, nil, func(ts *testServer, err error) {
+			ts.members = errMembers{ts.members, err}
+		}},
+		{"send message", "POST", "/api/messages", false, CreateMessageRequest{RoomID: 1, Content: "hi"}, func(ts *testServer, err error) {
+			ts.rooms = errRooms{ts.rooms, err}
+		}},
+		{"get me", "GET", "/api/users/me", false, nil, func(ts *testServer, err error) {
+			ts.users = errUsers{ts.users, err}
+		}},
+		{"get reactions", "GET", "/api/messages/1/reactions", true, nil, func(ts *testServer, err error) {
+			ts.messages = errMessages{ts.messages, err}
+		}},
+	}
+	for _, e := range endpoints {
+		for _, tt := range storeErrorCases {
+			t.Run(e.name+"/"+tt.name, func(t *testing.T) {
+				ts := newTestServer(t)
+				_, token := ts.addUser("alice")
+				if e.anonymous {
+					token = ""
+				}
+				e.inject(ts, tt.err)
+
+				var apiErr APIError
+				decodeResponse(t, ts.do(e.method, e.path, token, e.body), tt.status, &apiErr)
+				if apiErr.Code != errorCodes[tt.status] {
+					t.Fatalf("code = %q, want %q", apiErr.Code, errorCodes[tt.status])
+				}
+			})
+		}
+	}
+}
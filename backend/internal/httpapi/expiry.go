@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"chatapp/internal/ws"
+)
+
+const (
+	// 阅后即焚消息的有效期为 10 秒到 1 周
+	minMessageTTLSeconds  = 10
+	maxMessageTTLSeconds  = 7 * 24 * 60 * 60
+	messageExpiryInterval = 30 * time.Second
+)
+
+// runMessageExpiry 定期删除到期的消息，ctx 取消后退出
+func (s *Server) runMessageExpiry(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(messageExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.expireMessages(ctx, now)
+			}
+		}
+	}()
+}
+
+// expireMessages 删除在 now 之前到期的消息，并通知客户端从界面上移除
+func (s *Server) expireMessages(ctx context.Context, now time.Time) {
+	expired, err := s.messages.DeleteExpiredMessages(ctx, now)
+	if err != nil {
+		slog.Error("failed to delete expired messages", "error", err)
+		return
+	}
+	for _, msg := range expired {
+		if msg.ConversationID == nil {
+			s.hub.Publish(ws.Event{Type: ws.EventMessageExpired, RoomID: msg.RoomID, Data: msg})
+			continue
+		}
+		conv, err := s.conversations.GetConversation(ctx, *msg.ConversationID)
+		if err != nil {
+			slog.Error("failed to load conversation for expired message", "message_id", msg.ID, "error", err)
+			continue
+		}
+		s.hub.Publish(ws.Event{Type: ws.EventMessageExpired, Data: msg, UserIDs: []int{conv.UserAID, conv.UserBID}})
+	}
+	if len(expired) > 0 {
+		slog.Info("deleted expired messages", "count", len(expired))
+	}
+}
@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// TestExpireMessages 到期的消息被删除并通知聊天室，未到期和永久保存的消息保留
+func TestExpireMessages(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	events := ts.subscribe(alice.ID, room.ID)
+
+	var short, long Message
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "short", TTLSeconds: minMessageTTLSeconds}), http.StatusOK, &short)
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "long", TTLSeconds: 3600}), http.StatusOK, &long)
+	kept := postMessage(t, ts, token, room.ID, "kept")
+	if short.ExpiresAt == nil || !short.ExpiresAt.Equal(short.CreatedAt.Add(minMessageTTLSeconds*time.Second)) {
+		t.Fatalf("expires_at = %v, want created_at + %ds", short.ExpiresAt, minMessageTTLSeconds)
+	}
+	events.drain(t, ts, room.ID)
+
+	ts.expireMessages(context.Background(), *short.ExpiresAt)
+	received := events.drain(t, ts, room.ID)
+	if len(received) != 1 || received[0].Type != ws.EventMessageExpired {
+		t.Fatalf("received %+v, want one message_expired event", received)
+	}
+	if expired := received[0].Data.(store.ExpiredMessage); expired.ID != short.ID || expired.RoomID != room.ID {
+		t.Fatalf("expired = %+v, want message %d in room %d", expired, short.ID, room.ID)
+	}
+	if _, err := ts.store.GetMessage(context.Background(), short.ID); err == nil {
+		t.Fatal("expired message was not deleted")
+	}
+	for _, id := range []int{long.ID, kept} {
+		if _, err := ts.store.GetMessage(context.Background(), id); err != nil {
+			t.Fatalf("message %d was deleted before it expired: %v", id, err)
+		}
+	}
+
+	// 再次清理没有可删除的消息
+	ts.expireMessages(context.Background(), *short.ExpiresAt)
+	if received := events.drain(t, ts, room.ID); len(received) != 0 {
+		t.Fatalf("second cleanup published %+v", received)
+	}
+}
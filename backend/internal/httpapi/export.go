@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// UserDataExport GET /api/users/me/export 返回的 JSON 结构。
+// 数组字段按行从数据库读取并逐条写出，不会在内存中组装完整的结构体
+type UserDataExport struct {
+	ExportedAt     time.Time                `json:"exported_at"`
+	User           store.ExportProfile      `json:"user"`
+	Messages       []store.ExportedMessage  `json:"messages"`
+	DirectMessages []store.ExportedMessage  `json:"direct_messages"`
+	Reactions      []store.ExportedReaction `json:"reactions"`
+	Notifications  []Notification           `json:"notifications"`
+}
+
+// exportUserData 以附件形式返回当前用户的全部个人数据，并记录审计日志。
+// 开始写响应之后出错时中止连接，客户端不会收到不完整但看起来成功的文件
+func (s *Server) exportUserData(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := currentUser(r).UserID
+
+	profile, err := s.export.GetExportProfile(ctx, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.export.RecordUserAudit(ctx, userID, store.UserAuditDataExport); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	loggerFromContext(ctx).Info("user data exported", "user_id", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	ew := &exportWriter{w: w, enc: json.NewEncoder(w)}
+	ew.raw("{")
+	ew.field("exported_at", time.Now().UTC())
+	ew.field("user", profile)
+	ew.array("messages", func(emit func(any) error) error {
+		return s.export.ExportRoomMessages(ctx, userID, func(m store.ExportedMessage) error { return emit(m) })
+	})
+	ew.array("direct_messages", func(emit func(any) error) error {
+		return s.export.ExportDirectMessages(ctx, userID, func(m store.ExportedMessage) error { return emit(m) })
+	})
+	ew.array("reactions", func(emit func(any) error) error {
+		return s.export.ExportReactions(ctx, userID, func(r store.ExportedReaction) error { return emit(r) })
+	})
+	ew.array("notifications", func(emit func(any) error) error {
+		return s.export.ExportNotifications(ctx, userID, func(n Notification) error { return emit(n) })
+	})
+	ew.raw("}\n")
+
+	if ew.err != nil {
+		if ctx.Err() == nil {
+			loggerFromContext(ctx).Error("user data export failed", "user_id", userID, "error", ew.err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportWriter 手动写出 JSON 对象的框架，每个值用 json.Encoder 单独编码。
+// 出错后后续调用都不再写入，错误保存在 err 中
+type exportWriter struct {
+	w      io.Writer
+	enc    *json.Encoder
+	fields int
+	err    error
+}
+
+func (ew *exportWriter) raw(s string) {
+	if ew.err == nil {
+		_, ew.err = io.WriteString(ew.w, s)
+	}
+}
+
+func (ew *exportWriter) key(name string) {
+	if ew.fields > 0 {
+		ew.raw(",")
+	}
+	ew.fields++
+	ew.raw(`"` + name + `":`)
+}
+
+func (ew *exportWriter) field(name string, v any) {
+	ew.key(name)
+	if ew.err == nil {
+		ew.err = ew.enc.Encode(v)
+	}
+}
+
+// array 写出一个数组字段，rows 每读到一行调用一次 emit
+func (ew *exportWriter) array(name string, rows func(emit func(any) error) error) {
+	ew.key(name)
+	ew.raw("[")
+	if ew.err != nil {
+		return
+	}
+	n := 0
+	err := rows(func(v any) error {
+		if n > 0 {
+			if _, err := io.WriteString(ew.w, ","); err != nil {
+				return err
+			}
+		}
+		n++
+		return ew.enc.Encode(v)
+	})
+	if err != nil {
+		ew.err = err
+		return
+	}
+	ew.raw("]")
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test ./internal/httpapi -run TestGoldenResponses -update 重新生成 testdata/golden 下的响应
+var updateGolden = flag.Bool("update", false, "rewrite golden response files")
+
+// goldenDynamicFields 每次运行都会变化的字段，比较前替换为固定值
+var goldenDynamicFields = map[string]bool{
+	"token":        true,
+	"created_at":   true,
+	"updated_at":   true,
+	"last_seen_at": true,
+	"edited_at":    true,
+	"request_id":   true,
+}
+
+// TestGoldenResponses 拆分之前就存在的接口的状态码和 JSON 响应必须保持不变
+func TestGoldenResponses(t *testing.T) {
+	ts := newTestServer(t)
+	alice, _ := ts.addUser("alice")
+	room := ts.store.AddRoom("general", "General chat", &alice.ID)
+
+	var registered AuthResponse
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  func() string
+		body   interface{}
+	}{
+		{"register", "POST", "/api/auth/register", nil, RegisterRequest{Username: "bob", Email: "bob@example.com", Password: testPassword}},
+		{"register_duplicate", "POST", "/api/auth/register", nil, RegisterRequest{Username: "bob", Email: "bob@example.com", Password: testPassword}},
+		{"login", "POST", "/api/auth/login", nil, LoginRequest{Email: "alice@example.com", Password: testPassword}},
+		{"login_wrong_password", "POST", "/api/auth/login", nil, LoginRequest{Email: "alice@example.com", Password: "wrong-password"}},
+		{"rooms", "GET", "/api/rooms", nil, nil},
+		{"join", "POST", fmt.Sprintf("/api/rooms/%d/join", room.ID), func() string { return registered.Token }, nil},
+		{"create_message", "POST", "/api/messages", func() string { return registered.Token }, CreateMessageRequest{RoomID: room.ID, Content: "hello"}},
+		{"create_message_unauthenticated", "POST", "/api/messages", nil, CreateMessageRequest{RoomID: room.ID, Content: "hello"}},
+		{"room_messages", "GET", fmt.Sprintf("/api/rooms/%d/messages", room.ID), func() string { return registered.Token }, nil},
+		{"room_messages_invalid_id", "GET", "/api/rooms/abc/messages", func() string { return registered.Token }, nil},
+	}
+	for _, step := range steps {
+		token := ""
+		if step.token != nil {
+			token = step.token()
+		}
+		rec := ts.do(step.method, step.path, token, step.body)
+		if step.name == "register" {
+			decodeResponse(t, rec, rec.Code, &registered)
+		}
+		got := fmt.Sprintf("%d\n%s", rec.Code, normalizeGolden(t, rec.Body.Bytes()))
+
+		path := filepath.Join("testdata", "golden", step.name+".json")
+		if *updateGolden {
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v (run with -update to create it)", step.name, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: response changed\ngot:\n%s\nwant:\n%s", step.name, got, want)
+		}
+	}
+}
+
+// normalizeGolden 把动态字段替换为固定值并重新缩进，空响应体原样返回
+func normalizeGolden(t *testing.T, body []byte) string {
+	t.Helper()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("decode response %q: %v", body, err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(replaceDynamic(v)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func replaceDynamic(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if goldenDynamicFields[key] && value != nil {
+				v[key] = "<" + key + ">"
+				continue
+			}
+			v[key] = replaceDynamic(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = replaceDynamic(v[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,185 @@
+package httpapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthCheckTimeout = time.Second
+	// GET /api/health 中数据库检查的超时时间
+	healthDBTimeout = 2 * time.Second
+	// 就绪检查结果缓存的时间，避免频繁的探测请求压垮数据库
+	readinessCacheTTL = 2 * time.Second
+)
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReadinessResponse GET /api/health/ready 的响应
+type ReadinessResponse struct {
+	Status    string                      `json:"status"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+	CheckedAt time.Time                   `json:"checked_at"`
+}
+
+// HealthStatus GET /api/health 的响应，任一检查失败时 Status 为 unavailable 并返回 503。
+// 未配置数据库或 Broker 时对应的字段为空。
+type HealthStatus struct {
+	Status string            `json:"status"`
+	DB     *DependencyStatus `json:"db,omitempty"`
+	Broker *DependencyStatus `json:"broker,omitempty"`
+	// SchemaVersion 最后执行的数据库迁移
+	SchemaVersion string `json:"schema_version,omitempty"`
+	// WSConnections 本实例当前的 WebSocket 连接数
+	WSConnections int            `json:"ws_connections"`
+	Uptime        string         `json:"uptime"`
+	WSAdmission   AdmissionStats `json:"ws_admission"`
+	DBStats       *DBStats       `json:"db_stats,omitempty"`
+}
+
+// pinger 可以检查连通性的依赖，例如 RedisBroker
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readinessCache 缓存最近一次就绪检查的结果
+type readinessCache struct {
+	mu     sync.Mutex
+	result *ReadinessResponse
+}
+
+// healthCheck 检查数据库连接、迁移版本和 Broker，并返回连接数等运行信息
+func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	resp := HealthStatus{
+		Status:        "ok",
+		WSConnections: s.hub.ConnectionCount(),
+		Uptime:        time.Since(s.startedAt).Round(time.Second).String(),
+		WSAdmission:   s.admission.stats(),
+	}
+	if s.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), healthDBTimeout)
+		defer cancel()
+
+		start := time.Now()
+		db := DependencyStatus{Status: "ok"}
+		err := s.db.PingContext(ctx)
+		if err == nil {
+			err = s.db.QueryRowContext(ctx,
+				"SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1",
+			).Scan(&resp.SchemaVersion)
+		}
+		db.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			db.Status = "unavailable"
+			db.Error = err.Error()
+			resp.Status = "unavailable"
+		}
+		resp.DB = &db
+
+		stats := dbStats(s.db)
+		resp.DBStats = &stats
+	}
+	if p, ok := s.hub.Broker.(pinger); ok {
+		broker := checkDependency(r.Context(), p.Ping)
+		if broker.Status != "ok" {
+			resp.Status = "unavailable"
+		}
+		resp.Broker = &broker
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// liveness 只表示进程仍在运行，不检查任何依赖
+func (s *Server) liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readiness 检查数据库和 Broker（如果配置了），任一不可用时返回 503
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	result := s.checkReadiness(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) checkReadiness(ctx context.Context) ReadinessResponse {
+	s.readyCache.mu.Lock()
+	defer s.readyCache.mu.Unlock()
+	if cached := s.readyCache.result; cached != nil && time.Since(cached.CheckedAt) < readinessCacheTTL {
+		return *cached
+	}
+
+	result := ReadinessResponse{Status: "ok", Checks: make(map[string]DependencyStatus), CheckedAt: time.Now()}
+	if s.db != nil {
+		result.Checks["database"] = checkDependency(ctx, s.db.PingContext)
+	}
+	if p, ok := s.hub.Broker.(pinger); ok {
+		result.Checks["broker"] = checkDependency(ctx, p.Ping)
+	}
+	for _, check := range result.Checks {
+		if check.Status != "ok" {
+			result.Status = "unavailable"
+		}
+	}
+
+	s.readyCache.result = &result
+	return result
+}
+
+func checkDependency(ctx context.Context, ping func(context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	status := DependencyStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = "unavailable"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// DBStats 健康检查中返回的连接池状态
+type DBStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+func dbStats(db *sql.DB) DBStats {
+	s := db.Stats()
+	return DBStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration.String(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// TestHealthDBStats 配置了数据库时健康检查返回迁移版本和连接池状态
+func TestHealthDBStats(t *testing.T) {
+	ts := newTestServer(t)
+	ts.db = newFakeDB(t, &fakeDB{columns: []string{"version"}, row: []driver.Value{"039"}})
+	ts.db.SetMaxOpenConns(7)
+
+	var resp HealthStatus
+	decodeResponse(t, ts.do("GET", "/api/health", "", nil), http.StatusOK, &resp)
+	if resp.DB == nil || resp.DB.Status != "ok" || resp.SchemaVersion != "039" {
+		t.Fatalf("db = %+v, schema version %q, want ok and 039", resp.DB, resp.SchemaVersion)
+	}
+	if resp.DBStats == nil {
+		t.Fatal("db_stats is missing")
+	}
+	if resp.DBStats.MaxOpenConnections != 7 || resp.DBStats.OpenConnections != 1 || resp.DBStats.Idle != 1 {
+		t.Fatalf("db_stats = %+v, want max 7 with one idle connection", resp.DBStats)
+	}
+}
+
+// TestHealthWithoutDB 没有数据库时不返回数据库相关的字段
+func TestHealthWithoutDB(t *testing.T) {
+	ts := newTestServer(t)
+
+	var resp map[string]interface{}
+	decodeResponse(t, ts.do("GET", "/api/health", "", nil), http.StatusOK, &resp)
+	for _, key := range []string{"db", "db_stats", "schema_version"} {
+		if _, ok := resp[key]; ok {
+			t.Errorf("response has %q without a database", key)
+		}
+	}
+}
+
+// TestHealthBrokenDB 数据库不可用或没有迁移记录时返回 503 和结构化的检查结果
+func TestHealthBrokenDB(t *testing.T) {
+	tests := []struct {
+		name string
+		db   *fakeDB
+	}{
+		{"connection refused", &fakeDB{err: errors.New("connection refused")}},
+		{"no migrations", &fakeDB{columns: []string{"version"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.db = newFakeDB(t, tt.db)
+
+			var resp HealthStatus
+			decodeResponse(t, ts.do("GET", "/api/health", "", nil), http.StatusServiceUnavailable, &resp)
+			if resp.Status != "unavailable" || resp.DB == nil || resp.DB.Status != "unavailable" || resp.DB.Error == "" {
+				t.Fatalf("health = %+v, db %+v, want the database reported unavailable", resp, resp.DB)
+			}
+			if resp.SchemaVersion != "" || resp.Uptime == "" {
+				t.Fatalf("health = %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+const (
+	// 客户端在 24 小时内用相同的 client_msg_id 重试都返回同一条消息，之后幂等键被清除
+	clientMsgIDTTL             = 24 * time.Hour
+	clientMsgIDCleanupInterval = time.Hour
+)
+
+var errInvalidClientMsgID = &APIError{Status: http.StatusBadRequest, Message: "client_msg_id must be a UUID", Field: "client_msg_id"}
+
+func normalizeClientMsgID(id string) (string, error) {
+	id, ok := normalizeUUID(id)
+	if !ok {
+		return "", errInvalidClientMsgID
+	}
+	return id, nil
+}
+
+// normalizeUUID 检查 UUID 格式（8-4-4-4-12 个十六进制字符）并转换为小写
+func normalizeUUID(id string) (string, bool) {
+	id = strings.ToLower(id)
+	if len(id) != 36 {
+		return "", false
+	}
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return "", false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return "", false
+			}
+		}
+	}
+	return id, true
+}
+
+// previousMessage 返回发送者之前用相同 client_msg_id 保存的消息，没有时返回 store.ErrNotFound
+func (s *Server) previousMessage(ctx context.Context, sender *Claims, clientMsgID string) (Message, error) {
+	if clientMsgID == "" {
+		return Message{}, store.ErrNotFound
+	}
+	return s.messages.GetMessageByClientID(ctx, sender.UserID, clientMsgID)
+}
+
+// isClientMsgIDConflict 判断 InsertMessage 是否因为并发的重试而失败
+func isClientMsgIDConflict(err error) bool {
+	var uniqueErr *store.ErrUniqueViolation
+	return errors.As(err, &uniqueErr) && uniqueErr.Field == "client_msg_id"
+}
+
+// runClientMsgIDCleanup 定期清除过期的幂等键，ctx 取消后退出
+func (s *Server) runClientMsgIDCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(clientMsgIDCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.messages.ExpireClientMessageIDs(ctx, time.Now().Add(-clientMsgIDTTL))
+				if err != nil {
+					slog.Error("failed to expire client message IDs", "error", err)
+				} else if n > 0 {
+					slog.Info("expired client message IDs", "count", n)
+				}
+			}
+		}
+	}()
+}
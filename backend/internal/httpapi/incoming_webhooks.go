@@ -0,0 +1,312 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxIncomingWebhookName = 50
+	// 每个入站 webhook 平均每秒最多发送 1 条消息，允许短时间内连续发送 10 条
+	incomingWebhookRate  = 1
+	incomingWebhookBurst = 10
+	// 每个聊天室的共享密钥每分钟最多发送 30 条消息
+	roomWebhookRate  = 30.0 / 60
+	roomWebhookBurst = 10
+	// roomWebhookSecretHeader 聊天室共享密钥，由 rotate-secret 生成
+	roomWebhookSecretHeader = "X-Webhook-Secret"
+	defaultRoomWebhookName  = "Bot"
+)
+
+type IncomingWebhook = store.IncomingWebhook
+
+type CreateIncomingWebhookRequest struct {
+	Name string `json:"name"`
+}
+
+// IncomingWebhookMessage POST /api/webhooks/incoming/{token} 的请求体，
+// DisplayName 为空时使用 webhook 的名称
+type IncomingWebhookMessage struct {
+	Content     string `json:"content"`
+	DisplayName string `json:"display_name"`
+}
+
+// RoomWebhookMessage POST /api/rooms/{id}/webhook-incoming 的请求体，Username 为空时使用 Bot
+type RoomWebhookMessage struct {
+	Content  string `json:"content"`
+	Username string `json:"username"`
+}
+
+// RoomWebhookSecretResponse 轮换共享密钥的响应，密钥只在这里返回一次
+type RoomWebhookSecretResponse struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+// IncomingWebhookResponse 创建入站 webhook 的响应，URL 是发送消息的地址
+type IncomingWebhookResponse struct {
+	IncomingWebhook
+	URL string `json:"url"`
+}
+
+// webhookLimiter 按入站 webhook（或聊天室共享密钥）分别限流
+type webhookLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[int]*tokenBucket
+}
+
+func newWebhookLimiter(rate float64, burst int) *webhookLimiter {
+	return &webhookLimiter{rate: rate, burst: burst, buckets: make(map[int]*tokenBucket)}
+}
+
+func (l *webhookLimiter) take(id int) (bool, time.Duration) {
+	l.mu.Lock()
+	bucket, ok := l.buckets[id]
+	if !ok {
+		bucket = newTokenBucket(l.rate, l.burst)
+		l.buckets[id] = bucket
+	}
+	l.mu.Unlock()
+	return bucket.take()
+}
+
+func validateWebhookName(name string, field string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxIncomingWebhookName {
+		return "", &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("%s must be between 1 and %d characters", field, maxIncomingWebhookName),
+			Field:   field,
+		}
+	}
+	return name, nil
+}
+
+func (s *Server) listIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	webhooks, err := s.webhooks.ListIncomingWebhooks(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// createIncomingWebhook 生成令牌并在响应中返回，数据库只保存哈希，之后无法再查看
+func (s *Server) createIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CreateIncomingWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	name, err := validateWebhookName(req.Name, "name")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, tokenHash, err := generateResetToken()
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+	webhook := IncomingWebhook{RoomID: roomID, Name: name}
+	if err := s.webhooks.CreateIncomingWebhook(r.Context(), &webhook, tokenHash, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	webhook.Token = token
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(IncomingWebhookResponse{IncomingWebhook: webhook, URL: "/api/webhooks/incoming/" + token})
+}
+
+// revokeIncomingWebhook 撤销后令牌立即失效，已经发送的消息保留
+func (s *Server) revokeIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := webhookIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.webhooks.RevokeIncomingWebhook(r.Context(), roomID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postIncomingWebhook 外部服务凭令牌向聊天室发送消息，令牌即认证，不需要用户账号
+func (s *Server) postIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := s.webhooks.GetIncomingWebhookByToken(r.Context(), hashResetToken(mux.Vars(r)["token"]))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if ok, wait := s.webhookLimits.take(webhook.ID); !ok {
+		writeError(w, r, &APIError{
+			Status:       http.StatusTooManyRequests,
+			Message:      "Too many messages from this webhook",
+			RetryAfterMs: wait.Milliseconds(),
+		})
+		return
+	}
+
+	var req IncomingWebhookMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	content, err := sanitizeContent(req.Content, s.MaxMessageLength)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	senderName := webhook.Name
+	if strings.TrimSpace(req.DisplayName) != "" {
+		if senderName, err = validateWebhookName(req.DisplayName, "display_name"); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	msg := Message{
+		RoomID:            webhook.RoomID,
+		Username:          senderName,
+		DisplayName:       senderName,
+		Content:           content,
+		IncomingWebhookID: &webhook.ID,
+		MessageType:       store.MessageTypeUser,
+	}
+	if err := s.messages.InsertMessage(r.Context(), &msg); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.publishMessage(r.Context(), msg, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// rotateRoomWebhookSecret 生成新的聊天室共享密钥并在响应中返回，旧密钥立即失效
+func (s *Server) rotateRoomWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	secret, secretHash, err := generateResetToken()
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate secret"))
+		return
+	}
+	if err := s.webhooks.SetRoomWebhookSecretHash(r.Context(), roomID, secretHash); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomWebhookSecretResponse{
+		Secret: secret,
+		URL:    fmt.Sprintf("/api/rooms/%d/webhook-incoming", roomID),
+	})
+}
+
+// postRoomWebhook 外部服务凭 X-Webhook-Secret 头中的聊天室共享密钥发送消息，不需要用户账号
+func (s *Server) postRoomWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 没有设置密钥与密钥错误返回同样的响应，不暴露聊天室是否启用了 webhook
+	secret := r.Header.Get(roomWebhookSecretHeader)
+	secretHash, err := s.webhooks.GetRoomWebhookSecretHash(r.Context(), roomID)
+	if errors.Is(err, store.ErrNotFound) || secret == "" ||
+		subtle.ConstantTimeCompare([]byte(secretHash), []byte(hashResetToken(secret))) != 1 {
+		writeError(w, r, apiError(http.StatusUnauthorized, "Invalid webhook secret"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if ok, wait := s.roomWebhookLimits.take(roomID); !ok {
+		writeError(w, r, &APIError{
+			Status:       http.StatusTooManyRequests,
+			Message:      "Too many messages from this webhook",
+			RetryAfterMs: wait.Milliseconds(),
+		})
+		return
+	}
+
+	var req RoomWebhookMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	content, err := sanitizeContent(req.Content, s.MaxMessageLength)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	senderName := defaultRoomWebhookName
+	if strings.TrimSpace(req.Username) != "" {
+		if senderName, err = validateWebhookName(req.Username, "username"); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	msg := Message{
+		RoomID:      roomID,
+		Username:    senderName,
+		DisplayName: senderName,
+		Content:     content,
+		MessageType: store.MessageTypeUser,
+	}
+	if err := s.messages.InsertMessage(r.Context(), &msg); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.publishMessage(r.Context(), msg, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// roomSecrets 只保存聊天室共享密钥的 WebhookStore，其他方法不会被调用
+type roomSecrets struct {
+	store.WebhookStore
+	mu     sync.Mutex
+	hashes map[int]string
+}
+
+func (r *roomSecrets) GetRoomWebhookSecretHash(ctx context.Context, roomID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hash, ok := r.hashes[roomID]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return hash, nil
+}
+
+func (r *roomSecrets) SetRoomWebhookSecretHash(ctx context.Context, roomID int, secretHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hashes[roomID] = secretHash
+	return nil
+}
+
+// roomWebhookFixture 创建聊天室并轮换出共享密钥，返回聊天室、owner 的 token 和密钥
+func roomWebhookFixture(t *testing.T, ts *testServer) (store.ChatRoom, string, string) {
+	t.Helper()
+	ts.webhooks = &roomSecrets{hashes: make(map[int]string)}
+	owner, token := ts.addUser("owner")
+	room := ts.store.AddRoom("alerts", "", &owner.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, owner.ID)
+
+	var resp RoomWebhookSecretResponse
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/rooms/%d/webhook-incoming/rotate-secret", room.ID), token, nil), http.StatusOK, &resp)
+	if resp.Secret == "" || resp.URL != fmt.Sprintf("/api/rooms/%d/webhook-incoming", room.ID) {
+		t.Fatalf("rotate response = %+v", resp)
+	}
+	return room, token, resp.Secret
+}
+
+// postRoomWebhookMessage 以 secret 向聊天室的共享密钥 webhook 发送消息
+func postRoomWebhookMessage(ts *testServer, roomID int, secret string, msg RoomWebhookMessage) *httptest.ResponseRecorder {
+	req := ts.request("POST", fmt.Sprintf("/api/rooms/%d/webhook-incoming", roomID), "", msg)
+	if secret != "" {
+		req.Header.Set(roomWebhookSecretHeader, secret)
+	}
+	return ts.serve(req)
+}
+
+// TestRoomWebhookSecret 密钥缺失、错误或已经轮换掉时返回 401，正确的密钥以 Bot 的名义发送消息并广播
+func TestRoomWebhookSecret(t *testing.T) {
+	ts := newTestServer(t)
+	room, token, secret := roomWebhookFixture(t, ts)
+	events := ts.subscribe(0, room.ID)
+
+	for name, s := range map[string]string{"missing": "", "wrong": secret + "x"} {
+		rec := postRoomWebhookMessage(ts, room.ID, s, RoomWebhookMessage{Content: "deploy finished"})
+		var apiErr APIError
+		decodeResponse(t, rec, http.StatusUnauthorized, &apiErr)
+		if apiErr.Message != "Invalid webhook secret" {
+			t.Fatalf("%s secret: error = %+v", name, apiErr)
+		}
+	}
+	// 没有设置过密钥的聊天室返回同样的错误
+	other := ts.store.AddRoom("other", "", nil)
+	decodeResponse(t, postRoomWebhookMessage(ts, other.ID, secret, RoomWebhookMessage{Content: "hi"}), http.StatusUnauthorized, nil)
+
+	var msg Message
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "deploy finished"}), http.StatusOK, &msg)
+	if msg.Username != defaultRoomWebhookName || msg.Content != "deploy finished" || msg.UserID != 0 {
+		t.Fatalf("message = %+v, want a bot message", msg)
+	}
+	var named Message
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "build failed", Username: "CI"}), http.StatusOK, &named)
+	if named.Username != "CI" {
+		t.Fatalf("username = %q, want CI", named.Username)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventMessage); got != 2 {
+		t.Fatalf("broadcast %d messages, want 2", got)
+	}
+
+	// 轮换之后旧密钥立即失效，只有 owner 可以轮换
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/rooms/%d/webhook-incoming/rotate-secret", room.ID), token, nil), http.StatusOK, nil)
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "hi"}), http.StatusUnauthorized, nil)
+	_, memberToken := ts.addUser("member")
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/rooms/%d/webhook-incoming/rotate-secret", room.ID), memberToken, nil), http.StatusForbidden, nil)
+}
+
+// TestRoomWebhookRateLimit 每个聊天室的共享密钥每分钟最多 30 条消息，允许连续发送 roomWebhookBurst 条，
+// 超出时返回 429 和等待时间，令牌补充后恢复
+func TestRoomWebhookRateLimit(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, secret := roomWebhookFixture(t, ts)
+
+	for i := 0; i < roomWebhookBurst; i++ {
+		decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: fmt.Sprintf("alert %d", i)}), http.StatusOK, nil)
+	}
+	now := time.Now()
+	ts.roomWebhookLimits.buckets[room.ID].now = func() time.Time { return now }
+
+	var apiErr APIError
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "one too many"}), http.StatusTooManyRequests, &apiErr)
+	// 每 2 秒补充一个令牌
+	if apiErr.RetryAfterMs <= 0 || apiErr.RetryAfterMs > 2000 {
+		t.Fatalf("retry_after_ms = %d, want at most 2000", apiErr.RetryAfterMs)
+	}
+	// 错误的密钥不消耗令牌，也不会被限流掩盖
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, "wrong", RoomWebhookMessage{Content: "hi"}), http.StatusUnauthorized, nil)
+
+	now = now.Add(2 * time.Second)
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "after waiting"}), http.StatusOK, nil)
+	decodeResponse(t, postRoomWebhookMessage(ts, room.ID, secret, RoomWebhookMessage{Content: "again"}), http.StatusTooManyRequests, nil)
+}
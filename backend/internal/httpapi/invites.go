@@ -0,0 +1,197 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateInviteRequest POST /api/rooms/{id}/invites 的请求体，两个字段都为空时邀请不限次数、不过期
+type CreateInviteRequest struct {
+	MaxUses   *int       `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// InviteResponse 邀请及可以分享的加入链接
+type InviteResponse struct {
+	store.RoomInvite
+	InviteURL string `json:"invite_url"`
+}
+
+// AcceptInviteResponse Joined 为 false 表示用户之前已经是成员，没有消耗使用次数
+type AcceptInviteResponse struct {
+	Room   ChatRoom `json:"room"`
+	Joined bool     `json:"joined"`
+}
+
+var errInvalidInvite = apiError(http.StatusNotFound, "Invite not found")
+
+func (s *Server) inviteResponse(inv store.RoomInvite) InviteResponse {
+	return InviteResponse{RoomInvite: inv, InviteURL: s.AppURL + "/join/" + inv.ID}
+}
+
+// inviteIDFromRequest 邀请 ID 格式不正确时同样返回 404，不把它交给数据库
+func inviteIDFromRequest(r *http.Request) (string, error) {
+	id, ok := normalizeUUID(mux.Vars(r)["id"])
+	if !ok {
+		return "", errInvalidInvite
+	}
+	return id, nil
+}
+
+// inviteError 把邀请无法使用的原因转换为 410，其他错误保持原样
+func inviteError(err error) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return errInvalidInvite
+	case errors.Is(err, store.ErrInviteDisabled):
+		return apiError(http.StatusGone, "This invite has been disabled")
+	case errors.Is(err, store.ErrInviteExpired):
+		return apiError(http.StatusGone, "This invite has expired")
+	case errors.Is(err, store.ErrInviteExhausted):
+		return apiError(http.StatusGone, "This invite has reached its maximum number of uses")
+	}
+	return err
+}
+
+// createInvite 聊天室 owner 或 moderator 创建邀请链接
+func (s *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.MaxUses != nil && *req.MaxUses < 1 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "max_uses must be at least 1", Field: "max_uses"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "expires_at must be in the future", Field: "expires_at"})
+		return
+	}
+
+	user := currentUser(r)
+	if _, err := s.requireModerator(r.Context(), roomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	inv := store.RoomInvite{RoomID: roomID, CreatedBy: &user.UserID, MaxUses: req.MaxUses}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		inv.ExpiresAt = &expiresAt
+	}
+	if err := s.invites.CreateInvite(r.Context(), &inv); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.inviteResponse(inv))
+}
+
+// listInvites 返回聊天室中仍然可以使用的邀请
+func (s *Server) listInvites(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := s.requireModerator(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	invites, err := s.invites.ListActiveInvites(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := make([]InviteResponse, 0, len(invites))
+	for _, inv := range invites {
+		resp = append(resp, s.inviteResponse(inv))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]InviteResponse{"invites": resp})
+}
+
+// acceptInvite 通过邀请加入聊天室，已经是成员时直接返回聊天室
+func (s *Server) acceptInvite(w http.ResponseWriter, r *http.Request) {
+	id, err := inviteIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user := currentUser(r)
+
+	inv, err := s.invites.GetInvite(r.Context(), id)
+	if err != nil {
+		writeError(w, r, inviteError(err))
+		return
+	}
+	banned, err := s.moderation.IsBanned(r.Context(), inv.RoomID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if banned {
+		writeError(w, r, errBanned)
+		return
+	}
+
+	roomID, joined, err := s.invites.AcceptInvite(r.Context(), id, user.UserID)
+	if err != nil {
+		writeError(w, r, inviteError(err))
+		return
+	}
+	if joined {
+		s.announceJoin(r.Context(), roomID, user)
+	}
+
+	room, err := s.rooms.GetRoom(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AcceptInviteResponse{Room: room, Joined: joined})
+}
+
+// disableInvite 聊天室 owner 或 moderator 停用邀请，重复停用没有副作用
+func (s *Server) disableInvite(w http.ResponseWriter, r *http.Request) {
+	id, err := inviteIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	inv, err := s.invites.GetInvite(r.Context(), id)
+	if err != nil {
+		writeError(w, r, inviteError(err))
+		return
+	}
+	if _, err := s.requireModerator(r.Context(), inv.RoomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.invites.DisableInvite(r.Context(), id); err != nil {
+		writeError(w, r, inviteError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
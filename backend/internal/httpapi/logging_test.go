@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// TestRequestID 客户端提供的 UUID 原样返回（统一为小写），缺失或格式错误时生成新的 UUID，
+// 同一个请求 ID 出现在响应头和请求日志中
+func TestRequestID(t *testing.T) {
+	const provided = "3f2b8c1e-9a4d-4e6f-8b7a-1c2d3e4f5a6b"
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"provided", provided, provided},
+		{"uppercase", "3F2B8C1E-9A4D-4E6F-8B7A-1C2D3E4F5A6B", provided},
+		{"missing", "", ""},
+		{"not a uuid", "request-1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			ts := newTestServer(t)
+			req := ts.request("GET", "/api/rooms", "", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rec := ts.serve(req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if tt.want != "" {
+				if got != tt.want {
+					t.Fatalf("X-Request-ID = %q, want %q", got, tt.want)
+				}
+			} else if id, ok := normalizeUUID(got); !ok || id != got || got == tt.header {
+				t.Fatalf("X-Request-ID = %q, want a new UUID", got)
+			}
+
+			var entry struct {
+				Msg       string `json:"msg"`
+				RequestID string `json:"request_id"`
+			}
+			found := false
+			scanner := bufio.NewScanner(logs)
+			for scanner.Scan() {
+				if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Msg == "http request" {
+					found = true
+					break
+				}
+			}
+			if !found || entry.RequestID != got {
+				t.Fatalf("request log has request_id %q, want %q", entry.RequestID, got)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+		})
+	}
+}
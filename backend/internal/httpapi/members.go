@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+const (
+	defaultMembersPageSize = 50
+	maxMembersPageSize     = 200
+)
+
+// MemberEvent 成员加入或离开聊天室时广播
+type MemberEvent struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+var (
+	errNotMember = apiError(http.StatusForbidden, "You are not a member of this room")
+	errBanned    = apiError(http.StatusForbidden, "You are banned from this room")
+)
+
+// requireMember 只有聊天室成员可以读取和发送消息
+func (s *Server) requireMember(ctx context.Context, roomID, userID int) error {
+	if userID == 0 {
+		return apiError(http.StatusUnauthorized, "Authentication required")
+	}
+	member, err := s.members.IsRoomMember(ctx, roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		banned, err := s.moderation.IsBanned(ctx, roomID, userID)
+		if err != nil {
+			return err
+		}
+		if banned {
+			return errBanned
+		}
+		return errNotMember
+	}
+	return nil
+}
+
+var errRoomNotFound = &APIError{Status: http.StatusNotFound, Message: "Room not found", Field: "room_id"}
+
+// checkMembership 发送到聊天室的消息要求聊天室存在且发送者是成员
+func (s *Server) checkMembership(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.ConversationID != 0 {
+		return nil
+	}
+	room, err := s.rooms.GetRoom(ctx, req.RoomID)
+	if errors.Is(err, store.ErrNotFound) {
+		return errRoomNotFound
+	}
+	if err != nil {
+		return err
+	}
+	req.room = &room
+	return s.requireMember(ctx, req.RoomID, sender.UserID)
+}
+
+// joinRoom 加入聊天室，重复加入没有副作用
+func (s *Server) joinRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := s.rooms.GetRoom(r.Context(), roomID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user := currentUser(r)
+	banned, err := s.moderation.IsBanned(r.Context(), roomID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if banned {
+		writeError(w, r, errBanned)
+		return
+	}
+
+	joined, err := s.members.JoinRoom(r.Context(), roomID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if joined {
+		s.announceJoin(r.Context(), roomID, user)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// announceJoin 用户加入聊天室后更新其连接的订阅范围，通知房间内的成员并记录系统消息
+func (s *Server) announceJoin(ctx context.Context, roomID int, user *Claims) {
+	s.hub.SetMembership(user.UserID, roomID, true)
+	s.hub.Publish(ws.Event{Type: ws.EventMemberJoined, RoomID: roomID, Data: MemberEvent{
+		UserID:   user.UserID,
+		Username: user.Username,
+	}})
+	s.postSystemMessage(ctx, roomID, SystemMessageMetadata{Event: systemEventMemberJoined, ActorID: user.UserID, Actor: user.Username})
+}
+
+// leaveRoom 离开聊天室，不是成员时同样返回 204
+func (s *Server) leaveRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user := currentUser(r)
+	left, err := s.members.LeaveRoom(r.Context(), roomID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if left {
+		s.hub.SetMembership(user.UserID, roomID, false)
+		s.hub.Publish(ws.Event{Type: ws.EventMemberLeft, RoomID: roomID, Data: MemberEvent{
+			UserID:   user.UserID,
+			Username: user.Username,
+		}})
+		s.postSystemMessage(r.Context(), roomID, SystemMessageMetadata{Event: systemEventMemberLeft, ActorID: user.UserID, Actor: user.Username})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getRoomMembers(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, defaultMembersPageSize, maxMembersPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := s.rooms.GetRoom(r.Context(), roomID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.requireMember(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	members, err := s.members.ListRoomMembers(r.Context(), roomID, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// TestJoinLeaveRoom 加入和离开都是幂等的，只有状态变化时才广播
+func TestJoinLeaveRoom(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, _, bobToken := messageRoom(t, ts)
+	events := ts.subscribe(alice.ID, room.ID)
+	base := fmt.Sprintf("/api/rooms/%d", room.ID)
+
+	for i := 0; i < 2; i++ {
+		decodeResponse(t, ts.do("POST", base+"/join", bobToken, nil), http.StatusNoContent, nil)
+	}
+	var members []store.RoomMember
+	decodeResponse(t, ts.do("GET", base+"/members", bobToken, nil), http.StatusOK, &members)
+	if len(members) != 2 || members[0].Username != "alice" || members[1].Username != "bob" {
+		t.Fatalf("members = %+v, want alice and bob", members)
+	}
+	decodeResponse(t, ts.do("POST", "/api/messages", bobToken, CreateMessageRequest{RoomID: room.ID, Content: "hi"}), http.StatusOK, nil)
+
+	for i := 0; i < 2; i++ {
+		decodeResponse(t, ts.do("DELETE", base+"/leave", bobToken, nil), http.StatusNoContent, nil)
+	}
+	decodeResponse(t, ts.do("POST", "/api/messages", bobToken, CreateMessageRequest{RoomID: room.ID, Content: "hi"}), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("GET", base+"/messages", bobToken, nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("GET", base+"/members", bobToken, nil), http.StatusForbidden, nil)
+
+	got := events.drain(t, ts, room.ID)
+	if joined, left := countEvents(got, ws.EventMemberJoined), countEvents(got, ws.EventMemberLeft); joined != 1 || left != 1 {
+		t.Fatalf("%d member_joined and %d member_left events, want 1 each", joined, left)
+	}
+}
+
+func TestMembersPagination(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	for i := 0; i < 3; i++ {
+		_, userToken := ts.addUser(fmt.Sprintf("user%d", i))
+		decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/rooms/%d/join", room.ID), userToken, nil), http.StatusNoContent, nil)
+	}
+
+	var members []store.RoomMember
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/members?limit=2&offset=2", room.ID), token, nil), http.StatusOK, &members)
+	if len(members) != 2 || members[0].Username != "user1" || members[1].Username != "user2" {
+		t.Fatalf("members = %+v, want user1 and user2", members)
+	}
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/members?limit=-1", room.ID), token, nil), http.StatusBadRequest, nil)
+}
+
+func TestMembershipAuthorization(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, aliceToken, bobToken := messageRoom(t, ts)
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		status       int
+	}{
+		{"join unknown room", "POST", "/api/rooms/999/join", bobToken, http.StatusNotFound},
+		{"join anonymously", "POST", fmt.Sprintf("/api/rooms/%d/join", room.ID), "", http.StatusUnauthorized},
+		{"members of unknown room", "GET", "/api/rooms/999/members", aliceToken, http.StatusNotFound},
+		{"members as non-member", "GET", fmt.Sprintf("/api/rooms/%d/members", room.ID), bobToken, http.StatusForbidden},
+		{"websocket as non-member", "GET", fmt.Sprintf("/ws?room_id=%d&token=%s", room.ID, bobToken), "", http.StatusForbidden},
+		{"websocket with invalid room", "GET", "/ws?room_id=abc&token=" + bobToken, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeResponse(t, ts.do(tt.method, tt.path, tt.token, nil), tt.status, nil)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+const messageLimiterCleanupInterval = time.Minute
+
+// messageLimiter 按用户限制发送消息的速率，REST 和 WebSocket 都通过 checkMessageRate 使用同一个限流器。
+// 多实例部署时每个实例单独计算
+type messageLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   int
+	buckets map[int]*tokenBucket
+	now     func() time.Time
+}
+
+func newMessageLimiter(rate float64, burst int) *messageLimiter {
+	return &messageLimiter{rate: rate, burst: burst, buckets: make(map[int]*tokenBucket), now: time.Now}
+}
+
+// take 在持有 l.mu 时取令牌，cleanup 不会删除正在使用的令牌桶
+func (l *messageLimiter) take(userID int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = newTokenBucket(l.rate, l.burst)
+		bucket.now = l.now
+		bucket.last = l.now()
+		l.buckets[userID] = bucket
+	}
+	return bucket.take()
+}
+
+// cleanup 删除令牌已经补满的用户，之后重新创建的令牌桶同样是满的，不影响限流结果
+func (l *messageLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for userID, bucket := range l.buckets {
+		bucket.mu.Lock()
+		bucket.refill()
+		full := bucket.tokens >= bucket.burst
+		bucket.mu.Unlock()
+		if full {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// runMessageLimiterCleanup 定期清理空闲用户的令牌桶，ctx 取消后退出
+func (s *Server) runMessageLimiterCleanup(ctx context.Context) {
+	if s.messageLimits == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(messageLimiterCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.messageLimits.cleanup()
+			}
+		}
+	}()
+}
+
+// checkMessageRate 每个用户发送消息的速率超出限制时返回 429，响应和 WebSocket 错误事件中的 retry_after 为需要等待的秒数。
+// 系统管理员和机器人令牌不受限制，在取令牌之前判断，不消耗所有者的令牌
+func (s *Server) checkMessageRate(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if s.messageLimits == nil {
+		return nil
+	}
+	exempt, err := s.messageRateExempt(ctx, sender)
+	if err != nil || exempt {
+		return err
+	}
+	ok, wait := s.messageLimits.take(sender.UserID)
+	if ok {
+		return nil
+	}
+	return &APIError{
+		Status:     http.StatusTooManyRequests,
+		Message:    "You are sending messages too quickly",
+		RetryAfter: wait.Seconds(),
+	}
+}
+
+// messageRateExempt 机器人令牌和系统管理员不受消息速率限制
+func (s *Server) messageRateExempt(ctx context.Context, sender *Claims) (bool, error) {
+	if sender.BotID != 0 {
+		return true, nil
+	}
+	if s.admin == nil {
+		return false, nil
+	}
+	isAdmin, err := s.admin.IsAdmin(ctx, sender.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return isAdmin, err
+}
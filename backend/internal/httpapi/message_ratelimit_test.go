@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+	"chatapp/internal/ws"
+)
+
+// TestMessageLimiterBurstAndRefill 每秒 5 条、突发 10 条：突发用完后按速率补充，空闲再久也不超过突发数
+func TestMessageLimiterBurstAndRefill(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newMessageLimiter(5, 10)
+	l.now = func() time.Time { return now }
+	takeAll := func(userID int) int {
+		n := 0
+		for ok, _ := l.take(userID); ok; ok, _ = l.take(userID) {
+			n++
+		}
+		return n
+	}
+
+	if n := takeAll(1); n != 10 {
+		t.Fatalf("burst allowed %d messages, want 10", n)
+	}
+	if _, wait := l.take(1); wait != 200*time.Millisecond {
+		t.Fatalf("wait = %s, want 200ms", wait)
+	}
+	// 用户之间互不影响
+	if ok, _ := l.take(2); !ok {
+		t.Fatal("another user was limited")
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if ok, wait := l.take(1); ok || wait != 100*time.Millisecond {
+		t.Fatalf("after 100ms: ok %v, wait %s, want to wait 100ms more", ok, wait)
+	}
+	now = now.Add(100 * time.Millisecond)
+	if n := takeAll(1); n != 1 {
+		t.Fatalf("after 200ms allowed %d messages, want 1", n)
+	}
+	now = now.Add(time.Second)
+	if n := takeAll(1); n != 5 {
+		t.Fatalf("after 1s allowed %d messages, want 5", n)
+	}
+	now = now.Add(time.Hour)
+	if n := takeAll(1); n != 10 {
+		t.Fatalf("after an hour allowed %d messages, want the burst of 10", n)
+	}
+
+	// cleanup 只删除令牌已经补满的用户
+	l.cleanup()
+	if _, ok := l.buckets[1]; !ok {
+		t.Fatal("cleanup removed an exhausted bucket")
+	}
+	now = now.Add(2 * time.Second)
+	l.cleanup()
+	if len(l.buckets) != 0 {
+		t.Fatalf("%d buckets left after cleanup, want 0", len(l.buckets))
+	}
+}
+
+// TestMessageRateLimit REST 和 WebSocket 共用同一个限流器，超出时分别返回 429 和带 retry_after 的 nack；
+// 系统管理员和机器人令牌不受限制，也不消耗令牌
+func TestMessageRateLimit(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) {
+		cfg.MessageRateLimit = 5
+		cfg.MessageRateBurst = 2
+	})
+	now := time.Now()
+	ts.messageLimits.now = func() time.Time { return now }
+	room, _, token, _ := messageRoom(t, ts)
+	send := func(token, content string) *http.Response {
+		rec := ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: content})
+		return rec.Result()
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := send(token, "hi"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("message %d: status %d", i+1, resp.StatusCode)
+		}
+	}
+	resp := send(token, "too many")
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status %d, body %v, err %v, want 429", resp.StatusCode, body, err)
+	}
+	if body["retry_after"] != 0.2 || body["retry_after_ms"] != nil || resp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("body = %v, Retry-After %q, want retry_after 0.2 and Retry-After 1", body, resp.Header.Get("Retry-After"))
+	}
+
+	conn := dialWebSocket(t, ts, token)
+	if err := conn.WriteJSON(CreateMessageRequest{RoomID: room.ID, Content: "over websocket", AckID: "w1"}); err != nil {
+		t.Fatal(err)
+	}
+	frame := readUntil(t, conn, func(fr wsFrame) bool { return fr.AckID == "w1" })
+	var wsErr struct {
+		Status     int     `json:"status"`
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.Unmarshal(frame.Data, &wsErr); err != nil || frame.Type != ws.EventNack ||
+		wsErr.Status != http.StatusTooManyRequests || wsErr.RetryAfter != 0.2 {
+		t.Fatalf("frame = %s %s, want a 429 nack with retry_after 0.2", frame.Type, frame.Data)
+	}
+
+	now = now.Add(200 * time.Millisecond)
+	if resp := send(token, "after waiting"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("after refill: status %d", resp.StatusCode)
+	}
+
+	// 机器人令牌和管理员在取令牌之前就被放行，所有者的令牌已经用完也可以发送
+	var bot Bot
+	decodeResponse(t, ts.do("POST", "/api/bots", token, CreateBotRequest{Name: "deploy"}), http.StatusCreated, &bot)
+	admin, adminToken := addAdmin(t, ts)
+	ts.store.JoinRoom(context.Background(), room.ID, admin.ID)
+	for i := 0; i < 5; i++ {
+		for _, tok := range []string{bot.Token, adminToken} {
+			if resp := send(tok, "exempt"); resp.StatusCode != http.StatusOK {
+				t.Fatalf("exempt message %d: status %d", i+1, resp.StatusCode)
+			}
+		}
+	}
+	if _, ok := ts.messageLimits.buckets[admin.ID]; ok {
+		t.Fatal("admin messages took tokens")
+	}
+	if resp := send(token, "still limited"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("owner after bot messages: status %d, want 429", resp.StatusCode)
+	}
+}
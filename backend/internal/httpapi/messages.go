@@ -0,0 +1,332 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// CreateMessageRequest 发送消息的请求体，REST 和 WebSocket 使用相同的格式
+type CreateMessageRequest struct {
+	RoomID          int    `json:"room_id"`
+	Content         string `json:"content"`
+	ParentMessageID *int   `json:"parent_message_id,omitempty"`
+	// Attachments 通过 POST /api/uploads 上传得到的附件 ID
+	Attachments []int `json:"attachments,omitempty"`
+	// ClientMsgID 客户端生成的 UUID，超时重试时使用相同的值不会产生重复消息
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+	// ScheduledAt 晚于当前时间时消息保存为定时消息，到时间后才发送
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	// TTLSeconds 阅后即焚，消息在发送 TTLSeconds 秒后被删除，0 表示永久保存
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// AckID 仅用于 WebSocket，设置后消息保存成功时向当前连接发送 ack，失败时发送 nack
+	AckID string `json:"ack_id,omitempty"`
+
+	// ConversationID 私信所属的会话，由 URL 决定而不是请求体
+	ConversationID int `json:"-"`
+	// conversation 由 checkConversation 加载，用于确定私信的接收者
+	conversation *store.Conversation
+	// room 由 checkMembership 加载，发送到聊天室的消息才有
+	room *ChatRoom
+	// reply 斜杠命令的回复，由 checkCommand 设置
+	reply *Message
+}
+
+const (
+	defaultRepliesPageSize = 50
+	maxRepliesPageSize     = 100
+)
+
+// messageFilter 消息保存前依次执行的处理步骤。
+// REST（createMessage）和 WebSocket 都通过 saveMessage 保存消息，
+// 新增的校验、过滤等功能都应该注册到 messageFilters 中，保证两条路径行为一致。
+type messageFilter struct {
+	name  string
+	apply func(s *Server, ctx context.Context, sender *Claims, req *CreateMessageRequest) error
+}
+
+var messageFilters = []messageFilter{
+	{name: "validate", apply: (*Server).validateMessage},
+	// 在其余查询数据库的步骤之前限流
+	{name: "rate_limit", apply: (*Server).checkMessageRate},
+	{name: "membership", apply: (*Server).checkMembership},
+	{name: "command", apply: (*Server).checkCommand},
+	{name: "conversation", apply: (*Server).checkConversation},
+	{name: "parent", apply: (*Server).checkParentMessage},
+	{name: "attachments", apply: (*Server).checkAttachments},
+	{name: "content_filter", apply: (*Server).checkContentFilter},
+	{name: "profanity", apply: (*Server).checkProfanity},
+	// 放在最后，前面的校验失败时不占用冷却时间
+	{name: "slow_mode", apply: (*Server).checkSlowMode},
+}
+
+func (s *Server) validateMessage(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.RoomID <= 0 && req.ConversationID == 0 {
+		return &APIError{Status: http.StatusBadRequest, Message: "room_id is required", Field: "room_id"}
+	}
+	content, err := sanitizeContent(req.Content, s.MaxMessageLength)
+	// 只有附件的消息可以没有文字
+	if errors.Is(err, errEmptyContent) && len(req.Attachments) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	req.Content = content
+
+	if req.TTLSeconds != 0 && (req.TTLSeconds < minMessageTTLSeconds || req.TTLSeconds > maxMessageTTLSeconds) {
+		return &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("ttl_seconds must be 0 or between %d and %d", minMessageTTLSeconds, maxMessageTTLSeconds),
+			Field:   "ttl_seconds",
+		}
+	}
+
+	if req.ScheduledAt != nil {
+		now := time.Now()
+		switch {
+		case !req.ScheduledAt.After(now):
+			// 时间已过的按普通消息立即发送
+			req.ScheduledAt = nil
+		case req.ScheduledAt.Sub(now) > maxScheduleAhead:
+			return &APIError{Status: http.StatusBadRequest, Message: "scheduled_at cannot be more than 30 days in the future", Field: "scheduled_at"}
+		default:
+			t := req.ScheduledAt.UTC()
+			req.ScheduledAt = &t
+		}
+	}
+	return nil
+}
+
+var errEmptyContent = &APIError{Status: http.StatusBadRequest, Message: "Message content cannot be empty", Field: "content"}
+
+// sanitizeContent 去掉空字节和首尾空白，并检查长度（按字符计算）
+func sanitizeContent(content string, maxLength int) (string, error) {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\x00", ""))
+	if content == "" {
+		return "", errEmptyContent
+	}
+	if maxLength > 0 && utf8.RuneCountInString(content) > maxLength {
+		return "", &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Message: fmt.Sprintf("Message content cannot exceed %d characters", maxLength),
+			Field:   "content",
+		}
+	}
+	return content, nil
+}
+
+// checkConversation 私信只能由会话参与者发送，非参与者看到的结果与会话不存在相同；
+// 任意一方屏蔽了另一方时返回 403
+func (s *Server) checkConversation(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.ConversationID == 0 {
+		return nil
+	}
+	conv, err := s.conversations.GetConversation(ctx, req.ConversationID)
+	if err != nil {
+		return err
+	}
+	if !conv.HasParticipant(sender.UserID) {
+		return store.ErrNotFound
+	}
+	if err := s.checkNotBlocked(ctx, conv.UserAID, conv.UserBID); err != nil {
+		return err
+	}
+	req.RoomID = 0
+	req.conversation = &conv
+	return nil
+}
+
+// checkParentMessage 回复的消息必须存在且属于同一个聊天室或会话
+func (s *Server) checkParentMessage(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.ParentMessageID == nil {
+		return nil
+	}
+	parent, err := s.messages.GetMessage(ctx, *req.ParentMessageID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !sameChannel(parent, req)) {
+		return &APIError{Status: http.StatusUnprocessableEntity, Message: "Parent message does not exist in this room", Field: "parent_message_id"}
+	}
+	return err
+}
+
+func sameChannel(parent Message, req *CreateMessageRequest) bool {
+	if req.ConversationID != 0 {
+		return parent.ConversationID != nil && *parent.ConversationID == req.ConversationID
+	}
+	return parent.RoomID == req.RoomID
+}
+
+// saveMessage 执行过滤器、保存消息并广播给聊天室（私信只发送给会话双方）。
+// client_msg_id 已经用过时不再保存和广播，直接返回之前的消息，created 为 false；
+// 斜杠命令同样返回 created 为 false，msg 是命令的回复
+func (s *Server) saveMessage(ctx context.Context, sender *Claims, req CreateMessageRequest) (msg Message, created bool, err error) {
+	if req.ClientMsgID != "" {
+		if req.ClientMsgID, err = normalizeClientMsgID(req.ClientMsgID); err != nil {
+			return Message{}, false, err
+		}
+		// 在过滤器之前查找，慢速模式等不会拒绝重试
+		if prev, err := s.previousMessage(ctx, sender, req.ClientMsgID); !errors.Is(err, store.ErrNotFound) {
+			return prev, false, err
+		}
+	}
+
+	for _, f := range messageFilters {
+		if err := f.apply(s, ctx, sender, &req); err != nil {
+			return Message{}, false, err
+		}
+		// 斜杠命令不保存也不广播，回复只返回给发送者
+		if req.reply != nil {
+			return *req.reply, false, nil
+		}
+	}
+
+	msg = Message{
+		RoomID:          req.RoomID,
+		UserID:          sender.UserID,
+		Username:        sender.Username,
+		Content:         req.Content,
+		ParentMessageID: req.ParentMessageID,
+		ClientMsgID:     req.ClientMsgID,
+		ScheduledAt:     req.ScheduledAt,
+		TTLSeconds:      req.TTLSeconds,
+		MessageType:     store.MessageTypeUser,
+	}
+	if req.conversation != nil {
+		msg.ConversationID = &req.conversation.ID
+	}
+	for _, id := range req.Attachments {
+		msg.Attachments = append(msg.Attachments, store.Attachment{ID: id})
+	}
+
+	if err := s.messages.InsertMessage(ctx, &msg); err != nil {
+		if isClientMsgIDConflict(err) {
+			if prev, prevErr := s.previousMessage(ctx, sender, req.ClientMsgID); prevErr == nil {
+				return prev, false, nil
+			}
+		}
+		return Message{}, false, err
+	}
+
+	// 定时消息在发送时才广播
+	if msg.ScheduledAt == nil {
+		s.publishMessage(ctx, msg, req.conversation)
+	}
+	return msg, true, nil
+}
+
+// publishMessage 广播新消息（私信只发送给会话双方，离线的接收者会收到推送），通知被提及和被回复的用户并发送到聊天室的 webhook
+func (s *Server) publishMessage(ctx context.Context, msg Message, conv *store.Conversation) {
+	if conv != nil {
+		s.hub.Publish(ws.Event{Type: ws.EventDirectMessage, Data: msg, UserIDs: []int{conv.UserAID, conv.UserBID}, SenderID: msg.UserID})
+		s.enqueuePush(ctx, pushEvent{userID: conv.UserAID, msg: msg})
+		s.enqueuePush(ctx, pushEvent{userID: conv.UserBID, msg: msg})
+		s.notifyDirectMessage(ctx, msg, conv)
+	} else {
+		s.hub.Publish(ws.Event{Type: ws.EventMessage, RoomID: msg.RoomID, Data: msg, SenderID: msg.UserID})
+	}
+	s.notifyMentions(ctx, msg)
+	s.notifyReply(ctx, msg)
+	s.enqueueWebhooks(ctx, msg)
+}
+
+// messageEventType 私信使用 direct_message 事件，聊天室消息使用 message 事件
+func messageEventType(msg Message) string {
+	if msg.ConversationID != nil {
+		return ws.EventDirectMessage
+	}
+	return ws.EventMessage
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	var req CreateMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	msg, _, err := s.saveMessage(r.Context(), currentUser(r), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// getReplies 返回某条消息的直接回复，按时间顺序排列
+func (s *Server) getReplies(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, defaultRepliesPageSize, maxRepliesPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, _, err := s.visibleMessage(r.Context(), messageID, viewerID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	replies, err := s.messages.ListReplies(r.Context(), messageID, viewerID(r), limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replies)
+}
+
+// ThreadResponse 讨论串：根消息和一页回复，根消息被删除后仍然返回（deleted 为 true）
+type ThreadResponse struct {
+	Parent  Message   `json:"parent"`
+	Replies []Message `json:"replies"`
+}
+
+// getThread 返回根消息及其回复
+func (s *Server) getThread(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, defaultRepliesPageSize, maxRepliesPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	parent, err := s.messages.GetThreadRoot(r.Context(), messageID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := s.checkMessageAccess(r.Context(), parent, viewerID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	replies, err := s.messages.ListReplies(r.Context(), messageID, viewerID(r), limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ThreadResponse{Parent: parent, Replies: replies})
+}
@@ -0,0 +1,394 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// messageRoom 创建聊天室并让 alice 加入，bob 不是成员
+func messageRoom(t *testing.T, ts *testServer) (room store.ChatRoom, alice store.User, aliceToken, bobToken string) {
+	t.Helper()
+	alice, aliceToken = ts.addUser("alice")
+	_, bobToken = ts.addUser("bob")
+	room = ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	return room, alice, aliceToken, bobToken
+}
+
+func TestCreateMessage(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	events := ts.subscribe(alice.ID, room.ID)
+
+	var msg Message
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "hello"}), http.StatusOK, &msg)
+	if msg.ID == 0 || msg.RoomID != room.ID || msg.UserID != alice.ID || msg.Content != "hello" || msg.Username != "alice" {
+		t.Fatalf("message = %+v", msg)
+	}
+
+	stored, err := ts.store.GetMessage(context.Background(), msg.ID)
+	if err != nil || stored.Content != "hello" {
+		t.Fatalf("stored message = %+v, %v", stored, err)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventMessage); got != 1 {
+		t.Fatalf("%d message events broadcast, want 1", got)
+	}
+}
+
+func TestCreateMessageRejects(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, aliceToken, bobToken := messageRoom(t, ts)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"anonymous", "", CreateMessageRequest{RoomID: room.ID, Content: "hi"}, http.StatusUnauthorized},
+		{"invalid body", aliceToken, "{", http.StatusBadRequest},
+		{"empty content", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "  "}, http.StatusBadRequest},
+		{"too long", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: strings.Repeat("a", 4001)}, http.StatusUnprocessableEntity},
+		{"unknown room", aliceToken, CreateMessageRequest{RoomID: room.ID + 100, Content: "hi"}, http.StatusNotFound},
+		{"not a member", bobToken, CreateMessageRequest{RoomID: room.ID, Content: "hi"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeResponse(t, ts.do("POST", "/api/messages", tt.token, tt.body), tt.status, nil)
+		})
+	}
+	if page, _ := ts.store.ListRoomMessages(context.Background(), store.MessagePageOptions{RoomID: room.ID, Limit: 10}); len(page) != 0 {
+		t.Fatalf("rejected requests stored %d messages", len(page))
+	}
+}
+
+// TestCreateMessageRejectsOverWebSocket WebSocket 发送的消息与 REST 接口使用相同的校验，
+// 错误事件中的状态码与 REST 接口返回的相同。最大长度可以配置
+func TestCreateMessageRejectsOverWebSocket(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.MaxMessageLength = 50 })
+	room, alice, aliceToken, bobToken := messageRoom(t, ts)
+
+	tests := []struct {
+		name   string
+		token  string
+		req    CreateMessageRequest
+		status int
+	}{
+		{"empty content", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: " \n\t "}, http.StatusBadRequest},
+		{"too long", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: strings.Repeat("é", 51)}, http.StatusUnprocessableEntity},
+		{"missing room", aliceToken, CreateMessageRequest{Content: "hi"}, http.StatusBadRequest},
+		{"unknown room", aliceToken, CreateMessageRequest{RoomID: room.ID + 100, Content: "hi"}, http.StatusNotFound},
+		{"not a member", bobToken, CreateMessageRequest{RoomID: room.ID, Content: "hi"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeResponse(t, ts.do("POST", "/api/messages", tt.token, tt.req), tt.status, nil)
+
+			claims, err := ts.auth.Authenticate(context.Background(), tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := &eventRecorder{events: make(chan ws.Event, 10)}
+			client := ws.NewClient(ts.hub, events, ws.ClientOptions{UserID: claims.UserID, Rooms: map[int]bool{room.ID: true}})
+			ts.hub.Register(client)
+			defer ts.hub.Unregister(client)
+
+			ts.handleClientMessage(context.Background(), slog.Default(), client, claims, tt.req)
+			received := events.drain(t, ts, room.ID)
+			if len(received) != 1 || received[0].Type != ws.EventError {
+				t.Fatalf("received %+v, want one error event", received)
+			}
+			if wsErr := received[0].Data.(WSError); wsErr.Status != tt.status {
+				t.Fatalf("error event status = %d, want %d", wsErr.Status, tt.status)
+			}
+		})
+	}
+
+	// 长度按字符计算，正好 50 个字符可以发送
+	msg := CreateMessageRequest{RoomID: room.ID, Content: strings.Repeat("é", 50)}
+	decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, msg), http.StatusOK, nil)
+	if page, _ := ts.store.ListRoomMessages(context.Background(), store.MessagePageOptions{RoomID: room.ID, Limit: 10}); len(page) != 1 || page[0].UserID != alice.ID {
+		t.Fatalf("stored messages = %+v, want only the valid one", page)
+	}
+}
+
+// failingMessages 模拟写入失败的数据库，读取仍然使用内存 store
+type failingMessages struct {
+	store.MessageStore
+}
+
+func (failingMessages) InsertMessage(ctx context.Context, msg *store.Message) error {
+	return errors.New("connection reset by peer")
+}
+
+// TestWebSocketNackOnWriteFailure 写入数据库失败时只给发送消息的连接回复 nack，不发送 ack，也不广播
+func TestWebSocketNackOnWriteFailure(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	ts.messages = failingMessages{ts.store}
+	events := ts.subscribe(alice.ID, room.ID)
+
+	conn := dialWebSocket(t, ts, token)
+	if err := conn.WriteJSON(CreateMessageRequest{RoomID: room.ID, Content: "lost", AckID: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	frame := readUntil(t, conn, func(fr wsFrame) bool { return fr.AckID == "a1" })
+	if frame.Type != ws.EventNack {
+		t.Fatalf("frame = %+v, want nack", frame)
+	}
+	var wsErr WSError
+	if err := json.Unmarshal(frame.Data, &wsErr); err != nil {
+		t.Fatal(err)
+	}
+	if wsErr.Status != http.StatusInternalServerError || frame.MessageID != 0 {
+		t.Fatalf("nack = %+v, data %s, want status 500 without a message ID", frame, frame.Data)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventMessage); got != 0 {
+		t.Fatalf("failed message was broadcast %d times", got)
+	}
+}
+
+func TestGetRoomMessages(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, bobToken := messageRoom(t, ts)
+	ids := make([]int, 5)
+	for i := range ids {
+		msg := store.Message{RoomID: room.ID, UserID: alice.ID, Content: fmt.Sprintf("message %d", i)}
+		if err := ts.store.InsertMessage(context.Background(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = msg.ID
+	}
+
+	var page MessagePage
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages?limit=3", room.ID), token, nil), http.StatusOK, &page)
+	if got := messageIDs(page.Messages); fmt.Sprint(got) != fmt.Sprint(ids[2:]) || !page.HasOlder || page.HasNewer {
+		t.Fatalf("latest page = %v (older %v, newer %v), want %v with older messages", got, page.HasOlder, page.HasNewer, ids[2:])
+	}
+
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages?limit=3&before_id=%d", room.ID, page.OldestID), token, nil), http.StatusOK, &page)
+	if got := messageIDs(page.Messages); fmt.Sprint(got) != fmt.Sprint(ids[:2]) || page.HasOlder || !page.HasNewer {
+		t.Fatalf("older page = %v (older %v, newer %v), want %v", got, page.HasOlder, page.HasNewer, ids[:2])
+	}
+
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages", room.ID), bobToken, nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages?before_id=1&after_id=2", room.ID), token, nil), http.StatusBadRequest, nil)
+}
+
+// TestMessagePageBoundaries 游标位于两端、页大小正好等于剩余消息数以及空聊天室时的 has_older 和 has_newer
+func TestMessagePageBoundaries(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	ids := make([]int, 4)
+	for i := range ids {
+		msg := store.Message{RoomID: room.ID, UserID: alice.ID, Content: fmt.Sprintf("message %d", i)}
+		if err := ts.store.InsertMessage(context.Background(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = msg.ID
+	}
+	oldest, newest := ids[0], ids[len(ids)-1]
+
+	tests := []struct {
+		name     string
+		query    string
+		want     []int
+		hasOlder bool
+		hasNewer bool
+	}{
+		{"before the oldest message", fmt.Sprintf("before_id=%d", oldest), nil, false, true},
+		{"after the newest message", fmt.Sprintf("after_id=%d", newest), nil, true, false},
+		{"exactly the remaining older messages", fmt.Sprintf("limit=2&before_id=%d", ids[2]), ids[:2], false, true},
+		{"exactly the remaining newer messages", fmt.Sprintf("limit=2&after_id=%d", ids[1]), ids[2:], true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var page MessagePage
+			decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages?%s", room.ID, tt.query), token, nil), http.StatusOK, &page)
+			if got := messageIDs(page.Messages); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("messages = %v, want %v", got, tt.want)
+			}
+			if page.HasOlder != tt.hasOlder || page.HasNewer != tt.hasNewer {
+				t.Fatalf("has_older = %v, has_newer = %v, want %v, %v", page.HasOlder, page.HasNewer, tt.hasOlder, tt.hasNewer)
+			}
+			if len(tt.want) == 0 && (page.OldestID != 0 || page.NewestID != 0) {
+				t.Fatalf("empty page has oldest_id %d and newest_id %d, want 0", page.OldestID, page.NewestID)
+			}
+			if len(tt.want) > 0 && (page.OldestID != tt.want[0] || page.NewestID != tt.want[len(tt.want)-1]) {
+				t.Fatalf("oldest_id = %d, newest_id = %d, want %d, %d", page.OldestID, page.NewestID, tt.want[0], tt.want[len(tt.want)-1])
+			}
+		})
+	}
+
+	// 重连后用 after_id=newest_id 只拉取断线期间的消息
+	missed := postMessage(t, ts, token, room.ID, "while disconnected")
+	var page MessagePage
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages?after_id=%d", room.ID, newest), token, nil), http.StatusOK, &page)
+	if got := messageIDs(page.Messages); fmt.Sprint(got) != fmt.Sprint([]int{missed}) || page.HasNewer {
+		t.Fatalf("gap = %v (newer %v), want [%d]", got, page.HasNewer, missed)
+	}
+
+	empty := ts.store.AddRoom("empty", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), empty.ID, alice.ID)
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/messages", empty.ID), token, nil), http.StatusOK, &page)
+	if len(page.Messages) != 0 || page.HasOlder || page.HasNewer || page.OldestID != 0 || page.NewestID != 0 {
+		t.Fatalf("empty room page = %+v", page)
+	}
+}
+
+func messageIDs(messages []Message) []int {
+	ids := make([]int, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// TestReplyNesting 回复可以继续被回复，每层只返回直接回复，广播中带有 parent_message_id
+func TestReplyNesting(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	events := ts.subscribe(alice.ID, room.ID)
+
+	var root, reply, nested Message
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "root"}), http.StatusOK, &root)
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "reply", ParentMessageID: &root.ID}), http.StatusOK, &reply)
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "nested", ParentMessageID: &reply.ID}), http.StatusOK, &nested)
+	if nested.ParentMessageID == nil || *nested.ParentMessageID != reply.ID {
+		t.Fatalf("nested reply parent = %v, want %d", nested.ParentMessageID, reply.ID)
+	}
+
+	for _, tt := range []struct {
+		parent Message
+		want   []int
+	}{
+		{root, []int{reply.ID}},
+		{reply, []int{nested.ID}},
+		{nested, []int{}},
+	} {
+		var replies []Message
+		decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/messages/%d/replies", tt.parent.ID), token, nil), http.StatusOK, &replies)
+		if got := messageIDs(replies); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("replies to %q = %v, want %v", tt.parent.Content, got, tt.want)
+		}
+	}
+
+	var parents []int
+	for _, e := range events.drain(t, ts, room.ID) {
+		if msg, ok := e.Data.(Message); ok && e.Type == ws.EventMessage && msg.ParentMessageID != nil {
+			parents = append(parents, *msg.ParentMessageID)
+		}
+	}
+	if fmt.Sprint(parents) != fmt.Sprint([]int{root.ID, reply.ID}) {
+		t.Fatalf("broadcast parent ids = %v, want [%d %d]", parents, root.ID, reply.ID)
+	}
+}
+
+func TestReplyToMissingParent(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	other := ts.store.AddRoom("other", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), other.ID, alice.ID)
+	elsewhere := store.Message{RoomID: other.ID, UserID: alice.ID, Content: "elsewhere"}
+	if err := ts.store.InsertMessage(context.Background(), &elsewhere); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parentID := range []int{9999, elsewhere.ID} {
+		var apiErr APIError
+		decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "reply", ParentMessageID: &parentID}), http.StatusUnprocessableEntity, &apiErr)
+		if apiErr.Field != "parent_message_id" {
+			t.Fatalf("parent %d: field = %q, want parent_message_id", parentID, apiErr.Field)
+		}
+	}
+}
+
+// discardSubscriber 丢弃所有事件的 Subscriber
+type discardSubscriber struct{}
+
+func (discardSubscriber) Send(ws.Event) error { return nil }
+func (discardSubscriber) Close(int, string)   {}
+
+func FuzzSanitizeContent(f *testing.F) {
+	for _, seed := range []string{"hello", "  padded  ", "\x00", "a\x00b", " \x00 ", "", "\xff\xfe", "日本語", strings.Repeat("x", 11)} {
+		f.Add(seed)
+	}
+	const maxLength = 10
+	f.Fuzz(func(t *testing.T, content string) {
+		got, err := sanitizeContent(content, maxLength)
+		if err != nil {
+			return
+		}
+		if got == "" || strings.Contains(got, "\x00") || strings.TrimSpace(got) != got {
+			t.Fatalf("sanitizeContent(%q) = %q", content, got)
+		}
+		if utf8.RuneCountInString(got) > maxLength {
+			t.Fatalf("sanitizeContent(%q) = %q exceeds %d characters", content, got, maxLength)
+		}
+	})
+}
+
+// FuzzCreateMessage 任意请求体都不会让 REST 和 WebSocket 的消息处理 panic 或返回 5xx
+func FuzzCreateMessage(f *testing.F) {
+	ts := newTestServer(f, func(cfg *config.Config) { cfg.MaxMessageLength = 100 })
+	alice, token := ts.addUser("alice")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	claims, err := ts.auth.Authenticate(context.Background(), token)
+	if err != nil {
+		f.Fatal(err)
+	}
+	client := ws.NewClient(ts.hub, discardSubscriber{}, ws.ClientOptions{UserID: alice.ID, Username: alice.Username, Rooms: map[int]bool{room.ID: true}})
+	ts.hub.Register(client)
+	// fuzz 进程的输出不会被及时读取，日志写满管道后会阻塞，所以关闭日志
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	f.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	for _, seed := range []string{
+		fmt.Sprintf(`{"room_id":%d,"content":"hello"}`, room.ID),
+		fmt.Sprintf(`{"room_id":%d,"content":"a\u0000b"}`, room.ID),
+		fmt.Sprintf(`{"room_id":%d,"content":"   "}`, room.ID),
+		fmt.Sprintf(`{"room_id":%d,"content":"%s"}`, room.ID, strings.Repeat("x", 101)),
+		fmt.Sprintf(`{"room_id":%d,"content":"hi","parent_message_id":-1,"client_msg_id":"\xff"}`, room.ID),
+		`{"room_id":"1"}`, `[]`, `null`, `{`, "\x00\xff",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		// 在 fuzz 目标中不能使用 ts.do：它调用 testServer 所属的 f.Helper
+		req := httptest.NewRequest("POST", "/api/messages", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := ts.serve(req)
+		if rec.Code >= http.StatusInternalServerError {
+			t.Fatalf("POST /api/messages with %q returned %d: %s", body, rec.Code, rec.Body)
+		}
+		if rec.Code == http.StatusOK {
+			var msg Message
+			if err := json.Unmarshal(rec.Body.Bytes(), &msg); err == nil && (strings.Contains(msg.Content, "\x00") || utf8.RuneCountInString(msg.Content) > 100) {
+				t.Fatalf("stored unsanitized content %q", msg.Content)
+			}
+		}
+
+		frame, err := decodeClientFrame(body)
+		if err == nil {
+			ts.handleClientMessage(context.Background(), logger, client, claims, frame.CreateMessageRequest)
+		}
+	})
+}
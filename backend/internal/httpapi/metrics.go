@@ -0,0 +1,136 @@
+package httpapi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// appMetrics 应用的 Prometheus 指标，注册到独立的 registry，便于测试时单独检查
+type appMetrics struct {
+	registry *prometheus.Registry
+
+	wsConnections     prometheus.Gauge
+	httpDuration      *prometheus.HistogramVec
+	dbErrors          prometheus.Counter
+	webhookDeliveries *prometheus.CounterVec
+
+	contentFilterDecisions *prometheus.CounterVec
+}
+
+var metrics = newAppMetrics(prometheus.NewRegistry())
+
+func newAppMetrics(registry *prometheus.Registry) *appMetrics {
+	m := &appMetrics{
+		registry: registry,
+		wsConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chat_websocket_connections",
+			Help: "Current number of WebSocket connections.",
+		}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chat_http_request_duration_seconds",
+			Help:    "HTTP request duration by route, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		dbErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chat_db_errors_total",
+			Help: "Total number of database query errors.",
+		}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_webhook_deliveries_total",
+			Help: "Outgoing webhook deliveries by result (success, failure, dropped).",
+		}, []string{"result"}),
+		contentFilterDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chat_content_filter_decisions_total",
+			Help: "Messages checked by the content filter by action (allow, mask, reject).",
+		}, []string{"action"}),
+	}
+
+	registry.MustRegister(
+		m.wsConnections,
+		m.httpDuration,
+		m.dbErrors,
+		m.webhookDeliveries,
+		m.contentFilterDecisions,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// registerOnce 注册指标。测试中可能创建多个 Server，已经注册过时保留第一个
+func (m *appMetrics) registerOnce(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			var already prometheus.AlreadyRegisteredError
+			if !errors.As(err, &already) {
+				panic(err)
+			}
+		}
+	}
+}
+
+// registerDB 注册连接池统计（db.Stats()），重复注册时保留第一个连接池
+func (m *appMetrics) registerDB(db *sql.DB) {
+	m.registerOnce(collectors.NewDBStatsCollector(db, "chatapp"))
+}
+
+// registerHub 注册 Hub 的广播指标，已经注册过时保留第一个 Hub 的指标
+func (m *appMetrics) registerHub(hub *ws.Hub) {
+	m.registerOnce(hub.Collectors()...)
+}
+
+// registerAdmissionMetrics 把 WebSocket 升级准入统计暴露为指标
+func (m *appMetrics) registerAdmissionMetrics(a *upgradeAdmission) {
+	m.registerOnce(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "chat_websocket_upgrades_total",
+			Help:        "WebSocket upgrade attempts by admission result.",
+			ConstLabels: prometheus.Labels{"result": "admitted_cold"},
+		}, func() float64 { return float64(a.admittedCold.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "chat_websocket_upgrades_total",
+			Help:        "WebSocket upgrade attempts by admission result.",
+			ConstLabels: prometheus.Labels{"result": "admitted_resume"},
+		}, func() float64 { return float64(a.admittedResume.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "chat_websocket_upgrades_total",
+			Help:        "WebSocket upgrade attempts by admission result.",
+			ConstLabels: prometheus.Labels{"result": "deferred"},
+		}, func() float64 { return float64(a.deferred.Load()) }),
+	)
+}
+
+func (m *appMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// metricsMiddleware 按路由模板记录请求耗时，作为 mux 中间件在路由匹配之后执行
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.httpDuration.WithLabelValues(route, r.Method, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
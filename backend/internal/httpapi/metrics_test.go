@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store/postgres"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// histogramCount 返回直方图中的样本数
+func histogramCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	ts := newTestServer(t)
+	decodeResponse(t, ts.do("GET", "/metrics", "", nil), http.StatusNotFound, nil)
+
+	ts = newTestServer(t, func(cfg *config.Config) { cfg.MetricsEnabled = true })
+	ts.do("GET", "/api/health/live", "", nil)
+	rec := ts.do("GET", "/metrics", "", nil)
+	decodeResponse(t, rec, http.StatusOK, nil)
+	for _, name := range []string{"chat_websocket_connections", "chat_http_request_duration_seconds", "chat_db_errors_total", "chat_websocket_upgrades_total"} {
+		if !strings.Contains(rec.Body.String(), name) {
+			t.Errorf("/metrics does not expose %s", name)
+		}
+	}
+}
+
+// TestMetricsCountMessageFlow 消息经过 REST 接口和 hub 时各项指标都会增加
+func TestMetricsCountMessageFlow(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.addUser("alice")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	events := ts.subscribe(alice.ID, room.ID)
+
+	broadcast := ts.hub.Collectors()[0].(prometheus.Counter)
+	latency := ts.hub.Collectors()[2].(prometheus.Histogram)
+	requests := metrics.httpDuration.WithLabelValues("/api/messages", "POST", "200")
+	requestsBefore := histogramCount(t, requests)
+	latencyBefore := histogramCount(t, latency)
+
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: "hello"}), http.StatusOK, nil)
+	events.drain(t, ts, room.ID)
+
+	if got := testutil.ToFloat64(broadcast); got != 1 {
+		t.Fatalf("chat_messages_broadcast_total = %v, want 1", got)
+	}
+	// 消息事件和 drain 的标记事件
+	if got := histogramCount(t, latency) - latencyBefore; got != 2 {
+		t.Fatalf("fan-out latency samples = %d, want 2", got)
+	}
+	if got := histogramCount(t, requests) - requestsBefore; got != 1 {
+		t.Fatalf("request duration samples for POST /api/messages = %d, want 1", got)
+	}
+}
+
+func TestMetricsWebSocketConnections(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("alice")
+	before := testutil.ToFloat64(metrics.wsConnections)
+
+	conn := dialWebSocket(t, ts, token)
+	if got := testutil.ToFloat64(metrics.wsConnections) - before; got != 1 {
+		t.Fatalf("chat_websocket_connections increased by %v, want 1", got)
+	}
+	conn.Close()
+}
+
+func TestMetricsDBErrors(t *testing.T) {
+	ts := newTestServer(t)
+	pg := postgres.New(newFakeDB(t, &fakeDB{err: errors.New("connection refused")}))
+	pg.ErrorHook = DBErrorHook
+	ts.rooms = pg
+	before := testutil.ToFloat64(metrics.dbErrors)
+
+	decodeResponse(t, ts.do("GET", "/api/rooms/1", "", nil), http.StatusInternalServerError, nil)
+	if got := testutil.ToFloat64(metrics.dbErrors) - before; got != 1 {
+		t.Fatalf("chat_db_errors_total increased by %v, want 1", got)
+	}
+}
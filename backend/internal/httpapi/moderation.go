@@ -0,0 +1,370 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const maxModerationReasonLength = 500
+
+// 批量删除一次最多 100 条消息
+const maxBulkDeleteMessages = 100
+
+// roleRank 角色高的用户才能管理角色低的用户，非成员为 0
+var roleRank = map[string]int{
+	store.RoleOwner:     3,
+	store.RoleModerator: 2,
+	store.RoleMember:    1,
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// ModerationRequest 踢出和封禁的请求体，可以为空
+type ModerationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ModerationEvent 管理操作完成后作为系统事件广播到聊天室
+type ModerationEvent struct {
+	Action   string `json:"action"`
+	ActorID  int    `json:"actor_id"`
+	Actor    string `json:"actor"`
+	TargetID int    `json:"target_id"`
+	Target   string `json:"target"`
+	Role     string `json:"role,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// BulkDeleteRequest POST /api/rooms/{id}/messages/bulk-delete 的请求体
+type BulkDeleteRequest struct {
+	MessageIDs []int `json:"message_ids"`
+}
+
+// MessagesBulkDeletedEvent 批量删除后广播到聊天室
+type MessagesBulkDeletedEvent struct {
+	RoomID     int   `json:"room_id"`
+	MessageIDs []int `json:"message_ids"`
+}
+
+// BanRequest POST /api/rooms/{id}/bans 的请求体
+type BanRequest struct {
+	UserID int    `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func targetUserIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid user ID")
+	}
+	return id, nil
+}
+
+// moderationTarget 解析路径中的聊天室并查找目标用户，同时检查当前用户是 owner 或 moderator
+func (s *Server) moderationTarget(r *http.Request, targetID int) (roomID int, actorRole string, target User, targetRole string, err error) {
+	roomID, err = roomIDFromRequest(r)
+	if err != nil {
+		return
+	}
+
+	actorRole, err = s.requireModerator(r.Context(), roomID, currentUser(r).UserID)
+	if err != nil {
+		return
+	}
+	target, err = s.users.GetUser(r.Context(), targetID)
+	if err != nil {
+		return
+	}
+	targetRole, err = s.roomRole(r.Context(), roomID, targetID)
+	return
+}
+
+// decodeModerationRequest 读取可选的 reason
+func decodeModerationRequest(r *http.Request) (ModerationRequest, error) {
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return req, apiError(http.StatusBadRequest, "Invalid request body")
+	}
+	reason, err := validateModerationReason(req.Reason)
+	req.Reason = reason
+	return req, err
+}
+
+func validateModerationReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxModerationReasonLength {
+		return "", &APIError{Status: http.StatusBadRequest, Message: "Reason is too long", Field: "reason"}
+	}
+	return reason, nil
+}
+
+// publishModeration 广播管理操作
+func (s *Server) publishModeration(r *http.Request, roomID int, action store.ModerationAction, target User, role string) {
+	actor := currentUser(r)
+	s.hub.Publish(ws.Event{Type: ws.EventModeration, RoomID: roomID, Data: ModerationEvent{
+		Action:   action.Action,
+		ActorID:  actor.UserID,
+		Actor:    actor.Username,
+		TargetID: target.ID,
+		Target:   target.Username,
+		Role:     role,
+		Reason:   action.Reason,
+	}})
+}
+
+// setMemberRole 只有 owner 可以把成员提升为 moderator 或降为 member
+func (s *Server) setMemberRole(w http.ResponseWriter, r *http.Request) {
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.Role != store.RoleModerator && req.Role != store.RoleMember {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Role must be moderator or member", Field: "role"})
+		return
+	}
+	s.changeMemberRole(w, r, req.Role)
+}
+
+func (s *Server) promoteMember(w http.ResponseWriter, r *http.Request) {
+	s.changeMemberRole(w, r, store.RoleModerator)
+}
+
+func (s *Server) demoteMember(w http.ResponseWriter, r *http.Request) {
+	s.changeMemberRole(w, r, store.RoleMember)
+}
+
+func (s *Server) changeMemberRole(w http.ResponseWriter, r *http.Request, role string) {
+	targetID, err := targetUserIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	roomID, actorRole, target, targetRole, err := s.moderationTarget(r, targetID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if actorRole != store.RoleOwner {
+		writeError(w, r, store.ErrPermission)
+		return
+	}
+	switch targetRole {
+	case "":
+		writeError(w, r, apiError(http.StatusNotFound, "User is not a member of this room"))
+		return
+	case store.RoleOwner:
+		writeError(w, r, apiError(http.StatusBadRequest, "The room owner's role cannot be changed"))
+		return
+	case role:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	action := store.ModerationAction{RoomID: roomID, ActorID: currentUser(r).UserID, TargetID: target.ID, Action: store.ModerationDemote}
+	if role == store.RoleModerator {
+		action.Action = store.ModerationPromote
+	}
+	if err := s.moderation.SetMemberRole(r.Context(), action, role); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	s.publishModeration(r, roomID, action, target, role)
+	s.notifyModeration(r.Context(), action)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// kickMember 移除成员，被踢出的用户之后仍可以重新加入
+func (s *Server) kickMember(w http.ResponseWriter, r *http.Request) {
+	s.removeMemberByPath(w, r, store.ModerationKick)
+}
+
+// banMember 移除成员并禁止其重新加入，也可以封禁尚未加入的用户
+func (s *Server) banMember(w http.ResponseWriter, r *http.Request) {
+	s.removeMemberByPath(w, r, store.ModerationBan)
+}
+
+// createBan POST /api/rooms/{id}/bans，目标用户在请求体中
+func (s *Server) createBan(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.UserID <= 0 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "user_id is required", Field: "user_id"})
+		return
+	}
+	reason, err := validateModerationReason(req.Reason)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.removeMember(w, r, store.ModerationBan, req.UserID, reason)
+}
+
+func (s *Server) removeMemberByPath(w http.ResponseWriter, r *http.Request, kind string) {
+	targetID, err := targetUserIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	req, err := decodeModerationRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.removeMember(w, r, kind, targetID, req.Reason)
+}
+
+func (s *Server) removeMember(w http.ResponseWriter, r *http.Request, kind string, targetID int, reason string) {
+	roomID, actorRole, target, targetRole, err := s.moderationTarget(r, targetID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if targetRole == "" && kind == store.ModerationKick {
+		writeError(w, r, apiError(http.StatusNotFound, "User is not a member of this room"))
+		return
+	}
+	// 只能管理角色比自己低的用户，因此也不能管理自己
+	if roleRank[targetRole] >= roleRank[actorRole] {
+		writeError(w, r, store.ErrPermission)
+		return
+	}
+
+	action := store.ModerationAction{RoomID: roomID, ActorID: currentUser(r).UserID, TargetID: target.ID, Action: kind, Reason: reason}
+	if kind == store.ModerationBan {
+		err = s.moderation.BanMember(r.Context(), action)
+	} else {
+		err = s.moderation.KickMember(r.Context(), action)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if targetRole != "" {
+		s.hub.RemoveFromRoom(target.ID, roomID, kind, reason)
+	}
+	s.publishModeration(r, roomID, action, target, "")
+	s.notifyModeration(r.Context(), action)
+
+	event := systemEventMemberKicked
+	if kind == store.ModerationBan {
+		event = systemEventMemberBanned
+	}
+	actor := currentUser(r)
+	s.postSystemMessage(r.Context(), roomID, SystemMessageMetadata{
+		Event:    event,
+		ActorID:  actor.UserID,
+		Actor:    actor.Username,
+		TargetID: target.ID,
+		Target:   target.Username,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unbanMember 解除封禁，用户需要重新加入聊天室
+func (s *Server) unbanMember(w http.ResponseWriter, r *http.Request) {
+	targetID, err := targetUserIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	roomID, _, target, _, err := s.moderationTarget(r, targetID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	action := store.ModerationAction{RoomID: roomID, ActorID: currentUser(r).UserID, TargetID: target.ID, Action: store.ModerationUnban}
+	err = s.moderation.UnbanMember(r.Context(), action)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, apiError(http.StatusNotFound, "User is not banned from this room"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	s.publishModeration(r, roomID, action, target, "")
+	s.notifyModeration(r.Context(), action)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bulkDeleteMessages owner 和 moderator 一次删除聊天室中的多条消息，用于清理刷屏。
+// 所有 ID 都必须属于该聊天室，否则不删除任何消息
+func (s *Server) bulkDeleteMessages(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	seen := make(map[int]bool, len(req.MessageIDs))
+	ids := req.MessageIDs[:0]
+	for _, id := range req.MessageIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "message_ids is required", Field: "message_ids"})
+		return
+	}
+	if len(ids) > maxBulkDeleteMessages {
+		writeError(w, r, &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("At most %d messages can be deleted at once", maxBulkDeleteMessages),
+			Field:   "message_ids",
+		})
+		return
+	}
+
+	if _, err := s.requireModerator(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	deleted, err := s.moderation.DeleteRoomMessages(r.Context(), roomID, ids)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, &APIError{Status: http.StatusUnprocessableEntity, Message: "Some messages do not belong to this room", Field: "message_ids"})
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if len(deleted) > 0 {
+		s.hub.Publish(ws.Event{Type: ws.EventMessagesBulkDeleted, RoomID: roomID, Data: MessagesBulkDeletedEvent{
+			RoomID:     roomID,
+			MessageIDs: deleted,
+		}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"deleted_count": len(deleted)})
+}
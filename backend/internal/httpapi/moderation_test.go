@@ -0,0 +1,195 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// moderationRoom 创建聊天室：owner 是创建者，mod1 和 mod2 是 moderator，member1 和 member2 是普通成员，
+// outsider 没有加入。返回聊天室、各用户和他们的 token
+func moderationRoom(t *testing.T, ts *testServer) (store.ChatRoom, map[string]store.User, map[string]string) {
+	t.Helper()
+	users := make(map[string]store.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"owner", "mod1", "mod2", "member1", "member2", "outsider"} {
+		users[name], tokens[name] = ts.addUser(name)
+	}
+	owner := users["owner"]
+	room := ts.store.AddRoom("general", "", &owner.ID)
+	ctx := context.Background()
+	for _, name := range []string{"owner", "mod1", "mod2", "member1", "member2"} {
+		if _, err := ts.store.JoinRoom(ctx, room.ID, users[name].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"mod1", "mod2"} {
+		if err := ts.store.SetMemberRole(ctx, store.ModerationAction{RoomID: room.ID, TargetID: users[name].ID}, store.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room, users, tokens
+}
+
+// TestModerationHierarchy 只有 owner 可以修改角色，owner 和 moderator 只能踢出或封禁角色比自己低的用户
+func TestModerationHierarchy(t *testing.T) {
+	tests := []struct {
+		actor  string
+		action string
+		target string
+		status int
+	}{
+		{"member1", "promote", "member2", http.StatusForbidden},
+		{"mod1", "promote", "member2", http.StatusForbidden},
+		{"owner", "promote", "member1", http.StatusNoContent},
+		{"owner", "promote", "mod1", http.StatusNoContent},
+		{"owner", "demote", "mod1", http.StatusNoContent},
+		{"owner", "demote", "owner", http.StatusBadRequest},
+		{"owner", "promote", "outsider", http.StatusNotFound},
+		{"mod1", "demote", "mod2", http.StatusForbidden},
+		{"outsider", "kick", "member1", http.StatusForbidden},
+		{"member1", "kick", "member2", http.StatusForbidden},
+		{"mod1", "kick", "member1", http.StatusNoContent},
+		{"mod1", "kick", "mod2", http.StatusForbidden},
+		{"mod1", "kick", "owner", http.StatusForbidden},
+		{"mod1", "kick", "mod1", http.StatusForbidden},
+		{"mod1", "kick", "outsider", http.StatusNotFound},
+		{"owner", "kick", "mod1", http.StatusNoContent},
+		{"mod1", "ban", "member1", http.StatusNoContent},
+		{"mod1", "ban", "mod2", http.StatusForbidden},
+		{"mod1", "ban", "outsider", http.StatusNoContent},
+		{"owner", "ban", "mod1", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %s", tt.actor, tt.action, tt.target), func(t *testing.T) {
+			ts := newTestServer(t)
+			room, users, tokens := moderationRoom(t, ts)
+			path := fmt.Sprintf("/api/rooms/%d/members/%d/%s", room.ID, users[tt.target].ID, tt.action)
+			decodeResponse(t, ts.do("POST", path, tokens[tt.actor], nil), tt.status, nil)
+			if tt.status != http.StatusNoContent {
+				return
+			}
+
+			role, err := ts.store.GetMemberRole(context.Background(), room.ID, users[tt.target].ID)
+			switch tt.action {
+			case "promote":
+				if role != store.RoleModerator {
+					t.Fatalf("role = %q, %v after promote", role, err)
+				}
+			case "demote":
+				if role != store.RoleMember {
+					t.Fatalf("role = %q, %v after demote", role, err)
+				}
+			default:
+				if err == nil {
+					t.Fatalf("target is still a member with role %q", role)
+				}
+			}
+		})
+	}
+}
+
+// TestBanBlocksRoomAccess 被封禁的用户收到通知，不能重新加入、发送消息或读取历史，解除封禁后可以重新加入
+func TestBanBlocksRoomAccess(t *testing.T) {
+	ts := newTestServer(t)
+	room, users, tokens := moderationRoom(t, ts)
+	target := users["member1"]
+	// 订阅所有聊天室的连接在封禁后保持打开，用另一个聊天室确认事件已经送达
+	other := ts.store.AddRoom("random", "", &target.ID)
+	events := &eventRecorder{events: make(chan ws.Event, 100)}
+	ts.hub.Register(ws.NewClient(ts.hub, events, ws.ClientOptions{
+		UserID: target.ID,
+		Rooms:  map[int]bool{room.ID: true, other.ID: true},
+	}))
+
+	rec := ts.do("POST", "/api/rooms/"+fmt.Sprint(room.ID)+"/bans", tokens["mod1"], BanRequest{UserID: target.ID, Reason: "spam"})
+	decodeResponse(t, rec, http.StatusNoContent, nil)
+	received := events.drain(t, ts, other.ID)
+	if got := countEvents(received, ws.EventRemovedFromRoom); got != 1 {
+		t.Fatalf("banned user received %d removed_from_room events, want 1", got)
+	}
+	ts.hub.Publish(ws.Event{Type: ws.EventMessage, RoomID: room.ID})
+	if got := countEvents(events.drain(t, ts, other.ID), ws.EventMessage); got != 0 {
+		t.Fatal("banned user still receives events from the room")
+	}
+
+	roomPath := fmt.Sprintf("/api/rooms/%d", room.ID)
+	decodeResponse(t, ts.do("POST", roomPath+"/join", tokens["member1"], nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("POST", "/api/messages", tokens["member1"], CreateMessageRequest{RoomID: room.ID, Content: "hi"}), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("GET", roomPath+"/messages", tokens["member1"], nil), http.StatusForbidden, nil)
+
+	unban := fmt.Sprintf("%s/bans/%d", roomPath, target.ID)
+	decodeResponse(t, ts.do("DELETE", unban, tokens["member2"], nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("DELETE", unban, tokens["mod1"], nil), http.StatusNoContent, nil)
+	decodeResponse(t, ts.do("DELETE", unban, tokens["mod1"], nil), http.StatusNotFound, nil)
+	decodeResponse(t, ts.do("POST", roomPath+"/join", tokens["member1"], nil), http.StatusNoContent, nil)
+}
+
+// TestBulkDeleteMessages 批量删除只能由 owner 和 moderator 执行，所有消息必须属于该聊天室，
+// 删除后广播一个事件
+func TestBulkDeleteMessages(t *testing.T) {
+	ts := newTestServer(t)
+	room, users, tokens := moderationRoom(t, ts)
+	owner := users["owner"]
+	other := ts.store.AddRoom("random", "", &owner.ID)
+	ts.store.JoinRoom(context.Background(), other.ID, users["member1"].ID)
+	path := fmt.Sprintf("/api/rooms/%d/messages/bulk-delete", room.ID)
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		ids = append(ids, postMessage(t, ts, tokens["member1"], room.ID, fmt.Sprintf("spam %d", i)))
+	}
+	kept := postMessage(t, ts, tokens["member2"], room.ID, "kept")
+	foreign := postMessage(t, ts, tokens["member1"], other.ID, "elsewhere")
+
+	for _, name := range []string{"member1", "outsider"} {
+		decodeResponse(t, ts.do("POST", path, tokens[name], BulkDeleteRequest{MessageIDs: ids}), http.StatusForbidden, nil)
+	}
+
+	tooMany := make([]int, maxBulkDeleteMessages+1)
+	for i := range tooMany {
+		tooMany[i] = i + 1
+	}
+	var apiErr APIError
+	decodeResponse(t, ts.do("POST", path, tokens["mod1"], BulkDeleteRequest{MessageIDs: tooMany}), http.StatusBadRequest, &apiErr)
+	if apiErr.Field != "message_ids" {
+		t.Fatalf("error = %+v, want field message_ids", apiErr)
+	}
+
+	// 混入其他聊天室的消息时整个请求被拒绝，任何消息都不删除
+	rec := ts.do("POST", path, tokens["mod1"], BulkDeleteRequest{MessageIDs: append([]int{foreign}, ids...)})
+	decodeResponse(t, rec, http.StatusUnprocessableEntity, nil)
+	for _, id := range append([]int{foreign}, ids...) {
+		if _, err := ts.store.GetMessage(context.Background(), id); err != nil {
+			t.Fatalf("message %d was deleted by a rejected request: %v", id, err)
+		}
+	}
+
+	events := ts.subscribe(users["member2"].ID, room.ID)
+	var resp map[string]int
+	decodeResponse(t, ts.do("POST", path, tokens["mod1"], BulkDeleteRequest{MessageIDs: append(ids, ids[0])}), http.StatusOK, &resp)
+	if resp["deleted_count"] != len(ids) {
+		t.Fatalf("response = %v, want deleted_count %d", resp, len(ids))
+	}
+	// 之前发送的消息可能在订阅之后才广播，只检查批量删除事件
+	var bulk []ws.Event
+	for _, e := range events.drain(t, ts, room.ID) {
+		if e.Type == ws.EventMessagesBulkDeleted {
+			bulk = append(bulk, e)
+		}
+	}
+	if len(bulk) != 1 {
+		t.Fatalf("received %d messages_bulk_deleted events, want 1", len(bulk))
+	}
+	event := bulk[0].Data.(MessagesBulkDeletedEvent)
+	if event.RoomID != room.ID || fmt.Sprint(event.MessageIDs) != fmt.Sprint(ids) {
+		t.Fatalf("event = %+v, want room %d and messages %v", event, room.ID, ids)
+	}
+	if _, err := ts.store.GetMessage(context.Background(), kept); err != nil {
+		t.Fatalf("message outside the request was deleted: %v", err)
+	}
+}
@@ -0,0 +1,454 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultNotificationsPageSize = 20
+	maxNotificationsPageSize     = 100
+
+	// 一条消息最多处理的提及数，防止刷屏
+	maxMentionsPerMessage = 20
+
+	// POST /api/notifications/mark-read 一次最多标记的通知数
+	maxMarkReadIDs = 100
+)
+
+type Notification = store.Notification
+
+// NotificationPayload 通知 payload 中的结构化数据，客户端按通知的 type 读取需要的字段
+type NotificationPayload struct {
+	MessageID      int `json:"message_id,omitempty"`
+	RoomID         int `json:"room_id,omitempty"`
+	ConversationID int `json:"conversation_id,omitempty"`
+	// ParentMessageID reply 通知中被回复的消息
+	ParentMessageID int `json:"parent_message_id,omitempty"`
+	ActorID         int `json:"actor_id,omitempty"`
+	// Emoji reaction 通知中添加的表情
+	Emoji string `json:"emoji,omitempty"`
+	// Action、Reason moderation 通知中的管理操作和原因
+	Action string `json:"action,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// messagePayload 消息产生的通知共用的 payload 字段
+func messagePayload(msg Message) NotificationPayload {
+	p := NotificationPayload{MessageID: msg.ID, RoomID: msg.RoomID, ActorID: msg.UserID}
+	if msg.ConversationID != nil {
+		p.ConversationID = *msg.ConversationID
+	}
+	return p
+}
+
+// encode 编码失败时返回 nil，store 按空对象保存
+func (p NotificationPayload) encode() json.RawMessage {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// mentionPattern 匹配 @username，@ 前面不能是字母、数字或下划线（排除邮箱地址）
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_]+)`)
+
+// parseMentions 返回消息中提及的用户名，去重并保持出现顺序
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := m[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		usernames = append(usernames, name)
+		if len(usernames) == maxMentionsPerMessage {
+			break
+		}
+	}
+	return usernames
+}
+
+// notifyMentions 记录聊天室消息中提及的用户，向他们的所有连接推送 mention 事件和通知，离线用户还会收到推送（静音了该聊天室的用户除外）。
+// 被提及的用户不需要是聊天室成员，提及自己不会产生通知；失败只记录日志，不影响消息发送。
+func (s *Server) notifyMentions(ctx context.Context, msg Message) {
+	if msg.ConversationID != nil {
+		return
+	}
+	usernames := parseMentions(msg.Content)
+	if len(usernames) == 0 {
+		return
+	}
+
+	notifications, err := s.notifications.CreateMentionNotifications(ctx, msg.ID, usernames, msg.UserID, messagePayload(msg).encode())
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to create mention notifications", "message_id", msg.ID, "error", err)
+		return
+	}
+	for _, n := range notifications {
+		s.hub.Publish(ws.Event{Type: ws.EventMention, Data: msg, UserIDs: []int{n.UserID}, SenderID: msg.UserID})
+		s.publishNotification(ctx, n)
+		s.enqueuePush(ctx, pushEvent{userID: n.UserID, msg: msg})
+	}
+}
+
+// notifyDirectMessage 接收者在本实例没有连接时为私信创建通知，上线后可以在通知列表中看到
+func (s *Server) notifyDirectMessage(ctx context.Context, msg Message, conv *store.Conversation) {
+	recipient := conv.UserAID
+	if recipient == msg.UserID {
+		recipient = conv.UserBID
+	}
+	if s.hub.IsOnline(recipient) {
+		return
+	}
+	n, err := s.notifications.CreateNotification(ctx, Notification{
+		UserID:    recipient,
+		Type:      store.NotificationDirectMessage,
+		MessageID: msg.ID,
+		Payload:   messagePayload(msg).encode(),
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to create direct message notification", "message_id", msg.ID, "error", err)
+		return
+	}
+	s.publishNotification(ctx, n)
+}
+
+// notifyReply 通知被回复消息的发送者。回复自己、webhook 消息以及回复中已经提及了对方时不通知
+func (s *Server) notifyReply(ctx context.Context, msg Message) {
+	if msg.ParentMessageID == nil {
+		return
+	}
+	parent, err := s.messages.GetMessage(ctx, *msg.ParentMessageID)
+	if err != nil {
+		loggerFromContext(ctx).Warn("failed to load replied message", "message_id", msg.ID, "error", err)
+		return
+	}
+	if parent.UserID == 0 || parent.UserID == msg.UserID {
+		return
+	}
+	if msg.ConversationID == nil {
+		for _, name := range parseMentions(msg.Content) {
+			if name == parent.Username {
+				return
+			}
+		}
+	}
+	payload := messagePayload(msg)
+	payload.ParentMessageID = parent.ID
+	s.notifyMessageActivity(ctx, Notification{
+		UserID:    parent.UserID,
+		Type:      store.NotificationReply,
+		MessageID: msg.ID,
+		ActorID:   msg.UserID,
+		Payload:   payload.encode(),
+	}, msg.RoomID)
+}
+
+// notifyReaction 通知消息的发送者有人添加了表情，对自己的消息添加表情不通知
+func (s *Server) notifyReaction(ctx context.Context, msg Message, actorID int, emoji string) {
+	if msg.UserID == 0 || msg.UserID == actorID {
+		return
+	}
+	payload := messagePayload(msg)
+	payload.ActorID = actorID
+	payload.Emoji = emoji
+	s.notifyMessageActivity(ctx, Notification{
+		UserID:    msg.UserID,
+		Type:      store.NotificationReaction,
+		MessageID: msg.ID,
+		ActorID:   actorID,
+		Action:    emoji,
+		Payload:   payload.encode(),
+	}, msg.RoomID)
+}
+
+// notifyMessageActivity 创建回复和表情通知。聊天室中只有通知级别为 all 的用户才会收到，私信（roomID 为 0）总是通知
+func (s *Server) notifyMessageActivity(ctx context.Context, n Notification, roomID int) {
+	if roomID != 0 {
+		level, err := s.notifications.GetRoomNotificationLevel(ctx, n.UserID, roomID)
+		if err != nil {
+			loggerFromContext(ctx).Warn("failed to load notification level", "room_id", roomID, "user_id", n.UserID, "error", err)
+			return
+		}
+		if level != store.NotificationLevelAll {
+			return
+		}
+	}
+	created, err := s.notifications.CreateNotification(ctx, n)
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to create notification", "type", n.Type, "message_id", n.MessageID, "error", err)
+		return
+	}
+	s.publishNotification(ctx, created)
+}
+
+// notifyModeration 通知被管理的用户，失败只记录日志
+func (s *Server) notifyModeration(ctx context.Context, action store.ModerationAction) {
+	n, err := s.notifications.CreateNotification(ctx, Notification{
+		UserID:  action.TargetID,
+		Type:    store.NotificationModeration,
+		RoomID:  action.RoomID,
+		ActorID: action.ActorID,
+		Action:  action.Action,
+		Content: action.Reason,
+		Payload: NotificationPayload{
+			RoomID:  action.RoomID,
+			ActorID: action.ActorID,
+			Action:  action.Action,
+			Reason:  action.Reason,
+		}.encode(),
+	})
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to create moderation notification", "room_id", action.RoomID, "user_id", action.TargetID, "error", err)
+		return
+	}
+	s.publishNotification(ctx, n)
+}
+
+// publishNotification 把新通知和最新的未读数推送给用户的所有连接
+func (s *Server) publishNotification(ctx context.Context, n Notification) {
+	s.hub.Publish(ws.Event{Type: ws.EventNotification, Data: n, UserIDs: []int{n.UserID}})
+	s.publishBadge(ctx, n.UserID)
+}
+
+// publishBadge 推送未读数，客户端不需要轮询 GET /api/users/me/badge
+func (s *Server) publishBadge(ctx context.Context, userID int) {
+	counts, err := s.notifications.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		loggerFromContext(ctx).Warn("failed to count unread notifications", "user_id", userID, "error", err)
+		return
+	}
+	s.hub.Publish(ws.Event{Type: ws.EventBadge, Data: counts, UserIDs: []int{userID}})
+}
+
+// getMentions 返回提及当前用户的消息
+func (s *Server) getMentions(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, defaultNotificationsPageSize, maxNotificationsPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	messages, err := s.notifications.ListMentions(r.Context(), currentUser(r).UserID, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// NotificationsResponse GET /api/notifications 的响应。NextBeforeID 为下一页的 before_id，没有更多通知时为 nil
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextBeforeID  *int           `json:"next_before_id"`
+}
+
+// getNotifications 按时间倒序返回通知，?unread=true 时只返回未读的，?before_id= 翻页
+func (s *Server) getNotifications(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, defaultNotificationsPageSize, maxNotificationsPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	q := store.NotificationQuery{Limit: limit, Offset: offset}
+	if v := r.URL.Query().Get("unread"); v != "" {
+		if q.UnreadOnly, err = strconv.ParseBool(v); err != nil {
+			writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "unread must be true or false", Field: "unread"})
+			return
+		}
+	}
+	if v := r.URL.Query().Get("before_id"); v != "" {
+		if q.BeforeID, err = strconv.Atoi(v); err != nil || q.BeforeID < 1 {
+			writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "before_id must be a positive integer", Field: "before_id"})
+			return
+		}
+	}
+
+	userID := currentUser(r).UserID
+	notifications, err := s.notifications.ListNotifications(r.Context(), userID, q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	counts, err := s.notifications.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	resp := NotificationsResponse{Notifications: notifications, UnreadCount: counts.Unread}
+	if len(notifications) == limit {
+		resp.NextBeforeID = &notifications[len(notifications)-1].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid notification ID"))
+		return
+	}
+
+	userID := currentUser(r).UserID
+	if err := s.notifications.MarkNotificationRead(r.Context(), userID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.publishBadge(r.Context(), userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkNotificationsReadRequest POST /api/notifications/mark-read 的请求体
+type MarkNotificationsReadRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// MarkReadResponse 批量标记已读的响应，UnreadCount 为标记之后的未读数
+type MarkReadResponse struct {
+	Marked      int `json:"marked"`
+	UnreadCount int `json:"unread_count"`
+}
+
+// markNotificationsRead 批量标记已读，不属于当前用户的 ID 被忽略
+func (s *Server) markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	var req MarkNotificationsReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxMarkReadIDs {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "ids must contain between 1 and 100 notification IDs", Field: "ids"})
+		return
+	}
+
+	userID := currentUser(r).UserID
+	marked, err := s.notifications.MarkNotificationsRead(r.Context(), userID, req.IDs)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.writeMarkRead(w, r, userID, marked)
+}
+
+func (s *Server) markAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID := currentUser(r).UserID
+	marked, err := s.notifications.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.writeMarkRead(w, r, userID, marked)
+}
+
+// writeMarkRead 返回最新的未读数，有通知被标记时同时推送给用户的所有连接
+func (s *Server) writeMarkRead(w http.ResponseWriter, r *http.Request, userID, marked int) {
+	counts, err := s.notifications.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if marked > 0 {
+		s.hub.Publish(ws.Event{Type: ws.EventBadge, Data: counts, UserIDs: []int{userID}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MarkReadResponse{Marked: marked, UnreadCount: counts.Unread})
+}
+
+// getBadge 返回未读通知数，供前端轮询
+func (s *Server) getBadge(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.notifications.CountUnreadNotifications(r.Context(), currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// RoomNotificationSettings GET/PUT /api/rooms/{id}/notifications 的请求和响应
+type RoomNotificationSettings struct {
+	RoomID int    `json:"room_id"`
+	Level  string `json:"level"`
+}
+
+var notificationLevels = map[string]bool{
+	store.NotificationLevelAll:          true,
+	store.NotificationLevelMentionsOnly: true,
+	store.NotificationLevelMuted:        true,
+}
+
+// getRoomNotifications 返回当前用户在聊天室中的通知级别
+func (s *Server) getRoomNotifications(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	userID := currentUser(r).UserID
+
+	if err := s.requireMember(r.Context(), roomID, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	level, err := s.notifications.GetRoomNotificationLevel(r.Context(), userID, roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomNotificationSettings{RoomID: roomID, Level: level})
+}
+
+// updateRoomNotifications 设置通知级别，muted 时不再收到该聊天室的提及通知
+func (s *Server) updateRoomNotifications(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req RoomNotificationSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if !notificationLevels[req.Level] {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "level must be one of all, mentions_only, muted", Field: "level"})
+		return
+	}
+
+	userID := currentUser(r).UserID
+	if err := s.requireMember(r.Context(), roomID, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.notifications.SetRoomNotificationLevel(r.Context(), userID, roomID, req.Level); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomNotificationSettings{RoomID: roomID, Level: req.Level})
+}
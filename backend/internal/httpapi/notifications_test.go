@@ -0,0 +1,177 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"hi @bob", []string{"bob"}},
+		{"@bob and @carol, @bob again", []string{"bob", "carol"}},
+		{"mail alice@example.com", nil},
+		{"@@bob", nil},
+		{"(@bob)", []string{"bob"}},
+		{"no mentions", nil},
+	}
+	for _, tt := range tests {
+		got := parseMentions(tt.content)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// TestMentionUserOutsideRoom 被提及的用户不是聊天室成员时仍然收到通知和实时事件
+func TestMentionUserOutsideRoom(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.addUser("alice")
+	bob, bobToken := ts.addUser("bob")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	// bob 只在另一个聊天室里有连接
+	other := ts.store.AddRoom("random", "", &bob.ID)
+	ts.store.JoinRoom(context.Background(), other.ID, bob.ID)
+	events := ts.subscribe(bob.ID, other.ID)
+
+	decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "hi @bob"}), http.StatusOK, nil)
+
+	var resp NotificationsResponse
+	decodeResponse(t, ts.do("GET", "/api/notifications", bobToken, nil), http.StatusOK, &resp)
+	if len(resp.Notifications) != 1 || resp.UnreadCount != 1 {
+		t.Fatalf("notifications = %+v, unread %d, want one unread mention", resp.Notifications, resp.UnreadCount)
+	}
+	n := resp.Notifications[0]
+	if n.Type != store.NotificationMention || n.FromUsername != "alice" || n.RoomID != room.ID || n.Read {
+		t.Fatalf("notification = %+v", n)
+	}
+
+	received := events.drain(t, ts, other.ID)
+	if countEvents(received, ws.EventMention) != 1 || countEvents(received, ws.EventNotification) != 1 {
+		t.Fatalf("bob received %+v, want a mention and a notification event", received)
+	}
+	if countEvents(received, ws.EventMessage) != 0 {
+		t.Fatal("bob received the room message without being a member")
+	}
+
+	decodeResponse(t, ts.do("POST", "/api/notifications/"+strconv.Itoa(n.ID)+"/read", bobToken, nil), http.StatusNoContent, nil)
+	decodeResponse(t, ts.do("GET", "/api/notifications", bobToken, nil), http.StatusOK, &resp)
+	if resp.UnreadCount != 0 || !resp.Notifications[0].Read {
+		t.Fatalf("after marking read: unread %d, notifications %+v", resp.UnreadCount, resp.Notifications)
+	}
+}
+
+// TestMentionWithoutNotification 提及不存在的用户或自己不产生通知
+func TestMentionWithoutNotification(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.addUser("alice")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+
+	decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "@alice @nobody"}), http.StatusOK, nil)
+
+	var resp NotificationsResponse
+	decodeResponse(t, ts.do("GET", "/api/notifications", aliceToken, nil), http.StatusOK, &resp)
+	if len(resp.Notifications) != 0 || resp.UnreadCount != 0 {
+		t.Fatalf("notifications = %+v, want none", resp.Notifications)
+	}
+}
+
+// TestMarkReadDecreasesBadge 标记已读之后响应、GET /api/notifications 和 badge 事件中的未读数同步减少，
+// 其他用户的通知 ID 被忽略
+func TestMarkReadDecreasesBadge(t *testing.T) {
+	ts := newTestServer(t)
+	alice, aliceToken := ts.addUser("alice")
+	bob, bobToken := ts.addUser("bob")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, bob.ID)
+	events := ts.subscribe(bob.ID, room.ID)
+
+	var mention Message
+	for i := 0; i < 3; i++ {
+		decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "ping @bob"}), http.StatusOK, &mention)
+	}
+	// alice 自己的通知，bob 不能标记
+	decodeResponse(t, ts.do("POST", "/api/messages", bobToken, CreateMessageRequest{RoomID: room.ID, Content: "hi @alice"}), http.StatusOK, nil)
+	unread := func() int {
+		t.Helper()
+		var resp NotificationsResponse
+		decodeResponse(t, ts.do("GET", "/api/notifications?unread=true", bobToken, nil), http.StatusOK, &resp)
+		if len(resp.Notifications) != resp.UnreadCount {
+			t.Fatalf("%d unread notifications listed, unread_count %d", len(resp.Notifications), resp.UnreadCount)
+		}
+		return resp.UnreadCount
+	}
+
+	var resp NotificationsResponse
+	decodeResponse(t, ts.do("GET", "/api/notifications", bobToken, nil), http.StatusOK, &resp)
+	if resp.UnreadCount != 3 || len(resp.Notifications) != 3 {
+		t.Fatalf("unread_count = %d, %d notifications, want 3", resp.UnreadCount, len(resp.Notifications))
+	}
+	var payload NotificationPayload
+	if err := json.Unmarshal(resp.Notifications[0].Payload, &payload); err != nil {
+		t.Fatalf("payload %s: %v", resp.Notifications[0].Payload, err)
+	}
+	if payload != (NotificationPayload{MessageID: mention.ID, RoomID: room.ID, ActorID: alice.ID}) {
+		t.Fatalf("payload = %+v", payload)
+	}
+	var aliceResp NotificationsResponse
+	decodeResponse(t, ts.do("GET", "/api/notifications", aliceToken, nil), http.StatusOK, &aliceResp)
+	events.drain(t, ts, room.ID)
+
+	var marked MarkReadResponse
+	ids := []int{resp.Notifications[0].ID, resp.Notifications[1].ID, aliceResp.Notifications[0].ID}
+	decodeResponse(t, ts.do("POST", "/api/notifications/mark-read", bobToken, MarkNotificationsReadRequest{IDs: ids}), http.StatusOK, &marked)
+	if marked.Marked != 2 || marked.UnreadCount != 1 || unread() != 1 {
+		t.Fatalf("mark-read = %+v, want 2 marked and 1 unread", marked)
+	}
+	var badges []store.NotificationCounts
+	for _, e := range events.drain(t, ts, room.ID) {
+		if e.Type == ws.EventBadge {
+			badges = append(badges, e.Data.(store.NotificationCounts))
+		}
+	}
+	if len(badges) != 1 || badges[0].Unread != 1 {
+		t.Fatalf("badge events = %+v, want one with 1 unread", badges)
+	}
+	decodeResponse(t, ts.do("GET", "/api/notifications", aliceToken, nil), http.StatusOK, &aliceResp)
+	if aliceResp.UnreadCount != 1 {
+		t.Fatalf("alice unread_count = %d after bob marked her notification", aliceResp.UnreadCount)
+	}
+
+	// 再次标记同样的 ID 不改变未读数，也不推送 badge
+	decodeResponse(t, ts.do("POST", "/api/notifications/mark-read", bobToken, MarkNotificationsReadRequest{IDs: ids}), http.StatusOK, &marked)
+	if marked.Marked != 0 || marked.UnreadCount != 1 {
+		t.Fatalf("repeated mark-read = %+v", marked)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventBadge); got != 0 {
+		t.Fatalf("repeated mark-read pushed %d badge events", got)
+	}
+
+	decodeResponse(t, ts.do("POST", "/api/notifications/mark-all-read", bobToken, nil), http.StatusOK, &marked)
+	if marked.Marked != 1 || marked.UnreadCount != 0 || unread() != 0 {
+		t.Fatalf("mark-all-read = %+v, want 1 marked and none unread", marked)
+	}
+	var counts store.NotificationCounts
+	decodeResponse(t, ts.do("GET", "/api/users/me/badge", bobToken, nil), http.StatusOK, &counts)
+	if counts.Unread != 0 {
+		t.Fatalf("badge = %+v, want no unread notifications", counts)
+	}
+}
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originPolicy 允许访问 API 和 WebSocket 的来源列表。
+// 支持 * 匹配所有来源，以及 https://*.example.com 形式的子域名通配符；
+// 协议和端口必须完全一致（未写端口时按协议的默认端口比较）。
+type originPolicy struct {
+	allowAll bool
+	devMode  bool
+	patterns []originPattern
+}
+
+type originPattern struct {
+	scheme   string
+	host     string // 通配符模式下为去掉 "*." 的域名后缀
+	port     string
+	wildcard bool
+}
+
+// newOriginPolicy 解析逗号分隔的来源列表，devMode 为 true 时允许所有来源
+func newOriginPolicy(origins string, devMode bool) *originPolicy {
+	p := &originPolicy{allowAll: devMode, devMode: devMode}
+	for _, origin := range splitList(origins) {
+		if origin == "*" {
+			p.allowAll = true
+			continue
+		}
+		pattern, ok := parseOriginPattern(origin)
+		if !ok {
+			continue
+		}
+		p.patterns = append(p.patterns, pattern)
+	}
+	return p
+}
+
+func parseOriginPattern(origin string) (originPattern, bool) {
+	var wildcard bool
+	if i := strings.Index(origin, "://*."); i >= 0 {
+		wildcard = true
+		origin = origin[:i+3] + origin[i+5:]
+	}
+	scheme, host, port, ok := splitOrigin(origin)
+	if !ok {
+		return originPattern{}, false
+	}
+	return originPattern{scheme: scheme, host: host, port: port, wildcard: wildcard}, true
+}
+
+// splitOrigin 把 Origin 拆分为协议、主机名和端口，端口缺省时补全默认值
+func splitOrigin(origin string) (scheme, host, port string, ok bool) {
+	u, err := url.Parse(strings.ToLower(strings.TrimSpace(origin)))
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		return "", "", "", false
+	}
+	port = u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+	return u.Scheme, u.Hostname(), port, true
+}
+
+// allowed 判断来源是否在允许列表中
+func (p *originPolicy) allowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	scheme, host, port, ok := splitOrigin(origin)
+	if !ok {
+		return false
+	}
+	for _, pattern := range p.patterns {
+		if pattern.scheme != scheme || pattern.port != port {
+			continue
+		}
+		if pattern.wildcard {
+			if strings.HasSuffix(host, "."+pattern.host) {
+				return true
+			}
+		} else if host == pattern.host {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequest 用作 WebSocket 升级的 CheckOrigin。
+// 没有 Origin 头的请求不是来自浏览器，不存在跨站劫持的问题，直接放行。
+func (p *originPolicy) checkRequest(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || p.allowed(origin)
+}
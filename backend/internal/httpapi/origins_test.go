@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"net/http"
+	"testing"
+
+	"chatapp/internal/config"
+)
+
+func TestOriginPolicyAllowed(t *testing.T) {
+	policy := newOriginPolicy("http://localhost:3000, https://chat.example.com, https://*.example.org, http://*.dev.test:8080, not a url", false)
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"http://localhost:3000", true},
+		{"HTTP://LOCALHOST:3000", true},
+		{"http://localhost:3001", false},
+		{"http://localhost", false},
+		{"https://localhost:3000", false},
+
+		{"https://chat.example.com", true},
+		{"https://chat.example.com:443", true},
+		{"https://chat.example.com:8443", false},
+		{"http://chat.example.com", false},
+		{"https://evil.chat.example.com", false},
+		{"https://chat.example.com.evil.com", false},
+
+		{"https://app.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"http://app.example.org", false},
+		{"https://app.example.org:444", false},
+
+		{"http://api.dev.test:8080", true},
+		{"http://api.dev.test", false},
+
+		{"", false},
+		{"null", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := policy.allowed(tt.origin); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestOriginPolicyAllowAll(t *testing.T) {
+	for _, policy := range []*originPolicy{
+		newOriginPolicy("*", false),
+		newOriginPolicy("http://localhost:3000", true),
+	} {
+		if !policy.allowed("https://anything.example.com") {
+			t.Fatalf("policy %+v rejected an origin", policy)
+		}
+	}
+	if newOriginPolicy("", false).allowed("http://localhost:3000") {
+		t.Fatal("empty policy allowed an origin")
+	}
+}
+
+func TestWebSocketRejectsUnlistedOrigin(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.CORS.AllowedOrigins = "https://chat.example.com" })
+	_, token := ts.addUser("alice")
+
+	req := ts.request("GET", "/ws?token="+token, "", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	decodeResponse(t, ts.serve(req), http.StatusForbidden, nil)
+
+	// 没有 Origin 头的请求不是来自浏览器，可以升级
+	if !ts.upgrader.CheckOrigin(ts.request("GET", "/ws", "", nil)) {
+		t.Fatal("request without Origin was rejected")
+	}
+	req = ts.request("GET", "/ws", "", nil)
+	req.Header.Set("Origin", "https://chat.example.com")
+	if !ts.upgrader.CheckOrigin(req) {
+		t.Fatal("listed origin was rejected")
+	}
+}
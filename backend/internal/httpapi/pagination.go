@@ -0,0 +1,143 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/store"
+)
+
+const (
+	defaultMessagesPageSize = 50
+	maxMessagesPageSize     = 100
+)
+
+// parsePagination 读取 ?limit= 和 ?offset=，limit 超过 maxLimit 时截断
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, err error) {
+	limit = defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return 0, 0, &APIError{Status: http.StatusBadRequest, Message: "limit must be a positive integer", Field: "limit"}
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, &APIError{Status: http.StatusBadRequest, Message: "offset must be a non-negative integer", Field: "offset"}
+		}
+	}
+	return limit, offset, nil
+}
+
+// MessagePage GET /api/rooms/{id}/messages 的响应，消息按 ID 升序排列。
+//
+// 以消息 ID 为游标分页，新消息不会造成重复或遗漏：
+//   - 不带游标时返回最新的一页；
+//   - ?before_id=X 返回 ID 小于 X 的一页，向上翻页时传 before_id=oldest_id；
+//   - ?after_id=Y 返回 ID 大于 Y 的一页，向下翻页时传 after_id=newest_id。
+//
+// WebSocket 重连后，客户端用 after_id=当前的 newest_id 拉取断线期间的消息，
+// has_newer 为 true 时继续拉取，直到补齐后再依赖实时推送。
+// 结果为空时 OldestID 和 NewestID 为 0。
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	OldestID int       `json:"oldest_id"`
+	NewestID int       `json:"newest_id"`
+	HasOlder bool      `json:"has_older"`
+	HasNewer bool      `json:"has_newer"`
+}
+
+// parseMessagePage 读取 ?before_id=、?after_id=、?limit= 和 ?include_blocked=，两个游标不能同时使用
+func parseMessagePage(r *http.Request) (store.MessagePageOptions, error) {
+	limit, _, err := parsePagination(r, defaultMessagesPageSize, maxMessagesPageSize)
+	if err != nil {
+		return store.MessagePageOptions{}, err
+	}
+	opts := store.MessagePageOptions{Limit: limit}
+
+	query := r.URL.Query()
+	for _, cursor := range []struct {
+		name string
+		dst  *int
+	}{{"before_id", &opts.BeforeID}, {"after_id", &opts.AfterID}} {
+		v := query.Get(cursor.name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 0 {
+			return opts, &APIError{Status: http.StatusBadRequest, Message: cursor.name + " must be a non-negative integer", Field: cursor.name}
+		}
+		*cursor.dst = id
+	}
+	if query.Get("before_id") != "" && query.Get("after_id") != "" {
+		return opts, &APIError{Status: http.StatusBadRequest, Message: "before_id and after_id cannot be used together", Field: "before_id"}
+	}
+	if v := query.Get("include_blocked"); v != "" {
+		if opts.IncludeBlocked, err = strconv.ParseBool(v); err != nil {
+			return opts, &APIError{Status: http.StatusBadRequest, Message: "include_blocked must be true or false", Field: "include_blocked"}
+		}
+	}
+	return opts, nil
+}
+
+// listMessagePage 多取一条判断翻页方向上是否还有消息，另一个方向再查询一条
+func (s *Server) listMessagePage(ctx context.Context, opts store.MessagePageOptions) (MessagePage, error) {
+	limit := opts.Limit
+	opts.Limit = limit + 1
+	messages, err := s.messages.ListRoomMessages(ctx, opts)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	page := MessagePage{}
+	forward := opts.AfterID > 0
+	if len(messages) > limit {
+		// 多取的一条位于翻页方向的末端
+		if forward {
+			messages = messages[:limit]
+			page.HasNewer = true
+		} else {
+			messages = messages[1:]
+			page.HasOlder = true
+		}
+	}
+	page.Messages = messages
+	if len(messages) > 0 {
+		page.OldestID, page.NewestID = messages[0].ID, messages[len(messages)-1].ID
+	}
+
+	// 另一个方向：从游标（或本页的边界）开始查询一条
+	check := store.MessagePageOptions{RoomID: opts.RoomID, ViewerID: opts.ViewerID, IncludeBlocked: opts.IncludeBlocked, Limit: 1}
+	switch {
+	case forward:
+		check.BeforeID = opts.AfterID + 1
+		if page.OldestID > 0 {
+			check.BeforeID = page.OldestID
+		}
+	case opts.BeforeID > 0:
+		check.AfterID = opts.BeforeID - 1
+		if page.NewestID > 0 {
+			check.AfterID = page.NewestID
+		}
+	default:
+		// 最新的一页之后没有更新的消息
+		return page, nil
+	}
+	other, err := s.messages.ListRoomMessages(ctx, check)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	if forward {
+		page.HasOlder = len(other) > 0
+	} else {
+		page.HasNewer = len(other) > 0
+	}
+	return page, nil
+}
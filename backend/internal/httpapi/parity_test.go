@@ -0,0 +1,387 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// parityFixture 每个场景使用的数据：alice 发送消息，bob 是另一个成员，carol 不是成员，
+// 聊天室由 owner 创建，parent 是 bob 之前发的一条消息
+type parityFixture struct {
+	ts     *testServer
+	room   store.ChatRoom
+	alice  store.User
+	bob    store.User
+	carol  store.User
+	parent store.Message
+	tokens map[string]string
+	// sender 发送消息的用户，默认为 alice
+	sender string
+}
+
+// parityResult 两条路径必须完全相同的结果。WebSocket 的 nack 按 WSError 的约定映射：
+// Status 对应 HTTP 状态码，Code 和 Field 与 REST 错误响应体相同；ack 对应 200
+type parityResult struct {
+	Statuses []int
+	Code     string
+	Field    string
+	// Stored 场景发送的消息中保存到聊天室的内容
+	Stored []string
+	// Broadcast 聊天室其他连接收到的 message 事件的内容
+	Broadcast []string
+	// DirectMessages 收到的 direct_message 事件数
+	DirectMessages int
+	// Notifications bob 的未读通知数
+	Notifications int
+}
+
+// parityScenario 参数矩阵中的一行。filter 为该场景主要覆盖的 messageFilters 步骤，
+// 为空表示保存之后的副作用（提及、幂等等），TestParityCoversMessageFilters 据此检查覆盖情况
+type parityScenario struct {
+	name      string
+	filter    string
+	configure func(cfg *config.Config)
+	setup     func(t *testing.T, f *parityFixture)
+	requests  func(f *parityFixture) []CreateMessageRequest
+	want      []int
+}
+
+func roomMessage(content string) func(f *parityFixture) []CreateMessageRequest {
+	return func(f *parityFixture) []CreateMessageRequest {
+		return []CreateMessageRequest{{RoomID: f.room.ID, Content: content}}
+	}
+}
+
+var parityScenarios = []parityScenario{
+	{name: "plain message", filter: "validate", requests: roomMessage("hello"), want: []int{200}},
+	{name: "sanitized content", filter: "validate", requests: roomMessage("  hi\x00 there  "), want: []int{200}},
+	{name: "empty content", filter: "validate", requests: roomMessage("   "), want: []int{400}},
+	{
+		name:      "content too long",
+		filter:    "validate",
+		configure: func(cfg *config.Config) { cfg.MaxMessageLength = 5 },
+		requests:  roomMessage("too long"),
+		want:      []int{422},
+	},
+	{
+		name:   "invalid ttl",
+		filter: "validate",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "hi", TTLSeconds: 1}}
+		},
+		want: []int{400},
+	},
+	{
+		name:      "rate limited",
+		filter:    "rate_limit",
+		configure: func(cfg *config.Config) { cfg.MessageRateLimit, cfg.MessageRateBurst = 1, 2 },
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "1"}, {RoomID: f.room.ID, Content: "2"}, {RoomID: f.room.ID, Content: "3"}}
+		},
+		want: []int{200, 200, 429},
+	},
+	{
+		name:     "not a member",
+		filter:   "membership",
+		setup:    func(t *testing.T, f *parityFixture) { f.sender = "carol" },
+		requests: roomMessage("hi"),
+		want:     []int{403},
+	},
+	{
+		name:   "unknown room",
+		filter: "membership",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			return []CreateMessageRequest{{RoomID: 999, Content: "hi"}}
+		},
+		want: []int{404},
+	},
+	{
+		name:   "banned",
+		filter: "membership",
+		setup: func(t *testing.T, f *parityFixture) {
+			f.ts.store.BanMember(context.Background(), store.ModerationAction{RoomID: f.room.ID, TargetID: f.alice.ID})
+		},
+		requests: roomMessage("hi"),
+		want:     []int{403},
+	},
+	{name: "unknown command", filter: "command", requests: roomMessage("/nosuchcommand"), want: []int{200}},
+	{name: "escaped slash", filter: "command", requests: roomMessage("//not a command"), want: []int{200}},
+	// WebSocket 不能发送私信，聊天室消息不能被当作私信投递
+	{name: "room message is not a direct message", filter: "conversation", requests: roomMessage("hi"), want: []int{200}},
+	{
+		name:   "reply",
+		filter: "parent",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "reply", ParentMessageID: &f.parent.ID}}
+		},
+		want: []int{200},
+	},
+	{
+		name:   "unknown parent",
+		filter: "parent",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			missing := 999
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "reply", ParentMessageID: &missing}}
+		},
+		want: []int{422},
+	},
+	{
+		name:   "too many attachments",
+		filter: "attachments",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			ids := make([]int, maxAttachmentsPerMessage+1)
+			for i := range ids {
+				ids[i] = i + 1
+			}
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "files", Attachments: ids}}
+		},
+		want: []int{400},
+	},
+	{
+		name:   "content filter rejects",
+		filter: "content_filter",
+		configure: func(cfg *config.Config) {
+			cfg.ContentFilter = config.ContentFilterConfig{Kind: "wordlist", Mode: FilterReject, Words: "forbidden"}
+		},
+		requests: roomMessage("a forbidden word"),
+		want:     []int{422},
+	},
+	{
+		name:   "content filter masks",
+		filter: "content_filter",
+		configure: func(cfg *config.Config) {
+			cfg.ContentFilter = config.ContentFilterConfig{Kind: "wordlist", Mode: FilterMask, Words: "forbidden"}
+		},
+		requests: roomMessage("a forbidden word"),
+		want:     []int{200},
+	},
+	{
+		name:   "profanity blocked",
+		filter: "profanity",
+		setup: func(t *testing.T, f *parityFixture) {
+			f.ts.store.SaveProfanitySettings(context.Background(), f.room.ID, store.ProfanitySettings{Enabled: true, Action: store.ProfanityBlock})
+		},
+		requests: roomMessage("you arse"),
+		want:     []int{422},
+	},
+	{
+		name:   "profanity redacted",
+		filter: "profanity",
+		setup: func(t *testing.T, f *parityFixture) {
+			f.ts.store.SaveProfanitySettings(context.Background(), f.room.ID, store.ProfanitySettings{Enabled: true, Action: store.ProfanityRedact})
+		},
+		requests: roomMessage("you arse"),
+		want:     []int{200},
+	},
+	{
+		name:   "slow mode",
+		filter: "slow_mode",
+		setup: func(t *testing.T, f *parityFixture) {
+			seconds := 60
+			f.ts.store.UpdateRoom(context.Background(), f.room.ID, f.room.Name, "", &seconds)
+		},
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			return []CreateMessageRequest{{RoomID: f.room.ID, Content: "1"}, {RoomID: f.room.ID, Content: "2"}}
+		},
+		want: []int{200, 429},
+	},
+	{name: "mention notifies", requests: roomMessage("hi @bob"), want: []int{200}},
+	{
+		name: "client_msg_id retry",
+		requests: func(f *parityFixture) []CreateMessageRequest {
+			req := CreateMessageRequest{RoomID: f.room.ID, Content: "once", ClientMsgID: "7d7a5e9c-3f0b-4c8e-9a51-0d6f1b2c3e4f"}
+			return []CreateMessageRequest{req, req}
+		},
+		want: []int{200, 200},
+	},
+}
+
+// TestParityCoversMessageFilters 新增的 messageFilters 步骤必须在参数矩阵中有对应的场景
+func TestParityCoversMessageFilters(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, sc := range parityScenarios {
+		covered[sc.filter] = true
+	}
+	for _, f := range messageFilters {
+		if !covered[f.name] {
+			t.Errorf("message filter %q has no scenario in parityScenarios", f.name)
+		}
+	}
+}
+
+// TestMessageIngestionParity 每个场景分别通过 POST /api/messages 和 WebSocket 执行，
+// 状态码、错误、保存的消息、广播和通知必须完全相同
+func TestMessageIngestionParity(t *testing.T) {
+	paths := []struct {
+		name string
+		send func(t *testing.T, f *parityFixture, reqs []CreateMessageRequest) parityResult
+	}{
+		{"rest", sendREST},
+		{"websocket", sendWebSocket},
+	}
+	for _, sc := range parityScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			results := make([]parityResult, len(paths))
+			for i, path := range paths {
+				f := newParityFixture(t, sc)
+				events := f.ts.subscribe(f.bob.ID, f.room.ID)
+				result := path.send(t, f, sc.requests(f))
+				f.collect(t, &result, events)
+				if !reflect.DeepEqual(result.Statuses, sc.want) {
+					t.Errorf("%s: statuses = %v, want %v (code %q)", path.name, result.Statuses, sc.want, result.Code)
+				}
+				results[i] = result
+			}
+			if !reflect.DeepEqual(results[0], results[1]) {
+				t.Errorf("rest and websocket differ:\nrest:      %+v\nwebsocket: %+v", results[0], results[1])
+			}
+		})
+	}
+}
+
+func newParityFixture(t *testing.T, sc parityScenario) *parityFixture {
+	t.Helper()
+	var configure []func(*config.Config)
+	if sc.configure != nil {
+		configure = append(configure, sc.configure)
+	}
+	ts := newTestServer(t, configure...)
+	ctx := context.Background()
+	f := &parityFixture{ts: ts, tokens: make(map[string]string), sender: "alice"}
+	owner, _ := ts.addUser("owner")
+	f.alice, f.tokens["alice"] = ts.addUser("alice")
+	f.bob, f.tokens["bob"] = ts.addUser("bob")
+	f.carol, f.tokens["carol"] = ts.addUser("carol")
+	f.room = ts.store.AddRoom("general", "", &owner.ID)
+	for _, id := range []int{owner.ID, f.alice.ID, f.bob.ID} {
+		ts.store.JoinRoom(ctx, f.room.ID, id)
+	}
+	f.parent = store.Message{RoomID: f.room.ID, UserID: f.bob.ID, Content: "parent", MessageType: store.MessageTypeUser}
+	if err := ts.store.InsertMessage(ctx, &f.parent); err != nil {
+		t.Fatal(err)
+	}
+	if sc.setup != nil {
+		sc.setup(t, f)
+	}
+	return f
+}
+
+// collect 记录场景执行后保存的消息、广播和通知
+func (f *parityFixture) collect(t *testing.T, result *parityResult, events *eventRecorder) {
+	t.Helper()
+	ctx := context.Background()
+	messages, err := f.ts.store.ListRoomMessagesAfter(ctx, f.room.ID, f.parent.ID, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range messages {
+		result.Stored = append(result.Stored, msg.Content)
+	}
+	for _, e := range events.drain(t, f.ts, f.room.ID) {
+		switch e.Type {
+		case ws.EventMessage:
+			result.Broadcast = append(result.Broadcast, e.Data.(Message).Content)
+		case ws.EventDirectMessage:
+			result.DirectMessages++
+		}
+	}
+	counts, err := f.ts.store.CountUnreadNotifications(ctx, f.bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Notifications = counts.Unread
+}
+
+func sendREST(t *testing.T, f *parityFixture, reqs []CreateMessageRequest) parityResult {
+	var result parityResult
+	for _, req := range reqs {
+		rec := f.ts.do("POST", "/api/messages", f.tokens[f.sender], req)
+		result.Statuses = append(result.Statuses, rec.Code)
+		if rec.Code != http.StatusOK {
+			var apiErr APIError
+			json.Unmarshal(rec.Body.Bytes(), &apiErr)
+			result.Code, result.Field = apiErr.Code, apiErr.Field
+		}
+	}
+	return result
+}
+
+// wsFrame 客户端读取的服务端事件
+type wsFrame struct {
+	Type      string          `json:"type"`
+	AckID     string          `json:"ack_id"`
+	MessageID int             `json:"message_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func sendWebSocket(t *testing.T, f *parityFixture, reqs []CreateMessageRequest) parityResult {
+	conn := dialWebSocket(t, f.ts, f.tokens[f.sender])
+	var result parityResult
+	for i, req := range reqs {
+		req.AckID = strconv.Itoa(i)
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatal(err)
+		}
+		frame := readUntil(t, conn, func(fr wsFrame) bool {
+			return (fr.Type == ws.EventAck || fr.Type == ws.EventNack) && fr.AckID == req.AckID
+		})
+		if frame.Type == ws.EventAck {
+			result.Statuses = append(result.Statuses, http.StatusOK)
+			continue
+		}
+		var wsErr struct {
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+			Field  string `json:"field"`
+		}
+		if err := json.Unmarshal(frame.Data, &wsErr); err != nil {
+			t.Fatal(err)
+		}
+		result.Statuses = append(result.Statuses, wsErr.Status)
+		result.Code, result.Field = wsErr.Code, wsErr.Field
+	}
+	return result
+}
+
+// dialWebSocket 通过 httptest.Server 建立 WebSocket 连接并读取 welcome 事件
+func dialWebSocket(t *testing.T, ts *testServer, token string) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(ts.handler)
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?token=" + token
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial websocket: %v (response %v)", err, resp)
+	}
+	t.Cleanup(func() { conn.Close() })
+	readUntil(t, conn, func(fr wsFrame) bool { return fr.Type == ws.EventWelcome })
+	return conn
+}
+
+// readUntil 读取事件直到 match 返回 true
+func readUntil(t *testing.T, conn *websocket.Conn, match func(wsFrame) bool) wsFrame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var frame wsFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("read websocket event: %v", err)
+		}
+		if match(frame) {
+			return frame
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"chatapp/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher 计算新密码的哈希。哈希自带算法标记（bcrypt 为 $2a$/$2b$，Argon2id 为 $argon2id$），
+// checkPassword 可以验证任何一种格式，切换算法后已有的密码仍然有效
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash 哈希不是当前算法，或参数低于当前设置时返回 true
+	NeedsRehash(hash string) bool
+}
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltBytes = 16
+	argon2KeyBytes  = 32
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+func newPasswordHasher(cfg config.PasswordConfig) PasswordHasher {
+	if cfg.Algorithm == config.PasswordHashArgon2id {
+		return argon2idHasher{
+			time:    uint32(cfg.Argon2Time),
+			memory:  uint32(cfg.Argon2MemoryKiB),
+			threads: uint8(cfg.Argon2Threads),
+		}
+	}
+	return bcryptHasher{cost: cfg.BcryptCost}
+}
+
+// checkPassword 按哈希的格式选择算法验证密码
+func checkPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		derived := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(derived, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(hash), err
+}
+
+// NeedsRehash 成本高于当前设置的哈希保留，降低成本不会让已有的哈希变弱
+func (h bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+type argon2idHasher struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// Hash 使用 PHC 字符串格式：$argon2id$v=19$m=<KiB>,t=<迭代次数>,p=<并行度>$<salt>$<key>
+func (h argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, argon2KeyBytes)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err != nil || params.time < h.time || params.memory < h.memory
+}
+
+func parseArgon2id(hash string) (params argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	return params, salt, key, nil
+}
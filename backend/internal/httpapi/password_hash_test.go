@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"chatapp/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 测试使用的 Argon2id 参数，足够小以保证测试速度
+func testArgon2(cfg *config.Config) {
+	cfg.Password.Algorithm = config.PasswordHashArgon2id
+	cfg.Password.Argon2Time = 1
+	cfg.Password.Argon2MemoryKiB = 1024
+	cfg.Password.Argon2Threads = 1
+}
+
+func TestCheckPassword(t *testing.T) {
+	bcryptHash, _ := bcryptHasher{cost: bcrypt.MinCost}.Hash(testPassword)
+	argonHash, _ := argon2idHasher{time: 1, memory: 1024, threads: 1}.Hash(testPassword)
+	if !strings.HasPrefix(argonHash, argon2idPrefix+"v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("argon2id hash = %q", argonHash)
+	}
+
+	for name, hash := range map[string]string{"bcrypt": bcryptHash, "argon2id": argonHash} {
+		if !checkPassword(hash, testPassword) {
+			t.Errorf("%s: correct password rejected", name)
+		}
+		if checkPassword(hash, testPassword+"x") {
+			t.Errorf("%s: wrong password accepted", name)
+		}
+	}
+	for _, hash := range []string{"", "plain", "$argon2id$v=19$m=1024,t=1,p=1$!!!$abc", "$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		if checkPassword(hash, testPassword) {
+			t.Errorf("malformed hash %q accepted", hash)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	low, _ := bcryptHasher{cost: bcrypt.MinCost}.Hash(testPassword)
+	weakArgon, _ := argon2idHasher{time: 1, memory: 1024, threads: 1}.Hash(testPassword)
+	argon := argon2idHasher{time: 2, memory: 2048, threads: 1}
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"bcrypt at the current cost", bcryptHasher{cost: bcrypt.MinCost}, low, false},
+		{"bcrypt below the current cost", bcryptHasher{cost: bcrypt.MinCost + 1}, low, true},
+		{"bcrypt above the current cost", bcryptHasher{cost: bcrypt.MinCost - 1}, low, false},
+		{"argon2id hash with bcrypt configured", bcryptHasher{cost: bcrypt.MinCost}, weakArgon, true},
+		{"bcrypt hash with argon2id configured", argon, low, true},
+		{"argon2id below the current parameters", argon, weakArgon, true},
+		{"argon2id at the current parameters", argonHasherFor(weakArgon), weakArgon, false},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func argonHasherFor(hash string) argon2idHasher {
+	params, _, _, _ := parseArgon2id(hash)
+	return params
+}
+
+// TestLoginUpgradesToArgon2id 切换到 Argon2id 之后，旧的 bcrypt 密码仍然可以登录，登录成功时升级为 Argon2id；
+// 密码错误时不修改哈希
+func TestLoginUpgradesToArgon2id(t *testing.T) {
+	ts := newTestServer(t, testArgon2)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.store.CreateUser(context.Background(), "alice", "alice@example.com", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+	storedHash := func() string {
+		_, stored, err := ts.store.GetUserByEmail(context.Background(), "alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "wrong-password"}), http.StatusUnauthorized, nil)
+	if storedHash() != string(hash) {
+		t.Fatal("hash changed after a failed login")
+	}
+
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: testPassword}), http.StatusOK, nil)
+	upgraded := storedHash()
+	if !strings.HasPrefix(upgraded, argon2idPrefix) || !checkPassword(upgraded, testPassword) {
+		t.Fatalf("stored hash = %q, want a valid argon2id hash", upgraded)
+	}
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: testPassword}), http.StatusOK, nil)
+	if storedHash() != upgraded {
+		t.Fatal("argon2id hash was recomputed although it uses the current parameters")
+	}
+}
+
+func benchmarkHash(b *testing.B, h PasswordHasher) {
+	for i := 0; i < b.N; i++ {
+		if _, err := h.Hash(testPassword); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkCheck(b *testing.B, h PasswordHasher) {
+	hash, err := h.Hash(testPassword)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !checkPassword(hash, testPassword) {
+			b.Fatal("password rejected")
+		}
+	}
+}
+
+// 默认参数：bcrypt 成本 12，Argon2id t=3、64 MiB、p=2
+var (
+	defaultBcrypt   = newPasswordHasher(config.Default().Password)
+	defaultArgon2id = argon2idHasher{time: 3, memory: 64 * 1024, threads: 2}
+)
+
+func BenchmarkBcryptHash(b *testing.B)        { benchmarkHash(b, defaultBcrypt) }
+func BenchmarkBcryptCheck(b *testing.B)       { benchmarkCheck(b, defaultBcrypt) }
+func BenchmarkBcryptMinCostHash(b *testing.B) { benchmarkHash(b, bcryptHasher{cost: bcrypt.MinCost}) }
+func BenchmarkArgon2idHash(b *testing.B)      { benchmarkHash(b, defaultArgon2id) }
+func BenchmarkArgon2idCheck(b *testing.B)     { benchmarkCheck(b, defaultArgon2id) }
@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetConfirm struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// generateResetToken 生成 32 字节随机令牌，返回明文和 SHA-256 哈希
+func generateResetToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, hashResetToken(token), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// durationText 把有效期写成邮件中的文字，例如 "1 hour"、"30 minutes"，不是整分钟时使用 Duration.String
+func durationText(d time.Duration) string {
+	unit, name := time.Duration(0), ""
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, name = time.Hour, "hour"
+	case d >= time.Minute && d%time.Minute == 0:
+		unit, name = time.Minute, "minute"
+	default:
+		return d.String()
+	}
+	n := int(d / unit)
+	if n != 1 {
+		name += "s"
+	}
+	return fmt.Sprintf("%d %s", n, name)
+}
+
+func (s *Server) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	if req.Email == "" {
+		writeError(w, r, apiError(http.StatusBadRequest, "Email is required"))
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同响应，避免泄露账号信息
+	respond := func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "If the email exists, a password reset link has been sent",
+		})
+	}
+
+	user, _, err := s.users.GetUserByEmail(r.Context(), req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		respond()
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, tokenHash, err := generateResetToken()
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+
+	err = s.resets.CreatePasswordResetToken(r.Context(), user.ID, tokenHash, time.Now().Add(s.PasswordResetTTL))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 在后台发送邮件，响应时间不会因为 SMTP 耗时而暴露邮箱是否存在；ctx 不随请求取消，但保留 trace
+	body := fmt.Sprintf("Use the following token to reset your password (valid for %s):\n\n%s", durationText(s.PasswordResetTTL), token)
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		_, span := tracer.Start(ctx, "email.send")
+		defer span.End()
+		if err := s.email.SendEmail(req.Email, "Password reset", body); err != nil {
+			span.RecordError(err)
+			loggerFromContext(ctx).Error("failed to send password reset email", "error", err)
+		}
+	}()
+
+	respond()
+}
+
+func (s *Server) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	if req.Token == "" {
+		writeError(w, r, apiError(http.StatusBadRequest, "Token is required"))
+		return
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	hashedPassword, err := s.hashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to hash password"))
+		return
+	}
+
+	err = s.resets.ResetPassword(r.Context(), hashResetToken(req.Token), hashedPassword)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid or expired token"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset successful"})
+}
@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"chatapp/internal/config"
+)
+
+type sentEmail struct {
+	to, subject, body string
+}
+
+// fakeEmailSender 把邮件放入 channel，密码重置邮件在后台发送，测试从 channel 中等待
+type fakeEmailSender struct {
+	sent chan sentEmail
+	err  error
+}
+
+func newFakeEmailSender() *fakeEmailSender {
+	return &fakeEmailSender{sent: make(chan sentEmail, 10)}
+}
+
+func (f *fakeEmailSender) SendEmail(to, subject, body string) error {
+	f.sent <- sentEmail{to: to, subject: subject, body: body}
+	return f.err
+}
+
+func (f *fakeEmailSender) wait(t *testing.T) sentEmail {
+	t.Helper()
+	select {
+	case email := <-f.sent:
+		return email
+	case <-time.After(2 * time.Second):
+		t.Fatal("no email was sent")
+		return sentEmail{}
+	}
+}
+
+// resetToken 取出邮件正文最后一行的令牌
+func (e sentEmail) resetToken() string {
+	lines := strings.Split(strings.TrimSpace(e.body), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	ts := newTestServer(t)
+	email := newFakeEmailSender()
+	ts.email = email
+	user, oldToken := ts.addUser("alice")
+
+	rec := ts.do("POST", "/api/auth/password-reset/request", "", PasswordResetRequest{Email: user.Email})
+	decodeResponse(t, rec, http.StatusOK, nil)
+
+	sent := email.wait(t)
+	if sent.to != user.Email || sent.subject != "Password reset" {
+		t.Fatalf("sent %q to %q, want Password reset to %q", sent.subject, sent.to, user.Email)
+	}
+	token := sent.resetToken()
+	if len(token) != 64 {
+		t.Fatalf("token %q is not 32 hex-encoded bytes", token)
+	}
+
+	rec = ts.do("POST", "/api/auth/password-reset/confirm", "", PasswordResetConfirm{Token: token, NewPassword: "newpassword1"})
+	decodeResponse(t, rec, http.StatusOK, nil)
+
+	rec = ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "newpassword1"})
+	decodeResponse(t, rec, http.StatusOK, nil)
+	rec = ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword})
+	decodeResponse(t, rec, http.StatusUnauthorized, nil)
+
+	// 重置密码后旧 token 失效，令牌不能再次使用
+	rec = ts.do("GET", "/api/users/me", oldToken, nil)
+	decodeResponse(t, rec, http.StatusUnauthorized, nil)
+	rec = ts.do("POST", "/api/auth/password-reset/confirm", "", PasswordResetConfirm{Token: token, NewPassword: "another12"})
+	decodeResponse(t, rec, http.StatusBadRequest, nil)
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	ts := newTestServer(t)
+	email := newFakeEmailSender()
+	ts.email = email
+	user, _ := ts.addUser("alice")
+
+	known := ts.do("POST", "/api/auth/forgot", "", PasswordResetRequest{Email: user.Email})
+	email.wait(t)
+	unknown := ts.do("POST", "/api/auth/forgot", "", PasswordResetRequest{Email: "nobody@example.com"})
+
+	// 两种情况的响应相同，不泄露邮箱是否注册
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK || known.Body.String() != unknown.Body.String() {
+		t.Fatalf("responses differ: %d %s / %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	select {
+	case sent := <-email.sent:
+		t.Fatalf("sent email to unknown address %q", sent.to)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPasswordResetEmailFailureIsNotReported(t *testing.T) {
+	ts := newTestServer(t)
+	email := newFakeEmailSender()
+	email.err = errors.New("smtp unavailable")
+	ts.email = email
+	user, _ := ts.addUser("alice")
+
+	rec := ts.do("POST", "/api/auth/password-reset/request", "", PasswordResetRequest{Email: user.Email})
+	decodeResponse(t, rec, http.StatusOK, nil)
+	email.wait(t)
+}
+
+func TestPasswordResetEmailUsesConfiguredTTL(t *testing.T) {
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.PasswordResetTTL = 30 * time.Minute })
+	email := newFakeEmailSender()
+	ts.email = email
+	user, _ := ts.addUser("alice")
+
+	ts.do("POST", "/api/auth/password-reset/request", "", PasswordResetRequest{Email: user.Email})
+	if body := email.wait(t).body; !strings.Contains(body, "valid for 30 minutes") {
+		t.Fatalf("email body %q does not mention the configured TTL", body)
+	}
+}
+
+func TestConfirmPasswordResetRejects(t *testing.T) {
+	ts := newTestServer(t)
+	user, _ := ts.addUser("alice")
+
+	expired := "expired-token"
+	if err := ts.store.CreatePasswordResetToken(context.Background(), user.ID, hashResetToken(expired), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"invalid body", "{"},
+		{"missing token", PasswordResetConfirm{NewPassword: "newpassword1"}},
+		{"weak password", PasswordResetConfirm{Token: "token", NewPassword: "short"}},
+		{"unknown token", PasswordResetConfirm{Token: "unknown", NewPassword: "newpassword1"}},
+		{"expired token", PasswordResetConfirm{Token: expired, NewPassword: "newpassword1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do("POST", "/api/auth/password-reset/confirm", "", tt.body)
+			decodeResponse(t, rec, http.StatusBadRequest, nil)
+		})
+	}
+}
+
+func TestDurationText(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Hour, "1 hour"},
+		{2 * time.Hour, "2 hours"},
+		{30 * time.Minute, "30 minutes"},
+		{time.Minute, "1 minute"},
+		{90 * time.Minute, "90 minutes"},
+		{90 * time.Second, "1m30s"},
+	}
+	for _, tt := range tests {
+		if got := durationText(tt.d); got != tt.want {
+			t.Errorf("durationText(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
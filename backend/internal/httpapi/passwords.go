@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"unicode"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/store"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt 只使用前 72 个字节，更长的部分会被忽略
+	maxPasswordBytes = 72
+)
+
+// ChangePasswordRequest POST /api/users/me/password 的请求体
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// validatePassword 注册、重置和修改密码共用的密码强度规则
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return &APIError{Status: http.StatusBadRequest, Message: "Password must be at least 8 characters", Field: "password"}
+	}
+	if len(password) > maxPasswordBytes {
+		return &APIError{Status: http.StatusBadRequest, Message: "Password must be at most 72 bytes", Field: "password"}
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return &APIError{Status: http.StatusBadRequest, Message: "Password must contain both letters and digits", Field: "password"}
+	}
+	return nil
+}
+
+func (s *Server) hashPassword(password string) (string, error) {
+	return s.Passwords.Hash(password)
+}
+
+// upgradePasswordHash 登录成功后，如果哈希不是当前算法或参数低于当前设置则重新计算。
+// 失败只记录日志，不影响登录。
+func (s *Server) upgradePasswordHash(ctx context.Context, userID int, hash, password string) {
+	if !s.Passwords.NeedsRehash(hash) {
+		return
+	}
+	newHash, err := s.hashPassword(password)
+	if err == nil {
+		err = s.users.UpdatePasswordHash(ctx, userID, hash, newHash)
+	}
+	// 哈希已经被并发修改（例如同时修改了密码）时不需要再升级
+	var conflict *store.ErrConflict
+	if err != nil && !errors.As(err, &conflict) {
+		loggerFromContext(ctx).Error("failed to rehash password", "user_id", userID, "error", err)
+	}
+}
+
+// authError 将 token 校验错误转换为响应，数据库错误保持原样
+func authError(err error) error {
+	if errors.Is(err, store.ErrTimeout) {
+		return err
+	}
+	if errors.Is(err, auth.ErrMissingToken) {
+		return apiError(http.StatusUnauthorized, "Authorization header required")
+	}
+	if errors.Is(err, auth.ErrTokenRevoked) {
+		return apiError(http.StatusUnauthorized, "Token has been revoked")
+	}
+	if errors.Is(err, auth.ErrTokenExpired) {
+		return apiError(http.StatusUnauthorized, "Token has expired")
+	}
+	if errors.Is(err, auth.ErrTokenNotYetValid) {
+		return apiError(http.StatusUnauthorized, "Token is not valid yet")
+	}
+	return apiError(http.StatusUnauthorized, "Invalid token")
+}
+
+// changePassword 修改密码。修改后所有已签发的 token 都失效（被盗的 token 随之失效），
+// 响应中返回当前设备使用的新 token，发起修改的设备不需要重新登录
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	userID := currentUser(r).UserID
+	currentHash, err := s.users.GetPasswordHash(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !checkPassword(currentHash, req.CurrentPassword) {
+		writeError(w, r, &APIError{Status: http.StatusForbidden, Message: "Current password is incorrect", Field: "current_password"})
+		return
+	}
+
+	hashedPassword, err := s.hashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to hash password"))
+		return
+	}
+
+	// 只在哈希仍然是校验过的 currentHash 时更新，并发的修改返回 409
+	user, err := s.users.ChangePassword(r.Context(), userID, currentHash, hashedPassword)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token, err := s.auth.Issue(user)
+	if err != nil {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Failed to generate token"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:   token,
+		User:    user,
+		Message: "Password changed",
+	})
+}
@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"password1", true},
+		{"pässwört1", true},
+		{"short1", false},
+		{"onlyletters", false},
+		{"1234567890", false},
+		{"a1" + string(make([]byte, 71)), false},
+	}
+	for _, tt := range tests {
+		if err := validatePassword(tt.password); (err == nil) != tt.ok {
+			t.Errorf("validatePassword(%q) = %v, want ok %v", tt.password, err, tt.ok)
+		}
+	}
+}
+
+func TestChangePasswordRejects(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.addUser("alice")
+
+	tests := []struct {
+		name   string
+		token  string
+		body   interface{}
+		status int
+		field  string
+	}{
+		{"anonymous", "", ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "newpassword1"}, http.StatusUnauthorized, ""},
+		{"invalid body", token, "{", http.StatusBadRequest, ""},
+		{"too short", token, ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "abc1"}, http.StatusBadRequest, "password"},
+		{"no digits", token, ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "newpassword"}, http.StatusBadRequest, "password"},
+		{"wrong current password", token, ChangePasswordRequest{CurrentPassword: "wrong-password1", NewPassword: "newpassword1"}, http.StatusForbidden, "current_password"},
+		{"missing current password", token, ChangePasswordRequest{NewPassword: "newpassword1"}, http.StatusForbidden, "current_password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr APIError
+			decodeResponse(t, ts.do("POST", "/api/users/me/password", tt.token, tt.body), tt.status, &apiErr)
+			if apiErr.Field != tt.field {
+				t.Fatalf("field = %q, want %q", apiErr.Field, tt.field)
+			}
+		})
+	}
+
+	// 密码没有被修改，旧 token 仍然有效
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword}), http.StatusOK, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/me", token, nil), http.StatusOK, nil)
+}
+
+// TestChangePassword 修改后旧密码和修改前签发的所有 token（包括发起修改的设备的）立即失效，
+// 响应中的新 token 可以继续使用
+func TestChangePassword(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.addUser("alice")
+	var other AuthResponse
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword}), http.StatusOK, &other)
+
+	var resp AuthResponse
+	decodeResponse(t, ts.do("POST", "/api/users/me/password", token, ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "newpassword1"}), http.StatusOK, &resp)
+	if resp.Token == "" || resp.User.ID != user.ID {
+		t.Fatalf("response = %+v, want a fresh token for alice", resp)
+	}
+	for _, tok := range []string{token, other.Token} {
+		decodeResponse(t, ts.do("GET", "/api/users/me", tok, nil), http.StatusUnauthorized, nil)
+	}
+	decodeResponse(t, ts.do("GET", "/api/users/me", resp.Token, nil), http.StatusOK, nil)
+
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: "newpassword1"}), http.StatusOK, nil)
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword}), http.StatusUnauthorized, nil)
+}
+
+// TestChangePasswordWrongCurrentPassword 当前密码错误时返回 403，密码和 token 版本都不变
+func TestChangePasswordWrongCurrentPassword(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.addUser("alice")
+	_, hash, err := ts.store.GetUserByEmail(context.Background(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var apiErr APIError
+	decodeResponse(t, ts.do("POST", "/api/users/me/password", token, ChangePasswordRequest{CurrentPassword: "wrong-password1", NewPassword: "newpassword1"}), http.StatusForbidden, &apiErr)
+	if apiErr.Message != "Current password is incorrect" || apiErr.Field != "current_password" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+	after, stored, err := ts.store.GetUserByEmail(context.Background(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != hash || after.TokenVersion != user.TokenVersion {
+		t.Fatalf("hash changed %v, token version %d -> %d", stored != hash, user.TokenVersion, after.TokenVersion)
+	}
+	decodeResponse(t, ts.do("GET", "/api/users/me", token, nil), http.StatusOK, nil)
+}
+
+// racingPasswordChange 在返回当前哈希之后修改密码，模拟另一个请求同时修改了密码
+type racingPasswordChange struct {
+	store.UserStore
+	newHash string
+}
+
+func (r *racingPasswordChange) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	hash, err := r.UserStore.GetPasswordHash(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.UserStore.ChangePassword(ctx, id, hash, r.newHash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// TestChangePasswordConflict 校验当前密码之后哈希被并发修改时返回 409，不覆盖另一个请求设置的密码
+func TestChangePasswordConflict(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.addUser("alice")
+	concurrent, err := ts.hashPassword("concurrent1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.users = &racingPasswordChange{UserStore: ts.users, newHash: concurrent}
+
+	decodeResponse(t, ts.do("POST", "/api/users/me/password", token, ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "newpassword1"}), http.StatusConflict, nil)
+	if _, stored, _ := ts.store.GetUserByEmail(context.Background(), user.Email); stored != concurrent {
+		t.Fatal("password change overwrote a concurrent change")
+	}
+}
+
+// TestLoginRehashesLowCostPassword 登录时把成本低于当前设置的 bcrypt 哈希升级到当前成本
+func TestLoginRehashesLowCostPassword(t *testing.T) {
+	const cost = bcrypt.MinCost + 1
+	ts := newTestServer(t, func(cfg *config.Config) { cfg.Password.BcryptCost = cost })
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.store.CreateUser(context.Background(), "alice", "alice@example.com", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	login := LoginRequest{Email: "alice@example.com", Password: testPassword}
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", login), http.StatusOK, nil)
+	_, stored, err := ts.store.GetUserByEmail(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := bcrypt.Cost([]byte(stored)); err != nil || got != cost {
+		t.Fatalf("stored hash cost = %d, %v, want %d", got, err, cost)
+	}
+
+	// 已经是当前成本的哈希不再重新计算
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", login), http.StatusOK, nil)
+	if _, again, _ := ts.store.GetUserByEmail(context.Background(), "alice@example.com"); again != stored {
+		t.Fatal("hash was recomputed although it already uses the current cost")
+	}
+}
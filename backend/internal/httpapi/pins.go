@@ -0,0 +1,232 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// 每个聊天室最多置顶的消息数
+const maxPinsPerRoom = 50
+
+// 社区自动置顶时 PinEvent.PinnedBy 的值，手动置顶时为用户名
+const pinSourceCommunity = "community"
+
+const maxAutoPinThreshold = 1000
+
+// PinEvent 消息置顶/取消置顶时广播的数据
+type PinEvent struct {
+	MessageID int       `json:"message_id"`
+	PinnedBy  string    `json:"pinned_by"`
+	PinnedAt  time.Time `json:"pinned_at"`
+}
+
+type AutoPinSettings = store.AutoPinSettings
+
+var errTooManyPins = apiError(http.StatusConflict, "This room already has the maximum number of pinned messages")
+
+// pinnableMessage 取出可以置顶的聊天室消息，并检查当前用户是否为聊天室 owner 或 moderator
+func (s *Server) pinnableMessage(r *http.Request) (Message, error) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		return Message{}, err
+	}
+	user := currentUser(r)
+
+	msg, conv, err := s.visibleMessage(r.Context(), messageID, user.UserID)
+	if err != nil {
+		return Message{}, err
+	}
+	// 私信不支持置顶
+	if conv != nil {
+		return Message{}, apiError(http.StatusBadRequest, "Direct messages cannot be pinned")
+	}
+
+	if _, err := s.requireModerator(r.Context(), msg.RoomID, user.UserID); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+// pinMessage 手动置顶消息，重复置顶没有副作用
+func (s *Server) pinMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := s.pinnableMessage(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user := currentUser(r)
+
+	change, err := s.pins.PinMessage(r.Context(), msg.RoomID, msg.ID, user.UserID, maxPinsPerRoom)
+	if errors.Is(err, store.ErrLimitExceeded) {
+		writeError(w, r, errTooManyPins)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if change != nil {
+		s.hub.Publish(ws.Event{Type: ws.EventMessagePinned, RoomID: msg.RoomID, Data: PinEvent{
+			MessageID: msg.ID,
+			PinnedBy:  user.Username,
+			PinnedAt:  change.PinnedAt,
+		}})
+		s.postSystemMessage(r.Context(), msg.RoomID, SystemMessageMetadata{
+			Event:     systemEventMessagePinned,
+			ActorID:   user.UserID,
+			Actor:     user.Username,
+			MessageID: msg.ID,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unpinMessage 取消置顶，包括社区自动置顶的消息
+func (s *Server) unpinMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := s.pinnableMessage(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	removed, err := s.pins.UnpinMessage(r.Context(), msg.RoomID, msg.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if removed {
+		s.hub.Publish(ws.Event{Type: ws.EventMessageUnpinned, RoomID: msg.RoomID, Data: PinEvent{
+			MessageID: msg.ID,
+			PinnedBy:  currentUser(r).Username,
+		}})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getRoomPins 返回聊天室的置顶消息及置顶者，只有成员可以查看
+func (s *Server) getRoomPins(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.requireMember(r.Context(), roomID, viewerID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	messages, err := s.pins.ListPinnedMessages(r.Context(), roomID, viewerID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// getAutoPinSettings 返回聊天室的自动置顶设置，只有成员可以查看
+func (s *Server) getAutoPinSettings(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.requireMember(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	settings, err := s.pins.GetAutoPinSettings(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// updateAutoPinSettings 修改自动置顶设置，与手动置顶一样要求聊天室 owner 或 moderator
+func (s *Server) updateAutoPinSettings(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req AutoPinSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	if req.Emoji == "" {
+		req.Emoji = store.DefaultAutoPinEmoji
+	}
+	emoji, ok := normalizeEmoji(req.Emoji)
+	if !ok {
+		writeError(w, r, errInvalidEmoji)
+		return
+	}
+	req.Emoji = emoji
+
+	if req.Threshold < 0 || req.Threshold > maxAutoPinThreshold {
+		writeError(w, r, apiError(http.StatusBadRequest, "Threshold must be between 0 and 1000"))
+		return
+	}
+
+	if _, err := s.requireModerator(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.pins.SaveAutoPinSettings(r.Context(), roomID, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// applyAutoPin 在表情数量变化后检查是否需要自动置顶或取消置顶，
+// 事件在事务提交后才广播。
+func (s *Server) applyAutoPin(ctx context.Context, roomID, messageID int, emoji string) error {
+	settings, err := s.pins.GetAutoPinSettings(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if settings.Threshold == 0 || settings.Emoji != emoji {
+		return nil
+	}
+
+	change, err := s.pins.ApplyAutoPin(ctx, roomID, messageID, settings, maxPinsPerRoom)
+	if err != nil || change == nil {
+		return err
+	}
+
+	if change.Pinned {
+		s.hub.Publish(ws.Event{Type: ws.EventMessagePinned, RoomID: roomID, Data: PinEvent{
+			MessageID: messageID,
+			PinnedBy:  pinSourceCommunity,
+			PinnedAt:  change.PinnedAt,
+		}})
+	} else {
+		s.hub.Publish(ws.Event{Type: ws.EventMessageUnpinned, RoomID: roomID, Data: PinEvent{
+			MessageID: messageID,
+			PinnedBy:  pinSourceCommunity,
+		}})
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// autoPinRoom 创建聊天室、成员和一条消息，开启阈值为 threshold 的自动置顶
+func autoPinRoom(t *testing.T, ts *testServer, members, threshold int) (store.ChatRoom, store.Message, []string) {
+	t.Helper()
+	ctx := context.Background()
+	owner, _ := ts.addUser("owner")
+	room := ts.store.AddRoom("general", "", &owner.ID)
+	tokens := make([]string, members)
+	for i := range tokens {
+		user, token := ts.addUser(fmt.Sprintf("user%d", i))
+		ts.store.JoinRoom(ctx, room.ID, user.ID)
+		tokens[i] = token
+	}
+	msg := store.Message{RoomID: room.ID, UserID: owner.ID, Content: "pin me"}
+	if err := ts.store.InsertMessage(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	settings := store.AutoPinSettings{Emoji: store.DefaultAutoPinEmoji, Threshold: threshold}
+	if err := ts.store.SaveAutoPinSettings(ctx, room.ID, settings); err != nil {
+		t.Fatal(err)
+	}
+	return room, msg, tokens
+}
+
+// TestAutoPinConcurrentReactions 多个表情同时越过阈值时只广播一次置顶，同时撤回时只广播一次取消置顶
+func TestAutoPinConcurrentReactions(t *testing.T) {
+	ts := newTestServer(t)
+	room, msg, tokens := autoPinRoom(t, ts, 20, 5)
+	events := ts.subscribe(0, room.ID)
+	path := "/api/messages/" + strconv.Itoa(msg.ID) + "/reactions"
+
+	react := func(method string) {
+		var wg sync.WaitGroup
+		for _, token := range tokens {
+			wg.Add(1)
+			go func(token string) {
+				defer wg.Done()
+				rec := ts.do(method, path, token, ReactionRequest{Emoji: store.DefaultAutoPinEmoji})
+				if rec.Code != http.StatusOK {
+					t.Errorf("%s reaction: status %d, body %s", method, rec.Code, rec.Body)
+				}
+			}(token)
+		}
+		wg.Wait()
+	}
+
+	react("POST")
+	received := events.drain(t, ts, room.ID)
+	if n := countEvents(received, ws.EventMessagePinned); n != 1 {
+		t.Fatalf("got %d pin events, want 1", n)
+	}
+	pins, _ := ts.store.ListPinnedMessages(context.Background(), room.ID, 0)
+	if len(pins) != 1 || pins[0].PinnedBy != "" {
+		t.Fatalf("pins = %+v, want one community pin", pins)
+	}
+
+	react("DELETE")
+	received = events.drain(t, ts, room.ID)
+	if n := countEvents(received, ws.EventMessageUnpinned); n != 1 {
+		t.Fatalf("got %d unpin events, want 1", n)
+	}
+	if n := countEvents(received, ws.EventMessagePinned); n != 0 {
+		t.Fatalf("got %d pin events while removing reactions", n)
+	}
+}
+
+func TestAutoPinOtherEmojiIgnored(t *testing.T) {
+	ts := newTestServer(t)
+	room, msg, tokens := autoPinRoom(t, ts, 2, 1)
+	events := ts.subscribe(0, room.ID)
+
+	rec := ts.do("POST", "/api/messages/"+strconv.Itoa(msg.ID)+"/reactions", tokens[0], ReactionRequest{Emoji: "👍"})
+	decodeResponse(t, rec, http.StatusOK, nil)
+	if n := countEvents(events.drain(t, ts, room.ID), ws.EventMessagePinned); n != 0 {
+		t.Fatalf("got %d pin events for a different emoji", n)
+	}
+}
+
+func TestAutoPinSettingsAuthorization(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	owner, ownerToken := ts.addUser("owner")
+	moderator, moderatorToken := ts.addUser("moderator")
+	member, memberToken := ts.addUser("member")
+	_, outsiderToken := ts.addUser("outsider")
+	room := ts.store.AddRoom("general", "", &owner.ID)
+	ts.store.JoinRoom(ctx, room.ID, owner.ID)
+	ts.store.JoinRoom(ctx, room.ID, moderator.ID)
+	ts.store.JoinRoom(ctx, room.ID, member.ID)
+	ts.store.SetMemberRole(ctx, store.ModerationAction{RoomID: room.ID, TargetID: moderator.ID}, store.RoleModerator)
+	path := "/api/rooms/" + strconv.Itoa(room.ID) + "/auto-pin"
+	settings := AutoPinSettings{Emoji: store.DefaultAutoPinEmoji, Threshold: 3}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantGet    int
+		wantUpdate int
+	}{
+		{"owner", ownerToken, http.StatusOK, http.StatusOK},
+		{"moderator", moderatorToken, http.StatusOK, http.StatusOK},
+		{"member", memberToken, http.StatusOK, http.StatusForbidden},
+		{"outsider", outsiderToken, http.StatusForbidden, http.StatusForbidden},
+		{"anonymous", "", http.StatusUnauthorized, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeResponse(t, ts.do("GET", path, tt.token, nil), tt.wantGet, nil)
+			decodeResponse(t, ts.do("PUT", path, tt.token, settings), tt.wantUpdate, nil)
+		})
+	}
+
+	var got AutoPinSettings
+	decodeResponse(t, ts.do("GET", path, memberToken, nil), http.StatusOK, &got)
+	if got != settings {
+		t.Fatalf("settings = %+v, want %+v", got, settings)
+	}
+}
+
+// TestPinLimit 置顶数达到 maxPinsPerRoom 后返回 409，取消一个之后可以继续置顶
+func TestPinLimit(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	owner, token := ts.addUser("owner")
+	room := ts.store.AddRoom("general", "", &owner.ID)
+	ts.store.JoinRoom(ctx, room.ID, owner.ID)
+	events := ts.subscribe(owner.ID, room.ID)
+
+	ids := make([]int, maxPinsPerRoom+1)
+	for i := range ids {
+		msg := store.Message{RoomID: room.ID, UserID: owner.ID, Content: fmt.Sprintf("message %d", i)}
+		if err := ts.store.InsertMessage(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = msg.ID
+	}
+	for _, id := range ids[:maxPinsPerRoom] {
+		decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/messages/%d/pin", id), token, nil), http.StatusNoContent, nil)
+	}
+	// 重复置顶不占用名额
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/messages/%d/pin", ids[0]), token, nil), http.StatusNoContent, nil)
+	extra := fmt.Sprintf("/api/messages/%d/pin", ids[maxPinsPerRoom])
+	decodeResponse(t, ts.do("POST", extra, token, nil), http.StatusConflict, nil)
+
+	decodeResponse(t, ts.do("DELETE", fmt.Sprintf("/api/messages/%d/pin", ids[0]), token, nil), http.StatusNoContent, nil)
+	decodeResponse(t, ts.do("POST", extra, token, nil), http.StatusNoContent, nil)
+
+	var pinned []store.PinnedMessage
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/rooms/%d/pins", room.ID), token, nil), http.StatusOK, &pinned)
+	if len(pinned) != maxPinsPerRoom || pinned[0].ID != ids[1] || pinned[len(pinned)-1].ID != ids[maxPinsPerRoom] {
+		t.Fatalf("%d pinned messages, want %d without the unpinned one", len(pinned), maxPinsPerRoom)
+	}
+	if pinned[0].PinnedBy != "owner" || pinned[0].Content != "message 1" {
+		t.Fatalf("pinned message = %+v", pinned[0])
+	}
+
+	got := events.drain(t, ts, room.ID)
+	if n := countEvents(got, ws.EventMessagePinned); n != maxPinsPerRoom+1 {
+		t.Fatalf("%d message_pinned events, want %d", n, maxPinsPerRoom+1)
+	}
+	if n := countEvents(got, ws.EventMessageUnpinned); n != 1 {
+		t.Fatalf("%d message_unpinned events, want 1", n)
+	}
+}
+
+// TestPinAuthorization 只有聊天室的所有者和版主可以置顶
+func TestPinAuthorization(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	owner, ownerToken := ts.addUser("owner")
+	moderator, moderatorToken := ts.addUser("moderator")
+	member, memberToken := ts.addUser("member")
+	_, outsiderToken := ts.addUser("outsider")
+	room := ts.store.AddRoom("general", "", &owner.ID)
+	for _, id := range []int{owner.ID, moderator.ID, member.ID} {
+		ts.store.JoinRoom(ctx, room.ID, id)
+	}
+	ts.store.SetMemberRole(ctx, store.ModerationAction{RoomID: room.ID, TargetID: moderator.ID}, store.RoleModerator)
+	msg := store.Message{RoomID: room.ID, UserID: member.ID, Content: "pin me"}
+	if err := ts.store.InsertMessage(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/messages/%d/pin", msg.ID)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"owner", ownerToken, http.StatusNoContent},
+		{"moderator", moderatorToken, http.StatusNoContent},
+		{"member", memberToken, http.StatusForbidden},
+		{"outsider", outsiderToken, http.StatusForbidden},
+		{"anonymous", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeResponse(t, ts.do("POST", path, tt.token, nil), tt.status, nil)
+			decodeResponse(t, ts.do("DELETE", path, tt.token, nil), tt.status, nil)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxPollQuestion   = 300
+	maxPollOptionText = 100
+	minPollOptions    = 2
+	maxPollOptions    = 10
+	// 投票最长持续时间
+	maxPollDuration = 30 * 24 * time.Hour
+)
+
+type Poll = store.Poll
+
+// CreatePollRequest POST /api/rooms/{id}/polls 的请求体，EndsAt 为截止时间，必须在将来
+type CreatePollRequest struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	AllowsMultiple bool       `json:"allows_multiple"`
+	Anonymous      bool       `json:"anonymous"`
+	EndsAt         *time.Time `json:"ends_at"`
+}
+
+// VotePollRequest POST /api/polls/{id}/vote 的请求体，再次投票时替换之前的选择
+type VotePollRequest struct {
+	OptionIndices []int `json:"option_indices"`
+}
+
+var errPollClosed = apiError(http.StatusConflict, "This poll has ended")
+
+func pollIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid poll ID")
+	}
+	return id, nil
+}
+
+// pollText 去掉首尾空白并检查长度，field 用于错误响应
+func pollText(text, field string, maxLength int) (string, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\x00", ""))
+	if text == "" || utf8.RuneCountInString(text) > maxLength {
+		return "", &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("%s must be between 1 and %d characters", field, maxLength),
+			Field:   field,
+		}
+	}
+	return text, nil
+}
+
+// validate 检查请求并返回要保存的投票，选项不能重复
+func (req *CreatePollRequest) validate(now time.Time) (Poll, error) {
+	question, err := pollText(req.Question, "question", maxPollQuestion)
+	if err != nil {
+		return Poll{}, err
+	}
+	if len(req.Options) < minPollOptions || len(req.Options) > maxPollOptions {
+		return Poll{}, &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("A poll must have between %d and %d options", minPollOptions, maxPollOptions),
+			Field:   "options",
+		}
+	}
+	options := make([]store.PollOption, 0, len(req.Options))
+	seen := make(map[string]bool)
+	for _, opt := range req.Options {
+		text, err := pollText(opt, "options", maxPollOptionText)
+		if err != nil {
+			return Poll{}, err
+		}
+		key := strings.ToLower(text)
+		if seen[key] {
+			return Poll{}, &APIError{Status: http.StatusBadRequest, Message: "Poll options must be unique", Field: "options"}
+		}
+		seen[key] = true
+		options = append(options, store.PollOption{Text: text})
+	}
+
+	if req.EndsAt == nil || !req.EndsAt.After(now) || req.EndsAt.Sub(now) > maxPollDuration {
+		return Poll{}, &APIError{
+			Status:  http.StatusBadRequest,
+			Message: "ends_at must be in the future and within 30 days",
+			Field:   "ends_at",
+		}
+	}
+
+	return Poll{
+		Question:       question,
+		Options:        options,
+		AllowsMultiple: req.AllowsMultiple,
+		Anonymous:      req.Anonymous,
+		EndsAt:         req.EndsAt.UTC(),
+	}, nil
+}
+
+// validVote 检查选项下标：至少选择一项、不能重复，单选投票只能选择一项
+func validVote(poll Poll, indices []int) error {
+	if len(indices) == 0 {
+		return &APIError{Status: http.StatusBadRequest, Message: "Select at least one option", Field: "option_indices"}
+	}
+	if !poll.AllowsMultiple && len(indices) > 1 {
+		return &APIError{Status: http.StatusBadRequest, Message: "This poll allows only one option", Field: "option_indices"}
+	}
+	seen := make(map[int]bool)
+	for _, i := range indices {
+		if i < 0 || i >= len(poll.Options) || seen[i] {
+			return &APIError{Status: http.StatusBadRequest, Message: "Invalid option index", Field: "option_indices"}
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// createPoll 聊天室成员创建投票并广播 poll_created
+func (s *Server) createPoll(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CreatePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	poll, err := req.validate(time.Now())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user := currentUser(r)
+	if err := s.requireMember(r.Context(), roomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	poll.RoomID = roomID
+	poll.CreatedBy = &user.UserID
+	poll.MyVotes = []int{}
+	if err := s.polls.CreatePoll(r.Context(), &poll); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	s.hub.Publish(ws.Event{Type: ws.EventPollCreated, RoomID: roomID, Data: poll})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(poll)
+}
+
+// getPoll 返回各选项的票数，匿名投票不包含投票者，只有聊天室成员可以查看
+func (s *Server) getPoll(w http.ResponseWriter, r *http.Request) {
+	id, err := pollIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user := currentUser(r)
+
+	poll, err := s.polls.GetPoll(r.Context(), id, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.requireMember(r.Context(), poll.RoomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// votePoll 记录当前用户的选择并广播最新票数，截止后返回 409
+func (s *Server) votePoll(w http.ResponseWriter, r *http.Request) {
+	id, err := pollIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req VotePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	user := currentUser(r)
+	poll, err := s.polls.GetPoll(r.Context(), id, 0)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.requireMember(r.Context(), poll.RoomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validVote(poll, req.OptionIndices); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 截止时间由数据库在写入时检查，GetPoll 之后才截止的投票同样被拒绝
+	err = s.polls.VotePoll(r.Context(), id, user.UserID, req.OptionIndices)
+	if errors.Is(err, store.ErrPollClosed) {
+		writeError(w, r, errPollClosed)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if updated, err := s.polls.GetPoll(r.Context(), id, 0); err == nil {
+		s.hub.Publish(ws.Event{Type: ws.EventPollUpdated, RoomID: poll.RoomID, Data: updated})
+	} else {
+		loggerFromContext(r.Context()).Error("failed to load poll after vote", "poll_id", id, "error", err)
+	}
+
+	poll, err = s.polls.GetPoll(r.Context(), id, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
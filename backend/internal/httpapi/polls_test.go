@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// TestCreatePollDeadline 截止时间必须在将来且不超过 30 天
+func TestCreatePollDeadline(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	tests := []struct {
+		name   string
+		endsAt *time.Time
+		ok     bool
+	}{
+		{"missing", nil, false},
+		{"in the past", at(-time.Minute), false},
+		{"now", at(0), false},
+		{"in a minute", at(time.Minute), true},
+		{"in 30 days", at(maxPollDuration), true},
+		{"after 30 days", at(maxPollDuration + time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := CreatePollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}, EndsAt: tt.endsAt}
+			_, err := req.validate(now)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("err = %v, want the poll to be accepted", err)
+				}
+				return
+			}
+			if apiErr := httpError(err); apiErr.Status != http.StatusBadRequest || apiErr.Field != "ends_at" {
+				t.Fatalf("err = %v, want 400 on ends_at", err)
+			}
+		})
+	}
+}
+
+// TestVoteAfterDeadline 截止之前可以投票并广播最新票数，截止之后投票返回 409，票数不变
+func TestVoteAfterDeadline(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	events := ts.subscribe(alice.ID, room.ID)
+
+	endsAt := time.Now().Add(time.Hour)
+	var poll store.Poll
+	rec := ts.do("POST", fmt.Sprintf("/api/rooms/%d/polls", room.ID), token, CreatePollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}, EndsAt: &endsAt})
+	decodeResponse(t, rec, http.StatusCreated, &poll)
+	votePath := fmt.Sprintf("/api/polls/%d/vote", poll.ID)
+	decodeResponse(t, ts.do("POST", votePath, token, VotePollRequest{OptionIndices: []int{1}}), http.StatusOK, &poll)
+	if poll.Options[1].Votes != 1 || poll.Closed {
+		t.Fatalf("poll = %+v, want one vote for option 1", poll)
+	}
+	received := events.drain(t, ts, room.ID)
+	if countEvents(received, ws.EventPollCreated) != 1 || countEvents(received, ws.EventPollUpdated) != 1 {
+		t.Fatalf("received %+v, want poll_created and poll_updated", received)
+	}
+
+	// 截止时间已过的投票，创建接口不允许，直接写入 store
+	ended := store.Poll{RoomID: room.ID, Question: "Dinner?", Options: []store.PollOption{{Text: "Yes"}, {Text: "No"}}, EndsAt: time.Now().Add(-time.Second)}
+	if err := ts.store.CreatePoll(context.Background(), &ended); err != nil {
+		t.Fatal(err)
+	}
+	var apiErr APIError
+	decodeResponse(t, ts.do("POST", fmt.Sprintf("/api/polls/%d/vote", ended.ID), token, VotePollRequest{OptionIndices: []int{0}}), http.StatusConflict, &apiErr)
+	if apiErr.Message != "This poll has ended" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+	decodeResponse(t, ts.do("GET", fmt.Sprintf("/api/polls/%d", ended.ID), token, nil), http.StatusOK, &poll)
+	if !poll.Closed || poll.TotalVoters != 0 {
+		t.Fatalf("poll = %+v, want closed without votes", poll)
+	}
+	if got := countEvents(events.drain(t, ts, room.ID), ws.EventPollUpdated); got != 0 {
+		t.Fatalf("rejected vote broadcast %d poll_updated events", got)
+	}
+}
@@ -0,0 +1,208 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+
+	"chatapp/internal/store"
+)
+
+//go:embed profanity_words.txt
+var defaultProfanityWords string
+
+type ProfanitySettings = store.ProfanitySettings
+
+var errProfanity = &APIError{Status: http.StatusUnprocessableEntity, Message: "Message contains words that are not allowed in this room", Field: "content"}
+
+// ProfanityFilter 用 Aho-Corasick 自动机一次扫描匹配整个词表。
+// 只匹配完整的单词（前后不是字母或数字），避免误伤包含这些字母组合的普通单词。
+type ProfanityFilter struct {
+	nodes []acNode
+}
+
+type acNode struct {
+	next map[rune]int
+	fail int
+	// outs 在此结束的所有词的长度（按字符），包括通过 fail 链继承的
+	outs []int
+}
+
+// NewProfanityFilter 根据词表构建过滤器，匹配不区分大小写
+func NewProfanityFilter(words []string) *ProfanityFilter {
+	f := &ProfanityFilter{nodes: []acNode{{next: make(map[rune]int)}}}
+	for _, word := range words {
+		runes := []rune(strings.ToLower(word))
+		if len(runes) == 0 {
+			continue
+		}
+		state := 0
+		for _, r := range runes {
+			next, ok := f.nodes[state].next[r]
+			if !ok {
+				next = len(f.nodes)
+				f.nodes = append(f.nodes, acNode{next: make(map[rune]int)})
+				f.nodes[state].next[r] = next
+			}
+			state = next
+		}
+		f.nodes[state].outs = append(f.nodes[state].outs, len(runes))
+	}
+
+	// 按层遍历计算 fail 链接，子节点继承 fail 节点的输出
+	queue := make([]int, 0, len(f.nodes))
+	for _, child := range f.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for r, v := range f.nodes[u].next {
+			fail := f.nodes[u].fail
+			for fail != 0 && !f.has(fail, r) {
+				fail = f.nodes[fail].fail
+			}
+			if next, ok := f.nodes[fail].next[r]; ok && next != v {
+				f.nodes[v].fail = next
+			}
+			f.nodes[v].outs = append(f.nodes[v].outs, f.nodes[f.nodes[v].fail].outs...)
+			queue = append(queue, v)
+		}
+	}
+	return f
+}
+
+func (f *ProfanityFilter) has(state int, r rune) bool {
+	_, ok := f.nodes[state].next[r]
+	return ok
+}
+
+// matches 返回所有命中的完整单词在 runes 中的范围 [start, end)
+func (f *ProfanityFilter) matches(runes []rune) [][2]int {
+	var found [][2]int
+	state := 0
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		for state != 0 && !f.has(state, r) {
+			state = f.nodes[state].fail
+		}
+		if next, ok := f.nodes[state].next[r]; ok {
+			state = next
+		}
+		for _, n := range f.nodes[state].outs {
+			start, end := i-n+1, i+1
+			if (start == 0 || !isWordRune(runes[start-1])) && (end == len(runes) || !isWordRune(runes[end])) {
+				found = append(found, [2]int{start, end})
+			}
+		}
+	}
+	return found
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Contains 判断文本中是否包含词表中的词
+func (f *ProfanityFilter) Contains(text string) bool {
+	return len(f.matches([]rune(text))) > 0
+}
+
+// Redact 把命中的词替换为等长的 *
+func (f *ProfanityFilter) Redact(text string) string {
+	runes := []rune(text)
+	found := f.matches(runes)
+	if len(found) == 0 {
+		return text
+	}
+	for _, m := range found {
+		for i := m[0]; i < m[1]; i++ {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
+
+// parseWordList 每行一个词，忽略空行和 # 开头的注释
+func parseWordList(data string) []string {
+	var words []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words
+}
+
+// loadProfanityFilter 读取 path 指定的词表，path 为空时使用内置词表
+func loadProfanityFilter(path string) (*ProfanityFilter, error) {
+	if path == "" {
+		return NewProfanityFilter(parseWordList(defaultProfanityWords)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewProfanityFilter(parseWordList(string(data))), nil
+}
+
+// checkProfanity 聊天室开启过滤时拒绝或替换命中的消息，私信不受影响
+func (s *Server) checkProfanity(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	if req.ConversationID != 0 || req.Content == "" {
+		return nil
+	}
+	settings, err := s.moderation.GetProfanitySettings(ctx, req.RoomID)
+	if err != nil || !settings.Enabled {
+		return err
+	}
+	if settings.Action == store.ProfanityRedact {
+		req.Content = s.Profanity.Redact(req.Content)
+		return nil
+	}
+	if s.Profanity.Contains(req.Content) {
+		return errProfanity
+	}
+	return nil
+}
+
+// updateRoomModeration 聊天室 owner 或 moderator 开启/关闭脏话过滤并选择处理方式
+func (s *Server) updateRoomModeration(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req ProfanitySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.Action == "" {
+		req.Action = store.ProfanityBlock
+	}
+	if req.Action != store.ProfanityBlock && req.Action != store.ProfanityRedact {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Action must be block or redact", Field: "action"})
+		return
+	}
+
+	if _, err := s.requireModerator(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.moderation.SaveProfanitySettings(r.Context(), roomID, req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 500
+	maxAvatarURLLength   = 2048
+
+	defaultUserSearchLimit = 10
+	maxUserSearchLimit     = 20
+)
+
+// PublicUser 其他用户可以看到的资料，不包含邮箱
+type PublicUser struct {
+	ID          int        `json:"id"`
+	Username    string     `json:"username"`
+	DisplayName string     `json:"display_name"`
+	Bio         string     `json:"bio"`
+	AvatarURL   string     `json:"avatar_url"`
+	LastSeenAt  *time.Time `json:"last_seen_at"`
+}
+
+func publicUser(u User) PublicUser {
+	return PublicUser{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		LastSeenAt:  u.LastSeenAt,
+	}
+}
+
+// UpdateProfileRequest 未提供的字段保持不变，username 不允许修改
+type UpdateProfileRequest struct {
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+func validateProfile(req *UpdateProfileRequest) error {
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return &APIError{Status: http.StatusBadRequest, Message: "Display name must be at most 50 characters", Field: "display_name"}
+		}
+		req.DisplayName = &name
+	}
+	if req.Bio != nil && utf8.RuneCountInString(*req.Bio) > maxBioLength {
+		return &APIError{Status: http.StatusBadRequest, Message: "Bio must be at most 500 characters", Field: "bio"}
+	}
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		u, err := url.Parse(*req.AvatarURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(*req.AvatarURL) > maxAvatarURLLength {
+			return &APIError{Status: http.StatusBadRequest, Message: "Avatar URL must be a valid http or https URL", Field: "avatar_url"}
+		}
+	}
+	return nil
+}
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	user, err := s.users.GetUser(r.Context(), currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (s *Server) updateMe(w http.ResponseWriter, r *http.Request) {
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	claims := currentUser(r)
+	if req.Username != nil && *req.Username != claims.Username {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Username cannot be changed", Field: "username"})
+		return
+	}
+
+	if err := validateProfile(&req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := s.users.UpdateProfile(r.Context(), claims.UserID, store.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AvatarURL:   req.AvatarURL,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// DeleteAccountRequest DELETE /api/users/me 的请求体，需要再次输入密码
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// deleteMe 用户删除自己的账号，成功后当前 token 立即失效
+func (s *Server) deleteMe(w http.ResponseWriter, r *http.Request) {
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	userID := currentUser(r).UserID
+	hash, err := s.users.GetPasswordHash(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !checkPassword(hash, req.Password) {
+		writeError(w, r, &APIError{Status: http.StatusForbidden, Message: "Password is incorrect", Field: "password"})
+		return
+	}
+
+	if err := s.deleteAccount(r.Context(), userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	loggerFromContext(r.Context()).Info("account deleted by user", "user_id", userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid user ID"))
+		return
+	}
+
+	user, err := s.users.GetUser(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(publicUser(user))
+}
+
+// searchUsers 按用户名前缀查找用户，用于 @提及 补全和发起私信，结果不包含当前用户
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "q is required", Field: "q"})
+		return
+	}
+	limit, _, err := parsePagination(r, defaultUserSearchLimit, maxUserSearchLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	users, err := s.users.SearchUsers(r.Context(), q, currentUser(r).UserID, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
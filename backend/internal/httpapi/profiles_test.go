@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestSearchUsers 按用户名前缀查找，不区分大小写，结果不包含当前用户，较短的用户名排在前面
+func TestSearchUsers(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("alice")
+	ts.addUser("Alicia")
+	ts.addUser("ALI")
+	ts.addUser("bob")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ali", []string{"ALI", "Alicia"}},
+		{"ALI", []string{"ALI", "Alicia"}},
+		{"alic", []string{"Alicia"}},
+		{"alice", []string{}},
+		{"b", []string{"bob"}},
+		{"li", []string{}},
+		{"%25", []string{}},
+		{"ali&limit=1", []string{"ALI"}},
+	}
+	for _, tt := range tests {
+		var users []store.Participant
+		decodeResponse(t, ts.do("GET", "/api/users/search?q="+tt.query, token, nil), http.StatusOK, &users)
+		got := make([]string, len(users))
+		for i, u := range users {
+			got[i] = u.Username
+			if u.ID == 0 {
+				t.Errorf("q=%s: user %q has no id", tt.query, u.Username)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("q=%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchUsersRejects(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("alice")
+
+	decodeResponse(t, ts.do("GET", "/api/users/search?q=ali", "", nil), http.StatusUnauthorized, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/search?q=%20", token, nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("GET", "/api/users/search?q=ali&limit=0", token, nil), http.StatusBadRequest, nil)
+}
+
+// TestSearchUsersLimit 最多返回 20 个用户
+func TestSearchUsersLimit(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("me")
+	for i := 0; i < maxUserSearchLimit+5; i++ {
+		ts.addUser("user" + strconv.Itoa(i))
+	}
+
+	for query, want := range map[string]int{"user": defaultUserSearchLimit, "user&limit=100": maxUserSearchLimit} {
+		var users []store.Participant
+		decodeResponse(t, ts.do("GET", "/api/users/search?q="+query, token, nil), http.StatusOK, &users)
+		if len(users) != want {
+			t.Errorf("q=%s: %d users, want %d", query, len(users), want)
+		}
+	}
+}
+
+// slowLastSeen 在 release 关闭之前阻塞 TouchLastSeen，更新完成后把用户 ID 发送到 touched
+type slowLastSeen struct {
+	store.UserStore
+	release chan struct{}
+	touched chan int
+}
+
+func (s *slowLastSeen) TouchLastSeen(ctx context.Context, id int) error {
+	<-s.release
+	err := s.UserStore.TouchLastSeen(ctx, id)
+	s.touched <- id
+	return err
+}
+
+// TestLastSeenUpdatedInBackground 已认证的请求更新 last_seen_at，但请求不等待数据库更新完成
+func TestLastSeenUpdatedInBackground(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.addUser("alice")
+	slow := &slowLastSeen{UserStore: ts.users, release: make(chan struct{}), touched: make(chan int, 1)}
+	ts.users = slow
+	path := "/api/users/" + strconv.Itoa(alice.ID)
+
+	var profile PublicUser
+	decodeResponse(t, ts.do("GET", path, "", nil), http.StatusOK, &profile)
+	if profile.LastSeenAt != nil {
+		t.Fatalf("last_seen_at = %v before any authenticated request", profile.LastSeenAt)
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- ts.do("GET", "/api/users/me", token, nil) }()
+	select {
+	case rec := <-done:
+		decodeResponse(t, rec, http.StatusOK, nil)
+	case <-time.After(time.Second):
+		t.Fatal("request waited for the last_seen_at update")
+	}
+
+	before := time.Now()
+	close(slow.release)
+	select {
+	case id := <-slow.touched:
+		if id != alice.ID {
+			t.Fatalf("touched user %d, want %d", id, alice.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("last_seen_at was not updated")
+	}
+	decodeResponse(t, ts.do("GET", path, "", nil), http.StatusOK, &profile)
+	if profile.LastSeenAt == nil || profile.LastSeenAt.Before(before) {
+		t.Fatalf("last_seen_at = %v, want at least %v", profile.LastSeenAt, before)
+	}
+}
+
+// TestDeleteMe 密码错误时返回 403 且账号不受影响；删除成功后连接以 4410 关闭，旧 token 失效，旧的邮箱和密码不能登录
+func TestDeleteMe(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.addUser("alice")
+	conn := dialWebSocket(t, ts, token)
+
+	var apiErr APIError
+	decodeResponse(t, ts.do("DELETE", "/api/users/me", token, DeleteAccountRequest{Password: "wrong-password1"}), http.StatusForbidden, &apiErr)
+	if apiErr.Field != "password" {
+		t.Fatalf("error = %+v, want password field", apiErr)
+	}
+	decodeResponse(t, ts.do("GET", "/api/users/me", token, nil), http.StatusOK, nil)
+
+	decodeResponse(t, ts.do("DELETE", "/api/users/me", token, DeleteAccountRequest{Password: testPassword}), http.StatusNoContent, nil)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var closeErr *websocket.CloseError
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !asCloseError(err, &closeErr) || closeErr.Code != 4410 {
+			t.Fatalf("read error = %v, want close code 4410", err)
+		}
+		break
+	}
+
+	decodeResponse(t, ts.do("GET", "/api/users/me", token, nil), http.StatusUnauthorized, nil)
+	if rec := ts.do("POST", "/api/auth/login", "", LoginRequest{Email: alice.Email, Password: testPassword}); rec.Code == http.StatusOK {
+		t.Fatal("deleted user logged in")
+	}
+}
@@ -0,0 +1,284 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	pushQueueSize = 256
+	// 同一用户在同一聊天室或会话中的消息在这段时间内合并为一条推送
+	pushBatchWindow   = 10 * time.Second
+	pushFlushInterval = time.Second
+	// 推送正文中消息内容的最大字符数
+	maxPushPreviewLength = 200
+	maxPushTokenLength   = 4096
+)
+
+type PushDevice = store.PushDevice
+
+// PushNotification 发给设备的通知内容，Data 由客户端用于打开对应的聊天室或会话
+type PushNotification struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// errInvalidPushToken 推送服务报告令牌无效（应用已卸载、订阅已过期等），设备会被删除
+var errInvalidPushToken = errors.New("push token is no longer valid")
+
+// PushSender 向单个设备发送推送，令牌无效时返回 errInvalidPushToken
+type PushSender interface {
+	Send(ctx context.Context, device PushDevice, n PushNotification) error
+}
+
+// logPushSender 未配置推送服务时使用，只把通知写到日志
+type logPushSender struct{}
+
+func (logPushSender) Send(ctx context.Context, device PushDevice, n PushNotification) error {
+	slog.Info("push notification (not sent)", "user_id", device.UserID, "platform", device.Platform, "title", n.Title, "body", n.Body)
+	return nil
+}
+
+// newPushSender Provider 为 fcm 时使用服务账号通过 FCM 发送，否则只记录日志
+func newPushSender(cfg config.PushConfig) (PushSender, error) {
+	if cfg.Provider != "fcm" {
+		return logPushSender{}, nil
+	}
+	return newFCMSenderFromFile(cfg.FCMCredentialsFile)
+}
+
+// RegisterDeviceRequest POST /api/users/me/devices 的请求体。
+// FCM 设备提供 token，Web Push 设备提供浏览器 PushManager.subscribe() 返回的 subscription
+type RegisterDeviceRequest struct {
+	Platform     string                   `json:"platform"`
+	Token        string                   `json:"token"`
+	Subscription *WebPushSubscriptionJSON `json:"subscription"`
+}
+
+type WebPushSubscriptionJSON struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+func (req *RegisterDeviceRequest) device(userID int) (PushDevice, error) {
+	device := PushDevice{UserID: userID, Platform: req.Platform}
+	switch req.Platform {
+	case store.PushPlatformFCM:
+		if req.Token == "" || len(req.Token) > maxPushTokenLength {
+			return device, &APIError{Status: http.StatusBadRequest, Message: "token is required", Field: "token"}
+		}
+		device.Token = req.Token
+	case store.PushPlatformWebPush:
+		sub := req.Subscription
+		if sub == nil || sub.Keys.P256dh == "" || sub.Keys.Auth == "" || len(sub.Endpoint) > maxPushTokenLength {
+			return device, &APIError{Status: http.StatusBadRequest, Message: "subscription with endpoint and keys is required", Field: "subscription"}
+		}
+		if u, err := url.Parse(sub.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+			return device, &APIError{Status: http.StatusBadRequest, Message: "subscription endpoint must be an https URL", Field: "subscription"}
+		}
+		device.Token, device.P256dh, device.Auth = sub.Endpoint, sub.Keys.P256dh, sub.Keys.Auth
+	default:
+		return device, &APIError{Status: http.StatusBadRequest, Message: "platform must be fcm or webpush", Field: "platform"}
+	}
+	return device, nil
+}
+
+// registerDevice 注册推送设备，同一令牌重复注册时更新为当前用户
+func (s *Server) registerDevice(w http.ResponseWriter, r *http.Request) {
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	device, err := req.device(currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.push.RegisterPushDevice(r.Context(), &device); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(device)
+}
+
+func (s *Server) deleteDevice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid device ID"))
+		return
+	}
+
+	if err := s.push.DeletePushDevice(r.Context(), currentUser(r).UserID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pushEvent 需要推送给离线用户的消息：聊天室中提及该用户的消息，或发给该用户的私信
+type pushEvent struct {
+	userID int
+	msg    Message
+}
+
+// pushKey 推送按用户和聊天室（或会话）合并
+type pushKey struct {
+	userID         int
+	roomID         int
+	conversationID int
+}
+
+// pushBatch 合并窗口内的消息，只保留第一条用于生成通知内容
+type pushBatch struct {
+	first    Message
+	count    int
+	deadline time.Time
+}
+
+// enqueuePush 用户在本实例没有 WebSocket 连接时把消息加入推送队列，队列已满时丢弃。
+// 多实例部署时只检查本实例的连接，连接在其他实例上的用户也可能收到推送。
+func (s *Server) enqueuePush(ctx context.Context, ev pushEvent) {
+	if s.push == nil || ev.userID == ev.msg.UserID || s.hub.IsOnline(ev.userID) {
+		return
+	}
+	select {
+	case s.pushQueue <- ev:
+	default:
+		loggerFromContext(ctx).Warn("push queue is full, skipping", "user_id", ev.userID, "message_id", ev.msg.ID)
+	}
+}
+
+// runPushNotifier 合并推送队列中的消息，窗口结束后发送，ctx 取消后退出
+func (s *Server) runPushNotifier(ctx context.Context) {
+	go func() {
+		pending := make(map[pushKey]*pushBatch)
+		ticker := time.NewTicker(pushFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-s.pushQueue:
+				key := pushKey{userID: ev.userID, roomID: ev.msg.RoomID}
+				if ev.msg.ConversationID != nil {
+					key = pushKey{userID: ev.userID, conversationID: *ev.msg.ConversationID}
+				}
+				batch := pending[key]
+				if batch == nil {
+					batch = &pushBatch{first: ev.msg, deadline: time.Now().Add(pushBatchWindow)}
+					pending[key] = batch
+				}
+				batch.count++
+			case now := <-ticker.C:
+				for key, batch := range pending {
+					if now.Before(batch.deadline) {
+						continue
+					}
+					delete(pending, key)
+					go s.sendPush(ctx, key.userID, batch)
+				}
+			}
+		}
+	}()
+}
+
+// sendPush 发送合并后的通知。用户在合并期间上线时不再推送，无效的令牌会被删除
+func (s *Server) sendPush(ctx context.Context, userID int, batch *pushBatch) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(slog.Default(), "push notification panic", p)
+		}
+	}()
+	if s.hub.IsOnline(userID) {
+		return
+	}
+
+	devices, err := s.push.ListPushDevices(ctx, userID)
+	if err != nil {
+		slog.Error("failed to load push devices", "user_id", userID, "error", err)
+		return
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	n, err := s.pushNotification(ctx, batch)
+	if err != nil {
+		slog.Error("failed to build push notification", "user_id", userID, "error", err)
+		return
+	}
+
+	var invalid []string
+	for _, device := range devices {
+		err := s.PushSender.Send(ctx, device, n)
+		if errors.Is(err, errInvalidPushToken) {
+			invalid = append(invalid, device.Token)
+			continue
+		}
+		if err != nil {
+			slog.Warn("push notification failed", "user_id", userID, "device_id", device.ID, "error", err)
+		}
+	}
+	if len(invalid) > 0 {
+		if err := s.push.DeletePushTokens(ctx, invalid); err != nil {
+			slog.Error("failed to delete invalid push tokens", "user_id", userID, "error", err)
+		}
+	}
+}
+
+func (s *Server) pushNotification(ctx context.Context, batch *pushBatch) (PushNotification, error) {
+	msg := batch.first
+	sender := msg.DisplayName
+	if sender == "" {
+		sender = msg.Username
+	}
+	preview := msg.Content
+	if utf8.RuneCountInString(preview) > maxPushPreviewLength {
+		preview = string([]rune(preview)[:maxPushPreviewLength]) + "…"
+	}
+
+	n := PushNotification{Data: map[string]string{"message_id": strconv.Itoa(msg.ID)}}
+	if msg.ConversationID != nil {
+		n.Title = sender
+		n.Data["conversation_id"] = strconv.Itoa(*msg.ConversationID)
+		n.Body = preview
+		if batch.count > 1 {
+			n.Body = fmt.Sprintf("%d new messages", batch.count)
+		}
+		return n, nil
+	}
+
+	room, err := s.rooms.GetRoom(ctx, msg.RoomID)
+	if err != nil {
+		return n, err
+	}
+	n.Title = "#" + room.Name
+	n.Data["room_id"] = strconv.Itoa(msg.RoomID)
+	n.Body = fmt.Sprintf("%s mentioned you: %s", sender, preview)
+	if batch.count > 1 {
+		n.Body = fmt.Sprintf("You were mentioned %d times", batch.count)
+	}
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket 令牌桶限流器，now 可在测试中替换为假时钟
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+func (b *tokenBucket) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// takeAbove 在取走一个令牌后剩余令牌数不低于 reserve 时成功；
+// 失败时返回需要等待的时间。reserve 为 0 即普通的 take。
+func (b *tokenBucket) takeAbove(reserve float64) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens-1 >= reserve {
+		b.tokens--
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Hour
+	}
+	missing := reserve + 1 - b.tokens
+	return false, time.Duration(missing / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() (bool, time.Duration) {
+	return b.takeAbove(0)
+}
@@ -0,0 +1,213 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+type ReactionSummary = store.ReactionSummary
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// ReactionEvent 表情变化时广播给聊天室的数据
+type ReactionEvent struct {
+	MessageID int    `json:"message_id"`
+	Emoji     string `json:"emoji"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+}
+
+// ReactionUpdatedEvent 表情变化后广播的完整汇总，客户端可以直接替换本地数据。
+// 广播给所有人，因此 Reacted 始终为 false。
+type ReactionUpdatedEvent struct {
+	MessageID int               `json:"message_id"`
+	Reactions []ReactionSummary `json:"reactions"`
+}
+
+// ReactionCount GET /api/messages/{id}/reactions 返回的每个表情的数据
+type ReactionCount struct {
+	Count   int  `json:"count"`
+	Reacted bool `json:"reacted"`
+}
+
+var errInvalidEmoji = &APIError{Status: http.StatusBadRequest, Message: "Emoji is not in the list of allowed reactions", Field: "emoji"}
+
+func messageIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid message ID")
+	}
+	return id, nil
+}
+
+// parseReactionRequest 解析消息 ID 和表情，失败时已写入错误响应
+func parseReactionRequest(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return 0, "", false
+	}
+
+	// DELETE /api/messages/{id}/reactions/{emoji} 从路径中取表情，其余从请求体中取
+	raw, fromPath := mux.Vars(r)["emoji"]
+	if !fromPath {
+		var req ReactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+			return 0, "", false
+		}
+		raw = req.Emoji
+	}
+
+	emoji, ok := normalizeEmoji(raw)
+	if !ok {
+		writeError(w, r, errInvalidEmoji)
+		return 0, "", false
+	}
+	return messageID, emoji, true
+}
+
+func (s *Server) addReaction(w http.ResponseWriter, r *http.Request) {
+	messageID, emoji, ok := parseReactionRequest(w, r)
+	if !ok {
+		return
+	}
+	user := currentUser(r)
+
+	msg, conv, err := s.visibleMessage(r.Context(), messageID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if msg.MessageType == store.MessageTypeSystem {
+		writeError(w, r, errSystemMessage)
+		return
+	}
+
+	added, err := s.reactions.AddReaction(r.Context(), messageID, user.UserID, emoji)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 重复添加同一个表情不再广播
+	if added {
+		s.hub.Publish(messageEvent(msg, conv, ws.EventReactionAdded, ReactionEvent{
+			MessageID: messageID,
+			Emoji:     emoji,
+			UserID:    user.UserID,
+			Username:  user.Username,
+		}))
+		s.publishReactionsUpdated(r.Context(), msg, conv)
+		s.notifyReaction(r.Context(), msg, user.UserID, emoji)
+
+		// 私信不支持置顶
+		if conv == nil {
+			if err := s.applyAutoPin(r.Context(), msg.RoomID, messageID, emoji); err != nil {
+				loggerFromContext(r.Context()).Error("auto pin failed", "message_id", messageID, "error", err)
+			}
+		}
+	}
+
+	s.writeReactions(w, r, messageID, user.UserID)
+}
+
+func (s *Server) removeReaction(w http.ResponseWriter, r *http.Request) {
+	messageID, emoji, ok := parseReactionRequest(w, r)
+	if !ok {
+		return
+	}
+	user := currentUser(r)
+
+	msg, conv, err := s.visibleMessage(r.Context(), messageID, user.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	removed, err := s.reactions.RemoveReaction(r.Context(), messageID, user.UserID, emoji)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if removed {
+		s.hub.Publish(messageEvent(msg, conv, ws.EventReactionRemoved, ReactionEvent{
+			MessageID: messageID,
+			Emoji:     emoji,
+			UserID:    user.UserID,
+			Username:  user.Username,
+		}))
+		s.publishReactionsUpdated(r.Context(), msg, conv)
+
+		// 私信不支持置顶
+		if conv == nil {
+			if err := s.applyAutoPin(r.Context(), msg.RoomID, messageID, emoji); err != nil {
+				loggerFromContext(r.Context()).Error("auto pin failed", "message_id", messageID, "error", err)
+			}
+		}
+	}
+
+	s.writeReactions(w, r, messageID, user.UserID)
+}
+
+// publishReactionsUpdated 广播消息当前的表情汇总
+func (s *Server) publishReactionsUpdated(ctx context.Context, msg Message, conv *store.Conversation) {
+	reactions, err := s.reactions.ListReactions(ctx, msg.ID, 0)
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to load reactions", "message_id", msg.ID, "error", err)
+		return
+	}
+	s.hub.Publish(messageEvent(msg, conv, ws.EventReactionUpdated, ReactionUpdatedEvent{
+		MessageID: msg.ID,
+		Reactions: reactions,
+	}))
+}
+
+func (s *Server) getReactions(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, _, err := s.visibleMessage(r.Context(), messageID, viewerID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	reactions, err := s.reactions.ListReactions(r.Context(), messageID, viewerID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	counts := make(map[string]ReactionCount, len(reactions))
+	for _, reaction := range reactions {
+		counts[reaction.Emoji] = ReactionCount{Count: reaction.Count, Reacted: reaction.Reacted}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// writeReactions 返回某条消息当前的表情汇总
+func (s *Server) writeReactions(w http.ResponseWriter, r *http.Request, messageID, userID int) {
+	reactions, err := s.reactions.ListReactions(r.Context(), messageID, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+func TestNormalizeEmoji(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"👍", "👍", true},
+		{":thumbsup:", "👍", true},
+		{":+1:", "👍", true},
+		{" :FIRE: ", "🔥", true},
+		{"❤️", "❤️", true},
+		{"❤", "❤️", true},
+		{":heart:", "❤️", true},
+		{"🦄", "", false},
+		{":unicorn:", "", false},
+		{"", "", false},
+		{"👍👍", "", false},
+		{"thumbsup", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeEmoji(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeEmoji(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// reactionMessage 创建聊天室和一条消息，alice 和 bob 都是成员
+func reactionMessage(t *testing.T, ts *testServer) (store.Message, store.User, string, string) {
+	t.Helper()
+	ctx := context.Background()
+	alice, aliceToken := ts.addUser("alice")
+	bob, bobToken := ts.addUser("bob")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(ctx, room.ID, alice.ID)
+	ts.store.JoinRoom(ctx, room.ID, bob.ID)
+	msg := store.Message{RoomID: room.ID, UserID: alice.ID, Content: "hello"}
+	if err := ts.store.InsertMessage(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg, alice, aliceToken, bobToken
+}
+
+// TestAddReactionIdempotent 重复添加同一个表情只计一次，也不再广播
+func TestAddReactionIdempotent(t *testing.T) {
+	ts := newTestServer(t)
+	msg, alice, aliceToken, bobToken := reactionMessage(t, ts)
+	events := ts.subscribe(alice.ID, msg.RoomID)
+	path := fmt.Sprintf("/api/messages/%d/reactions", msg.ID)
+
+	for i := 0; i < 2; i++ {
+		var summary []ReactionSummary
+		decodeResponse(t, ts.do("POST", path, aliceToken, ReactionRequest{Emoji: "👍"}), http.StatusOK, &summary)
+		if len(summary) != 1 || summary[0].Emoji != "👍" || summary[0].Count != 1 || !summary[0].Reacted {
+			t.Fatalf("attempt %d: reactions = %+v", i+1, summary)
+		}
+	}
+	// 短代码和 Unicode 表情是同一个表情
+	decodeResponse(t, ts.do("POST", path, aliceToken, ReactionRequest{Emoji: ":thumbsup:"}), http.StatusOK, nil)
+	decodeResponse(t, ts.do("POST", path, bobToken, ReactionRequest{Emoji: ":+1:"}), http.StatusOK, nil)
+
+	got := events.drain(t, ts, msg.RoomID)
+	if n := countEvents(got, ws.EventReactionAdded); n != 2 {
+		t.Fatalf("%d reaction_added events, want 2", n)
+	}
+	if n := countEvents(got, ws.EventReactionUpdated); n != 2 {
+		t.Fatalf("%d reaction_updated events, want 2", n)
+	}
+
+	var counts map[string]ReactionCount
+	decodeResponse(t, ts.do("GET", path, bobToken, nil), http.StatusOK, &counts)
+	if want := (ReactionCount{Count: 2, Reacted: true}); counts["👍"] != want || len(counts) != 1 {
+		t.Fatalf("counts = %+v, want 👍 %+v", counts, want)
+	}
+}
+
+func TestAddReactionAllowlist(t *testing.T) {
+	ts := newTestServer(t)
+	msg, _, token, _ := reactionMessage(t, ts)
+	path := fmt.Sprintf("/api/messages/%d/reactions", msg.ID)
+
+	for _, emoji := range []string{"🦄", ":unicorn:", "", "<script>"} {
+		var apiErr APIError
+		decodeResponse(t, ts.do("POST", path, token, ReactionRequest{Emoji: emoji}), http.StatusBadRequest, &apiErr)
+		if apiErr.Field != "emoji" {
+			t.Fatalf("emoji %q: field = %q, want emoji", emoji, apiErr.Field)
+		}
+	}
+	decodeResponse(t, ts.do("POST", path, token, "{"), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("POST", "/api/messages/999/reactions", token, ReactionRequest{Emoji: "👍"}), http.StatusNotFound, nil)
+}
+
+func TestRemoveReaction(t *testing.T) {
+	ts := newTestServer(t)
+	msg, alice, token, _ := reactionMessage(t, ts)
+	path := fmt.Sprintf("/api/messages/%d/reactions", msg.ID)
+	decodeResponse(t, ts.do("POST", path, token, ReactionRequest{Emoji: "🔥"}), http.StatusOK, nil)
+	events := ts.subscribe(alice.ID, msg.RoomID)
+
+	var summary []ReactionSummary
+	decodeResponse(t, ts.do("DELETE", path+"/"+url.PathEscape(":fire:"), token, nil), http.StatusOK, &summary)
+	if len(summary) != 0 {
+		t.Fatalf("reactions after removal = %+v", summary)
+	}
+	// 删除不存在的表情成功但不广播
+	decodeResponse(t, ts.do("DELETE", path+"/"+url.PathEscape("🔥"), token, nil), http.StatusOK, nil)
+	if n := countEvents(events.drain(t, ts, msg.RoomID), ws.EventReactionRemoved); n != 1 {
+		t.Fatalf("%d reaction_removed events, want 1", n)
+	}
+}
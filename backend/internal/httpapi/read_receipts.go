@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// MarkReadRequest 请求体可以省略，此时标记到聊天室最新一条消息
+type MarkReadRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+// RoomReadEvent 发送给同一用户的所有连接，用于多设备同步未读数
+type RoomReadEvent struct {
+	RoomID            int `json:"room_id"`
+	LastReadMessageID int `json:"last_read_message_id"`
+}
+
+// ReadEvent 用户标记已读时广播给聊天室，可用于显示“已读”
+type ReadEvent struct {
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	MessageID int    `json:"message_id"`
+}
+
+func (s *Server) markRoomRead(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.MessageID < 0 {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid message_id"))
+		return
+	}
+
+	user := currentUser(r)
+	if err := s.requireMember(r.Context(), roomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if req.MessageID == 0 {
+		req.MessageID, err = s.messages.LatestRoomMessageID(r.Context(), roomID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// 聊天室还没有消息
+		if req.MessageID == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	} else {
+		msg, err := s.messages.GetMessage(r.Context(), req.MessageID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if msg.RoomID != roomID {
+			writeError(w, r, store.ErrNotFound)
+			return
+		}
+	}
+
+	moved, err := s.reads.MarkRead(r.Context(), user.UserID, roomID, req.MessageID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if moved {
+		s.hub.Publish(ws.Event{Type: ws.EventRead, RoomID: roomID, Data: ReadEvent{
+			UserID:    user.UserID,
+			Username:  user.Username,
+			MessageID: req.MessageID,
+		}})
+		s.hub.Publish(ws.Event{Type: ws.EventRoomRead, RoomID: roomID, UserIDs: []int{user.UserID}, Data: RoomReadEvent{
+			RoomID:            roomID,
+			LastReadMessageID: req.MessageID,
+		}})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
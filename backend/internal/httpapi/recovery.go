@@ -0,0 +1,36 @@
+package httpapi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// logPanic 记录 panic 的值和调用栈，必须在 recover 所在的 defer 中调用才能拿到完整的栈
+func logPanic(logger *slog.Logger, msg string, p interface{}) {
+	logger.Error(msg, "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+}
+
+// recoverPanics 捕获 handler 中的 panic，记录日志并返回 JSON 500。
+// 放在 requestLogger 内层，日志中带有请求 ID；响应已经开始写入时只记录日志。
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// http.ErrAbortHandler 是有意中止响应，交给 net/http 处理
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			logPanic(loggerFromContext(r.Context()), "handler panic", p)
+			if rec, ok := w.(*statusRecorder); ok && rec.status != 0 {
+				return
+			}
+			writeError(w, r, apiError(http.StatusInternalServerError, "Internal server error"))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// captureLogs 把默认日志器替换为写入缓冲区的 JSON 日志器，测试结束时恢复
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	orig := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(orig) })
+	return &buf
+}
+
+// panicHandler 只在测试中注册会 panic 的路由，中间件顺序与 Server.Handler 相同
+func panicHandler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	router.HandleFunc("/panic-after-write", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("boom after write")
+	})
+	router.HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	return requestIDMiddleware(requestLogger(recoverPanics(router)))
+}
+
+func TestRecoverPanics(t *testing.T) {
+	logs := captureLogs(t)
+	rec := httptest.NewRecorder()
+	panicHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+
+	var apiErr APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("body %q is not JSON: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusInternalServerError || apiErr.Code != "internal_error" {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	requestID := rec.Header().Get(requestIDHeader)
+	var entry struct {
+		Msg       string `json:"msg"`
+		Panic     string `json:"panic"`
+		Stack     string `json:"stack"`
+		RequestID string `json:"request_id"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		json.Unmarshal([]byte(line), &entry)
+		if entry.Msg == "handler panic" {
+			break
+		}
+	}
+	if entry.Msg != "handler panic" || entry.Panic != "boom" || entry.RequestID != requestID {
+		t.Fatalf("panic log = %+v, want request ID %s", entry, requestID)
+	}
+	if !strings.Contains(entry.Stack, "recovery_test.go") {
+		t.Fatalf("stack does not include the panicking handler:\n%s", entry.Stack)
+	}
+}
+
+// TestRecoverPanicsAfterWrite 响应已经开始写入时不再追加错误响应
+func TestRecoverPanicsAfterWrite(t *testing.T) {
+	logs := captureLogs(t)
+	rec := httptest.NewRecorder()
+	panicHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/panic-after-write", nil))
+
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "boom after write") {
+		t.Fatal("panic was not logged")
+	}
+}
+
+// TestRecoverPanicsAbortHandler http.ErrAbortHandler 交给 net/http 处理
+func TestRecoverPanicsAbortHandler(t *testing.T) {
+	captureLogs(t)
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	panicHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	t.Fatal("ErrAbortHandler was swallowed")
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"chatapp/internal/ws"
+)
+
+// 每个聊天室最多补发的消息数，超过时客户端应该通过 HTTP 重新拉取历史
+const maxCatchUpMessages = 500
+
+// ResyncRequiredEvent 错过的消息太多，无法通过 WebSocket 补发
+type ResyncRequiredEvent struct {
+	RoomID int `json:"room_id"`
+}
+
+// ResumedEvent 补发结束，之后的事件都是实时推送。Delivered 为每个聊天室补发的消息数
+type ResumedEvent struct {
+	Delivered map[int]int `json:"delivered"`
+}
+
+// parseResumeCursors 解析 ?resume=room_id:last_message_id,...
+func parseResumeCursors(value string) (map[int]int, error) {
+	cursors := make(map[int]int)
+	if value == "" {
+		return cursors, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		roomPart, idPart, ok := strings.Cut(part, ":")
+		roomID, err1 := strconv.Atoi(roomPart)
+		lastID, err2 := strconv.Atoi(idPart)
+		if !ok || err1 != nil || err2 != nil || roomID <= 0 || lastID < 0 {
+			return nil, &APIError{Status: http.StatusBadRequest, Message: "resume must be a list of room_id:last_message_id", Field: "resume"}
+		}
+		cursors[roomID] = lastID
+	}
+	return cursors, nil
+}
+
+// catchUp 补发每个聊天室中 ID 大于客户端游标的消息，然后切换到实时推送。
+// 连接在查询之前已经注册并处于补发状态，期间的实时事件先缓存起来：
+// 查询之前保存的消息由查询返回，之后保存的消息进入缓存，两者重复的部分在切换时去掉，
+// 因此每条消息恰好送达一次。
+func (s *Server) catchUp(ctx context.Context, c *ws.Client, cursors map[int]int) {
+	sent := make(map[int]bool)
+	result := ResumedEvent{Delivered: make(map[int]int)}
+	for roomID, lastID := range cursors {
+		if !c.Subscribed(roomID) {
+			continue
+		}
+		messages, err := s.messages.ListRoomMessagesAfter(ctx, roomID, lastID, c.UserID, maxCatchUpMessages+1)
+		if err != nil {
+			loggerFromContext(ctx).Warn("websocket catch-up failed", "room_id", roomID, "error", err)
+		}
+		if err != nil || len(messages) > maxCatchUpMessages {
+			c.Send(ws.Event{Type: ws.EventResyncRequired, RoomID: roomID, Data: ResyncRequiredEvent{RoomID: roomID}})
+			continue
+		}
+		for _, msg := range messages {
+			c.Send(ws.Event{Type: ws.EventMessage, RoomID: roomID, Data: msg})
+			sent[msg.ID] = true
+		}
+		result.Delivered[roomID] = len(messages)
+	}
+	s.hub.FinishCatchUp(c, sent)
+	c.Send(ws.Event{Type: ws.EventResumed, Data: result})
+}
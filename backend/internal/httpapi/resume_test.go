@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseResumeCursors(t *testing.T) {
+	cursors, err := parseResumeCursors("1:10,2:0")
+	if err != nil || len(cursors) != 2 || cursors[1] != 10 || cursors[2] != 0 {
+		t.Fatalf("cursors = %v, %v", cursors, err)
+	}
+	for _, value := range []string{"1", "1:", ":5", "a:1", "0:5", "1:-1", "1:2,"} {
+		if _, err := parseResumeCursors(value); err == nil {
+			t.Errorf("parseResumeCursors(%q) succeeded", value)
+		}
+	}
+}
+
+// dialResume 以 resume 游标重新连接，返回连接和 welcome 之后收到的事件，直到 resumed 为止
+func dialResume(t *testing.T, srv *httptest.Server, token, resume string) (*websocket.Conn, []wsFrame) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?token=" + token + "&resume=" + resume
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial websocket: %v (response %v)", err, resp)
+	}
+	t.Cleanup(func() { conn.Close() })
+	readUntil(t, conn, func(fr wsFrame) bool { return fr.Type == ws.EventWelcome })
+	var frames []wsFrame
+	readUntil(t, conn, func(fr wsFrame) bool {
+		frames = append(frames, fr)
+		return fr.Type == ws.EventResumed
+	})
+	return conn, frames
+}
+
+// frameMessageID 取出 message 事件中的消息 ID
+func frameMessageID(t *testing.T, fr wsFrame) int {
+	t.Helper()
+	var msg Message
+	if err := json.Unmarshal(fr.Data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.ID
+}
+
+// postMessage 通过 HTTP 发送消息，返回消息 ID
+func postMessage(t *testing.T, ts *testServer, token string, roomID int, content string) int {
+	t.Helper()
+	var msg Message
+	decodeResponse(t, ts.do("POST", "/api/messages", token, CreateMessageRequest{RoomID: roomID, Content: content}), http.StatusOK, &msg)
+	return msg.ID
+}
+
+// TestResumeDeliversMissedMessages 断线期间通过 HTTP 发送的消息在重连时按顺序补发，之后切换到实时推送
+func TestResumeDeliversMissedMessages(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	srv := httptest.NewServer(ts.handler)
+	defer srv.Close()
+
+	conn := dialWebSocket(t, ts, token)
+	seen := postMessage(t, ts, token, room.ID, "before")
+	readUntil(t, conn, func(fr wsFrame) bool { return fr.Type == ws.EventMessage })
+	conn.Close()
+
+	var missed []int
+	for i := 0; i < 3; i++ {
+		missed = append(missed, postMessage(t, ts, token, room.ID, fmt.Sprintf("missed %d", i)))
+	}
+
+	conn, frames := dialResume(t, srv, token, fmt.Sprintf("%d:%d", room.ID, seen))
+	var got []int
+	for _, fr := range frames {
+		if fr.Type == ws.EventMessage {
+			got = append(got, frameMessageID(t, fr))
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(missed) {
+		t.Fatalf("caught up %v, want %v", got, missed)
+	}
+	var resumed ResumedEvent
+	json.Unmarshal(frames[len(frames)-1].Data, &resumed)
+	if resumed.Delivered[room.ID] != len(missed) {
+		t.Fatalf("resumed = %+v", resumed)
+	}
+
+	live := postMessage(t, ts, token, room.ID, "live")
+	fr := readUntil(t, conn, func(fr wsFrame) bool { return fr.Type == ws.EventMessage })
+	if id := frameMessageID(t, fr); id != live {
+		t.Fatalf("live message %d, want %d", id, live)
+	}
+}
+
+// TestResumeWhileMessagesArrive 补发期间不断有新消息，每条消息恰好送达一次
+func TestResumeWhileMessagesArrive(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	srv := httptest.NewServer(ts.handler)
+	defer srv.Close()
+
+	want := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		want[postMessage(t, ts, token, room.ID, fmt.Sprintf("old %d", i))] = true
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			var msg Message
+			rec := ts.serve(ts.request("POST", "/api/messages", token, CreateMessageRequest{RoomID: room.ID, Content: fmt.Sprintf("new %d", i)}))
+			json.Unmarshal(rec.Body.Bytes(), &msg)
+			mu.Lock()
+			want[msg.ID] = true
+			mu.Unlock()
+		}
+	}()
+
+	conn, frames := dialResume(t, srv, token, fmt.Sprintf("%d:0", room.ID))
+	wg.Wait()
+	last := postMessage(t, ts, token, room.ID, "last")
+	want[last] = true
+
+	got := make(map[int]int)
+	for _, fr := range frames {
+		if fr.Type == ws.EventMessage {
+			got[frameMessageID(t, fr)]++
+		}
+	}
+	readUntil(t, conn, func(fr wsFrame) bool {
+		if fr.Type != ws.EventMessage {
+			return false
+		}
+		id := frameMessageID(t, fr)
+		got[id]++
+		return id == last
+	})
+
+	for id := range want {
+		if got[id] != 1 {
+			t.Errorf("message %d delivered %d times", id, got[id])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("delivered %d distinct messages, want %d", len(got), len(want))
+	}
+}
+
+// TestResumeTooManyMissed 错过的消息超过上限时要求客户端通过 HTTP 重新拉取
+func TestResumeTooManyMissed(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, token, _ := messageRoom(t, ts)
+	srv := httptest.NewServer(ts.handler)
+	defer srv.Close()
+	for i := 0; i <= maxCatchUpMessages; i++ {
+		if err := ts.store.InsertMessage(context.Background(), &store.Message{RoomID: room.ID, UserID: alice.ID, Content: "spam"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, frames := dialResume(t, srv, token, fmt.Sprintf("%d:0", room.ID))
+	if len(frames) != 2 || frames[0].Type != ws.EventResyncRequired {
+		t.Fatalf("received %d frames starting with %q, want resync_required and resumed", len(frames), frames[0].Type)
+	}
+
+	decodeResponse(t, ts.do("GET", "/ws?token="+token+"&resume=x", "", nil), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,289 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultRoomsPageSize = 20
+	maxRoomsPageSize     = 100
+)
+
+var validRoomSorts = map[string]bool{
+	store.RoomSortNameAsc:      true,
+	store.RoomSortNameDesc:     true,
+	store.RoomSortCreatedAsc:   true,
+	store.RoomSortCreatedDesc:  true,
+	store.RoomSortActivityDesc: true,
+}
+
+// roomSortAliases 简写的排序方式，按各自最常用的方向排序
+var roomSortAliases = map[string]string{
+	"name":          store.RoomSortNameAsc,
+	"created_at":    store.RoomSortCreatedDesc,
+	"last_activity": store.RoomSortActivityDesc,
+}
+
+// RoomListResponse GET /api/rooms 的响应，Total 为符合条件的聊天室总数
+type RoomListResponse struct {
+	Rooms []ChatRoom `json:"rooms"`
+	Total int        `json:"total"`
+}
+
+type UpdateRoomRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// SlowModeSeconds 省略时保持不变，0 表示关闭慢速模式
+	SlowModeSeconds *int `json:"slow_mode_seconds"`
+}
+
+func roomIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid room ID")
+	}
+	return id, nil
+}
+
+// requireRoomOwner 只有聊天室创建者或系统管理员可以修改或删除聊天室，
+// 创建者已删除（created_by 为 NULL）的聊天室只能由管理员管理
+func (s *Server) requireRoomOwner(ctx context.Context, roomID, userID int) error {
+	room, err := s.rooms.GetRoom(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if room.CreatedBy != nil && *room.CreatedBy == userID {
+		return nil
+	}
+	isAdmin, err := s.admin.IsAdmin(ctx, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if !isAdmin {
+		return store.ErrPermission
+	}
+	return nil
+}
+
+// roomRole 返回用户在聊天室中的角色，创建者总是 owner，不是成员时返回空字符串
+func (s *Server) roomRole(ctx context.Context, roomID, userID int) (string, error) {
+	room, err := s.rooms.GetRoom(ctx, roomID)
+	if err != nil {
+		return "", err
+	}
+	if room.CreatedBy != nil && *room.CreatedBy == userID {
+		return store.RoleOwner, nil
+	}
+	role, err := s.members.GetMemberRole(ctx, roomID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	return role, err
+}
+
+// requireModerator 聊天室 owner 或 moderator 才能执行的操作，返回当前用户的角色
+func (s *Server) requireModerator(ctx context.Context, roomID, userID int) (string, error) {
+	role, err := s.roomRole(ctx, roomID, userID)
+	if err != nil {
+		return "", err
+	}
+	if role != store.RoleOwner && role != store.RoleModerator {
+		return "", store.ErrPermission
+	}
+	return role, nil
+}
+
+func (s *Server) updateRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req UpdateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		writeError(w, r, apiError(http.StatusBadRequest, "Room name must be between 1 and 100 characters"))
+		return
+	}
+	if req.SlowModeSeconds != nil && (*req.SlowModeSeconds < 0 || *req.SlowModeSeconds > maxSlowModeSeconds) {
+		writeError(w, r, &APIError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("slow_mode_seconds must be between 0 and %d", maxSlowModeSeconds),
+			Field:   "slow_mode_seconds",
+		})
+		return
+	}
+
+	user := currentUser(r)
+	if err := s.requireRoomOwner(r.Context(), roomID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	old, err := s.rooms.GetRoom(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	room, err := s.rooms.UpdateRoom(r.Context(), roomID, req.Name, req.Description, req.SlowModeSeconds)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	s.hub.Publish(ws.Event{Type: ws.EventRoomUpdated, RoomID: room.ID, Data: room})
+	if room.Name != old.Name {
+		s.postSystemMessage(r.Context(), room.ID, SystemMessageMetadata{
+			Event:   systemEventRoomRenamed,
+			ActorID: user.UserID,
+			Actor:   user.Username,
+			OldName: old.Name,
+			NewName: room.Name,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+func (s *Server) deleteRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.requireRoomOwner(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 先通知并断开房间内的连接，再删除聊天室（消息通过外键级联删除）
+	s.hub.CloseRoom(roomID, "room deleted")
+
+	if err := s.rooms.DeleteRoom(r.Context(), roomID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getRoom 返回单个聊天室，已认证时附带成员身份、成员数、消息数和最后一条消息。
+// HEAD 请求只检查聊天室是否存在
+func (s *Server) getRoom(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var room ChatRoom
+	if viewer := viewerID(r); viewer != 0 && r.Method != http.MethodHead {
+		room, err = s.rooms.GetRoomSummary(r.Context(), roomID, viewer)
+	} else {
+		room, err = s.rooms.GetRoom(r.Context(), roomID)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, errRoomNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	json.NewEncoder(w).Encode(room)
+}
+
+func (s *Server) getRooms(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, defaultRoomsPageSize, maxRoomsPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	sort := query.Get("sort")
+	if alias, ok := roomSortAliases[sort]; ok {
+		sort = alias
+	}
+	if sort == "" {
+		sort = store.RoomSortCreatedDesc
+	}
+	if !validRoomSorts[sort] {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Invalid sort order", Field: "sort"})
+		return
+	}
+
+	// q 与 search 相同，两者都有时使用 search
+	search := query.Get("search")
+	if search == "" {
+		search = query.Get("q")
+	}
+
+	rooms, total, err := s.rooms.ListRooms(r.Context(), store.RoomListOptions{
+		ViewerID: viewerID(r),
+		Search:   strings.TrimSpace(search),
+		Sort:     sort,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomListResponse{Rooms: rooms, Total: total})
+}
+
+func (s *Server) getRoomMessages(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.requireMember(r.Context(), roomID, viewerID(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	opts, err := parseMessagePage(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.RoomID, opts.ViewerID = roomID, viewerID(r)
+
+	page, err := s.listMessagePage(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
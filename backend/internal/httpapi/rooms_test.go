@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"chatapp/internal/store"
+)
+
+// TestRoomOwnerAuthorization 只有创建者和系统管理员可以修改或删除聊天室，
+// 创建者已删除的聊天室只能由管理员管理
+func TestRoomOwnerAuthorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       string
+		orphan     bool
+		wantUpdate int
+		wantDelete int
+	}{
+		{"owner", "owner", false, http.StatusOK, http.StatusNoContent},
+		{"member", "member", false, http.StatusForbidden, http.StatusForbidden},
+		{"admin", "admin", false, http.StatusOK, http.StatusNoContent},
+		{"anonymous", "", false, http.StatusUnauthorized, http.StatusUnauthorized},
+		{"admin on orphaned room", "admin", true, http.StatusOK, http.StatusNoContent},
+		{"member on orphaned room", "member", true, http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			owner, ownerToken := ts.addUser("owner")
+			member, memberToken := ts.addUser("member")
+			admin, adminToken := ts.addUser("admin")
+			if _, err := ts.store.PromoteAdmin(context.Background(), admin.Email); err != nil {
+				t.Fatal(err)
+			}
+			createdBy := &owner.ID
+			if tt.orphan {
+				createdBy = nil
+			}
+			room := ts.store.AddRoom("general", "", createdBy)
+			ts.store.JoinRoom(context.Background(), room.ID, member.ID)
+			token := map[string]string{"owner": ownerToken, "member": memberToken, "admin": adminToken}[tt.user]
+			path := "/api/rooms/" + strconv.Itoa(room.ID)
+
+			rec := ts.do("PUT", path, token, UpdateRoomRequest{Name: "renamed"})
+			decodeResponse(t, rec, tt.wantUpdate, nil)
+			rec = ts.do("DELETE", path, token, nil)
+			decodeResponse(t, rec, tt.wantDelete, nil)
+
+			_, err := ts.store.GetRoom(context.Background(), room.ID)
+			if deleted := err != nil; deleted != (tt.wantDelete == http.StatusNoContent) {
+				t.Fatalf("room deleted = %v after DELETE returned %d", deleted, tt.wantDelete)
+			}
+		})
+	}
+}
+
+func TestRoomOwnerAuthorizationUnknownRoom(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.addUser("alice")
+
+	decodeResponse(t, ts.do("PUT", "/api/rooms/42", token, UpdateRoomRequest{Name: "renamed"}), http.StatusNotFound, nil)
+	decodeResponse(t, ts.do("DELETE", "/api/rooms/42", token, nil), http.StatusNotFound, nil)
+}
+
+func TestDisabledAdminCannotManageRooms(t *testing.T) {
+	ts := newTestServer(t)
+	owner, _ := ts.addUser("owner")
+	admin, adminToken := ts.addUser("admin")
+	ts.store.PromoteAdmin(context.Background(), admin.Email)
+	room := ts.store.AddRoom("general", "", &owner.ID)
+
+	// 停用后旧 token 失效，即使绕过 token 检查，IsAdmin 也不把停用的管理员当作管理员
+	if _, err := ts.store.SetUserDisabled(context.Background(), admin.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.requireRoomOwner(context.Background(), room.ID, admin.ID); err == nil {
+		t.Fatal("disabled admin passed the owner check")
+	}
+	decodeResponse(t, ts.do("DELETE", "/api/rooms/"+strconv.Itoa(room.ID), adminToken, nil), http.StatusUnauthorized, nil)
+}
+
+// roomNames 返回 GET /api/rooms 的聊天室名称，用逗号连接
+func roomNames(t *testing.T, ts *testServer, query string) (string, int) {
+	t.Helper()
+	var resp RoomListResponse
+	decodeResponse(t, ts.do("GET", "/api/rooms"+query, "", nil), http.StatusOK, &resp)
+	names := make([]string, len(resp.Rooms))
+	for i, room := range resp.Rooms {
+		names[i] = room.Name
+	}
+	return strings.Join(names, ","), resp.Total
+}
+
+func TestListRoomsSort(t *testing.T) {
+	ts := newTestServer(t)
+	alice, _ := ts.addUser("alice")
+	bravo := ts.store.AddRoom("bravo", "", &alice.ID)
+	alpha := ts.store.AddRoom("alpha", "", &alice.ID)
+	ts.store.AddRoom("charlie", "", &alice.ID)
+	// bravo 的消息最新，charlie 没有消息
+	for _, roomID := range []int{alpha.ID, bravo.ID} {
+		if err := ts.store.InsertMessage(context.Background(), &store.Message{RoomID: roomID, UserID: alice.ID, Content: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"", "charlie,alpha,bravo"},
+		{"name_asc", "alpha,bravo,charlie"},
+		{"name_desc", "charlie,bravo,alpha"},
+		{"created_asc", "bravo,alpha,charlie"},
+		{"created_desc", "charlie,alpha,bravo"},
+		{"activity_desc", "bravo,alpha,charlie"},
+		{"name", "alpha,bravo,charlie"},
+		{"last_activity", "bravo,alpha,charlie"},
+	}
+	for _, tt := range tests {
+		got, total := roomNames(t, ts, "?sort="+tt.sort)
+		if got != tt.want || total != 3 {
+			t.Errorf("sort=%s: rooms %s, total %d, want %s, total 3", tt.sort, got, total, tt.want)
+		}
+	}
+
+	decodeResponse(t, ts.do("GET", "/api/rooms?sort=popular", "", nil), http.StatusBadRequest, nil)
+}
+
+func TestListRoomsSearch(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.AddRoom("Golang", "", nil)
+	ts.store.AddRoom("random", "Talk about GO and more", nil)
+	ts.store.AddRoom("music", "", nil)
+
+	tests := []struct {
+		query string
+		want  string
+		total int
+	}{
+		{"?search=go&sort=name_asc", "Golang,random", 2},
+		{"?search=GOLANG", "Golang", 1},
+		{"?search=%20music%20", "music", 1},
+		{"?q=talk", "random", 1},
+		{"?search=jazz", "", 0},
+		// total 是符合条件的总数，不受分页影响
+		{"?search=go&sort=name_asc&limit=1", "Golang", 2},
+		{"?search=go&sort=name_asc&limit=1&offset=1", "random", 2},
+		{"?search=go&offset=5", "", 2},
+	}
+	for _, tt := range tests {
+		got, total := roomNames(t, ts, tt.query)
+		if got != tt.want || total != tt.total {
+			t.Errorf("%s: rooms %q, total %d, want %q, total %d", tt.query, got, total, tt.want, tt.total)
+		}
+	}
+
+	decodeResponse(t, ts.do("GET", "/api/rooms?limit=0", "", nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("GET", "/api/rooms?offset=-1", "", nil), http.StatusBadRequest, nil)
+}
+
+// TestListRoomsPageSize 默认每页 20 个，limit 超过 100 时按 100 返回
+func TestListRoomsPageSize(t *testing.T) {
+	ts := newTestServer(t)
+	for i := 0; i < maxRoomsPageSize+5; i++ {
+		ts.store.AddRoom("room"+strconv.Itoa(i), "", nil)
+	}
+
+	for query, want := range map[string]int{"": defaultRoomsPageSize, "?limit=1000": maxRoomsPageSize} {
+		var resp RoomListResponse
+		decodeResponse(t, ts.do("GET", "/api/rooms"+query, "", nil), http.StatusOK, &resp)
+		if len(resp.Rooms) != want || resp.Total != maxRoomsPageSize+5 {
+			t.Errorf("%q: %d rooms, total %d, want %d and %d", query, len(resp.Rooms), resp.Total, want, maxRoomsPageSize+5)
+		}
+	}
+}
+
+// TestGetRoom 不存在的聊天室返回 404，HEAD 只检查是否存在；
+// 已认证的请求附带成员数、消息数和是否为成员，最后一条消息只返回给成员
+func TestGetRoom(t *testing.T) {
+	ts := newTestServer(t)
+	room, alice, aliceToken, bobToken := messageRoom(t, ts)
+	decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "first"}), http.StatusOK, nil)
+	decodeResponse(t, ts.do("POST", "/api/messages", aliceToken, CreateMessageRequest{RoomID: room.ID, Content: "latest"}), http.StatusOK, nil)
+	path := "/api/rooms/" + strconv.Itoa(room.ID)
+
+	var apiErr APIError
+	decodeResponse(t, ts.do("GET", "/api/rooms/9999", aliceToken, nil), http.StatusNotFound, &apiErr)
+	if apiErr.Message != errRoomNotFound.Message {
+		t.Fatalf("error = %+v", apiErr)
+	}
+	decodeResponse(t, ts.do("GET", "/api/rooms/abc", aliceToken, nil), http.StatusBadRequest, nil)
+	if rec := ts.do("HEAD", "/api/rooms/9999", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("HEAD unknown room = %d, want 404", rec.Code)
+	}
+	if rec := ts.do("HEAD", path, aliceToken, nil); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("HEAD room = %d with body %q, want 200 without body", rec.Code, rec.Body.String())
+	}
+
+	var member ChatRoom
+	decodeResponse(t, ts.do("GET", path, aliceToken, nil), http.StatusOK, &member)
+	if member.ID != room.ID || member.Name != "general" {
+		t.Fatalf("room = %+v", member)
+	}
+	if member.IsMember == nil || !*member.IsMember || member.MemberCount == nil || *member.MemberCount != 1 ||
+		member.MessageCount == nil || *member.MessageCount != 2 {
+		t.Fatalf("member view = %+v, want is_member, 1 member and 2 messages", member)
+	}
+	if member.LastMessage == nil || member.LastMessage.Content != "latest" || member.LastMessage.Username != alice.Username {
+		t.Fatalf("last_message = %+v, want alice's latest message", member.LastMessage)
+	}
+
+	var outsider ChatRoom
+	decodeResponse(t, ts.do("GET", path, bobToken, nil), http.StatusOK, &outsider)
+	if outsider.IsMember == nil || *outsider.IsMember || outsider.MemberCount == nil || *outsider.MemberCount != 1 || outsider.LastMessage != nil {
+		t.Fatalf("non-member view = %+v, want is_member false and no last_message", outsider)
+	}
+
+	// 未认证的请求只返回聊天室本身
+	var fields map[string]any
+	decodeResponse(t, ts.do("GET", path, "", nil), http.StatusOK, &fields)
+	for _, key := range []string{"is_member", "member_count", "message_count", "last_message"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("anonymous response contains %s", key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// 定时消息最多提前 30 天
+	maxScheduleAhead          = 30 * 24 * time.Hour
+	scheduledDeliveryInterval = 10 * time.Second
+)
+
+// runScheduledDelivery 定期发送到时间的定时消息，ctx 取消后退出
+func (s *Server) runScheduledDelivery(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(scheduledDeliveryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.deliverScheduledMessages(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Server) deliverScheduledMessages(ctx context.Context) {
+	messages, err := s.schedule.DeliverScheduledMessages(ctx, time.Now())
+	if err != nil {
+		slog.Error("failed to deliver scheduled messages", "error", err)
+		return
+	}
+	for _, msg := range messages {
+		if msg.ConversationID == nil {
+			s.publishMessage(ctx, msg, nil)
+			continue
+		}
+		conv, err := s.conversations.GetConversation(ctx, *msg.ConversationID)
+		if err != nil {
+			slog.Error("failed to load conversation for scheduled message", "message_id", msg.ID, "error", err)
+			continue
+		}
+		s.publishMessage(ctx, msg, &conv)
+	}
+}
+
+// getScheduledMessages 返回当前用户尚未发送的定时消息
+func (s *Server) getScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	messages, err := s.schedule.ListScheduledMessages(r.Context(), currentUser(r).UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// cancelScheduledMessage 取消自己尚未发送的定时消息，已经发送的消息返回 404
+func (s *Server) cancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.schedule.CancelScheduledMessage(r.Context(), messageID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
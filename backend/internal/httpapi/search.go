@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+)
+
+const (
+	minSearchQueryLength = 2
+	maxSearchQueryLength = 200
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+)
+
+// SearchResponse 搜索结果，下一页使用 offset + len(results)
+type SearchResponse struct {
+	Results []store.SearchResult `json:"results"`
+	Limit   int                  `json:"limit"`
+	Offset  int                  `json:"offset"`
+}
+
+func (s *Server) searchRoomMessages(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if n := utf8.RuneCountInString(q); n < minSearchQueryLength || n > maxSearchQueryLength {
+		writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Search query must be between 2 and 200 characters", Field: "q"})
+		return
+	}
+
+	limit, offset, err := parsePagination(r, defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := s.rooms.GetRoom(r.Context(), roomID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := s.requireMember(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	results, err := s.messages.SearchRoomMessages(r.Context(), roomID, q, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchResponse{Results: results, Limit: limit, Offset: offset})
+}
@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"chatapp/internal/store"
+)
+
+// searchRoom 创建聊天室并按顺序发送 contents，返回聊天室和成员的 token
+func searchRoom(t *testing.T, ts *testServer, contents ...string) (store.ChatRoom, string) {
+	t.Helper()
+	alice, token := ts.addUser("alice")
+	room := ts.store.AddRoom("general", "", &alice.ID)
+	ts.store.JoinRoom(context.Background(), room.ID, alice.ID)
+	for _, content := range contents {
+		if err := ts.store.InsertMessage(context.Background(), &store.Message{RoomID: room.ID, UserID: alice.ID, Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room, token
+}
+
+// search 返回搜索结果的消息内容，最新的在前
+func search(t *testing.T, ts *testServer, roomID int, token, q, extra string) []store.SearchResult {
+	t.Helper()
+	var resp SearchResponse
+	path := fmt.Sprintf("/api/rooms/%d/messages/search?q=%s%s", roomID, url.QueryEscape(q), extra)
+	decodeResponse(t, ts.do("GET", path, token, nil), http.StatusOK, &resp)
+	if resp.Results == nil {
+		t.Fatalf("q=%q: results is null, want an array", q)
+	}
+	return resp.Results
+}
+
+func resultContents(results []store.SearchResult) string {
+	contents := make([]string, len(results))
+	for i, r := range results {
+		contents[i] = r.Content
+	}
+	return strings.Join(contents, " | ")
+}
+
+func TestSearchRoomMessages(t *testing.T) {
+	ts := newTestServer(t)
+	room, token := searchRoom(t, ts,
+		"hello world",
+		"the world says hello",
+		"hello there",
+		"goodbye world",
+		"C++ & Go: (tips)!",
+		"50% off today",
+		"helloworld",
+	)
+
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{"single word", "hello", "hello there | the world says hello | hello world"},
+		{"case insensitive", "HELLO", "hello there | the world says hello | hello world"},
+		{"multi-word matches all words in any order", "world hello", "the world says hello | hello world"},
+		{"multi-word with extra spaces", "  hello   world ", "the world says hello | hello world"},
+		{"multi-word without a match", "hello goodbye", ""},
+		{"no match", "nothing", ""},
+		{"whole words only", "hell", ""},
+		{"operators are ignored", "go & (tips)", "C++ & Go: (tips)!"},
+		{"percent sign", "50%", "50% off today"},
+		{"quotes and colons", `"tips": c++`, "C++ & Go: (tips)!"},
+		{"sql injection", "'); DROP TABLE messages; --", ""},
+		{"only punctuation", "!!&|", ""},
+		{"backslash", `world\`, "goodbye world | the world says hello | hello world"},
+	}
+	for _, tt := range tests {
+		if got := resultContents(search(t, ts, room.ID, token, tt.q, "")); got != tt.want {
+			t.Errorf("%s: q=%q returned %q, want %q", tt.name, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRoomMessagesSnippetAndPaging(t *testing.T) {
+	ts := newTestServer(t)
+	room, token := searchRoom(t, ts, "Hello <b>world</b>", "hello again", "and hello once more")
+
+	results := search(t, ts, room.ID, token, "world hello", "")
+	if len(results) != 1 || results[0].Snippet != "<mark>Hello</mark> <b><mark>world</mark></b>" {
+		t.Fatalf("results = %+v", results)
+	}
+
+	page := search(t, ts, room.ID, token, "hello", "&limit=2")
+	next := search(t, ts, room.ID, token, "hello", "&limit=2&offset=2")
+	if resultContents(page) != "and hello once more | hello again" || resultContents(next) != "Hello <b>world</b>" {
+		t.Fatalf("pages = %q, %q", resultContents(page), resultContents(next))
+	}
+}
+
+func TestSearchRoomMessagesRejects(t *testing.T) {
+	ts := newTestServer(t)
+	room, token := searchRoom(t, ts, "hello")
+	_, outsider := ts.addUser("bob")
+	path := fmt.Sprintf("/api/rooms/%d/messages/search?q=", room.ID)
+
+	decodeResponse(t, ts.do("GET", path+"a", token, nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("GET", path+"%20%20", token, nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("GET", path+strings.Repeat("a", maxSearchQueryLength+1), token, nil), http.StatusBadRequest, nil)
+	decodeResponse(t, ts.do("GET", path+"hello", outsider, nil), http.StatusForbidden, nil)
+	decodeResponse(t, ts.do("GET", path+"hello", "", nil), http.StatusUnauthorized, nil)
+	decodeResponse(t, ts.do("GET", "/api/rooms/999/messages/search?q=hello", token, nil), http.StatusNotFound, nil)
+}
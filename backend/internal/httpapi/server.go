@@ -0,0 +1,387 @@
+package httpapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// 数据模型定义在 store 包中
+type (
+	Message  = store.Message
+	User     = store.User
+	ChatRoom = store.ChatRoom
+)
+
+// Stores handler 依赖的数据访问接口，生产环境全部由 postgres.Store 实现
+type Stores struct {
+	Users         store.UserStore
+	Resets        store.PasswordResetStore
+	Rooms         store.RoomStore
+	Members       store.MembershipStore
+	Messages      store.MessageStore
+	Conversations store.ConversationStore
+	Reactions     store.ReactionStore
+	Reads         store.ReadStore
+	Moderation    store.ModerationStore
+	Admin         store.AdminStore
+	Schedule      store.ScheduleStore
+	Webhooks      store.WebhookStore
+	Stats         store.StatsStore
+	Push          store.PushStore
+	Blocks        store.BlockStore
+	Export        store.ExportStore
+	Pins          store.PinStore
+	Stars         store.StarStore
+	Notifications store.NotificationStore
+	Attachments   store.AttachmentStore
+	Invites       store.InviteStore
+	Polls         store.PollStore
+	Bots          store.BotStore
+}
+
+// Server 持有所有 handler 的依赖，通过 NewServer 注入
+type Server struct {
+	db            *sql.DB // 仅用于健康检查中的连接池统计，可以为 nil
+	users         store.UserStore
+	resets        store.PasswordResetStore
+	rooms         store.RoomStore
+	members       store.MembershipStore
+	messages      store.MessageStore
+	conversations store.ConversationStore
+	reactions     store.ReactionStore
+	reads         store.ReadStore
+	moderation    store.ModerationStore
+	admin         store.AdminStore
+	schedule      store.ScheduleStore
+	webhooks      store.WebhookStore
+	stats         store.StatsStore
+	push          store.PushStore
+	blocks        store.BlockStore
+	export        store.ExportStore
+	pins          store.PinStore
+	stars         store.StarStore
+	notifications store.NotificationStore
+	attachments   store.AttachmentStore
+	invites       store.InviteStore
+	polls         store.PollStore
+	bots          store.BotStore
+
+	hub       *ws.Hub
+	auth      *auth.Authenticator
+	email     EmailSender
+	admission *upgradeAdmission
+	// upgrader 和 cors 的来源检查根据 CORS.AllowedOrigins 设置
+	upgrader    websocket.Upgrader
+	corsOptions cors.Options
+	// metricsEnabled 为 true 时暴露 GET /metrics
+	metricsEnabled bool
+
+	// MaxRequestBodyBytes 除上传文件外请求体的最大字节数
+	MaxRequestBodyBytes int64
+	// MaxMessageLength 消息内容的最大字符数
+	MaxMessageLength int
+	// WSReadLimit 客户端发送的单个 WebSocket 帧的最大字节数
+	WSReadLimit int64
+	// Passwords 计算新密码的哈希，已有的哈希在登录成功时升级到它的算法和参数
+	Passwords PasswordHasher
+	// PasswordResetTTL 密码重置链接的有效期
+	PasswordResetTTL time.Duration
+
+	// ValidateEmailMX 注册时检查邮箱域名的 MX 记录
+	ValidateEmailMX bool
+
+	// AppURL 前端地址，用于生成邀请链接
+	AppURL string
+
+	// Files 上传文件的存储后端，MaxUploadBytes 和 UploadTypes 限制上传的大小和类型
+	Files          FileStorage
+	MaxUploadBytes int64
+	UploadTypes    map[string]bool
+
+	// Profanity 开启了脏话过滤的聊天室使用的词表
+	Profanity *ProfanityFilter
+
+	// PushSender 向离线用户的设备发送推送通知
+	PushSender PushSender
+	// ContentFilter 对所有消息生效的内容过滤，ContentFilterAudit 为 true 时记录每次替换或拒绝
+	ContentFilter      ContentFilter
+	ContentFilterAudit bool
+
+	// thumbnails 等待生成缩略图的图片，由 runThumbnailWorkers 处理
+	thumbnails chan Attachment
+	// webhookQueue 等待发送到 webhook 的聊天室消息，由 runWebhookWorkers 处理
+	webhookQueue  chan webhookJob
+	webhookClient *http.Client
+	// webhookBackoff 第一次重试前的等待时间，之后每次翻倍
+	webhookBackoff time.Duration
+	webhookLimits  *webhookLimiter
+	// roomWebhookLimits 按聊天室限制共享密钥入站 webhook 的发送频率
+	roomWebhookLimits *webhookLimiter
+	// messageLimits 按用户限制发送消息的速率，未开启时为 nil
+	messageLimits *messageLimiter
+	// pushQueue 等待推送给离线用户的消息，由 runPushNotifier 合并后发送
+	pushQueue  chan pushEvent
+	slowMode   *slowModeTracker
+	readyCache readinessCache
+	roomStats  roomStatsCache
+
+	// commands 聊天室斜杠命令，按命令名索引
+	commands map[string]CommandHandler
+	// startedAt 服务启动时间，用于健康检查中的 uptime
+	startedAt time.Time
+}
+
+// NewServer 根据 cfg 创建 Server，cfg 应当来自 config.LoadConfig 或 config.Default。
+// 词表、推送凭据等文件读取失败时返回错误
+func NewServer(db *sql.DB, stores Stores, hub *ws.Hub, jwtKeys auth.JWTKeys, cfg config.Config) (*Server, error) {
+	s := &Server{
+		db:            db,
+		users:         stores.Users,
+		resets:        stores.Resets,
+		rooms:         stores.Rooms,
+		members:       stores.Members,
+		messages:      stores.Messages,
+		conversations: stores.Conversations,
+		reactions:     stores.Reactions,
+		reads:         stores.Reads,
+		moderation:    stores.Moderation,
+		admin:         stores.Admin,
+		schedule:      stores.Schedule,
+		webhooks:      stores.Webhooks,
+		stats:         stores.Stats,
+		push:          stores.Push,
+		blocks:        stores.Blocks,
+		export:        stores.Export,
+		pins:          stores.Pins,
+		stars:         stores.Stars,
+		notifications: stores.Notifications,
+		attachments:   stores.Attachments,
+		invites:       stores.Invites,
+		polls:         stores.Polls,
+		bots:          stores.Bots,
+		hub:           hub,
+		auth:          auth.NewAuthenticator(jwtKeys, stores.Users),
+		email:         newEmailSender(cfg.SMTP),
+		admission:     newUpgradeAdmission(float64(cfg.WS.UpgradeRate), cfg.WS.UpgradeBurst),
+
+		metricsEnabled:      cfg.MetricsEnabled,
+		MaxRequestBodyBytes: cfg.MaxRequestBodyBytes,
+		MaxMessageLength:    cfg.MaxMessageLength,
+		WSReadLimit:         cfg.WS.ReadLimit,
+		Passwords:           newPasswordHasher(cfg.Password),
+		PasswordResetTTL:    cfg.PasswordResetTTL,
+		ValidateEmailMX:     cfg.ValidateEmailMX,
+		AppURL:              cfg.AppURL,
+		Files:               newFileStorage(cfg.Storage),
+		MaxUploadBytes:      cfg.Storage.MaxUploadBytes,
+		UploadTypes:         parseContentTypes(cfg.Storage.AllowedTypes),
+		ContentFilterAudit:  cfg.ContentFilter.Audit,
+		thumbnails:          make(chan Attachment, thumbnailQueueSize),
+		webhookQueue:        make(chan webhookJob, webhookQueueSize),
+		webhookClient:       &http.Client{Timeout: webhookTimeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		webhookBackoff:      webhookRetryBackoff,
+		webhookLimits:       newWebhookLimiter(incomingWebhookRate, incomingWebhookBurst),
+		roomWebhookLimits:   newWebhookLimiter(roomWebhookRate, roomWebhookBurst),
+		pushQueue:           make(chan pushEvent, pushQueueSize),
+		slowMode:            newSlowModeTracker(),
+		commands:            make(map[string]CommandHandler),
+		startedAt:           time.Now(),
+	}
+	s.auth.TTL = cfg.JWT.TokenTTL
+	s.auth.Bots = stores.Bots
+	if cfg.MessageRateLimit > 0 {
+		s.messageLimits = newMessageLimiter(float64(cfg.MessageRateLimit), cfg.MessageRateBurst)
+	}
+
+	var err error
+	if s.Profanity, err = loadProfanityFilter(cfg.ProfanityWordsFile); err != nil {
+		return nil, fmt.Errorf("load profanity word list: %w", err)
+	}
+	if s.ContentFilter, err = newContentFilter(cfg.ContentFilter); err != nil {
+		return nil, fmt.Errorf("configure content filter: %w", err)
+	}
+	if s.PushSender, err = newPushSender(cfg.Push); err != nil {
+		return nil, fmt.Errorf("configure push notifications: %w", err)
+	}
+
+	if cfg.DevMode {
+		slog.Warn("DEV_MODE is enabled, requests from any origin are allowed")
+	}
+	origins := newOriginPolicy(cfg.CORS.AllowedOrigins, cfg.DevMode)
+	s.upgrader.CheckOrigin = origins.checkRequest
+	s.corsOptions = parseCORSConfig(origins, cfg.CORS.AllowedMethods, cfg.CORS.AllowCredentials)
+
+	metrics.registerHub(hub)
+	if db != nil {
+		metrics.registerDB(db)
+	}
+	metrics.registerAdmissionMetrics(s.admission)
+	s.registerCommands(nickCommand{s}, topicCommand{s}, membersCommand{s})
+	return s, nil
+}
+
+// Start 启动后台任务，ctx 取消时退出
+func (s *Server) Start(ctx context.Context) {
+	s.runThumbnailWorkers(ctx)
+	s.runWebhookWorkers(ctx)
+	s.runSlowModeCleanup(ctx)
+	s.runMessageLimiterCleanup(ctx)
+	s.runClientMsgIDCleanup(ctx)
+	s.runScheduledDelivery(ctx)
+	s.runMessageExpiry(ctx)
+	s.runPushNotifier(ctx)
+}
+
+// Handler 返回带有请求 ID、CORS、panic 恢复、请求日志和 tracing 中间件的完整 handler
+func (s *Server) Handler() http.Handler {
+	router := s.routes()
+	if s.metricsEnabled {
+		router.Handle("/metrics", metrics.handler()).Methods("GET")
+	}
+
+	c := cors.New(s.corsOptions)
+	// 请求 ID 最先设置，之后的中间件和 handler 都能取到；
+	// otelhttp 在其余中间件外层，日志和 handler 中的 context 都带有当前请求的 span
+	handler := otelhttp.NewHandler(requestLogger(recoverPanics(c.Handler(router))), "http.server")
+	return requestIDMiddleware(handler)
+}
+
+// DBErrorHook 统计数据库错误，设置为 postgres.Store 的 ErrorHook
+func DBErrorHook(error) {
+	metrics.dbErrors.Inc()
+}
+
+// routes 注册所有路由
+func (s *Server) routes() *mux.Router {
+	router := mux.NewRouter()
+
+	// 公开路由（不需要认证）
+	router.HandleFunc("/api/health", s.healthCheck).Methods("GET")
+	router.HandleFunc("/api/health/live", s.liveness).Methods("GET")
+	router.HandleFunc("/api/health/ready", s.readiness).Methods("GET")
+	router.HandleFunc("/api/auth/register", s.register).Methods("POST")
+	router.HandleFunc("/api/auth/login", s.login).Methods("POST")
+	router.HandleFunc("/api/auth/password-reset/request", s.requestPasswordReset).Methods("POST")
+	router.HandleFunc("/api/auth/password-reset/confirm", s.confirmPasswordReset).Methods("POST")
+	router.HandleFunc("/api/auth/forgot", s.requestPasswordReset).Methods("POST")
+	router.HandleFunc("/api/auth/reset", s.confirmPasswordReset).Methods("POST")
+	router.HandleFunc("/api/rooms", s.optionalAuthMiddleware(s.getRooms)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}", s.optionalAuthMiddleware(s.getRoom)).Methods("GET", "HEAD")
+	router.HandleFunc("/api/messages/{id}/reactions", s.optionalAuthMiddleware(s.getReactions)).Methods("GET")
+	router.HandleFunc("/api/messages/{id}/replies", s.optionalAuthMiddleware(s.getReplies)).Methods("GET")
+	router.HandleFunc("/api/messages/{id}/thread", s.optionalAuthMiddleware(s.getThread)).Methods("GET")
+
+	// 需要认证的路由
+	router.HandleFunc("/api/rooms/{id}", s.authMiddleware(s.updateRoom)).Methods("PUT")
+	router.HandleFunc("/api/rooms/{id}", s.authMiddleware(s.deleteRoom)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/auto-pin", s.authMiddleware(s.getAutoPinSettings)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/auto-pin", s.authMiddleware(s.updateAutoPinSettings)).Methods("PUT")
+	router.HandleFunc("/api/rooms/{id}/moderation", s.authMiddleware(s.updateRoomModeration)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/read", s.authMiddleware(s.markRoomRead)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/notifications", s.authMiddleware(s.getRoomNotifications)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/notifications", s.authMiddleware(s.updateRoomNotifications)).Methods("PUT")
+	router.HandleFunc("/api/rooms/{id}/messages", s.authMiddleware(s.getRoomMessages)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/events", s.optionalAuthMiddleware(s.handleRoomEvents)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/messages/bulk-delete", s.authMiddleware(s.bulkDeleteMessages)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/messages/search", s.authMiddleware(s.searchRoomMessages)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/join", s.authMiddleware(s.joinRoom)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/leave", s.authMiddleware(s.leaveRoom)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/members", s.authMiddleware(s.getRoomMembers)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}", s.authMiddleware(s.kickMember)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/role", s.authMiddleware(s.setMemberRole)).Methods("PUT")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/promote", s.authMiddleware(s.promoteMember)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/demote", s.authMiddleware(s.demoteMember)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/kick", s.authMiddleware(s.kickMember)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/ban", s.authMiddleware(s.banMember)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/members/{user_id}/ban", s.authMiddleware(s.unbanMember)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/bans", s.authMiddleware(s.createBan)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/bans/{user_id}", s.authMiddleware(s.unbanMember)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/webhooks", s.authMiddleware(s.listWebhooks)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/webhooks", s.authMiddleware(s.createWebhook)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/webhooks/incoming", s.authMiddleware(s.listIncomingWebhooks)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/webhooks/incoming", s.authMiddleware(s.createIncomingWebhook)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/webhooks/incoming/{webhook_id:[0-9]+}", s.authMiddleware(s.revokeIncomingWebhook)).Methods("DELETE")
+	router.HandleFunc("/api/webhooks/incoming/{token}", s.postIncomingWebhook).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/webhook-incoming", s.postRoomWebhook).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/webhook-incoming/rotate-secret", s.authMiddleware(s.rotateRoomWebhookSecret)).Methods("POST")
+	router.HandleFunc("/api/rooms/{id}/webhooks/{webhook_id:[0-9]+}", s.authMiddleware(s.updateWebhook)).Methods("PUT")
+	router.HandleFunc("/api/rooms/{id}/webhooks/{webhook_id:[0-9]+}", s.authMiddleware(s.deleteWebhook)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/stats", s.authMiddleware(s.getRoomStats)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/pins", s.authMiddleware(s.getRoomPins)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/invites", s.authMiddleware(s.listInvites)).Methods("GET")
+	router.HandleFunc("/api/rooms/{id}/invites", s.authMiddleware(s.createInvite)).Methods("POST")
+	router.HandleFunc("/api/invites/{id}/accept", s.authMiddleware(s.acceptInvite)).Methods("POST")
+	router.HandleFunc("/api/invites/{id}", s.authMiddleware(s.disableInvite)).Methods("DELETE")
+	router.HandleFunc("/api/rooms/{id}/polls", s.authMiddleware(s.createPoll)).Methods("POST")
+	router.HandleFunc("/api/polls/{id}", s.authMiddleware(s.getPoll)).Methods("GET")
+	router.HandleFunc("/api/polls/{id}/vote", s.authMiddleware(s.votePoll)).Methods("POST")
+	router.HandleFunc("/api/bots", s.authMiddleware(s.listBots)).Methods("GET")
+	router.HandleFunc("/api/bots", s.authMiddleware(s.createBot)).Methods("POST")
+	router.HandleFunc("/api/bots/{id:[0-9]+}/rotate", s.authMiddleware(s.rotateBotToken)).Methods("POST")
+	router.HandleFunc("/api/bots/{id:[0-9]+}", s.authMiddleware(s.deleteBot)).Methods("DELETE")
+	router.HandleFunc("/api/users/me", s.authMiddleware(s.getMe)).Methods("GET")
+	router.HandleFunc("/api/users/me", s.authMiddleware(s.updateMe)).Methods("PUT")
+	router.HandleFunc("/api/users/me", s.authMiddleware(s.deleteMe)).Methods("DELETE")
+	router.HandleFunc("/api/users/me/mentions", s.authMiddleware(s.getMentions)).Methods("GET")
+	router.HandleFunc("/api/users/me/starred", s.authMiddleware(s.getStarredMessages)).Methods("GET")
+	router.HandleFunc("/api/users/me/blocks", s.authMiddleware(s.getBlocks)).Methods("GET")
+	router.HandleFunc("/api/users/me/notifications", s.authMiddleware(s.getNotifications)).Methods("GET")
+	router.HandleFunc("/api/users/me/badge", s.authMiddleware(s.getBadge)).Methods("GET")
+	router.HandleFunc("/api/users/me/scheduled", s.authMiddleware(s.getScheduledMessages)).Methods("GET")
+	router.HandleFunc("/api/users/me/password", s.authMiddleware(s.changePassword)).Methods("POST")
+	router.HandleFunc("/api/users/search", s.authMiddleware(s.searchUsers)).Methods("GET")
+	router.HandleFunc("/api/users/me/avatar", s.authMiddleware(s.uploadAvatar)).Methods("PUT")
+	router.HandleFunc("/api/users/me/export", s.authMiddleware(s.exportUserData)).Methods("GET")
+	router.HandleFunc("/api/users/me/devices", s.authMiddleware(s.registerDevice)).Methods("POST")
+	router.HandleFunc("/api/users/me/devices/{id:[0-9]+}", s.authMiddleware(s.deleteDevice)).Methods("DELETE")
+	router.HandleFunc("/api/users/{id:[0-9]+}", s.getUser).Methods("GET")
+	router.HandleFunc("/api/users/{id:[0-9]+}/avatar", s.getAvatar).Methods("GET")
+	router.HandleFunc("/api/users/{id:[0-9]+}/block", s.authMiddleware(s.blockUser)).Methods("POST")
+	router.HandleFunc("/api/users/{id:[0-9]+}/block", s.authMiddleware(s.unblockUser)).Methods("DELETE")
+	router.HandleFunc("/api/messages", s.authMiddleware(s.createMessage)).Methods("POST")
+	router.HandleFunc("/api/conversations", s.authMiddleware(s.createConversation)).Methods("POST")
+	router.HandleFunc("/api/conversations", s.authMiddleware(s.listConversations)).Methods("GET")
+	router.HandleFunc("/api/conversations/{id}/messages", s.authMiddleware(s.getConversationMessages)).Methods("GET")
+	router.HandleFunc("/api/conversations/{id}/messages", s.authMiddleware(s.createConversationMessage)).Methods("POST")
+	router.HandleFunc("/api/conversations/{id}/read", s.authMiddleware(s.markConversationRead)).Methods("POST")
+	router.HandleFunc("/api/messages/{id}/reactions", s.authMiddleware(s.addReaction)).Methods("POST")
+	router.HandleFunc("/api/messages/{id}/reactions", s.authMiddleware(s.removeReaction)).Methods("DELETE")
+	router.HandleFunc("/api/messages/{id}/reactions/{emoji}", s.authMiddleware(s.removeReaction)).Methods("DELETE")
+	router.HandleFunc("/api/messages/{id}/schedule", s.authMiddleware(s.cancelScheduledMessage)).Methods("DELETE")
+	router.HandleFunc("/api/messages/{id}/pin", s.authMiddleware(s.pinMessage)).Methods("POST")
+	router.HandleFunc("/api/messages/{id}/pin", s.authMiddleware(s.unpinMessage)).Methods("DELETE")
+	router.HandleFunc("/api/messages/{id}/star", s.authMiddleware(s.starMessage)).Methods("POST")
+	router.HandleFunc("/api/messages/{id}/star", s.authMiddleware(s.unstarMessage)).Methods("DELETE")
+	router.HandleFunc("/api/uploads", s.authMiddleware(s.uploadFile)).Methods("POST")
+	router.HandleFunc("/api/files/{id:[0-9]+}", s.optionalAuthMiddleware(s.getFile)).Methods("GET")
+	router.HandleFunc("/api/files/{id:[0-9]+}/thumbnail", s.optionalAuthMiddleware(s.getThumbnail)).Methods("GET")
+	router.HandleFunc("/api/notifications", s.authMiddleware(s.getNotifications)).Methods("GET")
+	router.HandleFunc("/api/notifications/read-all", s.authMiddleware(s.markAllNotificationsRead)).Methods("POST")
+	router.HandleFunc("/api/notifications/mark-all-read", s.authMiddleware(s.markAllNotificationsRead)).Methods("POST")
+	router.HandleFunc("/api/notifications/mark-read", s.authMiddleware(s.markNotificationsRead)).Methods("POST")
+	router.HandleFunc("/api/notifications/{id}/read", s.authMiddleware(s.markNotificationRead)).Methods("POST")
+	router.HandleFunc("/api/admin/broadcast", s.adminMiddleware(s.adminBroadcast)).Methods("POST")
+	router.HandleFunc("/api/admin/users", s.adminMiddleware(s.adminListUsers)).Methods("GET")
+	router.HandleFunc("/api/admin/connections", s.adminMiddleware(s.adminListConnections)).Methods("GET")
+	router.HandleFunc("/api/admin/users/{id:[0-9]+}/disable", s.adminMiddleware(s.adminDisableUser)).Methods("POST")
+	router.HandleFunc("/api/admin/users/{id:[0-9]+}/enable", s.adminMiddleware(s.adminEnableUser)).Methods("POST")
+	router.HandleFunc("/api/admin/users/{id:[0-9]+}", s.adminMiddleware(s.adminDeleteUser)).Methods("DELETE")
+	router.HandleFunc("/ws", s.handleWebSocket)
+
+	router.Use(traceRouteMiddleware, metricsMiddleware, s.bodyLimitMiddleware)
+	return router
+}
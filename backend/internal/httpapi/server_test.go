@@ -0,0 +1,200 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/config"
+	"chatapp/internal/store"
+	"chatapp/internal/store/memory"
+	"chatapp/internal/ws"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword 测试用户的密码
+const testPassword = "password123"
+
+// testServer 使用内存 store 的 Server，请求经过完整的 Handler（路由、认证和中间件）
+type testServer struct {
+	*Server
+	t       testing.TB
+	store   *memory.Store
+	handler http.Handler
+}
+
+// newTestServer 创建使用内存 store 的 Server。bcrypt 使用最低成本，发送消息不限速，
+// configure 在创建 Server 之前修改配置。hub 在测试结束时停止
+func newTestServer(t testing.TB, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	cfg.MessageRateLimit = 0
+	for _, f := range configure {
+		f(&cfg)
+	}
+
+	mem := memory.New()
+	hub := ws.NewHub(cfg.WS.BroadcastBuffer, cfg.WS.SendBuffer, cfg.WS.MaxConnsPerUser)
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.HandleMessages(ctx)
+	t.Cleanup(cancel)
+
+	srv, err := NewServer(nil, Stores{
+		Users:         mem,
+		Resets:        mem,
+		Rooms:         mem,
+		Members:       mem,
+		Messages:      mem,
+		Reactions:     mem,
+		Reads:         mem,
+		Moderation:    mem,
+		Admin:         mem,
+		Pins:          mem,
+		Notifications: mem,
+		Stats:         mem,
+		Blocks:        mem,
+		Conversations: mem,
+		Polls:         mem,
+		Bots:          mem,
+	}, hub, auth.HS256Keys([]byte("test-secret")), cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return &testServer{Server: srv, t: t, store: mem, handler: srv.Handler()}
+}
+
+// addUser 创建密码为 testPassword 的用户，返回用户和它的 token
+func (ts *testServer) addUser(username string) (store.User, string) {
+	ts.t.Helper()
+	hash, err := ts.hashPassword(testPassword)
+	if err != nil {
+		ts.t.Fatalf("hash password: %v", err)
+	}
+	user, err := ts.store.CreateUser(context.Background(), username, username+"@example.com", hash)
+	if err != nil {
+		ts.t.Fatalf("create user %s: %v", username, err)
+	}
+	token, err := ts.auth.Issue(user)
+	if err != nil {
+		ts.t.Fatalf("issue token: %v", err)
+	}
+	return user, token
+}
+
+// do 发送请求并返回响应。body 为 string 时原样发送，不为 nil 时编码为 JSON；token 为空时不带 Authorization 头
+func (ts *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.serve(ts.request(method, path, token, body))
+}
+
+// request 创建 do 发送的请求，用于需要额外设置请求头的测试
+func (ts *testServer) request(method, path, token string, body interface{}) *http.Request {
+	ts.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			ts.t.Fatalf("encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// serve 通过完整的 Handler 处理请求
+func (ts *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ts.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeResponse 检查状态码并把 JSON 响应解码到 v，v 为 nil 时只检查状态码
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, want int, v interface{}) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d, body: %s", rec.Code, want, rec.Body.String())
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// eventRecorder 作为 hub 的 Subscriber 记录收到的事件
+type eventRecorder struct {
+	events chan ws.Event
+}
+
+func (e *eventRecorder) Send(event ws.Event) error {
+	e.events <- event
+	return nil
+}
+
+func (e *eventRecorder) Close(code int, reason string) {}
+
+// testEventDone 标记 hub 已处理完之前发布的所有事件
+const testEventDone = "test.done"
+
+// subscribe 以 userID 的身份订阅聊天室的实时事件
+func (ts *testServer) subscribe(userID, roomID int) *eventRecorder {
+	rec := &eventRecorder{events: make(chan ws.Event, 1000)}
+	ts.hub.Register(ws.NewClient(ts.hub, rec, ws.ClientOptions{
+		RoomID: roomID,
+		UserID: userID,
+		Rooms:  map[int]bool{roomID: true},
+	}))
+	return rec
+}
+
+// drain 发布一个标记事件，返回它之前收到的所有事件。hub 按发布顺序投递，
+// 标记到达时之前发布的事件都已经收到
+func (e *eventRecorder) drain(t *testing.T, ts *testServer, roomID int) []ws.Event {
+	t.Helper()
+	ts.hub.Publish(ws.Event{Type: testEventDone, RoomID: roomID})
+	var events []ws.Event
+	for {
+		select {
+		case event := <-e.events:
+			if event.Type == testEventDone {
+				return events
+			}
+			events = append(events, event)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for hub events")
+			return nil
+		}
+	}
+}
+
+// countEvents 返回指定类型的事件数
+func countEvents(events []ws.Event, eventType string) int {
+	n := 0
+	for _, e := range events {
+		if e.Type == eventType {
+			n++
+		}
+	}
+	return n
+}
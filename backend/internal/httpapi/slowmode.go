@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+const (
+	// 慢速模式的最长间隔为 6 小时
+	maxSlowModeSeconds      = 6 * 60 * 60
+	slowModeCleanupInterval = time.Minute
+)
+
+type slowModeKey struct {
+	userID int
+	roomID int
+}
+
+// slowModeTracker 在内存中记录每个用户在每个聊天室下一次可以发言的时间。
+// 多实例部署时每个实例单独计算，用户最多可以在每个实例上各发一条。
+type slowModeTracker struct {
+	mu   sync.Mutex
+	next map[slowModeKey]time.Time
+	now  func() time.Time
+}
+
+func newSlowModeTracker() *slowModeTracker {
+	return &slowModeTracker{next: make(map[slowModeKey]time.Time), now: time.Now}
+}
+
+// allow 冷却结束时记录本次发言并返回 true，否则返回还需等待的时间
+func (t *slowModeTracker) allow(userID, roomID int, cooldown time.Duration) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := slowModeKey{userID: userID, roomID: roomID}
+	now := t.now()
+	if next, ok := t.next[key]; ok && now.Before(next) {
+		return false, next.Sub(now)
+	}
+	t.next[key] = now.Add(cooldown)
+	return true, 0
+}
+
+// cleanup 删除冷却已经结束的记录
+func (t *slowModeTracker) cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for key, next := range t.next {
+		if !now.Before(next) {
+			delete(t.next, key)
+		}
+	}
+}
+
+// runSlowModeCleanup 定期清理过期记录，ctx 取消后退出
+func (s *Server) runSlowModeCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(slowModeCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.slowMode.cleanup()
+			}
+		}
+	}()
+}
+
+// checkSlowMode 开启慢速模式的聊天室中，普通成员两次发言之间必须间隔 SlowModeSeconds 秒，owner 和 moderator 不受限制
+func (s *Server) checkSlowMode(ctx context.Context, sender *Claims, req *CreateMessageRequest) error {
+	room := req.room
+	if room == nil || room.SlowModeSeconds == 0 {
+		return nil
+	}
+	if room.CreatedBy != nil && *room.CreatedBy == sender.UserID {
+		return nil
+	}
+	role, err := s.members.GetMemberRole(ctx, req.RoomID, sender.UserID)
+	if err != nil {
+		return err
+	}
+	if role == store.RoleOwner || role == store.RoleModerator {
+		return nil
+	}
+
+	ok, wait := s.slowMode.allow(sender.UserID, req.RoomID, time.Duration(room.SlowModeSeconds)*time.Second)
+	if !ok {
+		return &APIError{
+			Status:       http.StatusTooManyRequests,
+			Message:      fmt.Sprintf("Slow mode is enabled, wait %.0f seconds before sending another message", wait.Seconds()+0.5),
+			RetryAfterMs: wait.Milliseconds(),
+		}
+	}
+	return nil
+}
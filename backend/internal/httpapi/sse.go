@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// 定期发送注释行，防止代理因为连接空闲而断开
+	sseKeepAliveInterval = 25 * time.Second
+	// 连接断开后浏览器等待多久重连
+	sseRetryInterval = 3 * time.Second
+)
+
+// handleRoomEvents GET /api/rooms/{id}/events，为无法使用 WebSocket 的环境提供单个聊天室的 SSE 事件流。
+// EventSource 无法设置 Authorization 头，因此也接受 ?token= 参数。发送消息仍然使用 POST /api/messages。
+func (s *Server) handleRoomEvents(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid room ID"))
+		return
+	}
+
+	claims := currentUser(r)
+	if token := r.URL.Query().Get("token"); claims == nil && token != "" {
+		if claims, err = s.auth.Authenticate(r.Context(), token); err != nil {
+			writeError(w, r, authError(err))
+			return
+		}
+	}
+	if claims == nil {
+		writeError(w, r, apiError(http.StatusUnauthorized, "Authentication required"))
+		return
+	}
+
+	// Last-Event-ID 为最后收到的事件 id，其中带有最后收到的消息 ID
+	cursors := make(map[int]int)
+	lastID := 0
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if lastID, err = ws.ParseSSEEventID(v); err != nil {
+			writeError(w, r, &APIError{Status: http.StatusBadRequest, Message: "Last-Event-ID must be an event ID from this stream", Field: "Last-Event-ID"})
+			return
+		}
+		cursors[roomID] = lastID
+	}
+
+	if err := s.requireMember(r.Context(), roomID, claims.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// 新的事件流只推送之后的事件，id 从当前最新的消息开始，重连时不会补发连接之前的历史
+	if len(cursors) == 0 {
+		if lastID, err = s.messages.LatestRoomMessageID(r.Context(), roomID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	blocked := make(map[int]bool)
+	if s.blocks != nil {
+		ids, err := s.blocks.ListBlockedUserIDs(r.Context(), claims.UserID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, id := range ids {
+			blocked[id] = true
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, apiError(http.StatusInternalServerError, "Streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// 禁止 nginx 缓冲事件流
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryInterval.Milliseconds())
+	flusher.Flush()
+
+	sub := ws.NewSSESubscriber(w, flusher, lastID)
+	client := ws.NewClient(s.hub, sub, ws.ClientOptions{
+		RoomID:     roomID,
+		UserID:     claims.UserID,
+		Username:   claims.Username,
+		Rooms:      map[int]bool{roomID: true},
+		Blocked:    blocked,
+		CatchingUp: len(cursors) > 0,
+	})
+	logger := loggerFromContext(r.Context()).With("user_id", client.UserID, "username", client.Username, "room_id", roomID)
+
+	count := s.hub.Register(client)
+	defer func() {
+		count := s.hub.Unregister(client)
+		// handler 返回之后不能再写入 ResponseWriter
+		sub.Close(0, "")
+		logger.Info("event stream disconnected", "connections", count)
+	}()
+	logger.Info("event stream connected", "connections", count)
+
+	if len(cursors) > 0 {
+		s.catchUp(r.Context(), client, cursors)
+	}
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Closed():
+			return
+		case <-ticker.C:
+			if err := sub.KeepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chatapp/internal/ws"
+)
+
+// sseFrame 事件流中的一个事件
+type sseFrame struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// sseStream 在后台解析事件流，注释行和 retry 行被忽略
+type sseStream struct {
+	frames chan sseFrame
+	cancel context.CancelFunc
+}
+
+// openSSE 打开聊天室的事件流，lastEventID 不为空时作为 Last-Event-ID 发送。测试结束时断开
+func openSSE(t *testing.T, srv *httptest.Server, roomID int, token, lastEventID string) *sseStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/rooms/%d/events?token=%s", srv.URL, roomID, token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	s := &sseStream{frames: make(chan sseFrame, 100), cancel: cancel}
+	t.Cleanup(cancel)
+	go func() {
+		defer resp.Body.Close()
+		defer close(s.frames)
+		var frame sseFrame
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				frame.ID = value
+			case "event":
+				frame.Event = value
+			case "data":
+				frame.Data = value
+			case "":
+				if frame.Event != "" {
+					s.frames <- frame
+				}
+				frame = sseFrame{}
+			}
+		}
+	}()
+	return s
+}
+
+// next 返回下一个类型为 eventType 的事件
+func (s *sseStream) next(t *testing.T, eventType string) sseFrame {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case frame, ok := <-s.frames:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if frame.Event == eventType {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", eventType)
+		}
+	}
+}
+
+// sseMessageID 取出 message 事件中的消息 ID
+func sseMessageID(t *testing.T, frame sseFrame) int {
+	t.Helper()
+	var event struct {
+		Data Message `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(frame.Data), &event); err != nil {
+		t.Fatalf("decode %q: %v", frame.Data, err)
+	}
+	return event.Data.ID
+}
+
+// TestSSEResume 浏览器带着最后收到的事件 id 重连时补发断线期间的消息，之后继续实时推送
+func TestSSEResume(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	srv := httptest.NewServer(ts.handler)
+	// 在事件流断开之后关闭，Close 会等待进行中的请求结束
+	t.Cleanup(srv.Close)
+	// 连接之前的消息不会补发
+	postMessage(t, ts, token, room.ID, "history")
+
+	stream := openSSE(t, srv, room.ID, token, "")
+	waitForConnections(t, ts, 1)
+	seen := postMessage(t, ts, token, room.ID, "seen")
+	frame := stream.next(t, ws.EventMessage)
+	if id := sseMessageID(t, frame); id != seen || frame.ID != fmt.Sprintf("1-%d", seen) {
+		t.Fatalf("received message %d with id %q, want %d with id 1-%d", id, frame.ID, seen, seen)
+	}
+	stream.cancel()
+	waitForConnections(t, ts, 0)
+
+	missed := []int{postMessage(t, ts, token, room.ID, "missed 1"), postMessage(t, ts, token, room.ID, "missed 2")}
+	stream = openSSE(t, srv, room.ID, token, frame.ID)
+	for i, want := range missed {
+		frame = stream.next(t, ws.EventMessage)
+		if id := sseMessageID(t, frame); id != want || frame.ID != fmt.Sprintf("%d-%d", i+1, want) {
+			t.Fatalf("caught up message %d with id %q, want %d", id, frame.ID, want)
+		}
+	}
+	resumed := stream.next(t, ws.EventResumed)
+	if resumed.ID != fmt.Sprintf("3-%d", missed[1]) {
+		t.Fatalf("resumed id = %q, want 3-%d", resumed.ID, missed[1])
+	}
+
+	live := postMessage(t, ts, token, room.ID, "live")
+	if id := sseMessageID(t, stream.next(t, ws.EventMessage)); id != live {
+		t.Fatalf("live message %d, want %d", id, live)
+	}
+}
+
+// TestSSEResumeFreshStream 没有收到任何消息的事件流重连时不补发连接之前的历史
+func TestSSEResumeFreshStream(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	srv := httptest.NewServer(ts.handler)
+	t.Cleanup(srv.Close)
+	history := postMessage(t, ts, token, room.ID, "history")
+
+	stream := openSSE(t, srv, room.ID, token, "")
+	waitForConnections(t, ts, 1)
+	ts.hub.Publish(ws.Event{Type: ws.EventRoomUpdated, RoomID: room.ID, Data: room})
+	frame := stream.next(t, ws.EventRoomUpdated)
+	if frame.ID != fmt.Sprintf("1-%d", history) {
+		t.Fatalf("id = %q, want 1-%d", frame.ID, history)
+	}
+	stream.cancel()
+	waitForConnections(t, ts, 0)
+
+	stream = openSSE(t, srv, room.ID, token, frame.ID)
+	resumed := stream.next(t, ws.EventResumed)
+	var event struct {
+		Data ResumedEvent `json:"data"`
+	}
+	json.Unmarshal([]byte(resumed.Data), &event)
+	if event.Data.Delivered[room.ID] != 0 {
+		t.Fatalf("resumed = %s, want nothing caught up", resumed.Data)
+	}
+
+	req := ts.request("GET", fmt.Sprintf("/api/rooms/%d/events", room.ID), token, nil)
+	req.Header.Set("Last-Event-ID", "x-1")
+	decodeResponse(t, ts.serve(req), http.StatusBadRequest, nil)
+}
+
+// syncRecorder 可以在 handler 写入的同时读取的 httptest.ResponseRecorder
+type syncRecorder struct {
+	mu sync.Mutex
+	*httptest.ResponseRecorder
+}
+
+func (r *syncRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Write(b)
+}
+
+func (r *syncRecorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ResponseRecorder.Flush()
+}
+
+func (r *syncRecorder) body() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Body.String()
+}
+
+// TestSSEFrames 事件流以 retry 行开头，每条新消息是一个以空行结尾的 id/event/data 帧，
+// data 为一行 JSON；客户端断开后 handler 返回并移除连接
+func TestSSEFrames(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	rec := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := ts.request("GET", fmt.Sprintf("/api/rooms/%d/events?token=%s", room.ID, token), "", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ts.handler.ServeHTTP(rec, req)
+	}()
+	waitForConnections(t, ts, 1)
+
+	id := postMessage(t, ts, token, room.ID, "hello")
+	want := fmt.Sprintf("id: 1-%d\nevent: %s\ndata: ", id, ws.EventMessage)
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(rec.body(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("body %q has no message frame", rec.body())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the client disconnected")
+	}
+	waitForConnections(t, ts, 0)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("status %d, headers %v", rec.Code, rec.Header())
+	}
+	body := rec.body()
+	if !strings.HasPrefix(body, fmt.Sprintf("retry: %d\n\n", sseRetryInterval.Milliseconds())) {
+		t.Fatalf("body %q does not start with the retry interval", body)
+	}
+	_, frame, _ := strings.Cut(body, want)
+	data, rest, ok := strings.Cut(frame, "\n\n")
+	if !ok || strings.Contains(data, "\n") {
+		t.Fatalf("message frame %q is not a single data line followed by a blank line", frame)
+	}
+	var event struct {
+		Type   string  `json:"type"`
+		RoomID int     `json:"room_id"`
+		Data   Message `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decode %q: %v", data, err)
+	}
+	if event.Type != ws.EventMessage || event.RoomID != room.ID || event.Data.ID != id || event.Data.Content != "hello" {
+		t.Fatalf("event = %+v, want message %d", event, id)
+	}
+	if rest != "" {
+		t.Fatalf("unexpected data after the message frame: %q", rest)
+	}
+}
+
+// waitForConnections 等待 hub 中的连接数变为 n
+func waitForConnections(t *testing.T, ts *testServer, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if ts.hub.ConnectionCount() == n {
+			return
+		}
+	}
+	t.Fatalf("%d connections, want %d", ts.hub.ConnectionCount(), n)
+}
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"chatapp/internal/store"
+)
+
+const (
+	defaultStarredPageSize = 20
+	maxStarredPageSize     = 100
+)
+
+type StarredMessage = store.StarredMessage
+
+// starMessage 收藏消息，重复收藏没有副作用。看不到的消息（包括不是成员的聊天室中的消息）返回 404
+func (s *Server) starMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	user := currentUser(r)
+
+	_, _, err = s.visibleMessage(r.Context(), messageID, user.UserID)
+	if errors.Is(err, errNotMember) || errors.Is(err, errBanned) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.stars.StarMessage(r.Context(), messageID, user.UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unstarMessage 取消收藏，只删除当前用户自己的记录，离开聊天室后同样可以取消
+func (s *Server) unstarMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := messageIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := s.stars.UnstarMessage(r.Context(), messageID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getStarredMessages 按收藏时间倒序返回当前用户收藏的消息，附带聊天室名称
+func (s *Server) getStarredMessages(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, defaultStarredPageSize, maxStarredPageSize)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	messages, err := s.stars.ListStarredMessages(r.Context(), currentUser(r).UserID, limit, offset)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// 统计结果缓存 5 分钟，避免重复执行开销较大的聚合查询
+const roomStatsCacheTTL = 5 * time.Minute
+
+type cachedRoomStats struct {
+	stats     store.RoomStats
+	expiresAt time.Time
+}
+
+// roomStatsCache 按聊天室 ID 缓存 cachedRoomStats
+type roomStatsCache struct {
+	entries sync.Map
+}
+
+func (c *roomStatsCache) get(roomID int) (store.RoomStats, bool) {
+	v, ok := c.entries.Load(roomID)
+	if !ok {
+		return store.RoomStats{}, false
+	}
+	cached := v.(cachedRoomStats)
+	if time.Now().After(cached.expiresAt) {
+		c.entries.Delete(roomID)
+		return store.RoomStats{}, false
+	}
+	return cached.stats, true
+}
+
+func (c *roomStatsCache) put(roomID int, stats store.RoomStats) {
+	c.entries.Store(roomID, cachedRoomStats{stats: stats, expiresAt: time.Now().Add(roomStatsCacheTTL)})
+}
+
+// getRoomStats 聊天室 owner 和 moderator 可以查看活跃度统计
+func (s *Server) getRoomStats(w http.ResponseWriter, r *http.Request) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if _, err := s.requireModerator(r.Context(), roomID, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stats, ok := s.roomStats.get(roomID)
+	if !ok {
+		stats, err = s.stats.RoomStats(r.Context(), roomID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		s.roomStats.put(roomID, stats)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"chatapp/internal/store"
+)
+
+// TestRoomStats 只有 owner 和 moderator 可以查看统计，结果在缓存期内不变
+func TestRoomStats(t *testing.T) {
+	ts := newTestServer(t)
+	room, _, tokens := moderationRoom(t, ts)
+	path := fmt.Sprintf("/api/rooms/%d/stats", room.ID)
+	for i := 0; i < 3; i++ {
+		postMessage(t, ts, tokens["member1"], room.ID, "hello")
+	}
+	postMessage(t, ts, tokens["mod1"], room.ID, "hi")
+
+	for _, name := range []string{"member1", "outsider"} {
+		decodeResponse(t, ts.do("GET", path, tokens[name], nil), http.StatusForbidden, nil)
+	}
+
+	var stats store.RoomStats
+	decodeResponse(t, ts.do("GET", path, tokens["mod1"], nil), http.StatusOK, &stats)
+	if stats.MessageCount != 4 || stats.UniqueUsers != 2 || stats.MessagesLast24h != 4 || stats.MessagesLast7d != 4 {
+		t.Fatalf("stats = %+v, want 4 messages from 2 users", stats)
+	}
+	total := 0
+	for _, n := range stats.HourlyMessages {
+		total += n
+	}
+	if len(stats.HourlyMessages) != 24 || total != 4 {
+		t.Fatalf("hourly messages = %v, want 4 in 24 buckets", stats.HourlyMessages)
+	}
+	if len(stats.TopUsers) != 2 || stats.TopUsers[0].Username != "member1" || stats.TopUsers[0].MessageCount != 3 {
+		t.Fatalf("top users = %+v", stats.TopUsers)
+	}
+
+	postMessage(t, ts, tokens["member1"], room.ID, "after")
+	var cached store.RoomStats
+	decodeResponse(t, ts.do("GET", path, tokens["owner"], nil), http.StatusOK, &cached)
+	if cached.MessageCount != 4 || !cached.GeneratedAt.Equal(stats.GeneratedAt) {
+		t.Fatalf("second request = %+v, want the cached result", cached)
+	}
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+)
+
+// 系统消息 metadata 中的 event
+const (
+	systemEventMemberJoined  = "member_joined"
+	systemEventMemberLeft    = "member_left"
+	systemEventMemberKicked  = "member_kicked"
+	systemEventMemberBanned  = "member_banned"
+	systemEventRoomRenamed   = "room_renamed"
+	systemEventMessagePinned = "message_pinned"
+)
+
+var errSystemMessage = apiError(http.StatusBadRequest, "System messages cannot be reacted to")
+
+// SystemMessageMetadata 系统消息的结构化数据，客户端按 Event 渲染，不依赖 Content 的文字
+type SystemMessageMetadata struct {
+	Event    string `json:"event"`
+	ActorID  int    `json:"actor_id,omitempty"`
+	Actor    string `json:"actor,omitempty"`
+	TargetID int    `json:"target_id,omitempty"`
+	Target   string `json:"target,omitempty"`
+	// MessageID 置顶的消息
+	MessageID int `json:"message_id,omitempty"`
+	// OldName、NewName 聊天室改名前后的名称
+	OldName string `json:"old_name,omitempty"`
+	NewName string `json:"new_name,omitempty"`
+}
+
+// systemMessageContent 不支持富文本的客户端直接显示的文字
+func systemMessageContent(meta SystemMessageMetadata) string {
+	switch meta.Event {
+	case systemEventMemberJoined:
+		return fmt.Sprintf("%s joined", meta.Actor)
+	case systemEventMemberLeft:
+		return fmt.Sprintf("%s left", meta.Actor)
+	case systemEventMemberKicked:
+		return fmt.Sprintf("%s was removed by %s", meta.Target, meta.Actor)
+	case systemEventMemberBanned:
+		return fmt.Sprintf("%s was banned by %s", meta.Target, meta.Actor)
+	case systemEventRoomRenamed:
+		return fmt.Sprintf("%s renamed the room to %q", meta.Actor, meta.NewName)
+	case systemEventMessagePinned:
+		return fmt.Sprintf("message pinned by %s", meta.Actor)
+	}
+	return meta.Event
+}
+
+// postSystemMessage 保存一条没有发送者的系统消息并广播给聊天室。
+// 系统消息是附带的记录，失败只记录日志，不影响触发它的操作
+func (s *Server) postSystemMessage(ctx context.Context, roomID int, meta SystemMessageMetadata) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		loggerFromContext(ctx).Error("failed to encode system message", "room_id", roomID, "event", meta.Event, "error", err)
+		return
+	}
+	msg := Message{
+		RoomID:      roomID,
+		Username:    commandSender,
+		DisplayName: commandSender,
+		Content:     systemMessageContent(meta),
+		MessageType: store.MessageTypeSystem,
+		Metadata:    data,
+	}
+	if err := s.messages.InsertMessage(ctx, &msg); err != nil {
+		loggerFromContext(ctx).Error("failed to save system message", "room_id", roomID, "event", meta.Event, "error", err)
+		return
+	}
+	s.hub.Publish(ws.Event{Type: ws.EventMessage, RoomID: roomID, Data: msg})
+}
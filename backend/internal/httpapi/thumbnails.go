@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	// 缩略图长边的最大像素
+	maxThumbnailSize = 400
+	// 超过这个像素数的图片直接拒绝，防止解压炸弹占满内存
+	maxImagePixels = 40_000_000
+
+	thumbnailQueueSize = 64
+	thumbnailWorkers   = 2
+)
+
+// thumbnailTypes 需要生成缩略图的图片类型。
+// 标准库没有 WebP 解码器，WebP 图片会被跳过，客户端直接使用原图。
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+var errImageTooLarge = &APIError{Status: http.StatusUnprocessableEntity, Message: "Image dimensions are too large", Field: "file"}
+
+// imageDimensions 只读取图片头部获得尺寸，无法识别的格式返回 0
+func imageDimensions(f io.ReadSeeker) (width, height int, err error) {
+	cfg, _, decodeErr := image.DecodeConfig(f)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	if decodeErr != nil {
+		return 0, 0, nil
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return 0, 0, errImageTooLarge
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// enqueueThumbnail 把图片加入缩略图队列，队列已满时跳过
+func (s *Server) enqueueThumbnail(ctx context.Context, att Attachment) {
+	if !thumbnailTypes[att.ContentType] || att.Width == 0 {
+		return
+	}
+	select {
+	case s.thumbnails <- att:
+	default:
+		loggerFromContext(ctx).Warn("thumbnail queue is full, skipping", "attachment_id", att.ID)
+	}
+}
+
+// runThumbnailWorkers 启动后台生成缩略图的 worker，ctx 取消后退出
+func (s *Server) runThumbnailWorkers(ctx context.Context) {
+	for i := 0; i < thumbnailWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case att := <-s.thumbnails:
+					s.processThumbnail(ctx, att)
+				}
+			}
+		}()
+	}
+}
+
+// processThumbnail 图片解码器处理的是用户上传的数据，panic 只影响当前图片，worker 继续运行
+func (s *Server) processThumbnail(ctx context.Context, att Attachment) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(loggerFromContext(ctx), "thumbnail generation panic", p)
+		}
+	}()
+	if err := s.generateThumbnail(ctx, att); err != nil {
+		loggerFromContext(ctx).Warn("failed to generate thumbnail", "attachment_id", att.ID, "error", err)
+	}
+}
+
+func (s *Server) generateThumbnail(ctx context.Context, att Attachment) error {
+	body, err := s.Files.Open(ctx, att.StorageKey)
+	if err != nil {
+		return err
+	}
+	src, format, err := image.Decode(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	if b := src.Bounds(); b.Dx()*b.Dy() > maxImagePixels {
+		return errors.New("image is too large")
+	}
+
+	thumb := resizeImage(src, maxThumbnailSize)
+
+	// PNG 保留透明通道，其余格式输出 JPEG
+	var buf bytes.Buffer
+	contentType := "image/jpeg"
+	if format == "png" {
+		contentType = "image/png"
+		err = png.Encode(&buf, thumb)
+	} else {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		return err
+	}
+
+	key := att.StorageKey + "_thumb"
+	if err := s.Files.Save(ctx, key, &buf, int64(buf.Len()), contentType); err != nil {
+		return err
+	}
+	return s.attachments.SetAttachmentThumbnail(ctx, att.ID, key, contentType)
+}
+
+// resizeImage 按比例缩小到长边不超过 maxSize，每个目标像素取对应区域的平均值
+func resizeImage(src image.Image, maxSize int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return src
+	}
+
+	tw, th := maxSize, h*maxSize/w
+	if h > w {
+		tw, th = w*maxSize/h, maxSize
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy0, sy1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			sx0, sx1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"chatapp/internal/store/postgres"
+)
+
+// TestQueryTimeoutReturns503 数据库无响应时，查询在超时后返回，接口返回 503
+func TestQueryTimeoutReturns503(t *testing.T) {
+	ts := newTestServer(t)
+	pg := postgres.New(newFakeDB(t, &fakeDB{block: true}))
+	pg.QueryTimeout = 50 * time.Millisecond
+	ts.rooms = pg
+
+	start := time.Now()
+	var apiErr APIError
+	decodeResponse(t, ts.do("GET", "/api/rooms/1", "", nil), http.StatusServiceUnavailable, &apiErr)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("handler returned after %v", elapsed)
+	}
+	if apiErr.Code != "service_unavailable" {
+		t.Fatalf("code = %q, want service_unavailable", apiErr.Code)
+	}
+}
+
+// TestClientDisconnectCancelsQuery 客户端断开后正在执行的查询立即取消
+func TestClientDisconnectCancelsQuery(t *testing.T) {
+	ts := newTestServer(t)
+	pg := postgres.New(newFakeDB(t, &fakeDB{block: true}))
+	pg.QueryTimeout = time.Minute
+	ts.rooms = pg
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := ts.request("GET", "/api/rooms/1", "", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		ts.serve(req)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler kept waiting for the database after the client disconnected")
+	}
+}
@@ -0,0 +1,27 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer 创建应用内的 span。main 设置 TracerProvider 之前使用全局的 no-op 实现，之后自动切换到配置的 provider
+var tracer = otel.Tracer("chatapp")
+
+// traceRouteMiddleware 用路由模板命名 HTTP span，避免路径中的 ID 产生大量不同的 span 名称
+func traceRouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + tpl)
+				span.SetAttributes(semconv.HTTPRoute(tpl))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans 安装记录 span 的全局 TracerProvider。包级的 tracer 只会绑定第一次设置的 provider，
+// 所以整个测试进程共用一个 recorder，测试按 span 名称和属性查找自己的 span
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+	return spanRecorder
+}
+
+// endedSpans 返回名称为 name 的已结束 span
+func endedSpans(r *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range r.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// TestLoginSpan 登录请求导出以路由模板命名的 HTTP span
+func TestLoginSpan(t *testing.T) {
+	recorder := recordSpans()
+	ts := newTestServer(t)
+	user, _ := ts.addUser("alice")
+	before := len(endedSpans(recorder, "POST /api/auth/login"))
+
+	decodeResponse(t, ts.do("POST", "/api/auth/login", "", LoginRequest{Email: user.Email, Password: testPassword}), http.StatusOK, nil)
+
+	spans := endedSpans(recorder, "POST /api/auth/login")
+	if len(spans) != before+1 {
+		t.Fatalf("%d login spans exported, want %d", len(spans), before+1)
+	}
+	span := spans[len(spans)-1]
+	if route, ok := spanAttribute(span, "http.route"); !ok || route.AsString() != "/api/auth/login" {
+		t.Fatalf("http.route = %v, want /api/auth/login", route)
+	}
+	if !span.SpanContext().IsValid() || span.Parent().IsValid() {
+		t.Fatalf("span context %v, parent %v, want a valid root span", span.SpanContext(), span.Parent())
+	}
+}
+
+// TestWebSocketMessageSpan 通过 WebSocket 发送的每条消息对应一个带聊天室和消息 ID 的 span
+func TestWebSocketMessageSpan(t *testing.T) {
+	recorder := recordSpans()
+	ts := newTestServer(t)
+	room, _, token, _ := messageRoom(t, ts)
+	conn := dialWebSocket(t, ts, token)
+
+	if err := conn.WriteJSON(CreateMessageRequest{RoomID: room.ID, Content: "traced", AckID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	ack := readUntil(t, conn, func(fr wsFrame) bool { return fr.AckID == "1" })
+
+	// ack 在 span 结束之前发出，等待 span 结束
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, span := range endedSpans(recorder, "websocket.message") {
+			roomID, _ := spanAttribute(span, "messaging.room_id")
+			messageID, _ := spanAttribute(span, "messaging.message_id")
+			if roomID.AsInt64() == int64(room.ID) && messageID.AsInt64() == int64(ack.MessageID) {
+				return
+			}
+		}
+	}
+	t.Fatalf("no websocket.message span for message %d in room %d", ack.MessageID, room.ID)
+}
@@ -0,0 +1,357 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"chatapp/internal/store"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	webhookQueueSize   = 256
+	webhookWorkers     = 4
+	webhookMaxAttempts = 3
+	// 第一次重试前等待 1 秒，之后每次翻倍
+	webhookRetryBackoff = time.Second
+	webhookTimeout      = 5 * time.Second
+	maxWebhookURLLength = 2000
+
+	// webhookSignatureHeader 请求体的 HMAC-SHA256 签名，格式为 sha256=<hex>。
+	// 与 GitHub 使用相同的请求头，旧的 X-Webhook-Signature 保留给已有的接收方
+	webhookSignatureHeader       = "X-Hub-Signature-256"
+	legacyWebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookEvents 可以订阅的事件类型，未指定 events 时只订阅新消息
+var webhookEvents = map[string]bool{
+	ws.EventMessage: true,
+}
+
+type Webhook = store.Webhook
+
+type CreateWebhookRequest struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+type UpdateWebhookRequest struct {
+	URL     *string  `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// WebhookPayload POST 到 webhook 的请求体
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	RoomID    int       `json:"room_id"`
+	Message   Message   `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// validateWebhookURL 只接受 http 和 https 的绝对地址
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(raw) > maxWebhookURLLength {
+		return &APIError{Status: http.StatusBadRequest, Message: "url must be an absolute http or https URL", Field: "url"}
+	}
+	return nil
+}
+
+// validateWebhookEvents 去掉重复项，不支持的事件类型返回 400
+func validateWebhookEvents(events []string) ([]string, error) {
+	seen := make(map[string]bool, len(events))
+	valid := make([]string, 0, len(events))
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Unsupported webhook event %q", event), Field: "events"}
+		}
+		if !seen[event] {
+			seen[event] = true
+			valid = append(valid, event)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: "events must not be empty", Field: "events"}
+	}
+	return valid, nil
+}
+
+func webhookIDFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["webhook_id"])
+	if err != nil {
+		return 0, apiError(http.StatusBadRequest, "Invalid webhook ID")
+	}
+	return id, nil
+}
+
+// webhookRoom 解析路径中的聊天室，只有 owner 可以管理 webhook
+func (s *Server) webhookRoom(r *http.Request) (int, error) {
+	roomID, err := roomIDFromRequest(r)
+	if err != nil {
+		return 0, err
+	}
+	return roomID, s.requireRoomOwner(r.Context(), roomID, currentUser(r).UserID)
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	webhooks, err := s.webhooks.ListWebhooks(r.Context(), roomID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// createWebhook 生成签名密钥并在响应中返回，之后无法再查看
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if err := validateWebhookURL(req.URL); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	events := []string{ws.EventMessage}
+	if req.Events != nil {
+		if events, err = validateWebhookEvents(req.Events); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	webhook := Webhook{RoomID: roomID, URL: req.URL, Secret: hex.EncodeToString(secret), Events: events, Enabled: true}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	if err := s.webhooks.CreateWebhook(r.Context(), &webhook, currentUser(r).UserID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (s *Server) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := webhookIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apiError(http.StatusBadRequest, "Invalid request body"))
+		return
+	}
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if req.Events != nil {
+		if req.Events, err = validateWebhookEvents(req.Events); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	webhook, err := s.webhooks.UpdateWebhook(r.Context(), roomID, id, store.WebhookUpdate{URL: req.URL, Events: req.Events, Enabled: req.Enabled})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	roomID, err := s.webhookRoom(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := webhookIDFromRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := s.webhooks.DeleteWebhook(r.Context(), roomID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookJob 队列中的消息，span 是发送消息的请求的 span，发送 webhook 的 span 作为它的子 span
+type webhookJob struct {
+	msg  Message
+	span trace.SpanContext
+}
+
+// enqueueWebhooks 把聊天室的新消息加入 webhook 队列，队列已满时丢弃，不阻塞广播
+func (s *Server) enqueueWebhooks(ctx context.Context, msg Message) {
+	if msg.ConversationID != nil || s.webhooks == nil {
+		return
+	}
+	select {
+	case s.webhookQueue <- webhookJob{msg: msg, span: trace.SpanContextFromContext(ctx)}:
+	default:
+		metrics.webhookDeliveries.WithLabelValues("dropped").Inc()
+		loggerFromContext(ctx).Warn("webhook queue is full, skipping", "message_id", msg.ID)
+	}
+}
+
+// runWebhookWorkers 启动发送 webhook 的 worker，ctx 取消后退出
+func (s *Server) runWebhookWorkers(ctx context.Context) {
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.webhookQueue:
+					s.dispatchWebhooks(trace.ContextWithSpanContext(ctx, job.span), job.msg)
+				}
+			}
+		}()
+	}
+}
+
+// dispatchWebhooks 把消息发送到聊天室所有启用的 webhook
+func (s *Server) dispatchWebhooks(ctx context.Context, msg Message) {
+	ctx, span := tracer.Start(ctx, "webhook.dispatch", trace.WithAttributes(
+		attribute.Int("messaging.room_id", msg.RoomID),
+		attribute.Int("messaging.message_id", msg.ID),
+	))
+	defer span.End()
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(slog.Default(), "webhook dispatch panic", p)
+		}
+	}()
+
+	webhooks, err := s.webhooks.ListEnabledWebhooks(ctx, msg.RoomID, ws.EventMessage)
+	if err != nil {
+		slog.Error("failed to load webhooks", "room_id", msg.RoomID, "error", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(WebhookPayload{Event: ws.EventMessage, RoomID: msg.RoomID, Message: msg, Timestamp: time.Now().UTC()})
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "message_id", msg.ID, "error", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		if err := s.deliverWebhook(ctx, webhook, body); err != nil {
+			metrics.webhookDeliveries.WithLabelValues("failure").Inc()
+			slog.Warn("webhook delivery failed", "webhook_id", webhook.ID, "message_id", msg.ID, "error", err)
+			continue
+		}
+		metrics.webhookDeliveries.WithLabelValues("success").Inc()
+	}
+}
+
+// deliverWebhook 最多尝试 webhookMaxAttempts 次，网络错误、429 和 5xx 会重试
+func (s *Server) deliverWebhook(ctx context.Context, webhook Webhook, body []byte) error {
+	signature := "sha256=" + signWebhook(webhook.Secret, body)
+	backoff := s.webhookBackoff
+
+	var err error
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		var retry bool
+		retry, err = s.postWebhook(ctx, webhook.URL, signature, body)
+		if err == nil || !retry || attempt == webhookMaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+func (s *Server) postWebhook(ctx context.Context, target, signature string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatapp-webhook")
+	req.Header.Set(webhookSignatureHeader, signature)
+	req.Header.Set(legacyWebhookSignatureHeader, signature)
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+}
+
+// signWebhook 计算请求体的 HMAC-SHA256，接收方用相同的密钥验证
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
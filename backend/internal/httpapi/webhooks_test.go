@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '{"event":"message"}' | openssl dgst -sha256 -hmac secret
+	const want = "224d011e83a8b6cd84c326b1cde108c033ca5b12a0d26c693329203600a3a777"
+	if got := signWebhook("secret", []byte(`{"event":"message"}`)); got != want {
+		t.Fatalf("signature = %s, want %s", got, want)
+	}
+	if signWebhook("other", []byte(`{"event":"message"}`)) == want {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+// webhookReceiver 按顺序返回 statuses 中的状态码的 webhook 接收方，之后都返回 200
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+	times    []time.Time
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.requests = append(rcv.requests, r)
+	rcv.bodies = append(rcv.bodies, string(body))
+	rcv.times = append(rcv.times, time.Now())
+	status := http.StatusOK
+	if len(rcv.statuses) > 0 {
+		status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// TestDeliverWebhook 请求体带有 HMAC 签名；网络错误、429 和 5xx 以指数退避重试，最多 3 次，其他 4xx 不重试
+func TestDeliverWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		ok       bool
+	}{
+		{"success", nil, 1, true},
+		{"recovers after server errors", []int{http.StatusInternalServerError, http.StatusBadGateway}, 3, true},
+		{"retries rate limiting", []int{http.StatusTooManyRequests}, 2, true},
+		{"gives up after three attempts", []int{500, 500, 500, 500}, 3, false},
+		{"does not retry client errors", []int{http.StatusBadRequest}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.webhookBackoff = 20 * time.Millisecond
+			rcv := &webhookReceiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rcv)
+			defer srv.Close()
+
+			body := []byte(`{"event":"message"}`)
+			err := ts.deliverWebhook(context.Background(), Webhook{URL: srv.URL, Secret: "secret"}, body)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want success %v", err, tt.ok)
+			}
+
+			rcv.mu.Lock()
+			defer rcv.mu.Unlock()
+			if len(rcv.requests) != tt.attempts {
+				t.Fatalf("%d attempts, want %d", len(rcv.requests), tt.attempts)
+			}
+			want := "sha256=" + signWebhook("secret", body)
+			for i, r := range rcv.requests {
+				if r.Header.Get(webhookSignatureHeader) != want || rcv.bodies[i] != string(body) {
+					t.Fatalf("attempt %d: signature %q, body %q", i+1, r.Header.Get(webhookSignatureHeader), rcv.bodies[i])
+				}
+			}
+			// 每次重试前的等待时间翻倍
+			for i := 1; i < len(rcv.times); i++ {
+				if gap, min := rcv.times[i].Sub(rcv.times[i-1]), ts.webhookBackoff<<(i-1); gap < min {
+					t.Fatalf("retry %d after %s, want at least %s", i, gap, min)
+				}
+			}
+		})
+	}
+}
+
+// TestDeliverWebhookRetriesNetworkErrors 接收方无法连接时同样重试，全部失败后返回错误
+func TestDeliverWebhookRetriesNetworkErrors(t *testing.T) {
+	ts := newTestServer(t)
+	ts.webhookBackoff = time.Millisecond
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	start := time.Now()
+	if err := ts.deliverWebhook(context.Background(), Webhook{URL: url, Secret: "secret"}, []byte("{}")); err == nil {
+		t.Fatal("delivery to a closed server succeeded")
+	}
+	// 两次重试分别等待 1ms 和 2ms
+	if elapsed := time.Since(start); elapsed < 3*time.Millisecond {
+		t.Fatalf("gave up after %s without retrying", elapsed)
+	}
+}
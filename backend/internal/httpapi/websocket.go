@@ -0,0 +1,264 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatapp/internal/auth"
+	"chatapp/internal/ws"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// 连续收到这么多条无法解析的帧后断开连接
+	maxInvalidFrames = 5
+	// 主动断开连接时等待已排队的事件和关闭帧写出的最长时间
+	wsCloseTimeout = 5 * time.Second
+)
+
+// clientFrame 客户端通过 WebSocket 发送的帧，目前只支持 message，Type 为空时同样视为 message
+type clientFrame struct {
+	Type string `json:"type"`
+	CreateMessageRequest
+}
+
+var errInvalidFrame = apiError(http.StatusBadRequest, "Invalid message format")
+
+// decodeClientFrame 解析客户端帧并检查事件类型。返回的错误都可以恢复，连接继续使用
+func decodeClientFrame(data []byte) (clientFrame, error) {
+	var frame clientFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return clientFrame{}, errInvalidFrame
+	}
+	if frame.Type != "" && frame.Type != ws.EventMessage {
+		return frame, &APIError{Status: http.StatusBadRequest, Message: "Unknown event type", Field: "type"}
+	}
+	return frame, nil
+}
+
+// WSError WebSocket 错误事件的内容。与 HTTP 接口的对应关系：
+// Status 为同一请求通过 REST 提交时返回的 HTTP 状态码，其余字段与 JSON 错误响应体相同。
+type WSError struct {
+	Status int `json:"status"`
+	*APIError
+}
+
+func wsError(err error) WSError {
+	apiErr := httpError(err)
+	return WSError{Status: apiErr.Status, APIError: apiErr}
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	var roomID int
+	if v := r.URL.Query().Get("room_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, r, apiError(http.StatusBadRequest, "Invalid room_id"))
+			return
+		}
+		roomID = id
+	}
+
+	// 重连时通过 ?resume=room_id:last_message_id,... 补发错过的消息
+	cursors, err := parseResumeCursors(r.URL.Query().Get("resume"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// 在准入和升级之前拒绝未允许的来源，防止跨站 WebSocket 劫持
+	if !s.upgrader.CheckOrigin(r) {
+		writeError(w, r, apiError(http.StatusForbidden, "Origin not allowed"))
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	// 准入在所有数据库查询之前进行，大量重连时被推迟的连接不会访问数据库。
+	// 这里只验证 JWT 签名取出用户 ID，token_version 在准入之后检查；机器人令牌需要查询数据库，按匿名连接准入
+	token := r.URL.Query().Get("token")
+	var admitUserID int
+	if token != "" && !strings.HasPrefix(token, auth.BotTokenPrefix) {
+		c, err := s.auth.Parse(token)
+		if err != nil {
+			writeError(w, r, authError(err))
+			return
+		}
+		admitUserID = c.UserID
+	}
+	if ok, retryAfter := s.admission.admit(r.URL.Query().Get("resume_token"), admitUserID); !ok {
+		logger.Warn("websocket upgrade deferred", "retry_after", retryAfter)
+		s.rejectUpgrade(w, r, retryAfter)
+		return
+	}
+
+	var claims *Claims
+	if token != "" {
+		c, err := s.auth.Authenticate(r.Context(), token)
+		if err != nil {
+			writeError(w, r, authError(err))
+			return
+		}
+		claims = c
+	}
+
+	var userID int
+	if claims != nil {
+		userID = claims.UserID
+	}
+
+	// 订阅单个聊天室必须是该聊天室的成员；所有连接只接收已加入聊天室的事件
+	if roomID != 0 {
+		if err := s.requireMember(r.Context(), roomID, userID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	rooms := make(map[int]bool)
+	blocked := make(map[int]bool)
+	if userID != 0 {
+		ids, err := s.members.ListUserRoomIDs(r.Context(), userID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, id := range ids {
+			rooms[id] = true
+		}
+		if s.blocks != nil {
+			if ids, err = s.blocks.ListBlockedUserIDs(r.Context(), userID); err != nil {
+				writeError(w, r, err)
+				return
+			}
+			for _, id := range ids {
+				blocked[id] = true
+			}
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	// 超出上限时 gorilla/websocket 以 1009 关闭连接，ReadMessage 返回 ErrReadLimit
+	conn.SetReadLimit(s.WSReadLimit)
+
+	opts := ws.ClientOptions{RoomID: roomID, Rooms: rooms, Blocked: blocked, CatchingUp: len(cursors) > 0}
+	if claims != nil {
+		opts.UserID = claims.UserID
+		opts.Username = claims.Username
+	}
+	client := ws.NewClient(s.hub, ws.NewConnSubscriber(conn), opts)
+	logger = logger.With("user_id", client.UserID, "username", client.Username, "room_id", roomID)
+
+	count := s.hub.Register(client)
+	metrics.wsConnections.Inc()
+	defer metrics.wsConnections.Dec()
+	if claims != nil && claims.BotID == 0 {
+		s.updateLastSeen(claims.UserID)
+		defer s.updateLastSeen(claims.UserID)
+	}
+
+	// 连接已经被劫持，无法再返回 500，只能移除连接并记录日志
+	defer func() {
+		if p := recover(); p != nil {
+			s.hub.Unregister(client)
+			logPanic(logger, "websocket handler panic", p)
+		}
+	}()
+
+	logger.Info("websocket connected", "connections", count)
+
+	// 重连时携带 resume_token 可以优先获得准入
+	client.Send(ws.Event{Type: ws.EventWelcome, RoomID: roomID, Data: map[string]string{
+		"resume_token": s.admission.issueResumeToken(userID),
+	}})
+	if len(cursors) > 0 {
+		s.catchUp(r.Context(), client, cursors)
+	}
+
+	invalid := 0
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			count := s.hub.Unregister(client)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				logger.Warn("websocket frame too large", "limit", s.WSReadLimit)
+			}
+			logger.Info("websocket disconnected", "connections", count, "reason", err)
+			break
+		}
+
+		frame, err := decodeClientFrame(data)
+		req := frame.CreateMessageRequest
+		if req.RoomID == 0 {
+			req.RoomID = roomID
+		}
+		if err != nil {
+			// 格式错误只回复错误事件，连续多次才断开，一次客户端 bug 不会导致重连循环
+			sendClientError(client, req, err)
+			if invalid++; invalid >= maxInvalidFrames {
+				count := s.hub.CloseClient(client, websocket.ClosePolicyViolation, "too many invalid messages")
+				client.WaitClosed(wsCloseTimeout)
+				logger.Warn("websocket disconnected after invalid messages", "connections", count, "invalid", invalid)
+				break
+			}
+			continue
+		}
+		invalid = 0
+
+		if claims == nil {
+			sendClientError(client, req, apiError(http.StatusUnauthorized, "Authentication required"))
+			continue
+		}
+		s.handleClientMessage(r.Context(), logger, client, claims, req)
+	}
+}
+
+// handleClientMessage 保存连接发送的消息，每条消息对应一个 span
+func (s *Server) handleClientMessage(ctx context.Context, logger *slog.Logger, client *ws.Client, claims *Claims, req CreateMessageRequest) {
+	ctx, span := tracer.Start(ctx, "websocket.message", trace.WithAttributes(attribute.Int("messaging.room_id", req.RoomID)))
+	defer span.End()
+
+	msg, created, err := s.saveMessage(ctx, claims, req)
+	if err != nil {
+		if apiErr := httpError(err); apiErr.Status >= http.StatusInternalServerError {
+			logger.Error("websocket message failed", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.RecordError(err)
+		sendClientError(client, req, err)
+		return
+	}
+	span.SetAttributes(attribute.Int("messaging.message_id", msg.ID))
+
+	// 重试的消息、定时消息和命令回复不会广播，只发给当前连接，客户端据此确认待发送的消息
+	if !created || msg.ScheduledAt != nil {
+		client.Send(ws.Event{Type: messageEventType(msg), RoomID: msg.RoomID, Data: msg})
+	}
+	// ack 在消息写入数据库之后才发送，只发给发送消息的连接
+	if req.AckID != "" {
+		client.Send(ws.Event{Type: ws.EventAck, RoomID: msg.RoomID, AckID: req.AckID, MessageID: msg.ID})
+	}
+}
+
+// sendClientError 请求带有 ack_id 时以 nack 的形式返回错误，否则发送 error 事件
+func sendClientError(client *ws.Client, req CreateMessageRequest, err error) {
+	if req.AckID != "" {
+		client.Send(ws.Event{Type: ws.EventNack, RoomID: req.RoomID, AckID: req.AckID, Data: wsError(err)})
+		return
+	}
+	client.Send(ws.Event{Type: ws.EventError, RoomID: req.RoomID, Data: wsError(err)})
+}
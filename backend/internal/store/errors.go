@@ -0,0 +1,59 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 所有 store 方法返回的错误类型，handler 通过 httpError 统一映射为 HTTP 响应
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrDuplicate  = errors.New("duplicate")
+	ErrPermission = errors.New("permission denied")
+	// ErrTimeout 数据库操作超时或被取消
+	ErrTimeout = errors.New("database timeout")
+	// ErrLimitExceeded 超过数量上限，例如聊天室置顶消息数
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// 邀请链接无法使用的原因
+var (
+	ErrInviteDisabled  = errors.New("invite disabled")
+	ErrInviteExpired   = errors.New("invite expired")
+	ErrInviteExhausted = errors.New("invite has no uses left")
+)
+
+// ErrPollClosed 投票已经截止
+var ErrPollClosed = errors.New("poll closed")
+
+// ErrForeignKey 引用的记录不存在
+type ErrForeignKey struct {
+	Field string
+}
+
+func (e *ErrForeignKey) Error() string {
+	return fmt.Sprintf("referenced %s does not exist", e.Field)
+}
+
+// ErrUniqueViolation 违反唯一约束，Field 为冲突的字段（无法确定时为约束名）。
+// errors.Is(err, ErrDuplicate) 对它同样成立
+type ErrUniqueViolation struct {
+	Field string
+}
+
+func (e *ErrUniqueViolation) Error() string {
+	return fmt.Sprintf("duplicate %s", e.Field)
+}
+
+func (e *ErrUniqueViolation) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// ErrConflict 并发修改冲突，CurrentVersion 为数据库中的当前版本
+type ErrConflict struct {
+	CurrentVersion int
+}
+
+func (e *ErrConflict) Error() string {
+	return fmt.Sprintf("conflict: current version is %d", e.CurrentVersion)
+}
@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"chatapp/internal/store"
+)
+
+var _ store.AdminStore = (*Store)(nil)
+
+func (s *Store) adminUser(u store.User) store.AdminUser {
+	return store.AdminUser{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+		IsAdmin:     s.admins[u.ID],
+		Disabled:    u.Disabled,
+	}
+}
+
+func (s *Store) ListUsers(ctx context.Context, search string, limit, offset int) ([]store.AdminUser, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	search = strings.ToLower(search)
+	users := []store.AdminUser{}
+	total := 0
+	for _, u := range s.users {
+		if s.deleted[u.ID] {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(u.Username), search) && !strings.Contains(strings.ToLower(u.Email), search) {
+			continue
+		}
+		total++
+		if total > offset && len(users) < limit {
+			users = append(users, s.adminUser(u))
+		}
+	}
+	return users, total, nil
+}
+
+func (s *Store) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.ID == userID {
+			return s.admins[userID] && !u.Disabled, nil
+		}
+	}
+	return false, store.ErrNotFound
+}
+
+func (s *Store) SetUserDisabled(ctx context.Context, userID int, disabled bool) (store.AdminUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.ID != userID || s.deleted[userID] {
+			continue
+		}
+		if disabled && !u.Disabled {
+			u.TokenVersion++
+		}
+		u.Disabled = disabled
+		s.users[i] = u
+		return s.adminUser(u), nil
+	}
+	return store.AdminUser{}, store.ErrNotFound
+}
+
+func (s *Store) AnonymizeUser(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := 0
+	for i < len(s.users) && s.users[i].ID != userID {
+		i++
+	}
+	if i == len(s.users) {
+		return store.ErrNotFound
+	}
+	u := s.users[i]
+	u.Username = "deleted_user_" + strconv.Itoa(userID)
+	u.Email, u.DisplayName, u.Bio, u.AvatarURL = "", "", "", ""
+	u.Disabled = true
+	u.TokenVersion++
+	s.users[i] = u
+	s.passwords[userID] = "!"
+	delete(s.admins, userID)
+	delete(s.avatars, userID)
+	s.deleted[userID] = true
+
+	// 私信会话和其中的消息直接删除
+	removed := make(map[int]bool)
+	conversations := s.conversations[:0]
+	for _, conv := range s.conversations {
+		if conv.HasParticipant(userID) {
+			removed[conv.ID] = true
+			continue
+		}
+		conversations = append(conversations, conv)
+	}
+	s.conversations = conversations
+	messages := s.messages[:0]
+	for _, msg := range s.messages {
+		if msg.ConversationID == nil || !removed[*msg.ConversationID] {
+			messages = append(messages, msg)
+		}
+	}
+	s.messages = messages
+	for key := range s.conversationReads {
+		if key[0] == userID || removed[key[1]] {
+			delete(s.conversationReads, key)
+		}
+	}
+
+	for i := range s.messages {
+		if s.messages[i].UserID == userID {
+			s.messages[i].UserID = 0
+			s.messages[i].Username = store.DeletedUserName
+			s.messages[i].DisplayName = ""
+			s.messages[i].Content = store.DeletedMessageContent
+		}
+	}
+	members := s.members[:0]
+	for _, m := range s.members {
+		if m.UserID != userID {
+			members = append(members, m)
+		}
+	}
+	s.members = members
+	reactions := s.reactions[:0]
+	for _, r := range s.reactions {
+		if r.userID != userID {
+			reactions = append(reactions, r)
+		}
+	}
+	s.reactions = reactions
+	for key := range s.reads {
+		if key[0] == userID {
+			delete(s.reads, key)
+		}
+	}
+	for key := range s.levels {
+		if key[0] == userID {
+			delete(s.levels, key)
+		}
+	}
+	for key := range s.mentions {
+		if key.userID == userID {
+			delete(s.mentions, key)
+		}
+	}
+	notifications := s.notifications[:0]
+	for _, n := range s.notifications {
+		if n.UserID != userID {
+			notifications = append(notifications, n)
+		}
+	}
+	s.notifications = notifications
+	votes := s.pollVotes[:0]
+	for _, v := range s.pollVotes {
+		if v.userID != userID {
+			votes = append(votes, v)
+		}
+	}
+	s.pollVotes = votes
+	blocks := s.blocks[:0]
+	for _, b := range s.blocks {
+		if b.blockerID != userID && b.blockedID != userID {
+			blocks = append(blocks, b)
+		}
+	}
+	s.blocks = blocks
+	for hash, token := range s.resets {
+		if token.userID == userID {
+			delete(s.resets, hash)
+		}
+	}
+	return nil
+}
+
+func (s *Store) PromoteAdmin(ctx context.Context, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email && !s.deleted[u.ID] {
+			s.admins[u.ID] = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
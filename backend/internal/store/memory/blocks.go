@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.BlockStore = (*Store)(nil)
+
+type block struct {
+	blockerID int
+	blockedID int
+	createdAt time.Time
+}
+
+// isBlocked 调用方持有 s.mu
+func (s *Store) isBlocked(blockerID, blockedID int) bool {
+	for _, b := range s.blocks {
+		if b.blockerID == blockerID && b.blockedID == blockedID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) BlockUser(ctx context.Context, blockerID, blockedID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.username(blockedID) == "" {
+		return &store.ErrForeignKey{Field: "blocked_id"}
+	}
+	if !s.isBlocked(blockerID, blockedID) {
+		s.blocks = append(s.blocks, block{blockerID: blockerID, blockedID: blockedID, createdAt: time.Now()})
+	}
+	return nil
+}
+
+func (s *Store) UnblockUser(ctx context.Context, blockerID, blockedID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocks := s.blocks[:0]
+	for _, b := range s.blocks {
+		if b.blockerID != blockerID || b.blockedID != blockedID {
+			blocks = append(blocks, b)
+		}
+	}
+	s.blocks = blocks
+	return nil
+}
+
+func (s *Store) ListBlockedUserIDs(ctx context.Context, blockerID int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := []int{}
+	for _, b := range s.blocks {
+		if b.blockerID == blockerID {
+			ids = append(ids, b.blockedID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) ListBlockedUsers(ctx context.Context, blockerID int) ([]store.BlockedUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := []store.BlockedUser{}
+	// s.blocks 按屏蔽时间升序保存，倒序遍历
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		b := s.blocks[i]
+		if b.blockerID != blockerID {
+			continue
+		}
+		for _, u := range s.users {
+			if u.ID == b.blockedID {
+				users = append(users, store.BlockedUser{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, BlockedAt: b.createdAt})
+			}
+		}
+	}
+	return users, nil
+}
+
+func (s *Store) IsBlockedBetween(ctx context.Context, userA, userB int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isBlocked(userA, userB) || s.isBlocked(userB, userA), nil
+}
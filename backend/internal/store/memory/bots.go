@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.BotStore = (*Store)(nil)
+
+type botRecord struct {
+	store.Bot
+	tokenHash string
+}
+
+func (s *Store) CreateBot(ctx context.Context, bot *store.Bot, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.username(bot.OwnerUserID) == "" {
+		return &store.ErrForeignKey{Field: "owner_user_id"}
+	}
+	s.nextBotID++
+	bot.ID = s.nextBotID
+	bot.CreatedAt = time.Now()
+	s.bots = append(s.bots, botRecord{Bot: *bot, tokenHash: tokenHash})
+	return nil
+}
+
+func (s *Store) ListBots(ctx context.Context, ownerID int) ([]store.Bot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bots := []store.Bot{}
+	for _, b := range s.bots {
+		if b.OwnerUserID == ownerID {
+			bots = append(bots, b.Bot)
+		}
+	}
+	return bots, nil
+}
+
+func (s *Store) RotateBotToken(ctx context.Context, id, ownerID int, tokenHash string) (store.Bot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.bots {
+		if b.ID == id && b.OwnerUserID == ownerID {
+			s.bots[i].tokenHash = tokenHash
+			return b.Bot, nil
+		}
+	}
+	return store.Bot{}, store.ErrNotFound
+}
+
+func (s *Store) DeleteBot(ctx context.Context, id, ownerID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.bots {
+		if b.ID == id && b.OwnerUserID == ownerID {
+			s.bots = append(s.bots[:i], s.bots[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+// AuthenticateBot 与 postgres 一样，所有者被停用或删除后令牌失效
+func (s *Store) AuthenticateBot(ctx context.Context, tokenHash string) (store.BotIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.bots {
+		if b.tokenHash != tokenHash {
+			continue
+		}
+		for _, u := range s.users {
+			if u.ID != b.OwnerUserID || u.Disabled || s.deleted[u.ID] {
+				continue
+			}
+			now := time.Now()
+			s.bots[i].LastUsedAt = &now
+			return store.BotIdentity{BotID: b.ID, BotName: b.Name, UserID: u.ID, Username: u.Username, Email: u.Email}, nil
+		}
+	}
+	return store.BotIdentity{}, store.ErrNotFound
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.ConversationStore = (*Store)(nil)
+
+func (s *Store) GetOrCreateConversation(ctx context.Context, userID, otherUserID int) (store.Conversation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, b := userID, otherUserID
+	if a > b {
+		a, b = b, a
+	}
+	for _, conv := range s.conversations {
+		if conv.UserAID == a && conv.UserBID == b {
+			return conv, false, nil
+		}
+	}
+	if s.username(a) == "" || s.username(b) == "" {
+		return store.Conversation{}, false, &store.ErrForeignKey{Field: "user_id"}
+	}
+	conv := store.Conversation{ID: len(s.conversations) + 1, UserAID: a, UserBID: b, CreatedAt: time.Now()}
+	s.conversations = append(s.conversations, conv)
+	return conv, true, nil
+}
+
+func (s *Store) GetConversation(ctx context.Context, id int) (store.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conv := range s.conversations {
+		if conv.ID == id {
+			return conv, nil
+		}
+	}
+	return store.Conversation{}, store.ErrNotFound
+}
+
+func (s *Store) ListConversations(ctx context.Context, userID int) ([]store.ConversationSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := []store.ConversationSummary{}
+	for _, conv := range s.conversations {
+		if !conv.HasParticipant(userID) {
+			continue
+		}
+		otherID := conv.UserAID
+		if otherID == userID {
+			otherID = conv.UserBID
+		}
+		summary := store.ConversationSummary{
+			Conversation: conv,
+			OtherUser:    store.Participant{ID: otherID, Username: s.username(otherID)},
+		}
+		lastRead := s.conversationReads[[2]int{userID, conv.ID}]
+		for _, msg := range s.messages {
+			if msg.ConversationID == nil || *msg.ConversationID != conv.ID {
+				continue
+			}
+			last := msg
+			s.fillSender(&last)
+			summary.LastMessage = &last
+			if msg.ID > lastRead && msg.UserID != userID {
+				summary.UnreadCount++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return lastActivity(summaries[i]).After(lastActivity(summaries[j]))
+	})
+	return summaries, nil
+}
+
+func lastActivity(c store.ConversationSummary) time.Time {
+	if c.LastMessage != nil {
+		return c.LastMessage.CreatedAt
+	}
+	return c.CreatedAt
+}
+
+func (s *Store) ListConversationMessages(ctx context.Context, conversationID, viewerID, limit, offset int) ([]store.Message, error) {
+	messages := s.listMessages(func(msg store.Message) bool {
+		return msg.ConversationID != nil && *msg.ConversationID == conversationID
+	}, viewerID)
+	// offset 从最新的消息开始计算
+	end := len(messages) - offset
+	if end <= 0 {
+		return []store.Message{}, nil
+	}
+	return messages[max(end-limit, 0):end], nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, userID, conversationID, messageID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]int{userID, conversationID}
+	if s.conversationReads[key] >= messageID {
+		return false, nil
+	}
+	s.conversationReads[key] = messageID
+	return true, nil
+}
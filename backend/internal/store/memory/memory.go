@@ -0,0 +1,1009 @@
+// Package memory 是 store 接口的内存实现，用于不依赖 PostgreSQL 的单元测试
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"chatapp/internal/store"
+)
+
+type reactionKey struct {
+	messageID int
+	userID    int
+	emoji     string
+}
+
+type reaction struct {
+	reactionKey
+	createdAt time.Time
+}
+
+type clientMsgKey struct {
+	userID      int
+	clientMsgID string
+}
+
+type resetToken struct {
+	userID    int
+	expiresAt time.Time
+	used      bool
+}
+
+type member struct {
+	roomID int
+	store.RoomMember
+}
+
+// Store 实现 UserStore、PasswordResetStore、RoomStore、MembershipStore、MessageStore、ConversationStore、ReactionStore、
+// ReadStore、AdminStore、ModerationStore、PinStore 和 NotificationStore，
+// 返回与 postgres 实现相同的错误类型
+type Store struct {
+	mu sync.Mutex
+
+	users     []store.User
+	passwords map[int]string
+	avatars   map[int]store.Avatar
+	admins    map[int]bool
+	deleted   map[int]bool
+	resets    map[string]*resetToken
+	rooms     map[int]store.ChatRoom
+	messages  []store.Message
+	reactions []reaction
+	reads     map[[2]int]int
+	members   []member
+	pins      []pin
+	// conversationReads 以 (userID, conversationID) 为键
+	conversations     []store.Conversation
+	conversationReads map[[2]int]int
+	autoPins          map[int]store.AutoPinSettings
+	// notifications 保存创建时的字段，读取时与 postgres 一样从消息和用户中取来源和内容
+	notifications []store.Notification
+	mentions      map[mentionKey]bool
+	levels        map[[2]int]string
+	// bans 的值为封禁原因
+	bans            map[[2]int]string
+	profanity       map[int]store.ProfanitySettings
+	moderationLog   []store.ModerationAction
+	filterDecisions []store.ContentFilterDecision
+	// clientMsgIDs 保存 ClientMsgID 对应的消息 ID，消息本身不保存 ClientMsgID
+	clientMsgIDs map[clientMsgKey]int
+	// blocks 按屏蔽时间升序保存
+	blocks []block
+	// polls 的下标为 ID-1，pollVotes 按投票顺序保存
+	polls     []store.Poll
+	pollVotes []pollVote
+	bots      []botRecord
+
+	nextUserID    int
+	nextRoomID    int
+	nextMessageID int
+
+	nextNotificationID int
+	nextBotID          int
+}
+
+var (
+	_ store.UserStore          = (*Store)(nil)
+	_ store.PasswordResetStore = (*Store)(nil)
+	_ store.RoomStore          = (*Store)(nil)
+	_ store.MembershipStore    = (*Store)(nil)
+	_ store.MessageStore       = (*Store)(nil)
+	_ store.ReactionStore      = (*Store)(nil)
+	_ store.ReadStore          = (*Store)(nil)
+)
+
+func New() *Store {
+	return &Store{
+		passwords: make(map[int]string),
+		avatars:   make(map[int]store.Avatar),
+		admins:    make(map[int]bool),
+		deleted:   make(map[int]bool),
+		resets:    make(map[string]*resetToken),
+		rooms:     make(map[int]store.ChatRoom),
+		reads:     make(map[[2]int]int),
+		autoPins:  make(map[int]store.AutoPinSettings),
+
+		conversationReads: make(map[[2]int]int),
+		mentions:          make(map[mentionKey]bool),
+		levels:            make(map[[2]int]string),
+		bans:              make(map[[2]int]string),
+		profanity:         make(map[int]store.ProfanitySettings),
+
+		clientMsgIDs: make(map[clientMsgKey]int),
+	}
+}
+
+// AddRoom 添加一个聊天室并返回它，便于测试准备数据
+func (s *Store) AddRoom(name, description string, createdBy *int) store.ChatRoom {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRoomID++
+	room := store.ChatRoom{
+		ID:          s.nextRoomID,
+		Name:        name,
+		Description: description,
+		CreatedAt:   time.Now(),
+		CreatedBy:   createdBy,
+	}
+	s.rooms[room.ID] = room
+	return room
+}
+
+func (s *Store) CreateUser(ctx context.Context, username, email, passwordHash string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return store.User{}, &store.ErrUniqueViolation{Field: "email"}
+		}
+	}
+	for _, u := range s.users {
+		if u.Username == username {
+			return store.User{}, &store.ErrUniqueViolation{Field: "username"}
+		}
+	}
+	s.nextUserID++
+	user := store.User{ID: s.nextUserID, Username: username, Email: email}
+	s.users = append(s.users, user)
+	s.passwords[user.ID] = passwordHash
+	return user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (store.User, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, s.passwords[u.ID], nil
+		}
+	}
+	return store.User{}, "", store.ErrNotFound
+}
+
+func (s *Store) UserExists(ctx context.Context, email, username string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == email || u.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id int) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+func (s *Store) SearchUsers(ctx context.Context, prefix string, excludeUserID, limit int) ([]store.Participant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix = strings.ToLower(prefix)
+	users := []store.Participant{}
+	for _, u := range s.users {
+		if u.ID != excludeUserID && strings.HasPrefix(strings.ToLower(u.Username), prefix) {
+			users = append(users, store.Participant{ID: u.ID, Username: u.Username})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if len(users[i].Username) != len(users[j].Username) {
+			return len(users[i].Username) < len(users[j].Username)
+		}
+		return users[i].Username < users[j].Username
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (s *Store) UpdateProfile(ctx context.Context, id int, update store.ProfileUpdate) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.ID != id {
+			continue
+		}
+		if update.DisplayName != nil {
+			u.DisplayName = *update.DisplayName
+		}
+		if update.Bio != nil {
+			u.Bio = *update.Bio
+		}
+		if update.AvatarURL != nil {
+			u.AvatarURL = *update.AvatarURL
+		}
+		s.users[i] = u
+		return u, nil
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+func (s *Store) SetAvatar(ctx context.Context, id int, avatar store.Avatar, url string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.ID == id {
+			u.AvatarURL = url
+			s.users[i] = u
+			s.avatars[id] = avatar
+			return u, nil
+		}
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+func (s *Store) GetAvatar(ctx context.Context, id int) (store.Avatar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	avatar, ok := s.avatars[id]
+	if !ok {
+		return store.Avatar{}, store.ErrNotFound
+	}
+	return avatar, nil
+}
+
+func (s *Store) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.passwords[id]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return hash, nil
+}
+
+func (s *Store) ChangePassword(ctx context.Context, id int, oldHash, newHash string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, u := range s.users {
+		if u.ID == id {
+			if s.passwords[id] != oldHash {
+				return store.User{}, &store.ErrConflict{CurrentVersion: u.TokenVersion}
+			}
+			u.TokenVersion++
+			s.users[i] = u
+			s.passwords[id] = newHash
+			return u, nil
+		}
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+func (s *Store) GetTokenVersion(ctx context.Context, id int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.ID == id {
+			return u.TokenVersion, nil
+		}
+	}
+	return 0, store.ErrNotFound
+}
+
+func (s *Store) UpdatePasswordHash(ctx context.Context, id int, oldHash, newHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.ID == id {
+			if s.passwords[id] != oldHash {
+				return &store.ErrConflict{CurrentVersion: u.TokenVersion}
+			}
+			s.passwords[id] = newHash
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *Store) TouchLastSeen(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range s.users {
+		if s.users[i].ID == id {
+			s.users[i].LastSeenAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.passwords[userID]; !ok {
+		return &store.ErrForeignKey{Field: "user_id"}
+	}
+	s.resets[tokenHash] = &resetToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *Store) ResetPassword(ctx context.Context, tokenHash, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.resets[tokenHash]
+	if !ok || token.used || !token.expiresAt.After(time.Now()) {
+		return store.ErrNotFound
+	}
+	for i, u := range s.users {
+		if u.ID == token.userID {
+			s.users[i].TokenVersion++
+		}
+	}
+	s.passwords[token.userID] = passwordHash
+	token.used = true
+	return nil
+}
+
+// fillSender 填充消息发送者的用户名和显示名称，以及回复数
+func (s *Store) fillSender(msg *store.Message) {
+	msg.ReplyCount = 0
+	for _, m := range s.messages {
+		if m.ParentMessageID != nil && *m.ParentMessageID == msg.ID {
+			msg.ReplyCount++
+		}
+	}
+	for _, u := range s.users {
+		if u.ID == msg.UserID {
+			msg.Username = u.Username
+			msg.DisplayName = u.DisplayName
+			return
+		}
+	}
+}
+
+func (s *Store) username(userID int) string {
+	for _, u := range s.users {
+		if u.ID == userID {
+			return u.Username
+		}
+	}
+	return ""
+}
+
+func (s *Store) ListRooms(ctx context.Context, opts store.RoomListOptions) ([]store.ChatRoom, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	search := strings.ToLower(opts.Search)
+	rooms := make([]store.ChatRoom, 0, len(s.rooms))
+	activity := make(map[int]time.Time)
+	for _, room := range s.rooms {
+		if search != "" && !strings.Contains(strings.ToLower(room.Name), search) &&
+			!strings.Contains(strings.ToLower(room.Description), search) {
+			continue
+		}
+		unread := 0
+		lastRead := s.reads[[2]int{opts.ViewerID, room.ID}]
+		for _, msg := range s.messages {
+			if msg.RoomID != room.ID {
+				continue
+			}
+			if msg.CreatedAt.After(activity[room.ID]) {
+				activity[room.ID] = msg.CreatedAt
+			}
+			if msg.ID > lastRead && msg.UserID != opts.ViewerID {
+				unread++
+			}
+		}
+		if opts.ViewerID > 0 {
+			room.UnreadCount = &unread
+			room.NotificationLevel = store.NotificationLevelAll
+		}
+		rooms = append(rooms, room)
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		a, b := rooms[i], rooms[j]
+		switch opts.Sort {
+		case store.RoomSortNameAsc:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.ID < b.ID
+		case store.RoomSortNameDesc:
+			if a.Name != b.Name {
+				return a.Name > b.Name
+			}
+			return a.ID > b.ID
+		case store.RoomSortCreatedAsc:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		case store.RoomSortActivityDesc:
+			if !activity[a.ID].Equal(activity[b.ID]) {
+				return activity[a.ID].After(activity[b.ID])
+			}
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	total := len(rooms)
+	if opts.Offset >= total {
+		return []store.ChatRoom{}, total, nil
+	}
+	rooms = rooms[opts.Offset:]
+	if opts.Limit > 0 && len(rooms) > opts.Limit {
+		rooms = rooms[:opts.Limit]
+	}
+	return rooms, total, nil
+}
+
+func (s *Store) GetRoom(ctx context.Context, id int) (store.ChatRoom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return store.ChatRoom{}, store.ErrNotFound
+	}
+	return room, nil
+}
+
+func (s *Store) GetRoomSummary(ctx context.Context, id, viewerID int) (store.ChatRoom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return store.ChatRoom{}, store.ErrNotFound
+	}
+
+	members, isMember := 0, false
+	for _, m := range s.members {
+		if m.roomID == id {
+			members++
+			isMember = isMember || m.UserID == viewerID
+		}
+	}
+	messages := 0
+	var last *store.Message
+	for i := range s.messages {
+		if s.messages[i].RoomID == id {
+			messages++
+			last = &s.messages[i]
+		}
+	}
+
+	room.MemberCount = &members
+	room.IsMember = &isMember
+	room.MessageCount = &messages
+	if last != nil {
+		createdAt := last.CreatedAt
+		room.LastActivityAt = &createdAt
+		if isMember {
+			content := []rune(last.Content)
+			if len(content) > store.RoomPreviewLength {
+				content = content[:store.RoomPreviewLength]
+			}
+			room.LastMessage = &store.RoomLastMessage{
+				ID:        last.ID,
+				Username:  s.username(last.UserID),
+				Content:   string(content),
+				CreatedAt: createdAt,
+			}
+		}
+	}
+	return room, nil
+}
+
+func (s *Store) UpdateRoom(ctx context.Context, id int, name, description string, slowModeSeconds *int) (store.ChatRoom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[id]
+	if !ok {
+		return store.ChatRoom{}, store.ErrNotFound
+	}
+	room.Name = name
+	room.Description = description
+	if slowModeSeconds != nil {
+		room.SlowModeSeconds = *slowModeSeconds
+	}
+	s.rooms[id] = room
+	return room, nil
+}
+
+func (s *Store) DeleteRoom(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.rooms, id)
+
+	// 与外键级联删除保持一致
+	kept := s.messages[:0]
+	for _, msg := range s.messages {
+		if msg.RoomID != id {
+			kept = append(kept, msg)
+		}
+	}
+	s.messages = kept
+
+	members := s.members[:0]
+	for _, m := range s.members {
+		if m.roomID != id {
+			members = append(members, m)
+		}
+	}
+	s.members = members
+	return nil
+}
+
+func (s *Store) InsertMessage(ctx context.Context, msg *store.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[msg.RoomID]; !ok && msg.ConversationID == nil {
+		return &store.ErrForeignKey{Field: "room_id"}
+	}
+	// 系统消息和 webhook 消息没有发送者
+	if msg.UserID != 0 && s.username(msg.UserID) == "" {
+		return &store.ErrForeignKey{Field: "user_id"}
+	}
+	if msg.ParentMessageID != nil && !s.hasMessage(*msg.ParentMessageID) {
+		return &store.ErrForeignKey{Field: "parent_message_id"}
+	}
+	// 内存实现没有附件和定时消息
+	if len(msg.Attachments) > 0 {
+		return &store.ErrForeignKey{Field: "attachments"}
+	}
+	if msg.ScheduledAt != nil {
+		return &store.ErrForeignKey{Field: "scheduled_at"}
+	}
+	key := clientMsgKey{userID: msg.UserID, clientMsgID: msg.ClientMsgID}
+	if msg.ClientMsgID != "" {
+		if _, ok := s.clientMsgIDs[key]; ok {
+			return &store.ErrUniqueViolation{Field: "client_msg_id"}
+		}
+	}
+	s.nextMessageID++
+	msg.ID = s.nextMessageID
+	msg.CreatedAt = time.Now()
+	if msg.TTLSeconds > 0 {
+		expiresAt := msg.CreatedAt.Add(time.Duration(msg.TTLSeconds) * time.Second)
+		msg.ExpiresAt = &expiresAt
+	}
+	s.fillSender(msg)
+	stored := *msg
+	stored.ClientMsgID = ""
+	s.messages = append(s.messages, stored)
+	if msg.ClientMsgID != "" {
+		s.clientMsgIDs[key] = msg.ID
+	}
+	return nil
+}
+
+func (s *Store) GetMessageByClientID(ctx context.Context, userID int, clientMsgID string) (store.Message, error) {
+	s.mu.Lock()
+	id, ok := s.clientMsgIDs[clientMsgKey{userID: userID, clientMsgID: clientMsgID}]
+	s.mu.Unlock()
+	if !ok {
+		return store.Message{}, store.ErrNotFound
+	}
+	msg, err := s.GetMessage(ctx, id)
+	msg.ClientMsgID = clientMsgID
+	return msg, err
+}
+
+func (s *Store) DeleteExpiredMessages(ctx context.Context, now time.Time) ([]store.ExpiredMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := []store.ExpiredMessage{}
+	kept := s.messages[:0]
+	for _, msg := range s.messages {
+		if msg.ExpiresAt != nil && !msg.ExpiresAt.After(now) {
+			expired = append(expired, store.ExpiredMessage{ID: msg.ID, RoomID: msg.RoomID, ConversationID: msg.ConversationID})
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	s.messages = kept
+	return expired, nil
+}
+
+func (s *Store) ExpireClientMessageIDs(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key, id := range s.clientMsgIDs {
+		for _, msg := range s.messages {
+			if msg.ID == id && msg.CreatedAt.Before(before) {
+				delete(s.clientMsgIDs, key)
+				n++
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) hasMessage(id int) bool {
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetMessage(ctx context.Context, id int) (store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			s.fillSender(&msg)
+			return msg, nil
+		}
+	}
+	return store.Message{}, store.ErrNotFound
+}
+
+func (s *Store) LatestRoomMessageID(ctx context.Context, roomID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var id int
+	for _, msg := range s.messages {
+		if msg.RoomID == roomID && msg.ID > id {
+			id = msg.ID
+		}
+	}
+	return id, nil
+}
+
+func (s *Store) ListRoomMessages(ctx context.Context, opts store.MessagePageOptions) ([]store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []store.Message
+	for _, msg := range s.messages {
+		if msg.RoomID != opts.RoomID || msg.ID <= opts.AfterID || (opts.BeforeID > 0 && msg.ID >= opts.BeforeID) {
+			continue
+		}
+		msg.Blocked = s.isBlocked(opts.ViewerID, msg.UserID)
+		if msg.Blocked && !opts.IncludeBlocked {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+	// s.messages 按 ID 升序保存，向前翻页取最前面的，否则取最后面的
+	if len(matched) > opts.Limit {
+		if opts.AfterID > 0 {
+			matched = matched[:opts.Limit]
+		} else {
+			matched = matched[len(matched)-opts.Limit:]
+		}
+	}
+	messages := []store.Message{}
+	for _, msg := range matched {
+		s.fillSender(&msg)
+		msg.Reactions = s.summarize(msg.ID, opts.ViewerID)
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (s *Store) ListRoomMessagesAfter(ctx context.Context, roomID, afterID, viewerID, limit int) ([]store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := []store.Message{}
+	for _, msg := range s.messages {
+		if msg.RoomID != roomID || msg.ID <= afterID || s.isBlocked(viewerID, msg.UserID) {
+			continue
+		}
+		s.fillSender(&msg)
+		msg.Reactions = s.summarize(msg.ID, viewerID)
+		messages = append(messages, msg)
+		if len(messages) == limit {
+			break
+		}
+	}
+	return messages, nil
+}
+
+func (s *Store) GetThreadRoot(ctx context.Context, id int) (store.Message, error) {
+	return s.GetMessage(ctx, id)
+}
+
+func (s *Store) ListReplies(ctx context.Context, parentID, viewerID, limit, offset int) ([]store.Message, error) {
+	replies := s.listMessages(func(msg store.Message) bool {
+		return msg.ParentMessageID != nil && *msg.ParentMessageID == parentID
+	}, viewerID)
+	if offset >= len(replies) {
+		return []store.Message{}, nil
+	}
+	replies = replies[offset:]
+	if len(replies) > limit {
+		replies = replies[:limit]
+	}
+	return replies, nil
+}
+
+func (s *Store) listMessages(match func(store.Message) bool, viewerID int) []store.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := []store.Message{}
+	for _, msg := range s.messages {
+		if !match(msg) {
+			continue
+		}
+		s.fillSender(&msg)
+		msg.Reactions = s.summarize(msg.ID, viewerID)
+		messages = append(messages, msg)
+		if len(messages) == 100 {
+			break
+		}
+	}
+	return messages
+}
+
+// summarize 按表情首次出现的顺序聚合，与 postgres 实现的 ORDER BY MIN(created_at) 一致
+func (s *Store) summarize(messageID, viewerID int) []store.ReactionSummary {
+	var summaries []store.ReactionSummary
+	index := make(map[string]int)
+	for _, r := range s.reactions {
+		if r.messageID != messageID {
+			continue
+		}
+		i, ok := index[r.emoji]
+		if !ok {
+			i = len(summaries)
+			index[r.emoji] = i
+			summaries = append(summaries, store.ReactionSummary{Emoji: r.emoji})
+		}
+		summaries[i].Count++
+		if viewerID > 0 && r.userID == viewerID {
+			summaries[i].Reacted = true
+		}
+	}
+	return summaries
+}
+
+func (s *Store) AddReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reactionKey{messageID: messageID, userID: userID, emoji: emoji}
+	for _, r := range s.reactions {
+		if r.reactionKey == key {
+			return false, nil
+		}
+	}
+	s.reactions = append(s.reactions, reaction{reactionKey: key, createdAt: time.Now()})
+	return true, nil
+}
+
+func (s *Store) RemoveReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := reactionKey{messageID: messageID, userID: userID, emoji: emoji}
+	for i, r := range s.reactions {
+		if r.reactionKey == key {
+			s.reactions = append(s.reactions[:i], s.reactions[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) ListReactions(ctx context.Context, messageID, viewerID int) ([]store.ReactionSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := s.summarize(messageID, viewerID)
+	if summaries == nil {
+		return []store.ReactionSummary{}, nil
+	}
+	return summaries, nil
+}
+
+func (s *Store) MarkRead(ctx context.Context, userID, roomID, messageID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]int{userID, roomID}
+	if s.reads[key] >= messageID {
+		return false, nil
+	}
+	s.reads[key] = messageID
+	return true, nil
+}
+
+// SearchRoomMessages 不区分大小写的子串匹配，只用于测试
+// SearchRoomMessages 近似 PostgreSQL 的 websearch_to_tsquery('simple', ...)：查询中的标点被忽略，
+// 消息包含所有查询词（不区分大小写）时匹配。不支持短语、OR 和排除
+func (s *Store) SearchRoomMessages(ctx context.Context, roomID int, query string, limit, offset int) ([]store.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := []store.SearchResult{}
+	words := make(map[string]bool)
+	for _, w := range searchWords(query) {
+		words[w] = true
+	}
+	if len(words) == 0 {
+		return results, nil
+	}
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		msg := s.messages[i]
+		if msg.RoomID != roomID {
+			continue
+		}
+		found := 0
+		seen := make(map[string]bool)
+		for _, w := range searchWords(msg.Content) {
+			if words[w] && !seen[w] {
+				seen[w] = true
+				found++
+			}
+		}
+		if found < len(words) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		s.fillSender(&msg)
+		results = append(results, store.SearchResult{Message: msg, Snippet: highlightWords(msg.Content, words)})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// isWordRune 字母和数字组成词，其余字符都是分隔符
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// searchWords 把文本切分为小写的词
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWordRune(r) })
+}
+
+// highlightWords 用 <mark> 标记 content 中属于 words 的词，与 ts_headline 的格式相同
+func highlightWords(content string, words map[string]bool) string {
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		word := content[start:end]
+		if words[strings.ToLower(word)] {
+			b.WriteString("<mark>" + word + "</mark>")
+		} else {
+			b.WriteString(word)
+		}
+		start = -1
+	}
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			if start >= 0 {
+				flush(i)
+			}
+			b.WriteString(content[i : i+size])
+		}
+		i += size
+	}
+	if start >= 0 {
+		flush(len(content))
+	}
+	return b.String()
+}
+
+func (s *Store) JoinRoom(ctx context.Context, roomID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return false, &store.ErrForeignKey{Field: "room_id"}
+	}
+	for _, m := range s.members {
+		if m.roomID == roomID && m.UserID == userID {
+			return false, nil
+		}
+	}
+	s.members = append(s.members, member{roomID: roomID, RoomMember: store.RoomMember{
+		UserID:   userID,
+		Username: s.username(userID),
+		Role:     store.RoleMember,
+		JoinedAt: time.Now(),
+	}})
+	return true, nil
+}
+
+func (s *Store) LeaveRoom(ctx context.Context, roomID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, m := range s.members {
+		if m.roomID == roomID && m.UserID == userID {
+			s.members = append(s.members[:i], s.members[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) IsRoomMember(ctx context.Context, roomID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		if m.roomID == roomID && m.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) CountRoomMembers(ctx context.Context, roomID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, m := range s.members {
+		if m.roomID == roomID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) ListRoomMembers(ctx context.Context, roomID, limit, offset int) ([]store.RoomMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := []store.RoomMember{}
+	for _, m := range s.members {
+		if m.roomID != roomID {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		rm := m.RoomMember
+		for _, u := range s.users {
+			if u.ID == rm.UserID {
+				rm.LastSeenAt = u.LastSeenAt
+			}
+		}
+		members = append(members, rm)
+		if len(members) == limit {
+			break
+		}
+	}
+	return members, nil
+}
+
+func (s *Store) ListUserRoomIDs(ctx context.Context, userID int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int
+	for _, m := range s.members {
+		if m.UserID == userID {
+			ids = append(ids, m.roomID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) GetMemberRole(ctx context.Context, roomID, userID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.members {
+		if m.roomID == roomID && m.UserID == userID {
+			return m.Role, nil
+		}
+	}
+	return "", store.ErrNotFound
+}
@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// 内存实现必须返回与 PostgreSQL 实现相同的错误类型，handler 的测试才有意义
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice, err := s.CreateUser(ctx, "alice", "alice@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := s.AddRoom("general", "", &alice.ID)
+	missing := 999
+
+	tests := []struct {
+		name  string
+		err   error
+		want  error
+		field string
+	}{
+		{"duplicate email", createUserErr(s, "bob", "alice@example.com"), store.ErrDuplicate, "email"},
+		{"duplicate username", createUserErr(s, "alice", "other@example.com"), store.ErrDuplicate, "username"},
+		{"unknown user", getUserErr(s, missing), store.ErrNotFound, ""},
+		{"unknown room", getRoomErr(s, missing), store.ErrNotFound, ""},
+		{"unknown message", getMessageErr(s, missing), store.ErrNotFound, ""},
+		{"join unknown room", joinErr(s, missing, alice.ID), nil, "room_id"},
+		{"message to unknown room", s.InsertMessage(ctx, &store.Message{RoomID: missing, UserID: alice.ID, Content: "hi"}), nil, "room_id"},
+		{"reply to unknown message", s.InsertMessage(ctx, &store.Message{RoomID: room.ID, UserID: alice.ID, Content: "hi", ParentMessageID: &missing}), nil, "parent_message_id"},
+		{"reset token for unknown user", s.CreatePasswordResetToken(ctx, missing, "hash", time.Now().Add(time.Hour)), nil, "user_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want != nil {
+				if !errors.Is(tt.err, tt.want) {
+					t.Fatalf("err = %v, want %v", tt.err, tt.want)
+				}
+				var unique *store.ErrUniqueViolation
+				if tt.field != "" && (!errors.As(tt.err, &unique) || unique.Field != tt.field) {
+					t.Fatalf("err = %#v, want unique violation on %s", tt.err, tt.field)
+				}
+				return
+			}
+			var fk *store.ErrForeignKey
+			if !errors.As(tt.err, &fk) || fk.Field != tt.field {
+				t.Fatalf("err = %#v, want foreign key error on %s", tt.err, tt.field)
+			}
+		})
+	}
+}
+
+// TestStalePasswordHash 按旧哈希更新密码时，哈希已经被修改则返回 ErrConflict，不覆盖新的哈希
+func TestStalePasswordHash(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice, err := s.CreateUser(ctx, "alice", "alice@example.com", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := s.ChangePassword(ctx, alice.ID, "hash", "changed")
+	if err != nil || changed.TokenVersion != alice.TokenVersion+1 {
+		t.Fatalf("ChangePassword = %+v, %v, want the token version bumped", changed, err)
+	}
+
+	var conflict *store.ErrConflict
+	if err := s.UpdatePasswordHash(ctx, alice.ID, "hash", "rehashed"); !errors.As(err, &conflict) {
+		t.Fatalf("UpdatePasswordHash with a stale hash = %v, want ErrConflict", err)
+	}
+	if _, err := s.ChangePassword(ctx, alice.ID, "hash", "other"); !errors.As(err, &conflict) {
+		t.Fatalf("ChangePassword with a stale hash = %v, want ErrConflict", err)
+	}
+	if hash, _ := s.GetPasswordHash(ctx, alice.ID); hash != "changed" {
+		t.Fatalf("hash = %q, want changed", hash)
+	}
+	if err := s.UpdatePasswordHash(ctx, 999, "hash", "rehashed"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("UpdatePasswordHash for an unknown user = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDuplicateClientMessageID(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice, _ := s.CreateUser(ctx, "alice", "alice@example.com", "hash")
+	room := s.AddRoom("general", "", nil)
+
+	msg := store.Message{RoomID: room.ID, UserID: alice.ID, Content: "hi", ClientMsgID: "c1"}
+	if err := s.InsertMessage(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	retry := store.Message{RoomID: room.ID, UserID: alice.ID, Content: "hi", ClientMsgID: "c1"}
+	var unique *store.ErrUniqueViolation
+	if err := s.InsertMessage(ctx, &retry); !errors.As(err, &unique) || unique.Field != "client_msg_id" {
+		t.Fatalf("err = %v, want unique violation on client_msg_id", err)
+	}
+}
+
+func createUserErr(s *Store, username, email string) error {
+	_, err := s.CreateUser(context.Background(), username, email, "hash")
+	return err
+}
+
+func getUserErr(s *Store, id int) error {
+	_, err := s.GetUser(context.Background(), id)
+	return err
+}
+
+func getRoomErr(s *Store, id int) error {
+	_, err := s.GetRoom(context.Background(), id)
+	return err
+}
+
+func getMessageErr(s *Store, id int) error {
+	_, err := s.GetMessage(context.Background(), id)
+	return err
+}
+
+func joinErr(s *Store, roomID, userID int) error {
+	_, err := s.JoinRoom(context.Background(), roomID, userID)
+	return err
+}
+
+// TestAnonymizeUserRemovesPersonalData 删除账号后与用户相关的通知、提及、通知级别、屏蔽关系和私信会话都被删除，
+// 聊天室中的消息保留但不再关联用户
+func TestAnonymizeUserRemovesPersonalData(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice, _ := s.CreateUser(ctx, "alice", "alice@example.com", "hash")
+	bob, _ := s.CreateUser(ctx, "bob", "bob@example.com", "hash")
+	room := s.AddRoom("general", "", &alice.ID)
+
+	msg := &store.Message{RoomID: room.ID, UserID: bob.ID, Content: "hi @alice"}
+	if err := s.InsertMessage(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	mention := &store.Message{RoomID: room.ID, UserID: bob.ID, Content: "@alice"}
+	if err := s.InsertMessage(ctx, mention); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateMentionNotifications(ctx, mention.ID, []string{"alice"}, bob.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetRoomNotificationLevel(ctx, alice.ID, room.ID, store.NotificationLevelMuted); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BlockUser(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	conv, _, err := s.GetOrCreateConversation(ctx, alice.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct := &store.Message{ConversationID: &conv.ID, UserID: alice.ID, Content: "secret"}
+	if err := s.InsertMessage(ctx, direct); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarkConversationRead(ctx, bob.ID, conv.ID, direct.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AnonymizeUser(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := s.ListNotifications(ctx, alice.ID, store.NotificationQuery{}); len(n) != 0 {
+		t.Fatalf("notifications = %+v, want none", n)
+	}
+	if m, _ := s.ListMentions(ctx, alice.ID, 10, 0); len(m) != 0 {
+		t.Fatalf("mentions = %+v, want none", m)
+	}
+	if level, _ := s.GetRoomNotificationLevel(ctx, alice.ID, room.ID); level == store.NotificationLevelMuted {
+		t.Fatal("notification level was kept")
+	}
+	if ids, _ := s.ListBlockedUserIDs(ctx, bob.ID); len(ids) != 0 {
+		t.Fatalf("bob still blocks %v", ids)
+	}
+	if _, err := s.GetConversation(ctx, conv.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("conversation err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetMessage(ctx, direct.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("direct message err = %v, want ErrNotFound", err)
+	}
+	if len(s.conversationReads) != 0 {
+		t.Fatalf("conversation reads = %v, want none", s.conversationReads)
+	}
+	if _, err := s.GetMessage(ctx, msg.ID); err != nil {
+		t.Fatalf("room message was deleted: %v", err)
+	}
+}
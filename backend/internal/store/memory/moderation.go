@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+)
+
+var _ store.ModerationStore = (*Store)(nil)
+
+// memberIndex 返回成员在 s.members 中的位置，不是成员时返回 -1
+func (s *Store) memberIndex(roomID, userID int) int {
+	for i, m := range s.members {
+		if m.roomID == roomID && m.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) SetMemberRole(ctx context.Context, action store.ModerationAction, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.memberIndex(action.RoomID, action.TargetID)
+	if i < 0 {
+		return store.ErrNotFound
+	}
+	s.members[i].Role = role
+	s.moderationLog = append(s.moderationLog, action)
+	return nil
+}
+
+func (s *Store) KickMember(ctx context.Context, action store.ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.memberIndex(action.RoomID, action.TargetID)
+	if i < 0 {
+		return store.ErrNotFound
+	}
+	s.members = append(s.members[:i], s.members[i+1:]...)
+	s.moderationLog = append(s.moderationLog, action)
+	return nil
+}
+
+func (s *Store) BanMember(ctx context.Context, action store.ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.memberIndex(action.RoomID, action.TargetID); i >= 0 {
+		s.members = append(s.members[:i], s.members[i+1:]...)
+	}
+	s.bans[[2]int{action.RoomID, action.TargetID}] = action.Reason
+	s.moderationLog = append(s.moderationLog, action)
+	return nil
+}
+
+func (s *Store) UnbanMember(ctx context.Context, action store.ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]int{action.RoomID, action.TargetID}
+	if _, ok := s.bans[key]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.bans, key)
+	s.moderationLog = append(s.moderationLog, action)
+	return nil
+}
+
+func (s *Store) IsBanned(ctx context.Context, roomID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, banned := s.bans[[2]int{roomID, userID}]
+	return banned, nil
+}
+
+// DeleteRoomMessages 内存实现没有软删除，直接移除消息
+func (s *Store) DeleteRoomMessages(ctx context.Context, roomID int, messageIDs []int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remove := make(map[int]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		remove[id] = true
+	}
+	found := 0
+	for _, msg := range s.messages {
+		if remove[msg.ID] && msg.RoomID == roomID {
+			found++
+		}
+	}
+	if found != len(remove) {
+		return nil, store.ErrNotFound
+	}
+
+	deleted := []int{}
+	messages := s.messages[:0]
+	for _, msg := range s.messages {
+		if remove[msg.ID] {
+			deleted = append(deleted, msg.ID)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	s.messages = messages
+	return deleted, nil
+}
+
+func (s *Store) GetProfanitySettings(ctx context.Context, roomID int) (store.ProfanitySettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings, ok := s.profanity[roomID]; ok {
+		return settings, nil
+	}
+	return store.ProfanitySettings{Action: store.ProfanityBlock}, nil
+}
+
+func (s *Store) SaveProfanitySettings(ctx context.Context, roomID int, settings store.ProfanitySettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return &store.ErrForeignKey{Field: "room_id"}
+	}
+	s.profanity[roomID] = settings
+	return nil
+}
+
+func (s *Store) RecordContentFilterDecision(ctx context.Context, d store.ContentFilterDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filterDecisions = append(s.filterDecisions, d)
+	return nil
+}
@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.NotificationStore = (*Store)(nil)
+
+type mentionKey struct {
+	messageID int
+	userID    int
+}
+
+// notificationView 与 postgres 的 notificationColumns 一致：来源优先为 ActorID 对应的用户，其次为消息发送者，
+// 内容优先为消息内容，其次为管理操作的原因，没有 payload 时与数据库默认值一样为空对象
+func (s *Store) notificationView(n store.Notification) store.Notification {
+	view := n
+	view.ActorID = 0
+	if len(view.Payload) == 0 {
+		view.Payload = json.RawMessage("{}")
+	}
+	if n.MessageID != 0 {
+		for _, msg := range s.messages {
+			if msg.ID == n.MessageID {
+				if view.RoomID == 0 {
+					view.RoomID = msg.RoomID
+				}
+				if msg.ConversationID != nil {
+					view.ConversationID = *msg.ConversationID
+				}
+				view.FromUsername = msg.Username
+				if name := s.username(msg.UserID); name != "" {
+					view.FromUsername = name
+				}
+				view.Content = msg.Content
+			}
+		}
+	}
+	if name := s.username(n.ActorID); name != "" {
+		view.FromUsername = name
+	}
+	return view
+}
+
+func (s *Store) CreateMentionNotifications(ctx context.Context, messageID int, usernames []string, excludeUserID int, payload json.RawMessage) ([]store.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var roomID int
+	for _, msg := range s.messages {
+		if msg.ID == messageID {
+			roomID = msg.RoomID
+		}
+	}
+	created := []store.Notification{}
+	for _, u := range s.users {
+		if u.ID == excludeUserID || !contains(usernames, u.Username) {
+			continue
+		}
+		s.mentions[mentionKey{messageID: messageID, userID: u.ID}] = true
+		if s.levels[[2]int{u.ID, roomID}] == store.NotificationLevelMuted {
+			continue
+		}
+		n := s.addNotification(store.Notification{UserID: u.ID, Type: store.NotificationMention, MessageID: messageID, Payload: payload})
+		created = append(created, s.notificationView(n))
+	}
+	return created, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) addNotification(n store.Notification) store.Notification {
+	s.nextNotificationID++
+	n.ID = s.nextNotificationID
+	n.CreatedAt = time.Now()
+	s.notifications = append(s.notifications, n)
+	return n
+}
+
+func (s *Store) ListMentions(ctx context.Context, userID, limit, offset int) ([]store.Message, error) {
+	messages := s.listMessages(func(msg store.Message) bool {
+		return s.mentions[mentionKey{messageID: msg.ID, userID: userID}]
+	}, userID)
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+	if offset >= len(messages) {
+		return []store.Message{}, nil
+	}
+	messages = messages[offset:]
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (s *Store) CreateNotification(ctx context.Context, n store.Notification) (store.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.username(n.UserID) == "" {
+		return store.Notification{}, &store.ErrForeignKey{Field: "user_id"}
+	}
+	n.Read = false
+	return s.notificationView(s.addNotification(n)), nil
+}
+
+func (s *Store) ListNotifications(ctx context.Context, userID int, q store.NotificationQuery) ([]store.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications := []store.Notification{}
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		n := s.notifications[i]
+		if n.UserID != userID || (q.UnreadOnly && n.Read) || (q.BeforeID != 0 && n.ID >= q.BeforeID) {
+			continue
+		}
+		notifications = append(notifications, s.notificationView(n))
+	}
+	if q.Offset >= len(notifications) {
+		return []store.Notification{}, nil
+	}
+	notifications = notifications[q.Offset:]
+	if len(notifications) > q.Limit {
+		notifications = notifications[:q.Limit]
+	}
+	return notifications, nil
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.notifications {
+		if s.notifications[i].ID == id && s.notifications[i].UserID == userID {
+			s.notifications[i].Read = true
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marked := 0
+	for i := range s.notifications {
+		n := &s.notifications[i]
+		if n.UserID != userID || n.Read {
+			continue
+		}
+		for _, id := range ids {
+			if n.ID == id {
+				n.Read = true
+				marked++
+				break
+			}
+		}
+	}
+	return marked, nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	marked := 0
+	for i := range s.notifications {
+		if s.notifications[i].UserID == userID && !s.notifications[i].Read {
+			s.notifications[i].Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID int) (store.NotificationCounts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var counts store.NotificationCounts
+	for _, n := range s.notifications {
+		if n.UserID != userID || n.Read {
+			continue
+		}
+		counts.Unread++
+		if n.Type == store.NotificationMention {
+			counts.Mentions++
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) GetRoomNotificationLevel(ctx context.Context, userID, roomID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level, ok := s.levels[[2]int{userID, roomID}]; ok {
+		return level, nil
+	}
+	return store.NotificationLevelAll, nil
+}
+
+func (s *Store) SetRoomNotificationLevel(ctx context.Context, userID, roomID int, level string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if level == store.NotificationLevelAll {
+		delete(s.levels, [2]int{userID, roomID})
+		return nil
+	}
+	s.levels[[2]int{userID, roomID}] = level
+	return nil
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.PinStore = (*Store)(nil)
+
+type pin struct {
+	roomID    int
+	messageID int
+	// pinnedBy 为 0 表示社区自动置顶
+	pinnedBy int
+	pinnedAt time.Time
+}
+
+func (s *Store) GetAutoPinSettings(ctx context.Context, roomID int) (store.AutoPinSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings, ok := s.autoPins[roomID]; ok {
+		return settings, nil
+	}
+	return store.AutoPinSettings{Emoji: store.DefaultAutoPinEmoji}, nil
+}
+
+func (s *Store) SaveAutoPinSettings(ctx context.Context, roomID int, settings store.AutoPinSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		return &store.ErrForeignKey{Field: "room_id"}
+	}
+	s.autoPins[roomID] = settings
+	return nil
+}
+
+func (s *Store) pinIndex(roomID, messageID int) int {
+	for i, p := range s.pins {
+		if p.roomID == roomID && p.messageID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) roomPinCount(roomID int) int {
+	n := 0
+	for _, p := range s.pins {
+		if p.roomID == roomID {
+			n++
+		}
+	}
+	return n
+}
+
+// ApplyAutoPin 在锁内计数和修改，与 postgres 实现锁定 chat_rooms 行一样，并发调用只产生一次变化
+func (s *Store) ApplyAutoPin(ctx context.Context, roomID, messageID int, settings store.AutoPinSettings, maxPins int) (*store.PinChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, r := range s.reactions {
+		if r.messageID == messageID && r.emoji == settings.Emoji {
+			count++
+		}
+	}
+
+	i := s.pinIndex(roomID, messageID)
+	if count >= settings.Threshold {
+		if i >= 0 || s.roomPinCount(roomID) >= maxPins {
+			return nil, nil
+		}
+		p := pin{roomID: roomID, messageID: messageID, pinnedAt: time.Now()}
+		s.pins = append(s.pins, p)
+		return &store.PinChange{MessageID: messageID, Pinned: true, PinnedAt: p.pinnedAt}, nil
+	}
+	// 只取消由社区自动置顶的消息
+	if i < 0 || s.pins[i].pinnedBy != 0 {
+		return nil, nil
+	}
+	s.pins = append(s.pins[:i], s.pins[i+1:]...)
+	return &store.PinChange{MessageID: messageID}, nil
+}
+
+func (s *Store) PinMessage(ctx context.Context, roomID, messageID, userID, maxPins int) (*store.PinChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pinIndex(roomID, messageID) >= 0 {
+		return nil, nil
+	}
+	if s.roomPinCount(roomID) >= maxPins {
+		return nil, store.ErrLimitExceeded
+	}
+	p := pin{roomID: roomID, messageID: messageID, pinnedBy: userID, pinnedAt: time.Now()}
+	s.pins = append(s.pins, p)
+	return &store.PinChange{MessageID: messageID, Pinned: true, PinnedAt: p.pinnedAt}, nil
+}
+
+func (s *Store) UnpinMessage(ctx context.Context, roomID, messageID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.pinIndex(roomID, messageID)
+	if i < 0 {
+		return false, nil
+	}
+	s.pins = append(s.pins[:i], s.pins[i+1:]...)
+	return true, nil
+}
+
+func (s *Store) ListPinnedMessages(ctx context.Context, roomID, viewerID int) ([]store.PinnedMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pinned := []store.PinnedMessage{}
+	for _, p := range s.pins {
+		if p.roomID != roomID {
+			continue
+		}
+		for _, msg := range s.messages {
+			if msg.ID == p.messageID {
+				s.fillSender(&msg)
+				msg.Reactions = s.summarize(msg.ID, viewerID)
+				pinned = append(pinned, store.PinnedMessage{Message: msg, PinnedBy: s.username(p.pinnedBy), PinnedAt: p.pinnedAt})
+			}
+		}
+	}
+	sort.SliceStable(pinned, func(i, j int) bool { return pinned[i].PinnedAt.Before(pinned[j].PinnedAt) })
+	return pinned, nil
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.PollStore = (*Store)(nil)
+
+type pollVote struct {
+	pollID int
+	userID int
+	option int
+}
+
+func (s *Store) CreatePoll(ctx context.Context, poll *store.Poll) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[poll.RoomID]; !ok {
+		return &store.ErrForeignKey{Field: "room_id"}
+	}
+	poll.ID = len(s.polls) + 1
+	poll.CreatedAt = time.Now()
+	stored := *poll
+	stored.Options = make([]store.PollOption, len(poll.Options))
+	for i, opt := range poll.Options {
+		stored.Options[i] = store.PollOption{Text: opt.Text}
+	}
+	stored.MyVotes = nil
+	s.polls = append(s.polls, stored)
+	return nil
+}
+
+// GetPoll 与 postgres 一样按投票顺序统计票数和投票者
+func (s *Store) GetPoll(ctx context.Context, id, viewerID int) (store.Poll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.polls) {
+		return store.Poll{}, store.ErrNotFound
+	}
+	poll := s.polls[id-1]
+	poll.Options = append([]store.PollOption(nil), poll.Options...)
+	poll.Closed = !poll.EndsAt.After(time.Now())
+	poll.MyVotes = []int{}
+
+	voters := make(map[int]bool)
+	for _, v := range s.pollVotes {
+		if v.pollID != id || v.option >= len(poll.Options) {
+			continue
+		}
+		opt := &poll.Options[v.option]
+		opt.Votes++
+		if !poll.Anonymous {
+			opt.Voters = append(opt.Voters, s.username(v.userID))
+		}
+		voters[v.userID] = true
+		if viewerID != 0 && v.userID == viewerID {
+			poll.MyVotes = append(poll.MyVotes, v.option)
+		}
+	}
+	poll.TotalVoters = len(voters)
+	return poll, nil
+}
+
+func (s *Store) VotePoll(ctx context.Context, pollID, userID int, options []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pollID < 1 || pollID > len(s.polls) {
+		return store.ErrNotFound
+	}
+	if !s.polls[pollID-1].EndsAt.After(time.Now()) {
+		return store.ErrPollClosed
+	}
+	votes := s.pollVotes[:0]
+	for _, v := range s.pollVotes {
+		if v.pollID != pollID || v.userID != userID {
+			votes = append(votes, v)
+		}
+	}
+	for _, option := range options {
+		votes = append(votes, pollVote{pollID: pollID, userID: userID, option: option})
+	}
+	s.pollVotes = votes
+	return nil
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+var _ store.StatsStore = (*Store)(nil)
+
+// RoomStats 与 postgres 实现的统计口径相同，按本地时区划分小时
+func (s *Store) RoomStats(ctx context.Context, roomID int) (store.RoomStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	stats := store.RoomStats{TopUsers: []store.UserMessageCount{}, HourlyMessages: make([]int, 24)}
+	counts := make(map[int]int)
+	for _, m := range s.messages {
+		if m.RoomID != roomID || m.ConversationID != nil {
+			continue
+		}
+		stats.MessageCount++
+		// 系统消息和 webhook 消息没有发送者，与 postgres 中的 NULL 一样不计入用户
+		if m.UserID != 0 {
+			counts[m.UserID]++
+		}
+		if m.CreatedAt.After(now.Add(-24 * time.Hour)) {
+			stats.MessagesLast24h++
+		}
+		if m.CreatedAt.After(now.Add(-7 * 24 * time.Hour)) {
+			stats.MessagesLast7d++
+			stats.HourlyMessages[m.CreatedAt.Local().Hour()]++
+		}
+	}
+	stats.UniqueUsers = len(counts)
+
+	for userID, n := range counts {
+		stats.TopUsers = append(stats.TopUsers, store.UserMessageCount{UserID: userID, Username: s.username(userID), MessageCount: n})
+	}
+	sort.Slice(stats.TopUsers, func(i, j int) bool {
+		a, b := stats.TopUsers[i], stats.TopUsers[j]
+		if a.MessageCount != b.MessageCount {
+			return a.MessageCount > b.MessageCount
+		}
+		return a.Username < b.Username
+	})
+	if len(stats.TopUsers) > 5 {
+		stats.TopUsers = stats.TopUsers[:5]
+	}
+	stats.GeneratedAt = now
+	return stats, nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// seedMessage 保存一条指定发送时间的消息
+func seedMessage(t *testing.T, s *Store, roomID, userID int, createdAt time.Time) {
+	t.Helper()
+	msg := store.Message{RoomID: roomID, UserID: userID, Content: "hi"}
+	if err := s.InsertMessage(context.Background(), &msg); err != nil {
+		t.Fatal(err)
+	}
+	s.messages[len(s.messages)-1].CreatedAt = createdAt
+}
+
+// TestRoomStatsBuckets 按小时统计过去 7 天的消息，更早的消息只计入总数
+func TestRoomStatsBuckets(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	var users []store.User
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		u, err := s.CreateUser(ctx, name, name+"@example.com", "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	room := s.AddRoom("general", "", &users[0].ID)
+	other := s.AddRoom("random", "", &users[0].ID)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	// 两天前 9 点 3 条，三天前 9 点 1 条，四天前 17 点 2 条，十天前 9 点 4 条（不计入小时统计）
+	for i := 0; i < 3; i++ {
+		seedMessage(t, s, room.ID, users[0].ID, today.AddDate(0, 0, -2).Add(9*time.Hour+time.Duration(i)*time.Minute))
+	}
+	seedMessage(t, s, room.ID, users[1].ID, today.AddDate(0, 0, -3).Add(9*time.Hour))
+	seedMessage(t, s, room.ID, users[1].ID, today.AddDate(0, 0, -4).Add(17*time.Hour))
+	seedMessage(t, s, room.ID, users[2].ID, today.AddDate(0, 0, -4).Add(17*time.Hour+30*time.Minute))
+	for i, u := range users[2:] {
+		seedMessage(t, s, room.ID, u.ID, today.AddDate(0, 0, -10).Add(9*time.Hour+time.Duration(i)*time.Minute))
+	}
+	// 最近一小时 1 条，其他聊天室的消息不计入
+	recent := now.Add(-time.Hour)
+	seedMessage(t, s, room.ID, users[0].ID, recent)
+	seedMessage(t, s, other.ID, users[0].ID, recent)
+
+	stats, err := s.RoomStats(ctx, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]int, 24)
+	want[9] = 4
+	want[17] = 2
+	want[recent.Hour()]++
+	if len(stats.HourlyMessages) != 24 {
+		t.Fatalf("%d hourly buckets, want 24", len(stats.HourlyMessages))
+	}
+	for hour := range want {
+		if stats.HourlyMessages[hour] != want[hour] {
+			t.Fatalf("hourly messages = %v, want %v", stats.HourlyMessages, want)
+		}
+	}
+	if stats.MessageCount != 11 || stats.MessagesLast7d != 7 || stats.MessagesLast24h != 1 || stats.UniqueUsers != 6 {
+		t.Fatalf("stats = %+v, want 11 messages, 7 in 7 days, 1 in 24 hours from 6 users", stats)
+	}
+
+	// 消息数相同时按用户名排序，最多 5 个
+	wantTop := []string{"alice:4", "bob:2", "carol:2", "dave:1", "erin:1"}
+	if len(stats.TopUsers) != len(wantTop) {
+		t.Fatalf("top users = %+v", stats.TopUsers)
+	}
+	for i, u := range stats.TopUsers {
+		if got := fmt.Sprintf("%s:%d", u.Username, u.MessageCount); got != wantTop[i] {
+			t.Fatalf("top users = %+v, want %v", stats.TopUsers, wantTop)
+		}
+	}
+}
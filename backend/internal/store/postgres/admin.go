@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"chatapp/internal/store"
+)
+
+// adminUserColumns 与 scanAdminUser 的字段顺序一致
+const adminUserColumns = "id, username, COALESCE(email, ''), display_name, is_admin, disabled_at IS NOT NULL, created_at"
+
+func scanAdminUser(row scanner, user *store.AdminUser) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.DisplayName, &user.IsAdmin, &user.Disabled, &user.CreatedAt)
+}
+
+func (s *Store) ListUsers(ctx context.Context, search string, limit, offset int) ([]store.AdminUser, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	pattern := likePattern(search)
+	const filter = "deleted_at IS NULL AND ($1 = '' OR username ILIKE $1 OR email ILIKE $1)"
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+filter, pattern).Scan(&total); err != nil {
+		return nil, 0, s.mapError(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+adminUserColumns+` FROM users
+		WHERE `+filter+`
+		ORDER BY id
+		LIMIT $2 OFFSET $3
+	`, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, s.mapError(err)
+	}
+	defer rows.Close()
+
+	users := []store.AdminUser{}
+	for rows.Next() {
+		var user store.AdminUser
+		if err := scanAdminUser(rows, &user); err != nil {
+			return nil, 0, s.mapError(err)
+		}
+		users = append(users, user)
+	}
+	return users, total, s.mapError(rows.Err())
+}
+
+func (s *Store) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var isAdmin bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT is_admin FROM users WHERE id = $1 AND disabled_at IS NULL",
+		userID,
+	).Scan(&isAdmin)
+	return isAdmin, s.mapError(err)
+}
+
+func (s *Store) SetUserDisabled(ctx context.Context, userID int, disabled bool) (store.AdminUser, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 已经停用的用户再次停用时保留原来的时间，不递增 token_version
+	var user store.AdminUser
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE users SET
+			disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END,
+			token_version = token_version + CASE WHEN $2 AND disabled_at IS NULL THEN 1 ELSE 0 END,
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+adminUserColumns,
+		userID, disabled,
+	)
+	return user, s.mapError(scanAdminUser(row, &user))
+}
+
+func (s *Store) AnonymizeUser(ctx context.Context, userID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	// 随机值不是有效的 bcrypt 哈希，任何密码都无法通过校验
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+
+	// 用户名有唯一约束，用 ID 生成不会冲突的值
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users SET
+			username = 'deleted_user_' || id,
+			email = NULL,
+			password_hash = $2,
+			display_name = '', bio = '', avatar_url = '', avatar_key = '', avatar_content_type = '',
+			is_admin = FALSE,
+			disabled_at = COALESCE(disabled_at, NOW()),
+			deleted_at = COALESCE(deleted_at, NOW()),
+			token_version = token_version + 1,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, hex.EncodeToString(random))
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+
+	// 未发送的定时消息和私信会话直接删除，会话中的消息和已读位置随会话级联删除
+	for _, query := range []string{
+		"DELETE FROM messages WHERE user_id = $1 AND status = 'scheduled'",
+		"DELETE FROM direct_conversations WHERE user_a_id = $1 OR user_b_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return s.mapError(err)
+		}
+	}
+
+	// 消息与用户断开关联，内容替换为 DeletedMessageContent，以 sender_name 显示为已删除的用户
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE messages SET user_id = NULL, sender_name = $2, content = $3 WHERE user_id = $1",
+		userID, store.DeletedUserName, store.DeletedMessageContent,
+	); err != nil {
+		return s.mapError(err)
+	}
+	for _, query := range []string{
+		"DELETE FROM room_members WHERE user_id = $1",
+		"DELETE FROM reactions WHERE user_id = $1",
+		"DELETE FROM stars WHERE user_id = $1",
+		"DELETE FROM message_mentions WHERE user_id = $1",
+		"DELETE FROM room_read_positions WHERE user_id = $1",
+		"DELETE FROM poll_votes WHERE user_id = $1",
+		"DELETE FROM notifications WHERE user_id = $1",
+		"DELETE FROM room_notification_settings WHERE user_id = $1",
+		"DELETE FROM push_devices WHERE user_id = $1",
+		"DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1",
+		"DELETE FROM password_reset_tokens WHERE user_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return s.mapError(err)
+		}
+	}
+	return s.mapError(tx.Commit())
+}
+
+func (s *Store) PromoteAdmin(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET is_admin = TRUE WHERE email = $1 AND deleted_at IS NULL",
+		email,
+	)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// attachmentColumns 与 scanAttachments 的字段顺序一致
+const attachmentColumns = "id, user_id, message_id, storage_key, filename, content_type, size, created_at, width, height, thumbnail_key, thumbnail_content_type"
+
+func scanAttachment(row scanner, att *store.Attachment) error {
+	err := row.Scan(&att.ID, &att.UserID, &att.MessageID, &att.StorageKey, &att.Filename, &att.ContentType, &att.Size, &att.CreatedAt,
+		&att.Width, &att.Height, &att.ThumbnailKey, &att.ThumbnailContentType)
+	att.SetURLs()
+	return err
+}
+
+func (s *Store) scanAttachments(rows *sql.Rows) ([]store.Attachment, error) {
+	defer rows.Close()
+
+	attachments := []store.Attachment{}
+	for rows.Next() {
+		var att store.Attachment
+		if err := scanAttachment(rows, &att); err != nil {
+			return nil, s.mapError(err)
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, s.mapError(rows.Err())
+}
+
+func (s *Store) CreateAttachment(ctx context.Context, att *store.Attachment) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO attachments (user_id, storage_key, filename, content_type, size, width, height)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id, created_at`,
+		att.UserID, att.StorageKey, att.Filename, att.ContentType, att.Size, att.Width, att.Height,
+	).Scan(&att.ID, &att.CreatedAt)
+	att.SetURLs()
+	return s.mapError(err)
+}
+
+func (s *Store) GetAttachment(ctx context.Context, id int) (store.Attachment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var att store.Attachment
+	row := s.db.QueryRowContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id = $1", id)
+	return att, s.mapError(scanAttachment(row, &att))
+}
+
+func (s *Store) GetAttachments(ctx context.Context, ids []int) ([]store.Attachment, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+attachmentColumns+" FROM attachments WHERE id = ANY($1) ORDER BY id", pq.Array(ids))
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return s.scanAttachments(rows)
+}
+
+// loadAttachments 为一组消息填充附件
+func (s *Store) loadAttachments(ctx context.Context, messages []store.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = int64(msg.ID)
+		index[msg.ID] = i
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+attachmentColumns+" FROM attachments WHERE message_id = ANY($1) ORDER BY id",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return s.mapError(err)
+	}
+	attachments, err := s.scanAttachments(rows)
+	if err != nil {
+		return err
+	}
+	for _, att := range attachments {
+		i := index[*att.MessageID]
+		messages[i].Attachments = append(messages[i].Attachments, att)
+	}
+	return nil
+}
+
+func (s *Store) SetAttachmentThumbnail(ctx context.Context, id int, key, contentType string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE attachments SET thumbnail_key = $2, thumbnail_content_type = $3 WHERE id = $1",
+		id, key, contentType,
+	)
+	return s.mapError(err)
+}
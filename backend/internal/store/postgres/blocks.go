@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) BlockUser(ctx context.Context, blockerID, blockedID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		blockerID, blockedID,
+	)
+	return s.mapError(err)
+}
+
+func (s *Store) UnblockUser(ctx context.Context, blockerID, blockedID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2",
+		blockerID, blockedID,
+	)
+	return s.mapError(err)
+}
+
+func (s *Store) ListBlockedUserIDs(ctx context.Context, blockerID int) ([]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT blocked_id FROM user_blocks WHERE blocker_id = $1 ORDER BY blocked_id", blockerID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, s.mapError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, s.mapError(rows.Err())
+}
+
+func (s *Store) ListBlockedUsers(ctx context.Context, blockerID int) ([]store.BlockedUser, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT u.id, u.username, u.display_name, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, u.id
+	`, blockerID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	users := []store.BlockedUser{}
+	for rows.Next() {
+		var u store.BlockedUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.BlockedAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		users = append(users, u)
+	}
+	return users, s.mapError(rows.Err())
+}
+
+func (s *Store) IsBlockedBetween(ctx context.Context, userA, userB int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`, userA, userB).Scan(&blocked)
+	return blocked, s.mapError(err)
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+)
+
+const botColumns = "id, name, owner_user_id, created_at, last_used_at"
+
+// botTouchInterval last_used_at 的更新间隔，频繁调用的机器人不会每个请求都写一次数据库
+const botTouchInterval = "1 minute"
+
+func scanBot(row scanner, bot *store.Bot) error {
+	return row.Scan(&bot.ID, &bot.Name, &bot.OwnerUserID, &bot.CreatedAt, &bot.LastUsedAt)
+}
+
+func (s *Store) CreateBot(ctx context.Context, bot *store.Bot, tokenHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO bots (name, owner_user_id, token_hash) VALUES ($1, $2, $3) RETURNING id, created_at",
+		bot.Name, bot.OwnerUserID, tokenHash,
+	).Scan(&bot.ID, &bot.CreatedAt)
+	return s.mapError(err)
+}
+
+func (s *Store) ListBots(ctx context.Context, ownerID int) ([]store.Bot, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+botColumns+" FROM bots WHERE owner_user_id = $1 ORDER BY created_at, id",
+		ownerID,
+	)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	bots := []store.Bot{}
+	for rows.Next() {
+		var bot store.Bot
+		if err := scanBot(rows, &bot); err != nil {
+			return nil, s.mapError(err)
+		}
+		bots = append(bots, bot)
+	}
+	return bots, s.mapError(rows.Err())
+}
+
+func (s *Store) RotateBotToken(ctx context.Context, id, ownerID int, tokenHash string) (store.Bot, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var bot store.Bot
+	err := scanBot(s.db.QueryRowContext(ctx,
+		"UPDATE bots SET token_hash = $3 WHERE id = $1 AND owner_user_id = $2 RETURNING "+botColumns,
+		id, ownerID, tokenHash,
+	), &bot)
+	return bot, s.mapError(err)
+}
+
+func (s *Store) DeleteBot(ctx context.Context, id, ownerID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM bots WHERE id = $1 AND owner_user_id = $2", id, ownerID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) AuthenticateBot(ctx context.Context, tokenHash string) (store.BotIdentity, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id store.BotIdentity
+	var stale bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT b.id, b.name, u.id, u.username, COALESCE(u.email, ''),
+			b.last_used_at IS NULL OR b.last_used_at < NOW() - INTERVAL '`+botTouchInterval+`'
+		FROM bots b
+		JOIN users u ON u.id = b.owner_user_id
+		WHERE b.token_hash = $1 AND u.disabled_at IS NULL AND u.deleted_at IS NULL
+	`, tokenHash).Scan(&id.BotID, &id.BotName, &id.UserID, &id.Username, &id.Email, &stale)
+	if err != nil {
+		return store.BotIdentity{}, s.mapError(err)
+	}
+
+	if stale {
+		if _, err := s.db.ExecContext(ctx, "UPDATE bots SET last_used_at = NOW() WHERE id = $1", id.BotID); err != nil {
+			return store.BotIdentity{}, s.mapError(err)
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) GetOrCreateConversation(ctx context.Context, userID, otherUserID int) (store.Conversation, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	a, b := userID, otherUserID
+	if a > b {
+		a, b = b, a
+	}
+
+	// 并发创建时 ON CONFLICT 不返回行，再查询一次已有的会话
+	conv := store.Conversation{UserAID: a, UserBID: b}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO direct_conversations (user_a_id, user_b_id) VALUES ($1, $2)
+		ON CONFLICT (user_a_id, user_b_id) DO NOTHING
+		RETURNING id, created_at
+	`, a, b).Scan(&conv.ID, &conv.CreatedAt)
+	if err == nil {
+		return conv, true, nil
+	}
+	if mapped := s.mapError(err); !errors.Is(mapped, store.ErrNotFound) {
+		return store.Conversation{}, false, mapped
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		"SELECT id, created_at FROM direct_conversations WHERE user_a_id = $1 AND user_b_id = $2",
+		a, b,
+	).Scan(&conv.ID, &conv.CreatedAt)
+	return conv, false, s.mapError(err)
+}
+
+func (s *Store) GetConversation(ctx context.Context, id int) (store.Conversation, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var conv store.Conversation
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, user_a_id, user_b_id, created_at FROM direct_conversations WHERE id = $1",
+		id,
+	).Scan(&conv.ID, &conv.UserAID, &conv.UserBID, &conv.CreatedAt)
+	return conv, s.mapError(err)
+}
+
+func (s *Store) ListConversations(ctx context.Context, userID int) ([]store.ConversationSummary, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.user_a_id, c.user_b_id, c.created_at, o.id, o.username,
+			lm.id, lm.user_id, lm.username, lm.content, lm.created_at,
+			(SELECT COUNT(*) FROM messages m
+			 WHERE m.conversation_id = c.id
+			   AND m.id > COALESCE(rp.last_read_message_id, 0)
+			   AND m.user_id <> $1
+			   AND m.deleted_at IS NULL
+			   AND m.status = 'sent')
+		FROM direct_conversations c
+		JOIN users o ON o.id = CASE WHEN c.user_a_id = $1 THEN c.user_b_id ELSE c.user_a_id END
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.user_id, u.username, m.content, m.created_at
+			FROM messages m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.conversation_id = c.id AND m.deleted_at IS NULL AND m.status = 'sent'
+			ORDER BY m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		LEFT JOIN conversation_read_positions rp ON rp.conversation_id = c.id AND rp.user_id = $1
+		WHERE c.user_a_id = $1 OR c.user_b_id = $1
+		ORDER BY COALESCE(lm.created_at, c.created_at) DESC
+	`, userID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	conversations := []store.ConversationSummary{}
+	for rows.Next() {
+		var c store.ConversationSummary
+		var lastID, lastUserID *int
+		var lastUsername, lastContent *string
+		var lastCreatedAt *time.Time
+		err := rows.Scan(&c.ID, &c.UserAID, &c.UserBID, &c.CreatedAt, &c.OtherUser.ID, &c.OtherUser.Username,
+			&lastID, &lastUserID, &lastUsername, &lastContent, &lastCreatedAt, &c.UnreadCount)
+		if err != nil {
+			return nil, s.mapError(err)
+		}
+		if lastID != nil {
+			convID := c.ID
+			c.LastMessage = &store.Message{
+				ID:             *lastID,
+				UserID:         *lastUserID,
+				Username:       *lastUsername,
+				Content:        *lastContent,
+				CreatedAt:      *lastCreatedAt,
+				ConversationID: &convID,
+				MessageType:    store.MessageTypeUser,
+			}
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, s.mapError(rows.Err())
+}
+
+func (s *Store) ListConversationMessages(ctx context.Context, conversationID, viewerID, limit, offset int) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 先取最新的一页，再按时间顺序返回
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT * FROM (
+			SELECT `+messageColumns+`
+			FROM messages m
+			JOIN users u ON m.user_id = u.id
+			WHERE m.conversation_id = $1 AND m.deleted_at IS NULL AND (m.status = 'sent' OR m.user_id = $4) AND `+notExpired+`
+			ORDER BY m.id DESC
+			LIMIT $2 OFFSET $3
+		) page ORDER BY id ASC
+	`, conversationID, limit, offset, viewerID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, userID, conversationID, messageID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO conversation_read_positions (user_id, conversation_id, last_read_message_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, conversation_id) DO UPDATE
+		SET last_read_message_id = EXCLUDED.last_read_message_id, updated_at = NOW()
+		WHERE conversation_read_positions.last_read_message_id < EXCLUDED.last_read_message_id
+	`, userID, conversationID, messageID)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"chatapp/internal/store"
+)
+
+// 导出查询逐行读取，持续时间取决于数据量和客户端的下载速度，因此不使用 QueryTimeout，
+// 请求取消时查询随 ctx 结束
+
+func (s *Store) GetExportProfile(ctx context.Context, userID int) (store.ExportProfile, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var p store.ExportProfile
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, COALESCE(email, ''), display_name, bio, avatar_url, is_admin, created_at
+		FROM users WHERE id = $1 AND deleted_at IS NULL
+	`, userID).Scan(&p.ID, &p.Username, &p.Email, &p.DisplayName, &p.Bio, &p.AvatarURL, &p.IsAdmin, &p.CreatedAt)
+	return p, s.mapError(err)
+}
+
+func (s *Store) ExportRoomMessages(ctx context.Context, userID int, fn func(store.ExportedMessage) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.room_id, r.name, m.content, m.parent_message_id, m.created_at, m.deleted_at
+		FROM messages m
+		JOIN chat_rooms r ON r.id = m.room_id
+		WHERE m.user_id = $1
+		ORDER BY m.id
+	`, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return s.eachRow(rows, func(rows *sql.Rows) error {
+		var msg store.ExportedMessage
+		if err := rows.Scan(&msg.ID, &msg.RoomID, &msg.RoomName, &msg.Content, &msg.ParentID, &msg.CreatedAt, &msg.DeletedAt); err != nil {
+			return err
+		}
+		return fn(msg)
+	})
+}
+
+func (s *Store) ExportDirectMessages(ctx context.Context, userID int, fn func(store.ExportedMessage) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, COALESCE(u.username, ''), m.content, m.parent_message_id, m.created_at, m.deleted_at
+		FROM messages m
+		JOIN direct_conversations c ON c.id = m.conversation_id
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE c.user_a_id = $1 OR c.user_b_id = $1
+		ORDER BY m.id
+	`, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return s.eachRow(rows, func(rows *sql.Rows) error {
+		var msg store.ExportedMessage
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Sender, &msg.Content, &msg.ParentID, &msg.CreatedAt, &msg.DeletedAt); err != nil {
+			return err
+		}
+		return fn(msg)
+	})
+}
+
+func (s *Store) ExportReactions(ctx context.Context, userID int, fn func(store.ExportedReaction) error) error {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT message_id, emoji, created_at FROM reactions WHERE user_id = $1 ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return s.eachRow(rows, func(rows *sql.Rows) error {
+		var r store.ExportedReaction
+		if err := rows.Scan(&r.MessageID, &r.Emoji, &r.CreatedAt); err != nil {
+			return err
+		}
+		return fn(r)
+	})
+}
+
+func (s *Store) ExportNotifications(ctx context.Context, userID int, fn func(store.Notification) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications n`+notificationJoins+`
+		WHERE n.user_id = $1
+		ORDER BY n.id
+	`, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return s.eachRow(rows, func(rows *sql.Rows) error {
+		var n store.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return err
+		}
+		return fn(n)
+	})
+}
+
+func (s *Store) RecordUserAudit(ctx context.Context, userID int, action string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "INSERT INTO user_audit_log (user_id, action) VALUES ($1, $2)", userID, action)
+	return s.mapError(err)
+}
+
+// eachRow 对每一行调用 fn 并关闭 rows。fn 的错误大多来自写响应，原样返回，不计入数据库错误
+func (s *Store) eachRow(rows *sql.Rows, fn func(*sql.Rows) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return s.mapError(rows.Err())
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+const inviteColumns = "id, room_id, created_by, max_uses, use_count, expires_at, disabled, created_at"
+
+func scanInvite(row scanner, inv *store.RoomInvite) error {
+	return row.Scan(&inv.ID, &inv.RoomID, &inv.CreatedBy, &inv.MaxUses, &inv.UseCount, &inv.ExpiresAt, &inv.Disabled, &inv.CreatedAt)
+}
+
+func (s *Store) CreateInvite(ctx context.Context, invite *store.RoomInvite) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO room_invites (room_id, created_by, max_uses, expires_at)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id, use_count, created_at`,
+		invite.RoomID, invite.CreatedBy, invite.MaxUses, invite.ExpiresAt,
+	).Scan(&invite.ID, &invite.UseCount, &invite.CreatedAt)
+	return s.mapError(err)
+}
+
+func (s *Store) GetInvite(ctx context.Context, id string) (store.RoomInvite, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var inv store.RoomInvite
+	err := scanInvite(s.db.QueryRowContext(ctx, "SELECT "+inviteColumns+" FROM room_invites WHERE id = $1", id), &inv)
+	return inv, s.mapError(err)
+}
+
+func (s *Store) ListActiveInvites(ctx context.Context, roomID int) ([]store.RoomInvite, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+inviteColumns+`
+		FROM room_invites
+		WHERE room_id = $1 AND NOT disabled
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND (max_uses IS NULL OR use_count < max_uses)
+		ORDER BY created_at DESC, id
+	`, roomID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	invites := []store.RoomInvite{}
+	for rows.Next() {
+		var inv store.RoomInvite
+		if err := scanInvite(rows, &inv); err != nil {
+			return nil, s.mapError(err)
+		}
+		invites = append(invites, inv)
+	}
+	return invites, s.mapError(rows.Err())
+}
+
+// AcceptInvite 锁定邀请行，并发接受同一个邀请时使用次数不会超过 max_uses
+func (s *Store) AcceptInvite(ctx context.Context, id string, userID int) (int, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	var inv store.RoomInvite
+	var now time.Time
+	err = tx.QueryRowContext(ctx,
+		"SELECT room_id, max_uses, use_count, expires_at, disabled, NOW() FROM room_invites WHERE id = $1 FOR UPDATE",
+		id,
+	).Scan(&inv.RoomID, &inv.MaxUses, &inv.UseCount, &inv.ExpiresAt, &inv.Disabled, &now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, false, s.mapError(err)
+	}
+
+	switch {
+	case inv.Disabled:
+		return 0, false, store.ErrInviteDisabled
+	case inv.ExpiresAt != nil && !inv.ExpiresAt.After(now):
+		return 0, false, store.ErrInviteExpired
+	case inv.MaxUses != nil && inv.UseCount >= *inv.MaxUses:
+		return 0, false, store.ErrInviteExhausted
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO room_members (room_id, user_id)
+		 SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM room_bans WHERE room_id = $1 AND user_id = $2)
+		 ON CONFLICT (room_id, user_id) DO NOTHING`,
+		inv.RoomID, userID,
+	)
+	if err != nil {
+		return 0, false, s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return inv.RoomID, false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE room_invites SET use_count = use_count + 1 WHERE id = $1", id); err != nil {
+		return 0, false, s.mapError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, s.mapError(err)
+	}
+	return inv.RoomID, true, nil
+}
+
+func (s *Store) DisableInvite(ctx context.Context, id string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE room_invites SET disabled = TRUE WHERE id = $1", id)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) JoinRoom(ctx context.Context, roomID, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 被封禁的用户不会被加入，调用方应先通过 IsBanned 给出明确的错误
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_members (room_id, user_id)
+		 SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM room_bans WHERE room_id = $1 AND user_id = $2)
+		 ON CONFLICT (room_id, user_id) DO NOTHING`,
+		roomID, userID,
+	)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) LeaveRoom(ctx context.Context, roomID, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM room_members WHERE room_id = $1 AND user_id = $2", roomID, userID)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) IsRoomMember(ctx context.Context, roomID, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var member bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM room_members WHERE room_id = $1 AND user_id = $2)",
+		roomID, userID,
+	).Scan(&member)
+	return member, s.mapError(err)
+}
+
+func (s *Store) CountRoomMembers(ctx context.Context, roomID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM room_members WHERE room_id = $1", roomID).Scan(&count)
+	return count, s.mapError(err)
+}
+
+func (s *Store) ListRoomMembers(ctx context.Context, roomID, limit, offset int) ([]store.RoomMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT rm.user_id, u.username, rm.role, rm.joined_at, u.last_seen_at
+		FROM room_members rm
+		JOIN users u ON u.id = rm.user_id
+		WHERE rm.room_id = $1
+		ORDER BY rm.joined_at, rm.id
+		LIMIT $2 OFFSET $3
+	`, roomID, limit, offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	members := []store.RoomMember{}
+	for rows.Next() {
+		var m store.RoomMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt, &m.LastSeenAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		members = append(members, m)
+	}
+	return members, s.mapError(rows.Err())
+}
+
+func (s *Store) ListUserRoomIDs(ctx context.Context, userID int) ([]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT room_id FROM room_members WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, s.mapError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, s.mapError(rows.Err())
+}
+
+func (s *Store) GetMemberRole(ctx context.Context, roomID, userID int) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var role string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2",
+		roomID, userID,
+	).Scan(&role)
+	return role, s.mapError(err)
+}
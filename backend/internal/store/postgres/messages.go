@@ -0,0 +1,427 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// messageColumns 与 scanMessage 的字段顺序一致
+const messageColumns = `m.id, COALESCE(m.room_id, 0), COALESCE(m.user_id, 0),
+	COALESCE(u.username, m.sender_name), COALESCE(u.display_name, m.sender_name), m.content, m.parent_message_id,
+	(SELECT COUNT(*) FROM messages rc WHERE rc.parent_message_id = m.id AND rc.deleted_at IS NULL AND rc.status = 'sent'),
+	m.conversation_id, m.created_at, CASE WHEN m.status = 'scheduled' THEN m.scheduled_at END, m.ttl_seconds, m.expires_at,
+	m.incoming_webhook_id, m.message_type, m.metadata`
+
+// notExpired 排除已经到期、但还没有被后台任务删除的消息
+const notExpired = "(m.expires_at IS NULL OR m.expires_at > NOW())"
+
+// notBlockedBy 排除 viewer 屏蔽的用户发送的消息，viewer 为查询参数的占位符
+func notBlockedBy(viewer string) string {
+	return "NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = " + viewer + " AND b.blocked_id = m.user_id)"
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row scanner, msg *store.Message, extra ...interface{}) error {
+	dest := append([]interface{}{&msg.ID, &msg.RoomID, &msg.UserID, &msg.Username, &msg.DisplayName, &msg.Content,
+		&msg.ParentMessageID, &msg.ReplyCount, &msg.ConversationID, &msg.CreatedAt, &msg.ScheduledAt,
+		&msg.TTLSeconds, &msg.ExpiresAt, &msg.IncomingWebhookID, &msg.MessageType, (*[]byte)(&msg.Metadata)}, extra...)
+	return row.Scan(dest...)
+}
+
+func (s *Store) InsertMessage(ctx context.Context, msg *store.Message) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	// 同时返回发送者当前的用户名和显示名称，广播的消息与历史记录一致
+	query := `
+		WITH ins AS (
+			INSERT INTO messages (room_id, user_id, content, parent_message_id, conversation_id, client_msg_id, scheduled_at, status, ttl_seconds,
+				incoming_webhook_id, sender_name, message_type, metadata)
+			VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5, NULLIF($6, '')::uuid, $7::timestamptz,
+				CASE WHEN $7::timestamptz IS NULL THEN 'sent' ELSE 'scheduled' END, $8,
+				$9, NULLIF($10, ''), $11, NULLIF($12, '')::jsonb)
+			RETURNING id, user_id, sender_name, created_at, expires_at
+		)
+		SELECT ins.id, ins.created_at, ins.expires_at, COALESCE(u.username, ins.sender_name), COALESCE(u.display_name, ins.sender_name)
+		FROM ins LEFT JOIN users u ON u.id = ins.user_id
+	`
+	// 没有发送者账号的消息（入站 webhook、系统消息）保存显示的发送者名称
+	var senderName string
+	if msg.UserID == 0 {
+		senderName = msg.DisplayName
+	}
+	if msg.MessageType == "" {
+		msg.MessageType = store.MessageTypeUser
+	}
+	err = tx.QueryRowContext(ctx, query,
+		msg.RoomID, msg.UserID, msg.Content, msg.ParentMessageID, msg.ConversationID, msg.ClientMsgID, msg.ScheduledAt, msg.TTLSeconds,
+		msg.IncomingWebhookID, senderName, msg.MessageType, string(msg.Metadata),
+	).Scan(&msg.ID, &msg.CreatedAt, &msg.ExpiresAt, &msg.Username, &msg.DisplayName)
+	if err != nil {
+		return s.mapError(err)
+	}
+
+	if len(msg.Attachments) > 0 {
+		ids := make([]int64, len(msg.Attachments))
+		for i, att := range msg.Attachments {
+			ids[i] = int64(att.ID)
+		}
+		// 只能关联自己上传、还没有用过的附件
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE attachments SET message_id = $1
+			WHERE id = ANY($2) AND user_id = $3 AND message_id IS NULL
+			RETURNING `+attachmentColumns,
+			msg.ID, pq.Array(ids), msg.UserID,
+		)
+		if err != nil {
+			return s.mapError(err)
+		}
+		attachments, err := s.scanAttachments(rows)
+		if err != nil {
+			return err
+		}
+		if len(attachments) != len(ids) {
+			return &store.ErrForeignKey{Field: "attachments"}
+		}
+		msg.Attachments = attachments
+	}
+
+	return s.mapError(tx.Commit())
+}
+
+func (s *Store) GetMessage(ctx context.Context, id int) (store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var msg store.Message
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.id = $1 AND m.deleted_at IS NULL AND m.status = 'sent' AND `+notExpired+`
+	`, id)
+	return msg, s.mapError(scanMessage(row, &msg))
+}
+
+func (s *Store) GetMessageByClientID(ctx context.Context, userID int, clientMsgID string) (store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var msg store.Message
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.user_id = $1 AND m.client_msg_id = $2 AND m.deleted_at IS NULL
+	`, userID, clientMsgID)
+	if err := scanMessage(row, &msg); err != nil {
+		return msg, s.mapError(err)
+	}
+	msg.ClientMsgID = clientMsgID
+	msgs := []store.Message{msg}
+	if err := s.loadDetails(ctx, msgs, userID); err != nil {
+		return msg, err
+	}
+	return msgs[0], nil
+}
+
+func (s *Store) DeleteExpiredMessages(ctx context.Context, now time.Time) ([]store.ExpiredMessage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 表情、提及、通知和附件记录通过外键级联删除，回复的 parent_message_id 置为 NULL。
+	// 尚未发送的定时消息不删除，它们的有效期从发送时开始计算
+	rows, err := s.db.QueryContext(ctx, `
+		DELETE FROM messages
+		WHERE expires_at IS NOT NULL AND expires_at <= $1 AND status = 'sent'
+		RETURNING id, COALESCE(room_id, 0), conversation_id
+	`, now)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	expired := []store.ExpiredMessage{}
+	for rows.Next() {
+		var msg store.ExpiredMessage
+		if err := rows.Scan(&msg.ID, &msg.RoomID, &msg.ConversationID); err != nil {
+			return nil, s.mapError(err)
+		}
+		expired = append(expired, msg)
+	}
+	return expired, s.mapError(rows.Err())
+}
+
+func (s *Store) ExpireClientMessageIDs(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE messages SET client_msg_id = NULL
+		WHERE client_msg_id IS NOT NULL AND created_at < $1
+	`, before)
+	if err != nil {
+		return 0, s.mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *Store) LatestRoomMessageID(ctx context.Context, roomID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(id), 0) FROM messages WHERE room_id = $1 AND deleted_at IS NULL AND status = 'sent'",
+		roomID,
+	).Scan(&id)
+	return id, s.mapError(err)
+}
+
+func (s *Store) ListRoomMessages(ctx context.Context, opts store.MessagePageOptions) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 向前翻页时取紧挨着 AfterID 的消息，否则取紧挨着 BeforeID（或最新）的消息，结果统一按 ID 升序返回
+	order := "DESC"
+	if opts.AfterID > 0 {
+		order = "ASC"
+	}
+	blockedFilter := " AND " + notBlockedBy("$2")
+	if opts.IncludeBlocked {
+		blockedFilter = ""
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`, NOT `+notBlockedBy("$2")+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.room_id = $1 AND m.deleted_at IS NULL AND (m.status = 'sent' OR m.user_id = $2) AND `+notExpired+blockedFilter+`
+			AND ($3 = 0 OR m.id < $3) AND m.id > $4
+		ORDER BY m.id `+order+`
+		LIMIT $5
+	`, opts.RoomID, opts.ViewerID, opts.BeforeID, opts.AfterID, opts.Limit)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	messages := []store.Message{}
+	for rows.Next() {
+		var msg store.Message
+		if err := scanMessage(rows, &msg, &msg.Blocked); err != nil {
+			return nil, s.mapError(err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.mapError(err)
+	}
+	if order == "DESC" {
+		slices.Reverse(messages)
+	}
+
+	if err := s.loadDetails(ctx, messages, opts.ViewerID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) ListRoomMessagesAfter(ctx context.Context, roomID, afterID, viewerID, limit int) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.room_id = $1 AND m.id > $2 AND m.deleted_at IS NULL AND m.status = 'sent' AND `+notExpired+`
+			AND `+notBlockedBy("$4")+`
+		ORDER BY m.id ASC
+		LIMIT $3
+	`, roomID, afterID, limit, viewerID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) GetThreadRoot(ctx context.Context, id int) (store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var msg store.Message
+	var deletedAt sql.NullTime
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`, m.deleted_at
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.id = $1 AND m.status = 'sent'
+	`, id)
+	if err := scanMessage(row, &msg, &deletedAt); err != nil {
+		return msg, s.mapError(err)
+	}
+	if deletedAt.Valid {
+		msg.Deleted = true
+		msg.Content = ""
+	}
+	return msg, nil
+}
+
+func (s *Store) ListReplies(ctx context.Context, parentID, viewerID, limit, offset int) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.parent_message_id = $1 AND m.deleted_at IS NULL AND m.status = 'sent'
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT $2 OFFSET $3
+	`, parentID, limit, offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// listMessages 按时间顺序返回满足条件的前 100 条消息及其表情汇总，
+// 尚未发送的定时消息只有作者（viewerID，对应 $2）可以看到
+// scanMessages 读取 messageColumns 格式的结果集
+func (s *Store) scanMessages(rows *sql.Rows) ([]store.Message, error) {
+	defer rows.Close()
+
+	messages := []store.Message{}
+	for rows.Next() {
+		var msg store.Message
+		if err := scanMessage(rows, &msg); err != nil {
+			return nil, s.mapError(err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, s.mapError(rows.Err())
+}
+
+// loadDetails 为一组消息填充表情汇总、收藏标记和附件
+func (s *Store) loadDetails(ctx context.Context, messages []store.Message, viewerID int) error {
+	if err := s.loadReactions(ctx, messages, viewerID); err != nil {
+		return err
+	}
+	if err := s.loadStars(ctx, messages, viewerID); err != nil {
+		return err
+	}
+	return s.loadAttachments(ctx, messages)
+}
+
+// loadReactions 为一组消息填充聚合后的表情，viewerID 为 0 时 Reacted 始终为 false
+func (s *Store) loadReactions(ctx context.Context, messages []store.Message, viewerID int) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = int64(msg.ID)
+		index[msg.ID] = i
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $2)
+		FROM reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY MIN(created_at)
+	`, pq.Array(ids), viewerID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		var summary store.ReactionSummary
+		if err := rows.Scan(&messageID, &summary.Emoji, &summary.Count, &summary.Reacted); err != nil {
+			return s.mapError(err)
+		}
+		i := index[messageID]
+		messages[i].Reactions = append(messages[i].Reactions, summary)
+	}
+	return s.mapError(rows.Err())
+}
+
+func (s *Store) AddReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO reactions (message_id, user_id, emoji) VALUES ($1, $2, $3)
+		 ON CONFLICT (message_id, user_id, emoji) DO NOTHING`,
+		messageID, userID, emoji,
+	)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) RemoveReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3",
+		messageID, userID, emoji,
+	)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) ListReactions(ctx context.Context, messageID, viewerID int) ([]store.ReactionSummary, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	messages := []store.Message{{ID: messageID}}
+	if err := s.loadDetails(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	if messages[0].Reactions == nil {
+		return []store.ReactionSummary{}, nil
+	}
+	return messages[0].Reactions, nil
+}
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func (s *Store) SetMemberRole(ctx context.Context, action store.ModerationAction, role string) error {
+	return s.moderate(ctx, action, func(tx *sql.Tx) (bool, error) {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE room_members SET role = $3 WHERE room_id = $1 AND user_id = $2",
+			action.RoomID, action.TargetID, role,
+		)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n > 0, nil
+	})
+}
+
+func (s *Store) KickMember(ctx context.Context, action store.ModerationAction) error {
+	return s.moderate(ctx, action, func(tx *sql.Tx) (bool, error) {
+		return deleteMember(ctx, tx, action.RoomID, action.TargetID)
+	})
+}
+
+func (s *Store) BanMember(ctx context.Context, action store.ModerationAction) error {
+	return s.moderate(ctx, action, func(tx *sql.Tx) (bool, error) {
+		if _, err := deleteMember(ctx, tx, action.RoomID, action.TargetID); err != nil {
+			return false, err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO room_bans (room_id, user_id, banned_by, reason) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (room_id, user_id) DO UPDATE
+			 SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason, created_at = NOW()`,
+			action.RoomID, action.TargetID, action.ActorID, action.Reason,
+		)
+		return err == nil, err
+	})
+}
+
+func (s *Store) UnbanMember(ctx context.Context, action store.ModerationAction) error {
+	return s.moderate(ctx, action, func(tx *sql.Tx) (bool, error) {
+		res, err := tx.ExecContext(ctx,
+			"DELETE FROM room_bans WHERE room_id = $1 AND user_id = $2",
+			action.RoomID, action.TargetID,
+		)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n > 0, nil
+	})
+}
+
+func (s *Store) IsBanned(ctx context.Context, roomID, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var banned bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM room_bans WHERE room_id = $1 AND user_id = $2)",
+		roomID, userID,
+	).Scan(&banned)
+	return banned, s.mapError(err)
+}
+
+func (s *Store) DeleteRoomMessages(ctx context.Context, roomID int, messageIDs []int) ([]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	ids := make([]int64, len(messageIDs))
+	for i, id := range messageIDs {
+		ids[i] = int64(id)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM messages WHERE id = ANY($1) AND room_id = $2",
+		pq.Array(ids), roomID,
+	).Scan(&found)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if found != len(ids) {
+		return nil, store.ErrNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE messages SET deleted_at = NOW()
+		 WHERE id = ANY($1) AND room_id = $2 AND deleted_at IS NULL
+		 RETURNING id`,
+		pq.Array(ids), roomID,
+	)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+	deleted := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, s.mapError(err)
+		}
+		deleted = append(deleted, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.mapError(err)
+	}
+	return deleted, s.mapError(tx.Commit())
+}
+
+func (s *Store) GetProfanitySettings(ctx context.Context, roomID int) (store.ProfanitySettings, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	settings := store.ProfanitySettings{Action: store.ProfanityBlock}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT profanity_filter, profanity_action FROM room_settings WHERE room_id = $1",
+		roomID,
+	).Scan(&settings.Enabled, &settings.Action)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	return settings, s.mapError(err)
+}
+
+func (s *Store) SaveProfanitySettings(ctx context.Context, roomID int, settings store.ProfanitySettings) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, profanity_filter, profanity_action) VALUES ($1, $2, $3)
+		 ON CONFLICT (room_id) DO UPDATE
+		 SET profanity_filter = EXCLUDED.profanity_filter, profanity_action = EXCLUDED.profanity_action, updated_at = NOW()`,
+		roomID, settings.Enabled, settings.Action,
+	)
+	return s.mapError(err)
+}
+
+// moderate 在事务中执行 apply 并写入审计日志，apply 返回 false 表示目标不存在
+func (s *Store) moderate(ctx context.Context, action store.ModerationAction, apply func(tx *sql.Tx) (bool, error)) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	ok, err := apply(tx)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO moderation_audit (room_id, actor_id, target_id, action, reason)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		action.RoomID, action.ActorID, action.TargetID, action.Action, action.Reason,
+	)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return s.mapError(tx.Commit())
+}
+
+func deleteMember(ctx context.Context, tx *sql.Tx, roomID, userID int) (bool, error) {
+	res, err := tx.ExecContext(ctx, "DELETE FROM room_members WHERE room_id = $1 AND user_id = $2", roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) RecordContentFilterDecision(ctx context.Context, d store.ContentFilterDecision) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO content_filter_audit (user_id, room_id, conversation_id, action, matched_terms)
+		 VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5)`,
+		d.UserID, d.RoomID, d.ConversationID, d.Action, pq.Array(d.MatchedTerms),
+	)
+	return s.mapError(err)
+}
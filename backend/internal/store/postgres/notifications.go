@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// notificationColumns 与 scanNotification 的字段顺序一致，需要配合 notificationJoins 使用
+const notificationColumns = `n.id, n.user_id, n.type, COALESCE(n.message_id, 0), COALESCE(n.room_id, m.room_id, 0),
+	COALESCE(m.conversation_id, 0), COALESCE(a.username, u.username, m.sender_name, ''),
+	COALESCE(m.content, n.reason, ''), COALESCE(n.action, ''), n.payload, n.read, n.created_at`
+
+// notificationJoins 管理操作的通知没有消息，因此都是 LEFT JOIN；
+// a 为执行管理操作、添加表情或回复的用户，存在时作为通知的来源，否则为消息的发送者
+const notificationJoins = `
+	LEFT JOIN messages m ON m.id = n.message_id
+	LEFT JOIN users u ON u.id = m.user_id
+	LEFT JOIN users a ON a.id = n.actor_id`
+
+func scanNotification(row scanner, n *store.Notification) error {
+	return row.Scan(&n.ID, &n.UserID, &n.Type, &n.MessageID, &n.RoomID, &n.ConversationID,
+		&n.FromUsername, &n.Content, &n.Action, (*[]byte)(&n.Payload), &n.Read, &n.CreatedAt)
+}
+
+func (s *Store) scanNotifications(rows *sql.Rows) ([]store.Notification, error) {
+	defer rows.Close()
+
+	notifications := []store.Notification{}
+	for rows.Next() {
+		var n store.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, s.mapError(err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, s.mapError(rows.Err())
+}
+
+func (s *Store) CreateMentionNotifications(ctx context.Context, messageID int, usernames []string, excludeUserID int, payload json.RawMessage) ([]store.Notification, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH mentioned AS (
+			SELECT id FROM users WHERE username = ANY($1) AND id <> $4
+		), mm AS (
+			INSERT INTO message_mentions (message_id, user_id)
+			SELECT $3, id FROM mentioned
+			ON CONFLICT DO NOTHING
+		), n AS (
+			INSERT INTO notifications (user_id, type, message_id, payload)
+			SELECT id, $2, $3, COALESCE(NULLIF($6, '')::jsonb, '{}') FROM mentioned
+			WHERE NOT EXISTS (
+				SELECT 1 FROM room_notification_settings ns
+				JOIN messages msg ON msg.room_id = ns.room_id
+				WHERE msg.id = $3 AND ns.user_id = mentioned.id AND ns.level = $5
+			)
+			RETURNING *
+		)
+		SELECT `+notificationColumns+`
+		FROM n`+notificationJoins+`
+	`, pq.Array(usernames), store.NotificationMention, messageID, excludeUserID, store.NotificationLevelMuted, string(payload))
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return s.scanNotifications(rows)
+}
+
+func (s *Store) CreateNotification(ctx context.Context, n store.Notification) (store.Notification, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var created store.Notification
+	row := s.db.QueryRowContext(ctx, `
+		WITH n AS (
+			INSERT INTO notifications (user_id, type, message_id, room_id, actor_id, action, reason, payload)
+			VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, ''), NULLIF($7, ''), COALESCE(NULLIF($8, '')::jsonb, '{}'))
+			RETURNING *
+		)
+		SELECT `+notificationColumns+`
+		FROM n`+notificationJoins,
+		n.UserID, n.Type, n.MessageID, n.RoomID, n.ActorID, n.Action, n.Content, string(n.Payload),
+	)
+	return created, s.mapError(scanNotification(row, &created))
+}
+
+// 消息被软删除后其通知不再显示，也不计入未读数
+// ID 随创建时间递增，按 ID 倒序排列，before_id 游标与排序一致
+func (s *Store) ListNotifications(ctx context.Context, userID int, q store.NotificationQuery) ([]store.Notification, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications n`+notificationJoins+`
+		WHERE n.user_id = $1 AND m.deleted_at IS NULL AND (NOT $2 OR NOT n.read)
+		  AND ($3 = 0 OR n.id < $3)
+		ORDER BY n.id DESC
+		LIMIT $4 OFFSET $5
+	`, userID, q.UnreadOnly, q.BeforeID, q.Limit, q.Offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return s.scanNotifications(rows)
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, userID, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE notifications SET read = TRUE WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE notifications SET read = TRUE WHERE user_id = $1 AND id = ANY($2) AND NOT read",
+		userID, pq.Array(ids),
+	)
+	if err != nil {
+		return 0, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE notifications SET read = TRUE WHERE user_id = $1 AND NOT read", userID)
+	if err != nil {
+		return 0, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID int) (store.NotificationCounts, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var counts store.NotificationCounts
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE n.type = $2)
+		FROM notifications n
+		LEFT JOIN messages m ON m.id = n.message_id
+		WHERE n.user_id = $1 AND NOT n.read AND m.deleted_at IS NULL
+	`, userID, store.NotificationMention).Scan(&counts.Unread, &counts.Mentions)
+	return counts, s.mapError(err)
+}
+
+func (s *Store) ListMentions(ctx context.Context, userID, limit, offset int) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM message_mentions mm
+		JOIN messages m ON m.id = mm.message_id
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE mm.user_id = $1 AND m.deleted_at IS NULL
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, userID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) GetRoomNotificationLevel(ctx context.Context, userID, roomID int) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	level := store.NotificationLevelAll
+	err := s.db.QueryRowContext(ctx,
+		"SELECT level FROM room_notification_settings WHERE user_id = $1 AND room_id = $2",
+		userID, roomID,
+	).Scan(&level)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.NotificationLevelAll, nil
+	}
+	return level, s.mapError(err)
+}
+
+// SetRoomNotificationLevel 设置为 all 时删除记录，表中只保存非默认的级别
+func (s *Store) SetRoomNotificationLevel(ctx context.Context, userID, roomID int, level string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if level == store.NotificationLevelAll {
+		_, err := s.db.ExecContext(ctx,
+			"DELETE FROM room_notification_settings WHERE user_id = $1 AND room_id = $2",
+			userID, roomID,
+		)
+		return s.mapError(err)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_notification_settings (user_id, room_id, level) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, room_id) DO UPDATE SET level = EXCLUDED.level, updated_at = NOW()`,
+		userID, roomID, level,
+	)
+	return s.mapError(err)
+}
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// 置顶来源
+const (
+	pinSourceUser      = "user"
+	pinSourceCommunity = "community"
+)
+
+func (s *Store) GetAutoPinSettings(ctx context.Context, roomID int) (store.AutoPinSettings, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	settings := store.AutoPinSettings{Emoji: store.DefaultAutoPinEmoji}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT auto_pin_emoji, auto_pin_threshold FROM room_settings WHERE room_id = $1",
+		roomID,
+	).Scan(&settings.Emoji, &settings.Threshold)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	return settings, s.mapError(err)
+}
+
+func (s *Store) SaveAutoPinSettings(ctx context.Context, roomID int, settings store.AutoPinSettings) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, auto_pin_emoji, auto_pin_threshold) VALUES ($1, $2, $3)
+		 ON CONFLICT (room_id) DO UPDATE
+		 SET auto_pin_emoji = EXCLUDED.auto_pin_emoji, auto_pin_threshold = EXCLUDED.auto_pin_threshold, updated_at = NOW()`,
+		roomID, settings.Emoji, settings.Threshold,
+	)
+	return s.mapError(err)
+}
+
+// ApplyAutoPin 同一聊天室的置顶操作通过锁定 chat_rooms 行串行执行，
+// 多个并发表情同时越过阈值时只会产生一次置顶变化。
+func (s *Store) ApplyAutoPin(ctx context.Context, roomID, messageID int, settings store.AutoPinSettings, maxPins int) (*store.PinChange, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM chat_rooms WHERE id = $1 FOR UPDATE", roomID); err != nil {
+		return nil, s.mapError(err)
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM reactions WHERE message_id = $1 AND emoji = $2",
+		messageID, settings.Emoji,
+	).Scan(&count)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+
+	change := &store.PinChange{MessageID: messageID}
+	if count >= settings.Threshold {
+		var pinned int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pinned_messages WHERE room_id = $1", roomID).Scan(&pinned); err != nil {
+			return nil, s.mapError(err)
+		}
+		if pinned >= maxPins {
+			return nil, nil
+		}
+
+		var pinnedAt time.Time
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO pinned_messages (room_id, message_id, source) VALUES ($1, $2, $3)
+			 ON CONFLICT (room_id, message_id) DO NOTHING
+			 RETURNING pinned_at`,
+			roomID, messageID, pinSourceCommunity,
+		).Scan(&pinnedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // 已经置顶
+		}
+		if err != nil {
+			return nil, s.mapError(err)
+		}
+		change.Pinned = true
+		change.PinnedAt = pinnedAt
+	} else {
+		// 只取消由社区自动置顶的消息
+		res, err := tx.ExecContext(ctx,
+			"DELETE FROM pinned_messages WHERE room_id = $1 AND message_id = $2 AND source = $3",
+			roomID, messageID, pinSourceCommunity,
+		)
+		if err != nil {
+			return nil, s.mapError(err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, nil
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, s.mapError(err)
+	}
+	return change, nil
+}
+
+// PinMessage 与 ApplyAutoPin 一样锁定 chat_rooms 行，保证置顶数量不会超过上限
+func (s *Store) PinMessage(ctx context.Context, roomID, messageID, userID, maxPins int) (*store.PinChange, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT id FROM chat_rooms WHERE id = $1 FOR UPDATE", roomID); err != nil {
+		return nil, s.mapError(err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM pinned_messages WHERE room_id = $1 AND message_id = $2)",
+		roomID, messageID,
+	).Scan(&exists)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if exists {
+		return nil, nil
+	}
+
+	var pinned int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pinned_messages WHERE room_id = $1", roomID).Scan(&pinned); err != nil {
+		return nil, s.mapError(err)
+	}
+	if pinned >= maxPins {
+		return nil, store.ErrLimitExceeded
+	}
+
+	change := &store.PinChange{MessageID: messageID, Pinned: true}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO pinned_messages (room_id, message_id, pinned_by, source) VALUES ($1, $2, $3, $4)
+		 RETURNING pinned_at`,
+		roomID, messageID, userID, pinSourceUser,
+	).Scan(&change.PinnedAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, s.mapError(err)
+	}
+	return change, nil
+}
+
+func (s *Store) UnpinMessage(ctx context.Context, roomID, messageID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM pinned_messages WHERE room_id = $1 AND message_id = $2",
+		roomID, messageID,
+	)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *Store) ListPinnedMessages(ctx context.Context, roomID, viewerID int) ([]store.PinnedMessage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`, COALESCE(pu.username, ''), p.pinned_at
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id
+		LEFT JOIN users u ON m.user_id = u.id
+		LEFT JOIN users pu ON p.pinned_by = pu.id
+		WHERE p.room_id = $1 AND m.deleted_at IS NULL AND m.status = 'sent' AND `+notExpired+`
+		ORDER BY p.pinned_at, p.id
+	`, roomID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	pins := []store.PinnedMessage{}
+	for rows.Next() {
+		var pin store.PinnedMessage
+		if err := scanMessage(rows, &pin.Message, &pin.PinnedBy, &pin.PinnedAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		pins = append(pins, pin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.mapError(err)
+	}
+
+	messages := make([]store.Message, len(pins))
+	for i := range pins {
+		messages[i] = pins[i].Message
+	}
+	if err := s.loadDetails(ctx, messages, viewerID); err != nil {
+		return nil, err
+	}
+	for i := range pins {
+		pins[i].Message = messages[i]
+	}
+	return pins, nil
+}
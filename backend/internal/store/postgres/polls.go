@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func (s *Store) CreatePoll(ctx context.Context, poll *store.Poll) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	texts := make([]string, len(poll.Options))
+	for i, opt := range poll.Options {
+		texts[i] = opt.Text
+	}
+	options, err := json.Marshal(texts)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO polls (room_id, question, options, allows_multiple, anonymous, ends_at, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id, created_at`,
+		poll.RoomID, poll.Question, options, poll.AllowsMultiple, poll.Anonymous, poll.EndsAt, poll.CreatedBy,
+	).Scan(&poll.ID, &poll.CreatedAt)
+	return s.mapError(err)
+}
+
+// GetPoll 逐条读取投票记录统计票数，同时得到投票者和当前用户的选择
+func (s *Store) GetPoll(ctx context.Context, id, viewerID int) (store.Poll, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var poll store.Poll
+	var options []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, room_id, question, options, allows_multiple, anonymous, ends_at, ends_at <= NOW(), created_by, created_at
+		FROM polls WHERE id = $1
+	`, id).Scan(&poll.ID, &poll.RoomID, &poll.Question, &options, &poll.AllowsMultiple, &poll.Anonymous,
+		&poll.EndsAt, &poll.Closed, &poll.CreatedBy, &poll.CreatedAt)
+	if err != nil {
+		return store.Poll{}, s.mapError(err)
+	}
+
+	var texts []string
+	if err := json.Unmarshal(options, &texts); err != nil {
+		return store.Poll{}, err
+	}
+	poll.Options = make([]store.PollOption, len(texts))
+	for i, text := range texts {
+		poll.Options[i].Text = text
+	}
+	poll.MyVotes = []int{}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.option_index, v.user_id, COALESCE(u.username, '')
+		FROM poll_votes v
+		LEFT JOIN users u ON u.id = v.user_id
+		WHERE v.poll_id = $1
+		ORDER BY v.created_at, v.user_id
+	`, id)
+	if err != nil {
+		return store.Poll{}, s.mapError(err)
+	}
+	defer rows.Close()
+
+	voters := make(map[int]bool)
+	for rows.Next() {
+		var index, userID int
+		var username string
+		if err := rows.Scan(&index, &userID, &username); err != nil {
+			return store.Poll{}, s.mapError(err)
+		}
+		if index >= len(poll.Options) {
+			continue
+		}
+		opt := &poll.Options[index]
+		opt.Votes++
+		if !poll.Anonymous {
+			opt.Voters = append(opt.Voters, username)
+		}
+		voters[userID] = true
+		if viewerID != 0 && userID == viewerID {
+			poll.MyVotes = append(poll.MyVotes, index)
+		}
+	}
+	poll.TotalVoters = len(voters)
+	return poll, s.mapError(rows.Err())
+}
+
+// VotePoll 锁定投票行后检查截止时间，截止时间与投票记录在同一事务中比较，不受应用服务器时钟影响
+func (s *Store) VotePoll(ctx context.Context, pollID, userID int, options []int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	var closed bool
+	err = tx.QueryRowContext(ctx, "SELECT ends_at <= NOW() FROM polls WHERE id = $1 FOR UPDATE", pollID).Scan(&closed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return s.mapError(err)
+	}
+	if closed {
+		return store.ErrPollClosed
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM poll_votes WHERE poll_id = $1 AND user_id = $2", pollID, userID); err != nil {
+		return s.mapError(err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO poll_votes (poll_id, user_id, option_index) SELECT $1, $2, unnest($3::int[])",
+		pollID, userID, pq.Array(options),
+	); err != nil {
+		return s.mapError(err)
+	}
+	return s.mapError(tx.Commit())
+}
@@ -0,0 +1,119 @@
+// Package postgres 是 store 接口的 PostgreSQL 实现
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// DefaultQueryTimeout 单次数据库操作的默认超时时间
+const DefaultQueryTimeout = 5 * time.Second
+
+// Store 实现 store 包中的所有接口
+type Store struct {
+	db *sql.DB
+
+	// QueryTimeout 每个方法（包括其中的事务）的超时时间，0 表示只使用调用方的 context
+	QueryTimeout time.Duration
+
+	// ErrorHook 在发生数据库错误（不含 sql.ErrNoRows）时调用，用于统计指标
+	ErrorHook func(error)
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db, QueryTimeout: DefaultQueryTimeout}
+}
+
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.QueryTimeout)
+}
+
+// mapError 把 sql / pq 错误转换为 store 包定义的错误类型
+func (s *Store) mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if s.ErrorHook != nil {
+		s.ErrorHook(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", store.ErrTimeout, err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return &store.ErrUniqueViolation{Field: uniqueField(pqErr)}
+	case "23503": // foreign_key_violation
+		return &store.ErrForeignKey{Field: foreignKeyField(pqErr)}
+	case "42501": // insufficient_privilege
+		return store.ErrPermission
+	case "40001": // serialization_failure
+		return &store.ErrConflict{}
+	case "57014": // query_canceled，超时后 pq 发送取消请求时返回
+		return fmt.Errorf("%w: %v", store.ErrTimeout, err)
+	}
+	return err
+}
+
+// foreignKeyField 从约束名（如 messages_room_id_fkey）中取出字段名
+func foreignKeyField(pqErr *pq.Error) string {
+	if pqErr.Column != "" {
+		return pqErr.Column
+	}
+	name := strings.TrimSuffix(pqErr.Constraint, "_fkey")
+	if pqErr.Table != "" {
+		name = strings.TrimPrefix(name, pqErr.Table+"_")
+	}
+	return name
+}
+
+// uniqueField 从约束名（如 users_email_key）中取出字段名
+func uniqueField(pqErr *pq.Error) string {
+	name := strings.TrimSuffix(pqErr.Constraint, "_key")
+	if pqErr.Table != "" {
+		name = strings.TrimPrefix(name, pqErr.Table+"_")
+	}
+	return name
+}
+
+var (
+	_ store.UserStore          = (*Store)(nil)
+	_ store.PasswordResetStore = (*Store)(nil)
+	_ store.RoomStore          = (*Store)(nil)
+	_ store.MembershipStore    = (*Store)(nil)
+	_ store.MessageStore       = (*Store)(nil)
+	_ store.ConversationStore  = (*Store)(nil)
+	_ store.ReactionStore      = (*Store)(nil)
+	_ store.ReadStore          = (*Store)(nil)
+	_ store.ModerationStore    = (*Store)(nil)
+	_ store.AdminStore         = (*Store)(nil)
+	_ store.ScheduleStore      = (*Store)(nil)
+	_ store.WebhookStore       = (*Store)(nil)
+	_ store.ExportStore        = (*Store)(nil)
+	_ store.BlockStore         = (*Store)(nil)
+	_ store.PushStore          = (*Store)(nil)
+	_ store.StatsStore         = (*Store)(nil)
+	_ store.PinStore           = (*Store)(nil)
+	_ store.NotificationStore  = (*Store)(nil)
+	_ store.AttachmentStore    = (*Store)(nil)
+	_ store.InviteStore        = (*Store)(nil)
+)
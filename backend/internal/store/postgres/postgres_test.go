@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		want  error
+		field string
+	}{
+		{"no rows", sql.ErrNoRows, store.ErrNotFound, ""},
+		{"unique violation", &pq.Error{Code: "23505", Table: "users", Constraint: "users_email_key"}, store.ErrDuplicate, "email"},
+		{"foreign key", &pq.Error{Code: "23503", Table: "messages", Constraint: "messages_room_id_fkey"}, nil, "room_id"},
+		{"foreign key column", &pq.Error{Code: "23503", Column: "parent_message_id"}, nil, "parent_message_id"},
+		{"permission", &pq.Error{Code: "42501"}, store.ErrPermission, ""},
+		{"serialization failure", &pq.Error{Code: "40001"}, &store.ErrConflict{}, ""},
+		{"query canceled", &pq.Error{Code: "57014"}, store.ErrTimeout, ""},
+		{"deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), store.ErrTimeout, ""},
+	}
+	s := New(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.mapError(tt.err)
+			if tt.field != "" {
+				var unique *store.ErrUniqueViolation
+				var fk *store.ErrForeignKey
+				switch {
+				case errors.As(got, &unique):
+					if unique.Field != tt.field {
+						t.Fatalf("field = %q, want %q", unique.Field, tt.field)
+					}
+				case errors.As(got, &fk):
+					if fk.Field != tt.field {
+						t.Fatalf("field = %q, want %q", fk.Field, tt.field)
+					}
+				default:
+					t.Fatalf("mapError(%v) = %#v, want an error on %s", tt.err, got, tt.field)
+				}
+			}
+			if tt.want == nil {
+				return
+			}
+			var conflict *store.ErrConflict
+			if _, ok := tt.want.(*store.ErrConflict); ok {
+				if !errors.As(got, &conflict) {
+					t.Fatalf("mapError(%v) = %v, want conflict", tt.err, got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.want) {
+				t.Fatalf("mapError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapErrorPassesThroughUnknownErrors(t *testing.T) {
+	var hooked error
+	s := New(nil)
+	s.ErrorHook = func(err error) { hooked = err }
+
+	unknown := errors.New("connection refused")
+	if got := s.mapError(unknown); got != unknown {
+		t.Fatalf("mapError = %v, want the original error", got)
+	}
+	if hooked != unknown {
+		t.Fatalf("ErrorHook got %v", hooked)
+	}
+	hooked = nil
+	s.mapError(sql.ErrNoRows)
+	if hooked != nil {
+		t.Fatalf("ErrorHook called for sql.ErrNoRows")
+	}
+	if s.mapError(nil) != nil {
+		t.Fatal("mapError(nil) != nil")
+	}
+}
+
+// TestLikePatterns 搜索词中的 LIKE 通配符按字面匹配
+func TestLikePatterns(t *testing.T) {
+	tests := []struct {
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{prefixPattern, "ali", `ali%`},
+		{prefixPattern, "50%_off", `50\%\_off%`},
+		{prefixPattern, `a\b`, `a\\b%`},
+		{likePattern, "", ""},
+		{likePattern, "go", `%go%`},
+		{likePattern, "100%", `%100\%%`},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("pattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func (s *Store) RegisterPushDevice(ctx context.Context, device *store.PushDevice) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO push_devices (user_id, platform, token, p256dh, auth)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+			created_at = NOW()
+		RETURNING id, created_at
+	`, device.UserID, device.Platform, device.Token, device.P256dh, device.Auth).Scan(&device.ID, &device.CreatedAt)
+	return s.mapError(err)
+}
+
+func (s *Store) ListPushDevices(ctx context.Context, userID int) ([]store.PushDevice, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, platform, token, COALESCE(p256dh, ''), COALESCE(auth, ''), created_at
+		FROM push_devices WHERE user_id = $1 ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	devices := []store.PushDevice{}
+	for rows.Next() {
+		var d store.PushDevice
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.P256dh, &d.Auth, &d.CreatedAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, s.mapError(rows.Err())
+}
+
+func (s *Store) DeletePushDevice(ctx context.Context, userID, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM push_devices WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeletePushTokens(ctx context.Context, tokens []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM push_devices WHERE token = ANY($1)", pq.Array(tokens))
+	return s.mapError(err)
+}
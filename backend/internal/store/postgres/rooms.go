@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"chatapp/internal/store"
+)
+
+// roomOrders 排序方式对应的 ORDER BY，相同值按 id 排序保证分页稳定
+var roomOrders = map[string]string{
+	store.RoomSortNameAsc:      "r.name ASC, r.id ASC",
+	store.RoomSortNameDesc:     "r.name DESC, r.id DESC",
+	store.RoomSortCreatedAsc:   "r.created_at ASC, r.id ASC",
+	store.RoomSortCreatedDesc:  "r.created_at DESC, r.id DESC",
+	store.RoomSortActivityDesc: "lm.created_at DESC NULLS LAST, r.created_at DESC, r.id DESC",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likePattern 转义 LIKE 通配符，返回包含匹配的模式
+func likePattern(search string) string {
+	if search == "" {
+		return ""
+	}
+	return "%" + likeEscaper.Replace(search) + "%"
+}
+
+// prefixPattern 转义 LIKE 通配符，返回前缀匹配的模式
+func prefixPattern(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
+}
+
+func (s *Store) ListRooms(ctx context.Context, opts store.RoomListOptions) ([]store.ChatRoom, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	order, ok := roomOrders[opts.Sort]
+	if !ok {
+		order = roomOrders[store.RoomSortCreatedDesc]
+	}
+	pattern := likePattern(opts.Search)
+	const filter = "($1 = '' OR r.name ILIKE $1 OR r.description ILIKE $1)"
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM chat_rooms r WHERE "+filter, pattern).Scan(&total); err != nil {
+		return nil, 0, s.mapError(err)
+	}
+
+	// 按已读位置统计未读消息数（不计自己发的消息）并返回通知级别，未认证时均为 NULL。
+	// lm 为最后一条消息，侧边栏不需要再逐个聊天室请求；消息内容只返回给成员
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.name, COALESCE(r.description, ''), r.created_at, r.slow_mode_seconds,
+			CASE WHEN $2 > 0 THEN
+				(SELECT COUNT(*) FROM messages m
+				 WHERE m.room_id = r.id
+				   AND m.id > COALESCE(rp.last_read_message_id, 0)
+				   AND m.user_id IS DISTINCT FROM $2
+				   AND m.status = 'sent')
+			END,
+			CASE WHEN $2 > 0 THEN COALESCE(ns.level, $5) END,
+			(SELECT COUNT(*) FROM room_members rm WHERE rm.room_id = r.id),
+			EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = r.id AND rm.user_id = $2),
+			lm.id, lm.username, lm.content, lm.created_at
+		FROM chat_rooms r
+		LEFT JOIN room_read_positions rp ON rp.room_id = r.id AND rp.user_id = $2
+		LEFT JOIN room_notification_settings ns ON ns.room_id = r.id AND ns.user_id = $2
+		LEFT JOIN LATERAL (
+			SELECT m.id, COALESCE(u.username, m.sender_name, '') AS username, LEFT(m.content, $6) AS content, m.created_at
+			FROM messages m
+			LEFT JOIN users u ON u.id = m.user_id
+			WHERE m.room_id = r.id AND m.deleted_at IS NULL AND m.status = 'sent'
+			ORDER BY m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE `+filter+`
+		ORDER BY `+order+`
+		LIMIT $3 OFFSET $4
+	`, pattern, opts.ViewerID, opts.Limit, opts.Offset, store.NotificationLevelAll, store.RoomPreviewLength)
+	if err != nil {
+		return nil, 0, s.mapError(err)
+	}
+	defer rows.Close()
+
+	rooms := []store.ChatRoom{}
+	for rows.Next() {
+		var room store.ChatRoom
+		var unread sql.NullInt64
+		var level sql.NullString
+		var members int
+		var member bool
+		var lastID sql.NullInt64
+		var lastUsername, lastContent sql.NullString
+		var lastAt sql.NullTime
+		if err := rows.Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt, &room.SlowModeSeconds, &unread, &level,
+			&members, &member, &lastID, &lastUsername, &lastContent, &lastAt); err != nil {
+			return nil, 0, s.mapError(err)
+		}
+		room.NotificationLevel = level.String
+		room.Muted = level.String == store.NotificationLevelMuted
+		if unread.Valid {
+			n := int(unread.Int64)
+			room.UnreadCount = &n
+		}
+		room.MemberCount = &members
+		if lastID.Valid {
+			room.LastActivityAt = &lastAt.Time
+			if member {
+				room.LastMessage = &store.RoomLastMessage{
+					ID:        int(lastID.Int64),
+					Username:  lastUsername.String,
+					Content:   lastContent.String,
+					CreatedAt: lastAt.Time,
+				}
+			}
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, total, s.mapError(rows.Err())
+}
+
+func (s *Store) GetRoom(ctx context.Context, id int) (store.ChatRoom, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var room store.ChatRoom
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, COALESCE(description, ''), created_at, created_by, slow_mode_seconds FROM chat_rooms WHERE id = $1",
+		id,
+	).Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt, &room.CreatedBy, &room.SlowModeSeconds)
+	return room, s.mapError(err)
+}
+
+func (s *Store) GetRoomSummary(ctx context.Context, id, viewerID int) (store.ChatRoom, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var room store.ChatRoom
+	var members, messages int
+	var member bool
+	var lastID sql.NullInt64
+	var lastUsername, lastContent sql.NullString
+	var lastAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT r.id, r.name, COALESCE(r.description, ''), r.created_at, r.created_by, r.slow_mode_seconds,
+			(SELECT COUNT(*) FROM room_members rm WHERE rm.room_id = r.id),
+			EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = r.id AND rm.user_id = $2),
+			(SELECT COUNT(*) FROM messages m WHERE m.room_id = r.id AND m.deleted_at IS NULL AND m.status = 'sent'),
+			lm.id, lm.username, lm.content, lm.created_at
+		FROM chat_rooms r
+		LEFT JOIN LATERAL (
+			SELECT m.id, COALESCE(u.username, m.sender_name, '') AS username, LEFT(m.content, $3) AS content, m.created_at
+			FROM messages m
+			LEFT JOIN users u ON u.id = m.user_id
+			WHERE m.room_id = r.id AND m.deleted_at IS NULL AND m.status = 'sent'
+			ORDER BY m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		WHERE r.id = $1
+	`, id, viewerID, store.RoomPreviewLength).Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt, &room.CreatedBy,
+		&room.SlowModeSeconds, &members, &member, &messages, &lastID, &lastUsername, &lastContent, &lastAt)
+	if err != nil {
+		return store.ChatRoom{}, s.mapError(err)
+	}
+
+	room.MemberCount = &members
+	room.IsMember = &member
+	room.MessageCount = &messages
+	if lastID.Valid {
+		room.LastActivityAt = &lastAt.Time
+		if member {
+			room.LastMessage = &store.RoomLastMessage{
+				ID:        int(lastID.Int64),
+				Username:  lastUsername.String,
+				Content:   lastContent.String,
+				CreatedAt: lastAt.Time,
+			}
+		}
+	}
+	return room, nil
+}
+
+func (s *Store) UpdateRoom(ctx context.Context, id int, name, description string, slowModeSeconds *int) (store.ChatRoom, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var room store.ChatRoom
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE chat_rooms
+		 SET name = $1, description = $2, slow_mode_seconds = COALESCE($4, slow_mode_seconds), updated_at = NOW()
+		 WHERE id = $3
+		 RETURNING id, name, description, created_at, created_by, slow_mode_seconds`,
+		name, description, id, slowModeSeconds,
+	).Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt, &room.CreatedBy, &room.SlowModeSeconds)
+	return room, s.mapError(err)
+}
+
+func (s *Store) DeleteRoom(ctx context.Context, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM chat_rooms WHERE id = $1", id)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) MarkRead(ctx context.Context, userID, roomID, messageID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 已读位置只能前进，旧的位置不会覆盖新的位置
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO room_read_positions (user_id, room_id, last_read_message_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, room_id) DO UPDATE
+		SET last_read_message_id = EXCLUDED.last_read_message_id, updated_at = NOW()
+		WHERE room_read_positions.last_read_message_id < EXCLUDED.last_read_message_id
+	`, userID, roomID, messageID)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) DeliverScheduledMessages(ctx context.Context, now time.Time) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// UPDATE 会锁定行，并发执行时同一条消息只会被返回一次
+	rows, err := s.db.QueryContext(ctx, `
+		WITH m AS (
+			UPDATE messages SET status = 'sent', created_at = NOW()
+			WHERE status = 'scheduled' AND scheduled_at <= $1 AND deleted_at IS NULL
+			RETURNING *
+		)
+		SELECT `+messageColumns+`
+		FROM m
+		LEFT JOIN users u ON m.user_id = u.id
+		ORDER BY m.scheduled_at, m.id
+	`, now)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, 0); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) ListScheduledMessages(ctx context.Context, userID int) ([]store.Message, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id
+		WHERE m.user_id = $1 AND m.status = 'scheduled' AND m.deleted_at IS NULL
+		ORDER BY m.scheduled_at, m.id
+	`, userID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadDetails(ctx, messages, userID); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *Store) CancelScheduledMessage(ctx context.Context, messageID, userID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE messages SET deleted_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = 'scheduled' AND deleted_at IS NULL
+	`, messageID, userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
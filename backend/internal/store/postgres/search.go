@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) SearchRoomMessages(ctx context.Context, roomID int, query string, limit, offset int) ([]store.SearchResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`,
+			ts_headline('simple', m.content, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=1')
+		FROM messages m
+		LEFT JOIN users u ON m.user_id = u.id,
+			websearch_to_tsquery('simple', $2) q
+		WHERE m.room_id = $1 AND m.deleted_at IS NULL AND m.status = 'sent' AND m.search_vector @@ q
+		ORDER BY ts_rank(m.search_vector, q) DESC, m.created_at DESC
+		LIMIT $3 OFFSET $4
+	`, roomID, query, limit, offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	results := []store.SearchResult{}
+	for rows.Next() {
+		var r store.SearchResult
+		if err := scanMessage(rows, &r.Message, &r.Snippet); err != nil {
+			return nil, s.mapError(err)
+		}
+		results = append(results, r)
+	}
+	return results, s.mapError(rows.Err())
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func (s *Store) StarMessage(ctx context.Context, messageID, userID int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO stars (user_id, message_id) VALUES ($1, $2)
+		 ON CONFLICT (user_id, message_id) DO NOTHING`,
+		userID, messageID,
+	)
+	return s.mapError(err)
+}
+
+func (s *Store) UnstarMessage(ctx context.Context, messageID, userID int) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM stars WHERE user_id = $1 AND message_id = $2", userID, messageID)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListStarredMessages 离开聊天室后，该聊天室中收藏的消息不再返回，重新加入后恢复
+func (s *Store) ListStarredMessages(ctx context.Context, userID, limit, offset int) ([]store.StarredMessage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`, COALESCE(r.name, ''), st.created_at
+		FROM stars st
+		JOIN messages m ON m.id = st.message_id
+		LEFT JOIN users u ON m.user_id = u.id
+		LEFT JOIN chat_rooms r ON r.id = m.room_id
+		WHERE st.user_id = $1 AND m.deleted_at IS NULL AND m.status = 'sent' AND `+notExpired+`
+			AND (m.conversation_id IS NOT NULL
+				OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $1))
+		ORDER BY st.created_at DESC, m.id DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	starred := []store.StarredMessage{}
+	for rows.Next() {
+		var st store.StarredMessage
+		if err := scanMessage(rows, &st.Message, &st.RoomName, &st.StarredAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		starred = append(starred, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.mapError(err)
+	}
+
+	messages := make([]store.Message, len(starred))
+	for i := range starred {
+		messages[i] = starred[i].Message
+	}
+	if err := s.loadDetails(ctx, messages, userID); err != nil {
+		return nil, err
+	}
+	for i := range starred {
+		starred[i].Message = messages[i]
+	}
+	return starred, nil
+}
+
+// loadStars 标记 viewerID 收藏的消息，viewerID 为 0 时不查询
+func (s *Store) loadStars(ctx context.Context, messages []store.Message, viewerID int) error {
+	if len(messages) == 0 || viewerID == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = int64(msg.ID)
+		index[msg.ID] = i
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT message_id FROM stars WHERE user_id = $1 AND message_id = ANY($2)",
+		viewerID, pq.Array(ids),
+	)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		if err := rows.Scan(&messageID); err != nil {
+			return s.mapError(err)
+		}
+		messages[index[messageID]].Starred = true
+	}
+	return s.mapError(rows.Err())
+}
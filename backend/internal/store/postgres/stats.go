@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+func (s *Store) RoomStats(ctx context.Context, roomID int) (store.RoomStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var stats store.RoomStats
+	var topUsers, hourly []byte
+	err := s.db.QueryRowContext(ctx, `
+		WITH msgs AS (
+			SELECT user_id, created_at FROM messages
+			WHERE room_id = $1 AND deleted_at IS NULL AND status = 'sent'
+		),
+		totals AS (
+			SELECT COUNT(*) AS total,
+				COUNT(DISTINCT user_id) AS users,
+				COUNT(*) FILTER (WHERE created_at > NOW() - INTERVAL '24 hours') AS day,
+				COUNT(*) FILTER (WHERE created_at > NOW() - INTERVAL '7 days') AS week
+			FROM msgs
+		),
+		top_users AS (
+			SELECT u.id, u.username, COUNT(*) AS n
+			FROM msgs JOIN users u ON u.id = msgs.user_id
+			GROUP BY u.id, u.username
+			ORDER BY n DESC, u.username
+			LIMIT 5
+		),
+		hours AS (
+			SELECT h.hour, COUNT(m.created_at) AS n
+			FROM generate_series(0, 23) AS h(hour)
+			LEFT JOIN msgs m ON EXTRACT(HOUR FROM m.created_at) = h.hour
+				AND m.created_at > NOW() - INTERVAL '7 days'
+			GROUP BY h.hour
+		)
+		SELECT t.total, t.users, t.day, t.week,
+			COALESCE((SELECT json_agg(json_build_object('user_id', id, 'username', username, 'message_count', n)
+				ORDER BY n DESC, username) FROM top_users), '[]'),
+			(SELECT json_agg(n ORDER BY hour) FROM hours)
+		FROM totals t
+	`, roomID).Scan(&stats.MessageCount, &stats.UniqueUsers, &stats.MessagesLast24h, &stats.MessagesLast7d, &topUsers, &hourly)
+	if err != nil {
+		return stats, s.mapError(err)
+	}
+	if err := json.Unmarshal(topUsers, &stats.TopUsers); err != nil {
+		return stats, err
+	}
+	if err := json.Unmarshal(hourly, &stats.HourlyMessages); err != nil {
+		return stats, err
+	}
+	stats.GeneratedAt = time.Now()
+	return stats, nil
+}
@@ -0,0 +1,264 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"chatapp/internal/store"
+)
+
+// userColumns 与 scanUser 的字段顺序一致
+const userColumns = "id, username, COALESCE(email, ''), display_name, bio, avatar_url, last_seen_at, token_version, disabled_at IS NOT NULL"
+
+func scanUser(row scanner, user *store.User, extra ...interface{}) error {
+	dest := append([]interface{}{&user.ID, &user.Username, &user.Email, &user.DisplayName, &user.Bio, &user.AvatarURL,
+		&user.LastSeenAt, &user.TokenVersion, &user.Disabled}, extra...)
+	return row.Scan(dest...)
+}
+
+func (s *Store) CreateUser(ctx context.Context, username, email, passwordHash string) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	row := s.db.QueryRowContext(ctx,
+		"INSERT INTO users (username, email, password_hash) VALUES ($1, $2, $3) RETURNING "+userColumns,
+		username, email, passwordHash,
+	)
+	err := s.mapError(scanUser(row, &user))
+	// 唯一约束按列的顺序检查，用户名先于邮箱报告冲突；两者都被占用时与接口约定一致，报告邮箱
+	var unique *store.ErrUniqueViolation
+	if errors.As(err, &unique) && unique.Field == "username" {
+		var emailTaken bool
+		if s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", email).Scan(&emailTaken) == nil && emailTaken {
+			return user, &store.ErrUniqueViolation{Field: "email"}
+		}
+	}
+	return user, err
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (store.User, string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	var hashedPassword string
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+userColumns+", password_hash FROM users WHERE email = $1",
+		email,
+	)
+	err := scanUser(row, &user, &hashedPassword)
+	return user, hashedPassword, s.mapError(err)
+}
+
+func (s *Store) UserExists(ctx context.Context, email, username string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 OR username = $2)",
+		email, username,
+	).Scan(&exists)
+	return exists, s.mapError(err)
+}
+
+func (s *Store) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, tokenHash, expiresAt,
+	)
+	return s.mapError(err)
+}
+
+func (s *Store) ResetPassword(ctx context.Context, tokenHash, passwordHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.mapError(err)
+	}
+	defer tx.Rollback()
+
+	// 锁定令牌行，防止并发重复使用
+	var tokenID, userID int
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, user_id FROM password_reset_tokens
+		 WHERE token_hash = $1 AND used = FALSE AND expires_at > NOW()
+		 FOR UPDATE`,
+		tokenHash,
+	).Scan(&tokenID, &userID)
+	if err != nil {
+		return s.mapError(err)
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE users SET password_hash = $1, token_version = token_version + 1, updated_at = NOW() WHERE id = $2",
+		passwordHash, userID,
+	); err != nil {
+		return s.mapError(err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE password_reset_tokens SET used = TRUE WHERE id = $1", tokenID); err != nil {
+		return s.mapError(err)
+	}
+
+	return s.mapError(tx.Commit())
+}
+
+func (s *Store) GetUser(ctx context.Context, id int) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	return user, s.mapError(scanUser(row, &user))
+}
+
+func (s *Store) SearchUsers(ctx context.Context, prefix string, excludeUserID, limit int) ([]store.Participant, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// 较短的用户名更接近查询词，排在前面
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, username FROM users
+		WHERE username ILIKE $1 AND id <> $2
+		ORDER BY LENGTH(username), username
+		LIMIT $3
+	`, prefixPattern(prefix), excludeUserID, limit)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	users := []store.Participant{}
+	for rows.Next() {
+		var p store.Participant
+		if err := rows.Scan(&p.ID, &p.Username); err != nil {
+			return nil, s.mapError(err)
+		}
+		users = append(users, p)
+	}
+	return users, s.mapError(rows.Err())
+}
+
+func (s *Store) UpdateProfile(ctx context.Context, id int, update store.ProfileUpdate) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE users SET
+			display_name = COALESCE($2, display_name),
+			bio = COALESCE($3, bio),
+			avatar_url = COALESCE($4, avatar_url),
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, update.DisplayName, update.Bio, update.AvatarURL,
+	)
+	return user, s.mapError(scanUser(row, &user))
+}
+
+func (s *Store) SetAvatar(ctx context.Context, id int, avatar store.Avatar, url string) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE users SET avatar_key = $2, avatar_content_type = $3, avatar_url = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, avatar.StorageKey, avatar.ContentType, url,
+	)
+	return user, s.mapError(scanUser(row, &user))
+}
+
+func (s *Store) GetAvatar(ctx context.Context, id int) (store.Avatar, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var avatar store.Avatar
+	err := s.db.QueryRowContext(ctx,
+		"SELECT avatar_key, avatar_content_type FROM users WHERE id = $1 AND avatar_key <> ''",
+		id,
+	).Scan(&avatar.StorageKey, &avatar.ContentType)
+	return avatar, s.mapError(err)
+}
+
+func (s *Store) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var hash string
+	err := s.db.QueryRowContext(ctx, "SELECT password_hash FROM users WHERE id = $1", id).Scan(&hash)
+	return hash, s.mapError(err)
+}
+
+func (s *Store) ChangePassword(ctx context.Context, id int, oldHash, newHash string) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user store.User
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE users SET password_hash = $3, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1 AND password_hash = $2
+		RETURNING `+userColumns,
+		id, oldHash, newHash,
+	)
+	err := scanUser(row, &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, s.passwordConflict(ctx, id)
+	}
+	return user, s.mapError(err)
+}
+
+// passwordConflict 按哈希条件更新没有影响任何行时，用户存在说明哈希已经被并发修改
+func (s *Store) passwordConflict(ctx context.Context, id int) error {
+	var version int
+	err := s.db.QueryRowContext(ctx, "SELECT token_version FROM users WHERE id = $1", id).Scan(&version)
+	if err != nil {
+		return s.mapError(err)
+	}
+	return &store.ErrConflict{CurrentVersion: version}
+}
+
+func (s *Store) GetTokenVersion(ctx context.Context, id int) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var version int
+	err := s.db.QueryRowContext(ctx, "SELECT token_version FROM users WHERE id = $1", id).Scan(&version)
+	return version, s.mapError(err)
+}
+
+func (s *Store) UpdatePasswordHash(ctx context.Context, id int, oldHash, newHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2",
+		id, oldHash, newHash,
+	)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return s.passwordConflict(ctx, id)
+	}
+	return nil
+}
+
+func (s *Store) TouchLastSeen(ctx context.Context, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET last_seen_at = NOW() WHERE id = $1", id)
+	return s.mapError(err)
+}
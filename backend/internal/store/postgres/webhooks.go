@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"chatapp/internal/store"
+
+	"github.com/lib/pq"
+)
+
+// webhookColumns 与 scanWebhook 的字段顺序一致，不包含 secret
+const webhookColumns = "id, room_id, url, events, enabled, created_by, created_at"
+
+func scanWebhook(row scanner, webhook *store.Webhook, extra ...interface{}) error {
+	dest := append([]interface{}{
+		&webhook.ID, &webhook.RoomID, &webhook.URL, (*pq.StringArray)(&webhook.Events),
+		&webhook.Enabled, &webhook.CreatedBy, &webhook.CreatedAt,
+	}, extra...)
+	return row.Scan(dest...)
+}
+
+// nullableStringArray nil 切片传为 NULL，配合 COALESCE 保留原值
+func nullableStringArray(values []string) interface{} {
+	if values == nil {
+		return nil
+	}
+	return pq.Array(values)
+}
+
+func (s *Store) CreateWebhook(ctx context.Context, webhook *store.Webhook, createdBy int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO room_webhooks (room_id, url, secret, events, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, webhook.RoomID, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Enabled, createdBy).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return s.mapError(err)
+	}
+	webhook.CreatedBy = &createdBy
+	return nil
+}
+
+func (s *Store) ListWebhooks(ctx context.Context, roomID int) ([]store.Webhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+webhookColumns+" FROM room_webhooks WHERE room_id = $1 ORDER BY id",
+		roomID,
+	)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return s.scanWebhooks(rows, false)
+}
+
+func (s *Store) ListEnabledWebhooks(ctx context.Context, roomID int, event string) ([]store.Webhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+webhookColumns+", secret FROM room_webhooks WHERE room_id = $1 AND enabled AND $2 = ANY(events) ORDER BY id",
+		roomID, event,
+	)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return s.scanWebhooks(rows, true)
+}
+
+func (s *Store) scanWebhooks(rows *sql.Rows, withSecret bool) ([]store.Webhook, error) {
+	defer rows.Close()
+
+	webhooks := []store.Webhook{}
+	for rows.Next() {
+		var webhook store.Webhook
+		var extra []interface{}
+		if withSecret {
+			extra = append(extra, &webhook.Secret)
+		}
+		if err := scanWebhook(rows, &webhook, extra...); err != nil {
+			return nil, s.mapError(err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, s.mapError(rows.Err())
+}
+
+func (s *Store) UpdateWebhook(ctx context.Context, roomID, id int, update store.WebhookUpdate) (store.Webhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var webhook store.Webhook
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE room_webhooks SET
+			url = COALESCE($3, url),
+			events = COALESCE($4, events),
+			enabled = COALESCE($5, enabled),
+			updated_at = NOW()
+		WHERE room_id = $1 AND id = $2
+		RETURNING `+webhookColumns,
+		roomID, id, update.URL, nullableStringArray(update.Events), update.Enabled,
+	)
+	return webhook, s.mapError(scanWebhook(row, &webhook))
+}
+
+func (s *Store) DeleteWebhook(ctx context.Context, roomID, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM room_webhooks WHERE room_id = $1 AND id = $2", roomID, id)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CreateIncomingWebhook(ctx context.Context, webhook *store.IncomingWebhook, tokenHash string, createdBy int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO incoming_webhooks (room_id, name, token_hash, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, webhook.RoomID, webhook.Name, tokenHash, createdBy).Scan(&webhook.ID, &webhook.CreatedAt)
+	return s.mapError(err)
+}
+
+func (s *Store) ListIncomingWebhooks(ctx context.Context, roomID int) ([]store.IncomingWebhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, room_id, name, created_at FROM incoming_webhooks
+		WHERE room_id = $1 AND revoked_at IS NULL
+		ORDER BY id
+	`, roomID)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	defer rows.Close()
+
+	webhooks := []store.IncomingWebhook{}
+	for rows.Next() {
+		var webhook store.IncomingWebhook
+		if err := rows.Scan(&webhook.ID, &webhook.RoomID, &webhook.Name, &webhook.CreatedAt); err != nil {
+			return nil, s.mapError(err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, s.mapError(rows.Err())
+}
+
+func (s *Store) GetIncomingWebhookByToken(ctx context.Context, tokenHash string) (store.IncomingWebhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var webhook store.IncomingWebhook
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, room_id, name, created_at FROM incoming_webhooks
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, tokenHash).Scan(&webhook.ID, &webhook.RoomID, &webhook.Name, &webhook.CreatedAt)
+	return webhook, s.mapError(err)
+}
+
+func (s *Store) RevokeIncomingWebhook(ctx context.Context, roomID, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE incoming_webhooks SET revoked_at = NOW()
+		WHERE room_id = $1 AND id = $2 AND revoked_at IS NULL
+	`, roomID, id)
+	if err != nil {
+		return s.mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) GetRoomWebhookSecretHash(ctx context.Context, roomID int) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var hash sql.NullString
+	err := s.db.QueryRowContext(ctx,
+		"SELECT incoming_webhook_secret_hash FROM room_settings WHERE room_id = $1",
+		roomID,
+	).Scan(&hash)
+	if err == nil && !hash.Valid {
+		return "", store.ErrNotFound
+	}
+	return hash.String, s.mapError(err)
+}
+
+func (s *Store) SetRoomWebhookSecretHash(ctx context.Context, roomID int, secretHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO room_settings (room_id, incoming_webhook_secret_hash) VALUES ($1, $2)
+		 ON CONFLICT (room_id) DO UPDATE
+		 SET incoming_webhook_secret_hash = EXCLUDED.incoming_webhook_secret_hash, updated_at = NOW()`,
+		roomID, secretHash,
+	)
+	return s.mapError(err)
+}
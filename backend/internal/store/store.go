@@ -0,0 +1,880 @@
+// Package store 定义数据访问接口和数据模型，postgres 子包是数据库实现，
+// memory 子包是用于测试的内存实现。
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"-"` // 不返回密码
+
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	// LastSeenAt 最后活跃时间，从未活跃过时为 nil
+	LastSeenAt *time.Time `json:"last_seen_at"`
+
+	// TokenVersion 写入 JWT，修改密码后递增
+	TokenVersion int `json:"-"`
+	// Disabled 被管理员停用或已删除的账号不能登录
+	Disabled bool `json:"-"`
+}
+
+// AdminUser 管理员看到的用户信息
+type AdminUser struct {
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	IsAdmin     bool      `json:"is_admin"`
+	Disabled    bool      `json:"disabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ProfileUpdate 更新用户资料，nil 字段保持不变
+type ProfileUpdate struct {
+	DisplayName *string
+	Bio         *string
+	AvatarURL   *string
+}
+
+// Avatar 用户上传的头像文件
+type Avatar struct {
+	StorageKey  string
+	ContentType string
+}
+
+type ChatRoom struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	CreatedBy   *int      `json:"-"`
+	// SlowModeSeconds 同一用户两次发言之间的最短间隔，0 表示关闭
+	SlowModeSeconds int `json:"slow_mode_seconds"`
+
+	// 仅在已认证的请求中返回。静音的聊天室仍然统计未读数，Muted 为 true
+	UnreadCount       *int   `json:"unread_count,omitempty"`
+	NotificationLevel string `json:"notification_level,omitempty"`
+	Muted             bool   `json:"muted,omitempty"`
+
+	// 仅在聊天室列表和已认证的单个聊天室请求中返回。LastMessage 只对成员返回，LastActivityAt 为最后一条消息的时间
+	MemberCount    *int             `json:"member_count,omitempty"`
+	LastActivityAt *time.Time       `json:"last_activity_at,omitempty"`
+	LastMessage    *RoomLastMessage `json:"last_message,omitempty"`
+	// 仅在已认证的单个聊天室请求中返回
+	IsMember     *bool `json:"is_member,omitempty"`
+	MessageCount *int  `json:"message_count,omitempty"`
+}
+
+// RoomLastMessage 聊天室列表中最后一条消息的摘要，Content 最多 RoomPreviewLength 个字符
+type RoomLastMessage struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RoomPreviewLength 聊天室列表中消息摘要的最大字符数
+const RoomPreviewLength = 100
+
+// 聊天室列表的排序方式
+const (
+	RoomSortNameAsc      = "name_asc"
+	RoomSortNameDesc     = "name_desc"
+	RoomSortCreatedAsc   = "created_asc"
+	RoomSortCreatedDesc  = "created_desc"
+	RoomSortActivityDesc = "activity_desc" // 按最后一条消息的时间
+)
+
+// RoomListOptions 聊天室列表的查询条件
+type RoomListOptions struct {
+	ViewerID int
+	// Search 不区分大小写地匹配名称和描述，为空时不过滤
+	Search string
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// MessagePageOptions 按消息 ID 分页的查询条件。BeforeID 和 AfterID 最多设置一个：
+// BeforeID 返回紧挨着它的较早消息，AfterID 返回紧挨着它的较新消息，都为 0 时返回最新的消息
+type MessagePageOptions struct {
+	RoomID   int
+	ViewerID int
+	BeforeID int
+	AfterID  int
+	Limit    int
+	// IncludeBlocked 为 true 时同时返回 ViewerID 屏蔽的用户发送的消息，并设置 Message.Blocked
+	IncludeBlocked bool
+}
+
+type Message struct {
+	ID       int    `json:"id"`
+	RoomID   int    `json:"room_id"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	// DisplayName 发送者的显示名称，未设置时为空字符串
+	DisplayName string    `json:"display_name"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// ParentMessageID 回复的消息，顶层消息为 nil
+	ParentMessageID *int `json:"parent_message_id"`
+	// ReplyCount 未删除的直接回复数
+	ReplyCount int `json:"reply_count"`
+	// Deleted 只在讨论串的根消息已删除时为 true，此时 Content 为空
+	Deleted bool `json:"deleted,omitempty"`
+	// ConversationID 私信所属的会话，此时 RoomID 为 0
+	ConversationID *int `json:"conversation_id,omitempty"`
+	// TTLSeconds 大于 0 时消息在 ExpiresAt 之后被删除
+	TTLSeconds int        `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	// IncomingWebhookID 通过入站 webhook 发送的消息，此时 UserID 为 0，
+	// Username 和 DisplayName 为 webhook 提供的发送者名称。
+	// 通过聊天室共享密钥发送的消息 UserID 同样为 0，IncomingWebhookID 为 nil
+	IncomingWebhookID *int `json:"incoming_webhook_id,omitempty"`
+	// ScheduledAt 尚未发送的定时消息的发送时间，已发送的消息为 nil
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	// ClientMsgID 客户端生成的幂等键，只在发送消息的响应和广播中返回，用于客户端对应本地的待发送消息
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+	// Ephemeral 只发给当前用户、不保存的系统回复（例如斜杠命令的结果），ID 为 0
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// MessageType 为 MessageTypeUser 或 MessageTypeSystem
+	MessageType string `json:"message_type"`
+	// Metadata 保存的系统消息的结构化数据，其中 event 为事件类型，用户消息为空
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Starred 查询消息的用户是否收藏了该消息，广播给所有人时为 false
+	Starred bool `json:"starred"`
+	// Blocked 发送者被查询消息的用户屏蔽，只在请求包含被屏蔽用户的消息时出现
+	Blocked bool `json:"blocked,omitempty"`
+
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
+	// Attachments 保存消息时只需要填写 ID
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// 消息类型：用户（包括入站 webhook）发送的消息，或系统生成的消息。
+// 系统消息没有发送者，不能添加表情
+const (
+	MessageTypeUser   = "user"
+	MessageTypeSystem = "system"
+)
+
+// ExpiredMessage 到期后被删除的消息
+type ExpiredMessage struct {
+	ID             int  `json:"message_id"`
+	RoomID         int  `json:"room_id,omitempty"`
+	ConversationID *int `json:"conversation_id,omitempty"`
+}
+
+// Attachment 上传的文件，URL 指向 GET /api/files/{id}
+type Attachment struct {
+	ID          int       `json:"id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// 图片的原始尺寸，其他文件为 0；缩略图在后台生成，生成之前 ThumbnailURL 为空
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+
+	UserID               int    `json:"-"`
+	MessageID            *int   `json:"-"`
+	StorageKey           string `json:"-"`
+	ThumbnailKey         string `json:"-"`
+	ThumbnailContentType string `json:"-"`
+}
+
+// AttachmentURL 返回附件的下载地址
+func AttachmentURL(id int) string {
+	return fmt.Sprintf("/api/files/%d", id)
+}
+
+// SetURLs 根据 ID 和缩略图是否存在填充 URL 和 ThumbnailURL
+func (a *Attachment) SetURLs() {
+	a.URL = AttachmentURL(a.ID)
+	a.ThumbnailURL = ""
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = a.URL + "/thumbnail"
+	}
+}
+
+// UserMessageCount 用户在聊天室中发送的消息数
+type UserMessageCount struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	MessageCount int    `json:"message_count"`
+}
+
+// RoomStats 聊天室活跃度统计，不包含已删除的消息
+type RoomStats struct {
+	MessageCount    int                `json:"message_count"`
+	UniqueUsers     int                `json:"unique_users"`
+	MessagesLast24h int                `json:"messages_last_24h"`
+	MessagesLast7d  int                `json:"messages_last_7d"`
+	TopUsers        []UserMessageCount `json:"top_users"`
+	// HourlyMessages 过去 7 天中每个小时（0 到 23 点，数据库时区）的消息数
+	HourlyMessages []int     `json:"hourly_messages"`
+	GeneratedAt    time.Time `json:"generated_at"`
+}
+
+// Webhook 聊天室的出站 webhook。Secret 只在创建时返回
+type Webhook struct {
+	ID     int    `json:"id"`
+	RoomID int    `json:"room_id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events 订阅的事件类型，只有这些事件会发送到 URL
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedBy *int      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncomingWebhook 外部服务向聊天室发送消息使用的入站 webhook。Token 只在创建时返回
+type IncomingWebhook struct {
+	ID        int       `json:"id"`
+	RoomID    int       `json:"room_id"`
+	Name      string    `json:"name"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 推送设备的平台
+const (
+	PushPlatformFCM     = "fcm"
+	PushPlatformWebPush = "webpush"
+)
+
+// PushDevice 接收推送通知的设备。FCM 设备的 Token 为注册令牌；
+// Web Push 设备的 Token 为订阅的 endpoint，P256dh 和 Auth 为订阅的密钥
+type PushDevice struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	P256dh    string    `json:"-"`
+	Auth      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookUpdate 修改 webhook，nil 字段保持不变
+type WebhookUpdate struct {
+	URL     *string
+	Events  []string
+	Enabled *bool
+}
+
+// ReactionSummary 某条消息上某个表情的聚合结果
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// 聊天室成员角色
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// 管理操作，记录在审计日志中
+const (
+	ModerationPromote = "promote"
+	ModerationDemote  = "demote"
+	ModerationKick    = "kick"
+	ModerationBan     = "ban"
+	ModerationUnban   = "unban"
+)
+
+// ModerationAction 一次管理操作，ActorID 为执行者，TargetID 为被操作的用户
+type ModerationAction struct {
+	RoomID   int
+	ActorID  int
+	TargetID int
+	Action   string
+	Reason   string
+}
+
+// RoomMember 聊天室成员
+type RoomMember struct {
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	Role       string     `json:"role"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+// Conversation 两个用户之间的私信会话，UserAID 小于 UserBID
+type Conversation struct {
+	ID        int       `json:"id"`
+	UserAID   int       `json:"-"`
+	UserBID   int       `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasParticipant 判断用户是否是会话的参与者
+func (c Conversation) HasParticipant(userID int) bool {
+	return c.UserAID == userID || c.UserBID == userID
+}
+
+// Participant 会话中对方用户的公开信息
+type Participant struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// ConversationSummary 会话列表中的一项
+type ConversationSummary struct {
+	Conversation
+	OtherUser   Participant `json:"other_user"`
+	LastMessage *Message    `json:"last_message"`
+	UnreadCount int         `json:"unread_count"`
+}
+
+// SearchResult 搜索命中的消息，Snippet 中的匹配词用 <mark></mark> 标出
+type SearchResult struct {
+	Message
+	Snippet string `json:"snippet"`
+}
+
+// 通知类型
+const (
+	NotificationMention = "mention"
+	// NotificationDirectMessage 离线时收到的私信
+	NotificationDirectMessage = "direct_message"
+	// NotificationModeration 针对用户的管理操作（踢出、封禁、解封、角色变更）
+	NotificationModeration = "moderation"
+	// NotificationReaction 其他用户对自己的消息添加了表情
+	NotificationReaction = "reaction"
+	// NotificationReply 其他用户回复了自己的消息
+	NotificationReply = "reply"
+)
+
+// 聊天室通知级别：all 接收所有通知，mentions_only 只接收提及，muted 不接收任何通知
+const (
+	NotificationLevelAll          = "all"
+	NotificationLevelMentionsOnly = "mentions_only"
+	NotificationLevelMuted        = "muted"
+)
+
+// Notification 发给某个用户的通知。消息产生的通知附带消息摘要，管理操作的通知附带操作和原因
+type Notification struct {
+	ID             int    `json:"id"`
+	Type           string `json:"type"`
+	MessageID      int    `json:"message_id,omitempty"`
+	RoomID         int    `json:"room_id,omitempty"`
+	ConversationID int    `json:"conversation_id,omitempty"`
+	UserID         int    `json:"-"`
+	// ActorID 执行管理操作、添加表情或回复的用户，只在创建时使用
+	ActorID int `json:"-"`
+	// FromUsername 触发通知的用户
+	FromUsername string `json:"from_username"`
+	// Content 消息内容，管理操作时为原因
+	Content string `json:"content"`
+	// Action moderation 通知为管理操作的类型，reaction 通知为表情
+	Action string `json:"action,omitempty"`
+	// Payload 创建时保存的结构化数据，内容由通知类型决定，没有时为空对象
+	Payload   json.RawMessage `json:"payload"`
+	Read      bool            `json:"read"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NotificationQuery ListNotifications 的过滤和分页条件，BeforeID 不为 0 时只返回 ID 更小的通知
+type NotificationQuery struct {
+	UnreadOnly bool
+	BeforeID   int
+	Limit      int
+	Offset     int
+}
+
+// NotificationCounts 未读通知数，Mentions 为其中的提及
+type NotificationCounts struct {
+	Unread   int `json:"unread_notifications"`
+	Mentions int `json:"unread_mentions"`
+}
+
+// DefaultAutoPinEmoji 未配置时自动置顶使用的表情
+const DefaultAutoPinEmoji = "📌"
+
+// AutoPinSettings 收到足够多指定表情后自动置顶，Threshold 为 0 表示关闭
+type AutoPinSettings struct {
+	Emoji     string `json:"emoji"`
+	Threshold int    `json:"threshold"`
+}
+
+// 脏话过滤命中时的处理方式
+const (
+	ProfanityBlock  = "block"
+	ProfanityRedact = "redact"
+)
+
+// ProfanitySettings 聊天室的脏话过滤设置
+type ProfanitySettings struct {
+	Enabled bool   `json:"enabled"`
+	Action  string `json:"action"`
+}
+
+// ContentFilterDecision 一次内容过滤的结果，只记录命中的词，不记录消息内容
+type ContentFilterDecision struct {
+	UserID         int
+	RoomID         int
+	ConversationID int
+	Action         string
+	MatchedTerms   []string
+}
+
+// PinnedMessage 置顶消息及置顶者，社区自动置顶的消息 PinnedBy 为空
+type PinnedMessage struct {
+	Message
+	PinnedBy string    `json:"pinned_by"`
+	PinnedAt time.Time `json:"pinned_at"`
+}
+
+// StarredMessage 收藏的消息，RoomName 为消息所在的聊天室，私信为空
+type StarredMessage struct {
+	Message
+	RoomName  string    `json:"room_name,omitempty"`
+	StarredAt time.Time `json:"starred_at"`
+}
+
+// PinChange 自动置顶检查产生的变化
+type PinChange struct {
+	MessageID int
+	Pinned    bool
+	PinnedAt  time.Time
+}
+
+// RoomInvite 聊天室邀请链接。MaxUses 和 ExpiresAt 为空表示不限次数、不过期
+type RoomInvite struct {
+	ID        string     `json:"id"`
+	RoomID    int        `json:"room_id"`
+	CreatedBy *int       `json:"created_by"`
+	MaxUses   *int       `json:"max_uses"`
+	UseCount  int        `json:"use_count"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Disabled  bool       `json:"disabled"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Poll 聊天室投票。Anonymous 为 true 时不返回投票者；
+// MyVotes 为查询投票的用户选择的选项下标，广播给所有人时为空
+type Poll struct {
+	ID             int          `json:"id"`
+	RoomID         int          `json:"room_id"`
+	Question       string       `json:"question"`
+	Options        []PollOption `json:"options"`
+	AllowsMultiple bool         `json:"allows_multiple"`
+	Anonymous      bool         `json:"anonymous"`
+	EndsAt         time.Time    `json:"ends_at"`
+	Closed         bool         `json:"closed"`
+	CreatedBy      *int         `json:"created_by"`
+	CreatedAt      time.Time    `json:"created_at"`
+	TotalVoters    int          `json:"total_voters"`
+	MyVotes        []int        `json:"my_votes"`
+}
+
+// PollOption 投票选项及票数，Voters 为投票者的用户名，匿名投票时为空
+type PollOption struct {
+	Text   string   `json:"text"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+// Bot 机器人令牌，代表所有者调用 API，不会过期。Token 只在创建和轮换时返回
+type Bot struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	OwnerUserID int        `json:"owner_user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	Token       string     `json:"token,omitempty"`
+}
+
+// BotIdentity 通过机器人令牌认证的身份，包括所有者的用户信息
+type BotIdentity struct {
+	BotID    int
+	BotName  string
+	UserID   int
+	Username string
+	Email    string
+}
+
+type UserStore interface {
+	// CreateUser 邮箱或用户名已被占用时返回 ErrUniqueViolation，两者都被占用时 Field 为 email
+	CreateUser(ctx context.Context, username, email, passwordHash string) (User, error)
+	// GetUserByEmail 返回用户和密码哈希
+	GetUserByEmail(ctx context.Context, email string) (User, string, error)
+	UserExists(ctx context.Context, email, username string) (bool, error)
+	GetUser(ctx context.Context, id int) (User, error)
+	// SearchUsers 按用户名前缀（不区分大小写）查找用户，结果不包含 excludeUserID
+	SearchUsers(ctx context.Context, prefix string, excludeUserID, limit int) ([]Participant, error)
+	UpdateProfile(ctx context.Context, id int, update ProfileUpdate) (User, error)
+	// SetAvatar 保存上传的头像，同时把 AvatarURL 改为 url
+	SetAvatar(ctx context.Context, id int, avatar Avatar, url string) (User, error)
+	// GetAvatar 没有上传过头像时返回 ErrNotFound
+	GetAvatar(ctx context.Context, id int) (Avatar, error)
+	GetPasswordHash(ctx context.Context, id int) (string, error)
+	// ChangePassword 用新密码的哈希替换 oldHash 并递增 TokenVersion，返回更新后的用户；
+	// 哈希已经被并发修改时返回 ErrConflict
+	ChangePassword(ctx context.Context, id int, oldHash, newHash string) (User, error)
+	GetTokenVersion(ctx context.Context, id int) (int, error)
+	// UpdatePasswordHash 用新的哈希替换 oldHash（密码不变，例如提高成本因子），
+	// 哈希已经被修改时返回 ErrConflict
+	UpdatePasswordHash(ctx context.Context, id int, oldHash, newHash string) error
+	// TouchLastSeen 把用户的 last_seen_at 更新为当前时间
+	TouchLastSeen(ctx context.Context, id int) error
+}
+
+type PasswordResetStore interface {
+	CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	// ResetPassword 校验令牌并更新密码（同时递增 TokenVersion），令牌无效、过期或已使用时返回 ErrNotFound
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) error
+}
+
+type RoomStore interface {
+	// ListRooms 返回一页聊天室和符合条件的总数，ViewerID 大于 0 时同时返回该用户的未读消息数
+	ListRooms(ctx context.Context, opts RoomListOptions) ([]ChatRoom, int, error)
+	GetRoom(ctx context.Context, id int) (ChatRoom, error)
+	// GetRoomSummary 返回聊天室及 viewerID 的成员身份、成员数、消息数和最后一条消息（只对成员返回）
+	GetRoomSummary(ctx context.Context, id, viewerID int) (ChatRoom, error)
+	// UpdateRoom slowModeSeconds 为 nil 时保持不变
+	UpdateRoom(ctx context.Context, id int, name, description string, slowModeSeconds *int) (ChatRoom, error)
+	DeleteRoom(ctx context.Context, id int) error
+}
+
+type MembershipStore interface {
+	// JoinRoom 重复加入时返回 false
+	JoinRoom(ctx context.Context, roomID, userID int) (bool, error)
+	// LeaveRoom 不是成员时返回 false
+	LeaveRoom(ctx context.Context, roomID, userID int) (bool, error)
+	IsRoomMember(ctx context.Context, roomID, userID int) (bool, error)
+	CountRoomMembers(ctx context.Context, roomID int) (int, error)
+	// ListRoomMembers 按加入时间返回成员
+	ListRoomMembers(ctx context.Context, roomID, limit, offset int) ([]RoomMember, error)
+	// ListUserRoomIDs 返回用户加入的所有聊天室
+	ListUserRoomIDs(ctx context.Context, userID int) ([]int, error)
+	// GetMemberRole 返回成员在聊天室中的角色，不是成员时返回 ErrNotFound
+	GetMemberRole(ctx context.Context, roomID, userID int) (string, error)
+}
+
+type MessageStore interface {
+	// InsertMessage 保存消息并回填 ID、CreatedAt 和发送者信息。
+	// msg.Attachments 中的附件必须由发送者上传且尚未关联消息，否则返回 ErrForeignKey；
+	// 同一用户的 ClientMsgID 已存在时返回 ErrUniqueViolation{Field: "client_msg_id"}；
+	// ScheduledAt 不为 nil 时保存为定时消息，到时间后由 ScheduleStore.DeliverScheduledMessages 发送
+	InsertMessage(ctx context.Context, msg *Message) error
+	GetMessage(ctx context.Context, id int) (Message, error)
+	// GetMessageByClientID 返回用户以 clientMsgID 发送的未删除消息
+	GetMessageByClientID(ctx context.Context, userID int, clientMsgID string) (Message, error)
+	// DeleteExpiredMessages 物理删除在 now 之前到期的消息并返回它们
+	DeleteExpiredMessages(ctx context.Context, now time.Time) ([]ExpiredMessage, error)
+	// ExpireClientMessageIDs 清除 before 之前发送的消息的 ClientMsgID，返回清除的数量
+	ExpireClientMessageIDs(ctx context.Context, before time.Time) (int64, error)
+	// LatestRoomMessageID 返回聊天室最新一条消息的 ID，没有消息时返回 0
+	LatestRoomMessageID(ctx context.Context, roomID int) (int, error)
+	// ListRoomMessages 按 ID 升序返回一页聊天室消息及表情汇总，ViewerID 用于计算 Reacted，
+	// 支持屏蔽的实现同时排除 ViewerID 屏蔽的用户的消息
+	ListRoomMessages(ctx context.Context, opts MessagePageOptions) ([]Message, error)
+	// ListRoomMessagesAfter 按 ID 顺序返回聊天室中 ID 大于 afterID 的消息，用于断线重连后补发
+	ListRoomMessagesAfter(ctx context.Context, roomID, afterID, viewerID, limit int) ([]Message, error)
+	// GetThreadRoot 与 GetMessage 相同，但已删除的消息也会返回（Deleted 为 true），讨论串因此得以保留
+	GetThreadRoot(ctx context.Context, id int) (Message, error)
+	// ListReplies 按时间顺序返回某条消息的直接回复
+	ListReplies(ctx context.Context, parentID, viewerID, limit, offset int) ([]Message, error)
+	// SearchRoomMessages 全文搜索聊天室消息，按相关度和时间排序
+	SearchRoomMessages(ctx context.Context, roomID int, query string, limit, offset int) ([]SearchResult, error)
+}
+
+type ConversationStore interface {
+	// GetOrCreateConversation 返回两个用户之间的会话，不存在时创建，created 表示是否新建
+	GetOrCreateConversation(ctx context.Context, userID, otherUserID int) (conv Conversation, created bool, err error)
+	GetConversation(ctx context.Context, id int) (Conversation, error)
+	// ListConversations 按最后一条消息时间倒序返回用户的会话
+	ListConversations(ctx context.Context, userID int) ([]ConversationSummary, error)
+	// ListConversationMessages 按时间顺序返回会话消息，offset 从最新的消息开始计算
+	ListConversationMessages(ctx context.Context, conversationID, viewerID, limit, offset int) ([]Message, error)
+	// MarkConversationRead 已读位置只能前进，位置没有变化时返回 false
+	MarkConversationRead(ctx context.Context, userID, conversationID, messageID int) (bool, error)
+}
+
+type ReactionStore interface {
+	// AddReaction 重复添加时返回 false
+	AddReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error)
+	// RemoveReaction 没有可删除的记录时返回 false
+	RemoveReaction(ctx context.Context, messageID, userID int, emoji string) (bool, error)
+	ListReactions(ctx context.Context, messageID, viewerID int) ([]ReactionSummary, error)
+}
+
+type ReadStore interface {
+	// MarkRead 已读位置只能前进，位置没有变化时返回 false
+	MarkRead(ctx context.Context, userID, roomID, messageID int) (bool, error)
+}
+
+// ModerationStore 聊天室管理操作，每个操作都在同一事务中写入审计日志
+type ModerationStore interface {
+	// SetMemberRole 修改成员角色，不是成员时返回 ErrNotFound
+	SetMemberRole(ctx context.Context, action ModerationAction, role string) error
+	// KickMember 移除成员，不是成员时返回 ErrNotFound
+	KickMember(ctx context.Context, action ModerationAction) error
+	// BanMember 移除成员（如果是）并封禁，已被封禁时更新原因
+	BanMember(ctx context.Context, action ModerationAction) error
+	// UnbanMember 解除封禁，没有被封禁时返回 ErrNotFound
+	UnbanMember(ctx context.Context, action ModerationAction) error
+	IsBanned(ctx context.Context, roomID, userID int) (bool, error)
+	// DeleteRoomMessages 软删除聊天室中的多条消息，返回本次删除的 ID（已删除的不包括在内）。
+	// 有任何 ID 不属于该聊天室时返回 ErrNotFound，不删除任何消息
+	DeleteRoomMessages(ctx context.Context, roomID int, messageIDs []int) ([]int, error)
+	// GetProfanitySettings 没有设置过时返回关闭状态
+	GetProfanitySettings(ctx context.Context, roomID int) (ProfanitySettings, error)
+	SaveProfanitySettings(ctx context.Context, roomID int, settings ProfanitySettings) error
+	// RecordContentFilterDecision 记录全局内容过滤替换或拒绝的消息
+	RecordContentFilterDecision(ctx context.Context, d ContentFilterDecision) error
+}
+
+type AdminStore interface {
+	// ListUsers 返回一页未删除的用户和符合条件的总数，search 不区分大小写地匹配用户名和邮箱
+	ListUsers(ctx context.Context, search string, limit, offset int) ([]AdminUser, int, error)
+	IsAdmin(ctx context.Context, userID int) (bool, error)
+	// SetUserDisabled 停用或启用用户，停用时递增 TokenVersion 使已签发的 token 失效。
+	// 用户不存在或已删除时返回 ErrNotFound
+	SetUserDisabled(ctx context.Context, userID int, disabled bool) (AdminUser, error)
+	// AnonymizeUser 在一个事务中清除用户的个人信息（用户名改为 deleted_user_{id}，邮箱清空，密码哈希换成随机值）、
+	// 使 token 失效，退出所有聊天室并删除表情回应、通知等数据；消息保留但与用户断开关联，
+	// 发送者显示为 DeletedUserName，内容替换为 DeletedMessageContent。对已删除的用户重复执行没有副作用，
+	// 用户不存在时返回 ErrNotFound
+	AnonymizeUser(ctx context.Context, userID int) error
+	// PromoteAdmin 把邮箱对应的用户设为管理员，用户不存在时返回 false
+	PromoteAdmin(ctx context.Context, email string) (bool, error)
+}
+
+// ScheduleStore 定时消息。发送前的定时消息只出现在作者自己的消息列表中
+type ScheduleStore interface {
+	// DeliverScheduledMessages 把发送时间不晚于 now 的定时消息标记为已发送（created_at 改为当前时间）并返回。
+	// 每条消息只会被一个调用者取到，多个实例可以同时调用
+	DeliverScheduledMessages(ctx context.Context, now time.Time) ([]Message, error)
+	// ListScheduledMessages 按发送时间返回用户尚未发送的定时消息
+	ListScheduledMessages(ctx context.Context, userID int) ([]Message, error)
+	// CancelScheduledMessage 删除用户尚未发送的定时消息，不存在、不属于该用户或已发送时返回 ErrNotFound
+	CancelScheduledMessage(ctx context.Context, messageID, userID int) error
+}
+
+type WebhookStore interface {
+	// CreateWebhook 保存 webhook 并回填 ID、CreatedBy 和 CreatedAt
+	CreateWebhook(ctx context.Context, webhook *Webhook, createdBy int) error
+	// ListWebhooks 返回聊天室的所有 webhook，不包含 Secret
+	ListWebhooks(ctx context.Context, roomID int) ([]Webhook, error)
+	// ListEnabledWebhooks 返回聊天室启用且订阅了 event 的 webhook，包含 Secret，用于发送
+	ListEnabledWebhooks(ctx context.Context, roomID int, event string) ([]Webhook, error)
+	// UpdateWebhook webhook 不属于该聊天室时返回 ErrNotFound，结果不包含 Secret
+	UpdateWebhook(ctx context.Context, roomID, id int, update WebhookUpdate) (Webhook, error)
+	// DeleteWebhook webhook 不属于该聊天室时返回 ErrNotFound
+	DeleteWebhook(ctx context.Context, roomID, id int) error
+
+	// CreateIncomingWebhook 保存入站 webhook 及令牌哈希，回填 ID 和 CreatedAt
+	CreateIncomingWebhook(ctx context.Context, webhook *IncomingWebhook, tokenHash string, createdBy int) error
+	// ListIncomingWebhooks 返回聊天室未撤销的入站 webhook
+	ListIncomingWebhooks(ctx context.Context, roomID int) ([]IncomingWebhook, error)
+	// GetIncomingWebhookByToken 令牌不存在或已撤销时返回 ErrNotFound
+	GetIncomingWebhookByToken(ctx context.Context, tokenHash string) (IncomingWebhook, error)
+	// RevokeIncomingWebhook 撤销入站 webhook，不属于该聊天室或已撤销时返回 ErrNotFound
+	RevokeIncomingWebhook(ctx context.Context, roomID, id int) error
+
+	// GetRoomWebhookSecretHash 返回聊天室共享密钥的哈希，没有设置过时返回 ErrNotFound
+	GetRoomWebhookSecretHash(ctx context.Context, roomID int) (string, error)
+	// SetRoomWebhookSecretHash 设置或替换聊天室共享密钥的哈希，旧密钥立即失效
+	SetRoomWebhookSecretHash(ctx context.Context, roomID int, secretHash string) error
+}
+
+type BlockStore interface {
+	// BlockUser 重复屏蔽没有副作用
+	BlockUser(ctx context.Context, blockerID, blockedID int) error
+	// UnblockUser 没有屏蔽时没有副作用
+	UnblockUser(ctx context.Context, blockerID, blockedID int) error
+	// ListBlockedUserIDs 返回用户屏蔽的所有用户
+	ListBlockedUserIDs(ctx context.Context, blockerID int) ([]int, error)
+	// ListBlockedUsers 按屏蔽时间倒序返回用户屏蔽的用户
+	ListBlockedUsers(ctx context.Context, blockerID int) ([]BlockedUser, error)
+	// IsBlockedBetween 任意一方屏蔽了另一方时返回 true
+	IsBlockedBetween(ctx context.Context, userA, userB int) (bool, error)
+}
+
+// BlockedUser 被屏蔽的用户及屏蔽时间
+type BlockedUser struct {
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	BlockedAt   time.Time `json:"blocked_at"`
+}
+
+// 已删除用户的消息显示的发送者名称和内容
+const (
+	DeletedUserName       = "deleted user"
+	DeletedMessageContent = "[deleted]"
+)
+
+// ExportStore 按行读取用户的个人数据，每行调用一次 fn，fn 返回错误时停止查询
+type ExportStore interface {
+	GetExportProfile(ctx context.Context, userID int) (ExportProfile, error)
+	// ExportRoomMessages 用户在聊天室中发送的消息（包括已删除的），按 ID 排序
+	ExportRoomMessages(ctx context.Context, userID int, fn func(ExportedMessage) error) error
+	// ExportDirectMessages 用户参与的私信会话中的所有消息，包括对方发送的
+	ExportDirectMessages(ctx context.Context, userID int, fn func(ExportedMessage) error) error
+	ExportReactions(ctx context.Context, userID int, fn func(ExportedReaction) error) error
+	ExportNotifications(ctx context.Context, userID int, fn func(Notification) error) error
+	// RecordUserAudit 记录用户账号相关的操作
+	RecordUserAudit(ctx context.Context, userID int, action string) error
+}
+
+// UserAuditDataExport 用户导出了自己的数据
+const UserAuditDataExport = "data_export"
+
+// ExportProfile 导出的账号信息，包含 User 中不返回给客户端的字段
+type ExportProfile struct {
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsAdmin     bool      `json:"is_admin"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExportedMessage 导出的消息。聊天室消息带聊天室名称，私信带会话 ID 和发送者
+type ExportedMessage struct {
+	ID             int        `json:"id"`
+	RoomID         int        `json:"room_id,omitempty"`
+	RoomName       string     `json:"room_name,omitempty"`
+	ConversationID int        `json:"conversation_id,omitempty"`
+	Sender         string     `json:"sender,omitempty"`
+	Content        string     `json:"content"`
+	ParentID       *int       `json:"parent_message_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportedReaction 用户添加的表情回应
+type ExportedReaction struct {
+	MessageID int       `json:"message_id"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PushStore interface {
+	// RegisterPushDevice 保存设备并回填 ID 和 CreatedAt，令牌已存在时转移给当前用户
+	RegisterPushDevice(ctx context.Context, device *PushDevice) error
+	ListPushDevices(ctx context.Context, userID int) ([]PushDevice, error)
+	// DeletePushDevice 设备不属于该用户时返回 ErrNotFound
+	DeletePushDevice(ctx context.Context, userID, id int) error
+	// DeletePushTokens 删除推送服务报告为无效的令牌
+	DeletePushTokens(ctx context.Context, tokens []string) error
+}
+
+type StatsStore interface {
+	// RoomStats 在一次查询中计算聊天室统计
+	RoomStats(ctx context.Context, roomID int) (RoomStats, error)
+}
+
+type PinStore interface {
+	GetAutoPinSettings(ctx context.Context, roomID int) (AutoPinSettings, error)
+	SaveAutoPinSettings(ctx context.Context, roomID int, settings AutoPinSettings) error
+	// ApplyAutoPin 根据表情数量自动置顶或取消置顶，没有变化时返回 nil
+	ApplyAutoPin(ctx context.Context, roomID, messageID int, settings AutoPinSettings, maxPins int) (*PinChange, error)
+	// PinMessage 手动置顶消息，已经置顶时返回 nil，达到上限时返回 ErrLimitExceeded
+	PinMessage(ctx context.Context, roomID, messageID, userID, maxPins int) (*PinChange, error)
+	// UnpinMessage 取消置顶，没有置顶时返回 false
+	UnpinMessage(ctx context.Context, roomID, messageID int) (bool, error)
+	// ListPinnedMessages 按置顶时间返回聊天室的置顶消息
+	ListPinnedMessages(ctx context.Context, roomID, viewerID int) ([]PinnedMessage, error)
+}
+
+type StarStore interface {
+	// StarMessage 收藏消息，已经收藏时没有副作用
+	StarMessage(ctx context.Context, messageID, userID int) error
+	// UnstarMessage 取消收藏，没有收藏时返回 false
+	UnstarMessage(ctx context.Context, messageID, userID int) (bool, error)
+	// ListStarredMessages 按收藏时间倒序返回用户收藏的、仍然可以看到的消息
+	ListStarredMessages(ctx context.Context, userID, limit, offset int) ([]StarredMessage, error)
+}
+
+type NotificationStore interface {
+	// CreateMentionNotifications 记录 message_mentions 并为被提及的用户创建通知，
+	// 不存在的用户名和 excludeUserID 被忽略，静音了该聊天室的用户只记录提及、不创建通知
+	CreateMentionNotifications(ctx context.Context, messageID int, usernames []string, excludeUserID int, payload json.RawMessage) ([]Notification, error)
+	// ListMentions 按时间倒序返回提及该用户的消息
+	ListMentions(ctx context.Context, userID, limit, offset int) ([]Message, error)
+	// CreateNotification 创建私信或管理操作的通知，返回完整的通知
+	CreateNotification(ctx context.Context, n Notification) (Notification, error)
+	// ListNotifications 按时间倒序返回用户的通知
+	ListNotifications(ctx context.Context, userID int, q NotificationQuery) ([]Notification, error)
+	// MarkNotificationRead 通知不存在或不属于该用户时返回 ErrNotFound
+	MarkNotificationRead(ctx context.Context, userID, id int) error
+	// MarkNotificationsRead 批量标记已读，忽略不存在或不属于该用户的 ID，返回本次标记的数量
+	MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error)
+	// MarkAllNotificationsRead 把用户的所有通知标记为已读，返回本次标记的数量
+	MarkAllNotificationsRead(ctx context.Context, userID int) (int, error)
+	// CountUnreadNotifications 与 ListNotifications 一致，不计入消息已删除的通知
+	CountUnreadNotifications(ctx context.Context, userID int) (NotificationCounts, error)
+	// GetRoomNotificationLevel 没有设置时返回 NotificationLevelAll
+	GetRoomNotificationLevel(ctx context.Context, userID, roomID int) (string, error)
+	SetRoomNotificationLevel(ctx context.Context, userID, roomID int, level string) error
+}
+
+type AttachmentStore interface {
+	// CreateAttachment 保存附件元数据并回填 ID、URL 和 CreatedAt
+	CreateAttachment(ctx context.Context, att *Attachment) error
+	GetAttachment(ctx context.Context, id int) (Attachment, error)
+	// GetAttachments 返回存在的附件，不存在的 ID 被忽略
+	GetAttachments(ctx context.Context, ids []int) ([]Attachment, error)
+	SetAttachmentThumbnail(ctx context.Context, id int, key, contentType string) error
+}
+
+type InviteStore interface {
+	// CreateInvite 创建邀请链接并回填 ID、UseCount 和 CreatedAt
+	CreateInvite(ctx context.Context, invite *RoomInvite) error
+	GetInvite(ctx context.Context, id string) (RoomInvite, error)
+	// ListActiveInvites 返回聊天室中仍然可以使用的邀请，按创建时间倒序
+	ListActiveInvites(ctx context.Context, roomID int) ([]RoomInvite, error)
+	// AcceptInvite 校验邀请并把用户加入聊天室，只有新加入的用户消耗使用次数。
+	// 邀请不存在时返回 ErrNotFound，无法使用时返回 ErrInviteDisabled、ErrInviteExpired 或 ErrInviteExhausted，
+	// 被封禁的用户不会被加入
+	AcceptInvite(ctx context.Context, id string, userID int) (roomID int, joined bool, err error)
+	// DisableInvite 邀请不存在时返回 ErrNotFound，已经停用时没有副作用
+	DisableInvite(ctx context.Context, id string) error
+}
+
+type PollStore interface {
+	// CreatePoll 保存投票并回填 ID 和 CreatedAt，Options 只使用 Text
+	CreatePoll(ctx context.Context, poll *Poll) error
+	// GetPoll 返回投票及各选项的票数，viewerID 不为 0 时填充 MyVotes
+	GetPoll(ctx context.Context, id, viewerID int) (Poll, error)
+	// VotePoll 用 options 替换用户之前的选择。投票不存在时返回 ErrNotFound，已经截止时返回 ErrPollClosed
+	VotePoll(ctx context.Context, pollID, userID int, options []int) error
+}
+
+type BotStore interface {
+	// CreateBot 保存机器人并回填 ID 和 CreatedAt
+	CreateBot(ctx context.Context, bot *Bot, tokenHash string) error
+	// ListBots 返回用户创建的机器人，按创建时间排列
+	ListBots(ctx context.Context, ownerID int) ([]Bot, error)
+	// RotateBotToken 替换令牌哈希，旧令牌立即失效。机器人不存在或不属于该用户时返回 ErrNotFound
+	RotateBotToken(ctx context.Context, id, ownerID int, tokenHash string) (Bot, error)
+	// DeleteBot 机器人不存在或不属于该用户时返回 ErrNotFound
+	DeleteBot(ctx context.Context, id, ownerID int) error
+	// AuthenticateBot 按令牌哈希查找机器人并更新 last_used_at。
+	// 令牌不存在或所有者已被停用、删除时返回 ErrNotFound
+	AuthenticateBot(ctx context.Context, tokenHash string) (BotIdentity, error)
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker 在多个实例之间转发 Hub 消息。每个实例都会收到所有消息（包括自己发布的），
+// 再投递给本地连接。未配置 Broker 时 Hub 只在进程内广播。
+type Broker interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe 阻塞直到 ctx 取消，收到的每条消息都交给 handle
+	Subscribe(ctx context.Context, handle func(payload []byte)) error
+}
+
+// hubMessage Broker 上传输的消息。除了事件，成员变化、屏蔽和聊天室删除也要通知所有实例，
+// 因为对应用户的连接可能在其他实例上。
+type hubMessage struct {
+	Kind string `json:"kind"`
+
+	// kind = event
+	Type     string          `json:"type,omitempty"`
+	RoomID   int             `json:"room_id,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	UserIDs  []int           `json:"user_ids,omitempty"`
+	SenderID int             `json:"sender_id,omitempty"`
+	Everyone bool            `json:"everyone,omitempty"`
+
+	// kind = membership / remove_member / block / disconnect
+	UserID int  `json:"user_id,omitempty"`
+	Member bool `json:"member,omitempty"`
+
+	// kind = block
+	BlockedID int  `json:"blocked_id,omitempty"`
+	Blocked   bool `json:"blocked,omitempty"`
+
+	// kind = close_room / remove_member / disconnect
+	Reason string `json:"reason,omitempty"`
+
+	// kind = remove_member
+	Action string `json:"action,omitempty"`
+}
+
+const (
+	hubMessageEvent        = "event"
+	hubMessageMembership   = "membership"
+	hubMessageCloseRoom    = "close_room"
+	hubMessageRemoveMember = "remove_member"
+	hubMessageBlock        = "block"
+	hubMessageDisconnect   = "disconnect"
+)
+
+const brokerPublishTimeout = 2 * time.Second
+
+// RedisBroker 基于 Redis pub/sub 的 Broker
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBroker 根据 redis:// 地址创建 Broker
+func NewRedisBroker(url, channel string) (*RedisBroker, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBroker{client: redis.NewClient(opts), channel: channel}, nil
+}
+
+func (b *RedisBroker) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, payload []byte) error {
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, handle func(payload []byte)) error {
+	sub := b.client.Subscribe(ctx, b.channel)
+	defer sub.Close()
+
+	// 等待订阅确认，保证之后发布的消息不会丢失
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handle([]byte(msg.Payload))
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
+
+// send 把消息发布到 Broker，失败时返回 false，由调用方退回到只在本实例处理
+func (h *Hub) send(msg hubMessage) bool {
+	payload, err := json.Marshal(msg)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), brokerPublishTimeout)
+		defer cancel()
+		err = h.Broker.Publish(ctx, payload)
+	}
+	if err != nil {
+		slog.Error("broker publish failed, delivering locally", "kind", msg.Kind, "error", err)
+		return false
+	}
+	return true
+}
+
+// receive 处理从 Broker 收到的消息
+func (h *Hub) receive(payload []byte) {
+	var msg hubMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		slog.Warn("invalid broker message", "error", err)
+		return
+	}
+	switch msg.Kind {
+	case hubMessageEvent:
+		h.publishLocal(Event{Type: msg.Type, RoomID: msg.RoomID, Data: msg.Data, UserIDs: msg.UserIDs, SenderID: msg.SenderID, Everyone: msg.Everyone})
+	case hubMessageMembership:
+		h.applyMembership(msg.UserID, msg.RoomID, msg.Member)
+	case hubMessageCloseRoom:
+		h.applyCloseRoom(msg.RoomID, msg.Reason)
+	case hubMessageRemoveMember:
+		h.applyRemoveFromRoom(msg.UserID, msg.RoomID, msg.Action, msg.Reason)
+	case hubMessageBlock:
+		h.applyBlocked(msg.UserID, msg.BlockedID, msg.Blocked)
+	case hubMessageDisconnect:
+		h.applyDisconnectUser(msg.UserID, msg.Reason)
+	}
+}
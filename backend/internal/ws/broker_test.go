@@ -0,0 +1,175 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker 在进程内把消息转发给所有订阅者，模拟多个实例共用的 Redis
+type fakeBroker struct {
+	mu   sync.Mutex
+	err  error
+	subs map[int]func(payload []byte)
+	next int
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{subs: make(map[int]func([]byte))}
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, payload []byte) error {
+	b.mu.Lock()
+	if b.err != nil {
+		defer b.mu.Unlock()
+		return b.err
+	}
+	subs := make([]func([]byte), 0, len(b.subs))
+	for _, handle := range b.subs {
+		subs = append(subs, handle)
+	}
+	b.mu.Unlock()
+	for _, handle := range subs {
+		handle(payload)
+	}
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(ctx context.Context, handle func(payload []byte)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = handle
+	b.mu.Unlock()
+
+	<-ctx.Done()
+	b.mu.Lock()
+	delete(b.subs, id)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *fakeBroker) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// waitSubscribers 等待 n 个 Hub 完成订阅，之前发布的消息会丢失
+func (b *fakeBroker) waitSubscribers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.Lock()
+		got := len(b.subs)
+		b.mu.Unlock()
+		if got >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d hubs subscribed", got, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestBrokerDeliversAcrossInstances 一个实例发布的事件和成员变化经过 Broker 到达另一个实例上的连接
+func TestBrokerDeliversAcrossInstances(t *testing.T) {
+	broker := newFakeBroker()
+	a := newTestHub(t, broker)
+	b := newTestHub(t, broker)
+	broker.waitSubscribers(t, 2)
+	_, rec := subscribe(b, 2, 0, 1)
+
+	a.Publish(Event{Type: EventMessage, RoomID: 1, Data: map[string]string{"content": "hello"}})
+	event := rec.next(t)
+	if event.Type != EventMessage || event.RoomID != 1 || string(event.Data.(json.RawMessage)) != `{"content":"hello"}` {
+		t.Fatalf("event = %+v", event)
+	}
+
+	// 在实例 a 上加入聊天室，实例 b 上的连接开始接收该聊天室的事件
+	a.SetMembership(2, 3, true)
+	a.Publish(Event{Type: EventMessage, RoomID: 3, Data: "joined"})
+	if event := rec.next(t); event.RoomID != 3 {
+		t.Fatalf("event = %+v, want room 3", event)
+	}
+
+	a.Publish(Event{Type: EventNotification, Data: "direct", UserIDs: []int{2}})
+	if event := rec.next(t); event.Type != EventNotification {
+		t.Fatalf("event = %+v, want the notification", event)
+	}
+
+	a.DisconnectUser(2, "account deleted")
+	if code := rec.waitClosed(t); code != closeAccountDeleted {
+		t.Fatalf("close code = %d, want %d", code, closeAccountDeleted)
+	}
+}
+
+// TestBrokerPublishFailureDeliversLocally Broker 不可用时退回到只投递给本实例的连接
+func TestBrokerPublishFailureDeliversLocally(t *testing.T) {
+	broker := newFakeBroker()
+	a := newTestHub(t, broker)
+	b := newTestHub(t, broker)
+	broker.waitSubscribers(t, 2)
+	_, local := subscribe(a, 1, 0, 1)
+	_, remote := subscribe(b, 2, 0, 1)
+
+	broker.setErr(errors.New("connection refused"))
+	a.Publish(Event{Type: EventMessage, RoomID: 1, Data: "hello"})
+	if event := local.next(t); event.Data != "hello" {
+		t.Fatalf("local event = %+v", event)
+	}
+
+	broker.setErr(nil)
+	a.Publish(Event{Type: EventRoomUpdated, RoomID: 1, Data: "after"})
+	if event := remote.next(t); event.Type != EventRoomUpdated {
+		t.Fatalf("remote connection received %+v, want only the event published after recovery", event)
+	}
+}
+
+// TestRedisBroker 使用 TEST_REDIS_URL 指定的 Redis 在两个 Hub 之间转发事件，未设置时跳过
+func TestRedisBroker(t *testing.T) {
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL is not set")
+	}
+	channel := "chat-test-" + t.Name()
+	hubs := make([]*Hub, 2)
+	for i := range hubs {
+		broker, err := NewRedisBroker(url, channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { broker.Close() })
+		if err := broker.Ping(context.Background()); err != nil {
+			t.Fatalf("connect to redis: %v", err)
+		}
+		hubs[i] = newTestHub(t, broker)
+	}
+	_, rec := subscribe(hubs[1], 2, 1, 1)
+
+	// 订阅在后台建立，重复发布直到另一个实例收到
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hubs[0].Publish(Event{Type: EventMessage, RoomID: 1, Data: "hello"})
+		select {
+		case event := <-rec.events:
+			if event.Type != EventMessage || string(event.Data.(json.RawMessage)) != `"hello"` {
+				t.Fatalf("event = %+v", event)
+			}
+			hubs[0].CloseRoom(1, "deleted")
+			if code := rec.waitClosed(t); code != closeRoomDeleted {
+				t.Fatalf("close code = %d, want %d", code, closeRoomDeleted)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event was not delivered through redis")
+		}
+	}
+}
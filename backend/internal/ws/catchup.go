@@ -0,0 +1,94 @@
+package ws
+
+import (
+	"encoding/json"
+
+	"chatapp/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// 补发期间最多缓存的实时事件数，超过时关闭连接让客户端重新连接
+const maxPendingEvents = 1000
+
+// CatchingUp 连接是否正在补发断线期间的消息
+func (c *Client) CatchingUp() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return c.catchingUp
+}
+
+// Subscribed 判断连接是否会收到该聊天室的事件
+func (c *Client) Subscribed(roomID int) bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return (c.RoomID == 0 || c.RoomID == roomID) && c.rooms[roomID]
+}
+
+// queue 补发期间缓存实时事件，返回 false 表示缓存已满
+func (c *Client) queue(event Event) bool {
+	if len(c.pending) >= maxPendingEvents {
+		return false
+	}
+	c.pending = append(c.pending, event)
+	return true
+}
+
+// FinishCatchUp 发送补发期间缓存的事件（跳过 sent 中已经补发的消息）并切换到实时推送。
+// 发送缓存时不持有锁，期间到达的事件继续缓存，直到缓存为空才切换。
+// 切换之后才广播的已补发消息同样跳过，连接持有 sent，调用方不能再修改
+func (h *Hub) FinishCatchUp(c *Client, sent map[int]bool) {
+	for {
+		h.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		if len(pending) == 0 {
+			c.catchingUp = false
+			c.caughtUp = sent
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+
+		for _, event := range pending {
+			if event.Type == EventMessage && sent[EventMessageID(event)] {
+				delete(sent, EventMessageID(event))
+				continue
+			}
+			c.Send(event)
+		}
+	}
+}
+
+// skipCaughtUp 判断实时事件是否是已经补发过的消息。调用方持有 hub.mu
+func (c *Client) skipCaughtUp(event Event) bool {
+	if len(c.caughtUp) == 0 || event.Type != EventMessage {
+		return false
+	}
+	id := EventMessageID(event)
+	if !c.caughtUp[id] {
+		return false
+	}
+	delete(c.caughtUp, id)
+	return true
+}
+
+// EventMessageID 取出消息事件中的消息 ID，经过 Broker 转发的事件 Data 是原始 JSON
+func EventMessageID(event Event) int {
+	switch data := event.Data.(type) {
+	case store.Message:
+		return data.ID
+	case json.RawMessage:
+		var msg struct {
+			ID int `json:"id"`
+		}
+		json.Unmarshal(data, &msg)
+		return msg.ID
+	}
+	return 0
+}
+
+// dropSlowCatchUp 补发期间缓存溢出时关闭连接，客户端重连后再次补发
+func (h *Hub) dropSlowCatchUp(c *Client) {
+	h.closeLocked(c, websocket.CloseTryAgainLater, "too many events during catch-up")
+}
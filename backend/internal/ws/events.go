@@ -0,0 +1,14 @@
+package ws
+
+// RoomDeletedEvent 聊天室被删除时推送给房间内客户端
+type RoomDeletedEvent struct {
+	RoomID int    `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// RemovedFromRoomEvent 发送给被踢出或封禁的用户
+type RemovedFromRoomEvent struct {
+	RoomID int    `json:"room_id"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
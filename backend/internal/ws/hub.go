@@ -0,0 +1,530 @@
+// Package ws 管理实时连接（WebSocket 和 SSE）并向它们广播事件，多实例部署时通过 Broker 转发
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WebSocket 事件类型
+const (
+	EventMessage             = "message"
+	EventDirectMessage       = "direct_message"
+	EventReactionAdded       = "reaction_added"
+	EventReactionRemoved     = "reaction_removed"
+	EventReactionUpdated     = "reaction_updated"
+	EventRoomUpdated         = "room_updated"
+	EventRoomDeleted         = "room_deleted"
+	EventMessagePinned       = "message_pinned"
+	EventMessageUnpinned     = "message_unpinned"
+	EventRead                = "read"
+	EventRoomRead            = "room_read"
+	EventNotification        = "notification"
+	EventBadge               = "badge"
+	EventMention             = "mention"
+	EventMemberJoined        = "member_joined"
+	EventMemberLeft          = "member_left"
+	EventModeration          = "moderation"
+	EventMessagesBulkDeleted = "messages_bulk_deleted"
+	EventMessageExpired      = "message_expired"
+	EventRemovedFromRoom     = "removed_from_room"
+	EventError               = "error"
+	EventWelcome             = "welcome"
+	EventResyncRequired      = "resync_required"
+	EventResumed             = "resumed"
+	EventAnnouncement        = "announcement"
+	EventAck                 = "ack"
+	EventNack                = "nack"
+	EventPollCreated         = "poll_created"
+	EventPollUpdated         = "poll_updated"
+)
+
+// 自定义 WebSocket 关闭码
+const (
+	closeRoomDeleted     = 4000
+	closeRemovedFromRoom = 4403
+	closeAccountDeleted  = 4410
+	// closeTooManyConnections 用户的连接数超过上限，最早的连接被关闭
+	closeTooManyConnections = 4408
+)
+
+// Event 推送给 WebSocket 客户端的事件
+type Event struct {
+	Type   string      `json:"type"`
+	RoomID int         `json:"room_id"`
+	Data   interface{} `json:"data"`
+
+	// AckID 和 MessageID 只出现在 ack/nack 事件中，对应客户端消息的 ack_id 和保存后的消息 ID
+	AckID     string `json:"ack_id,omitempty"`
+	MessageID int    `json:"message_id,omitempty"`
+
+	// UserIDs 不为空时只发送给这些用户的所有连接，忽略 RoomID
+	UserIDs []int `json:"-"`
+	// SenderID 消息的发送者，不发送给屏蔽了该用户的连接
+	SenderID int `json:"-"`
+	// Everyone 为 true 时发送给所有连接，忽略 RoomID 和 UserIDs
+	Everyone bool `json:"-"`
+}
+
+// deliverTo 判断事件是否应该发送给该连接
+func (e Event) deliverTo(c *Client) bool {
+	if e.Everyone {
+		return true
+	}
+	if e.SenderID != 0 && c.blocked[e.SenderID] {
+		return false
+	}
+	if len(e.UserIDs) > 0 {
+		for _, id := range e.UserIDs {
+			if c.UserID != 0 && c.UserID == id {
+				return true
+			}
+		}
+		return false
+	}
+	return (c.RoomID == 0 || c.RoomID == e.RoomID) && c.rooms[e.RoomID]
+}
+
+// Hub 管理所有 WebSocket 连接并向它们广播事件
+type Hub struct {
+	clients map[*Client]bool
+	// byUser 已认证用户的连接，按注册顺序排列，由 mu 保护
+	byUser    map[int][]*Client
+	broadcast chan Event
+	done      chan struct{} // HandleMessages 退出后关闭
+	mu        sync.Mutex
+	metrics   hubMetrics
+	// sendBuffer 每个连接等待写入的事件数上限
+	sendBuffer int
+	// maxPerUser 每个用户的连接数上限
+	maxPerUser int
+
+	// Broker 不为 nil 时事件经过 Broker 转发，所有实例的连接都能收到。在 HandleMessages 之前设置
+	Broker Broker
+}
+
+type hubMetrics struct {
+	broadcast prometheus.Counter
+	dropped   prometheus.Counter
+	latency   prometheus.Histogram
+	evicted   prometheus.Counter
+	limited   prometheus.Counter
+}
+
+// NewHub bufferSize 为等待广播的事件数上限，缓冲区满时新事件会被丢弃；
+// sendBuffer 为每个连接等待写入的事件数上限，写满时断开该连接；
+// maxPerUser 为每个用户的连接数上限，超出时关闭该用户最早的连接
+func NewHub(bufferSize, sendBuffer, maxPerUser int) *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		byUser:     make(map[int][]*Client),
+		broadcast:  make(chan Event, bufferSize),
+		done:       make(chan struct{}),
+		sendBuffer: sendBuffer,
+		maxPerUser: maxPerUser,
+		metrics: hubMetrics{
+			broadcast: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "chat_messages_broadcast_total",
+				Help: "Total number of chat messages broadcast to WebSocket clients.",
+			}),
+			dropped: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "chat_broadcasts_dropped_total",
+				Help: "Total number of events dropped because the broadcast buffer was full.",
+			}),
+			latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+				Name:    "chat_broadcast_fanout_seconds",
+				Help:    "Time spent delivering one event to all subscribed clients.",
+				Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+			}),
+			evicted: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "chat_slow_clients_evicted_total",
+				Help: "Total number of connections closed because their send queue was full.",
+			}),
+			limited: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "chat_connections_limited_total",
+				Help: "Total number of connections closed because the user exceeded the per-user connection limit.",
+			}),
+		},
+	}
+}
+
+// Collectors 返回 Hub 的 Prometheus 指标，由调用方注册
+func (h *Hub) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{h.metrics.broadcast, h.metrics.dropped, h.metrics.latency, h.metrics.evicted, h.metrics.limited}
+}
+
+// Publish 广播事件。配置了 Broker 时发布到 Broker，由各实例的 HandleMessages 投递
+func (h *Hub) Publish(event Event) {
+	if h.Broker != nil {
+		data, err := json.Marshal(event.Data)
+		if err == nil && h.send(hubMessage{
+			Kind:     hubMessageEvent,
+			Type:     event.Type,
+			RoomID:   event.RoomID,
+			Data:     data,
+			UserIDs:  event.UserIDs,
+			SenderID: event.SenderID,
+			Everyone: event.Everyone,
+		}) {
+			return
+		}
+	}
+	h.publishLocal(event)
+}
+
+// publishLocal 把事件交给本实例的 HandleMessages 广播，不会阻塞调用方。
+// 缓冲区已满或服务已关闭时丢弃事件：消息已经保存，客户端可以通过历史记录补齐。
+func (h *Hub) publishLocal(event Event) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+	select {
+	case h.broadcast <- event:
+	default:
+		h.metrics.dropped.Inc()
+		slog.Warn("broadcast buffer is full, dropping event", "type", event.Type, "room_id", event.RoomID)
+	}
+}
+
+// Register 添加连接并启动它的写 goroutine，返回当前连接数。
+// 用户的连接数超过上限时关闭该用户最早的连接，新打开的页面优先
+func (h *Hub) Register(c *Client) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = true
+	go c.writePump()
+	if c.UserID != 0 {
+		conns := append(h.byUser[c.UserID], c)
+		h.byUser[c.UserID] = conns
+		for _, old := range conns[:max(len(conns)-h.maxPerUser, 0)] {
+			h.metrics.limited.Inc()
+			slog.Warn("too many connections, closing oldest", "user_id", c.UserID, "limit", h.maxPerUser)
+			h.closeLocked(old, closeTooManyConnections, "too many connections")
+		}
+	}
+	return len(h.clients)
+}
+
+// Unregister 移除连接，返回当前连接数。已经排队的事件仍会写完，连接由调用方关闭
+func (h *Hub) Unregister(c *Client) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeLocked(c, 0, "")
+	return len(h.clients)
+}
+
+// ConnectionCount 返回本实例的连接数
+func (h *Hub) ConnectionCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// IsOnline 用户在本实例是否有 WebSocket 连接
+func (h *Hub) IsOnline(userID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.byUser[userID]) > 0
+}
+
+// UserConnections 一个用户在本实例的连接数
+type UserConnections struct {
+	UserID      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Connections int    `json:"connections"`
+}
+
+// UserConnectionCounts 返回本实例每个已认证用户的连接数，按连接数从多到少排列
+func (h *Hub) UserConnectionCounts() []UserConnections {
+	h.mu.Lock()
+	counts := make([]UserConnections, 0, len(h.byUser))
+	for userID, conns := range h.byUser {
+		counts = append(counts, UserConnections{UserID: userID, Username: conns[0].Username, Connections: len(conns)})
+	}
+	h.mu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Connections != counts[j].Connections {
+			return counts[i].Connections > counts[j].Connections
+		}
+		return counts[i].UserID < counts[j].UserID
+	})
+	return counts
+}
+
+// SetMembership 用户加入或离开聊天室后更新其所有连接的订阅范围
+func (h *Hub) SetMembership(userID, roomID int, member bool) {
+	if h.Broker != nil && h.send(hubMessage{Kind: hubMessageMembership, UserID: userID, RoomID: roomID, Member: member}) {
+		return
+	}
+	h.applyMembership(userID, roomID, member)
+}
+
+func (h *Hub) applyMembership(userID, roomID int, member bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range h.byUser[userID] {
+		if member {
+			client.rooms[roomID] = true
+		} else {
+			delete(client.rooms, roomID)
+		}
+	}
+}
+
+// SetBlocked 用户屏蔽或取消屏蔽其他用户后更新其所有连接
+func (h *Hub) SetBlocked(userID, blockedID int, blocked bool) {
+	if h.Broker != nil && h.send(hubMessage{Kind: hubMessageBlock, UserID: userID, BlockedID: blockedID, Blocked: blocked}) {
+		return
+	}
+	h.applyBlocked(userID, blockedID, blocked)
+}
+
+func (h *Hub) applyBlocked(userID, blockedID int, blocked bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range h.byUser[userID] {
+		if blocked {
+			client.blocked[blockedID] = true
+		} else {
+			delete(client.blocked, blockedID)
+		}
+	}
+}
+
+// Subscriber 接收 Hub 事件的连接，WebSocket 和 SSE 各有一个实现。
+// Send 只由连接的写 goroutine 调用，同一时间只有一个写入者；Close 可以与 Send 并发调用。
+type Subscriber interface {
+	Send(event Event) error
+	// Close 以关闭码和原因断开连接，关闭码只对 WebSocket 有意义
+	Close(code int, reason string)
+}
+
+// ConnSubscriber 把事件以 JSON 文本帧写入 WebSocket 连接
+type ConnSubscriber struct {
+	conn *websocket.Conn
+}
+
+func NewConnSubscriber(conn *websocket.Conn) ConnSubscriber {
+	return ConnSubscriber{conn: conn}
+}
+
+func (w ConnSubscriber) Send(event Event) error {
+	return w.conn.WriteJSON(event)
+}
+
+func (w ConnSubscriber) Close(code int, reason string) {
+	closeMsg := websocket.FormatCloseMessage(code, reason)
+	w.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+	w.conn.Close()
+}
+
+// Client 一个订阅连接（WebSocket 或 SSE），RoomID 为 0 表示接收所有已加入聊天室的事件。
+// 通过令牌认证的连接会记录用户信息，未认证时 userID 为 0。
+type Client struct {
+	hub      *Hub
+	sub      Subscriber
+	RoomID   int
+	UserID   int
+	Username string
+
+	// rooms 用户已加入的聊天室，由 hub.mu 保护
+	rooms map[int]bool
+	// blocked 用户屏蔽的其他用户，由 hub.mu 保护
+	blocked map[int]bool
+
+	// catchingUp 为 true 时正在补发断线期间的消息，实时事件先放入 pending，都由 hub.mu 保护
+	catchingUp bool
+	pending    []Event
+	// caughtUp 补发过的消息 ID。消息可能在补发查询之前保存、在补发结束之后才广播，
+	// 这样的实时事件收到时跳过并从中删除，由 hub.mu 保护
+	caughtUp map[int]bool
+
+	// out 等待写入连接的事件，由 writePump 写入。done 关闭后 writePump 写完剩余事件，
+	// closeCode 不为 0 时再以它关闭连接。closed、closeCode 和 closeReason 由 hub.mu 保护
+	out         chan Event
+	done        chan struct{}
+	stopped     chan struct{} // writePump 退出后关闭
+	closed      bool
+	closeCode   int
+	closeReason string
+}
+
+// ClientOptions 创建连接时的订阅范围
+type ClientOptions struct {
+	RoomID   int
+	UserID   int
+	Username string
+	// Rooms 用户已加入的聊天室，Blocked 用户屏蔽的其他用户，连接持有这两个 map，调用方不能再修改
+	Rooms   map[int]bool
+	Blocked map[int]bool
+	// CatchingUp 为 true 时先缓存实时事件，直到调用 FinishCatchUp
+	CatchingUp bool
+}
+
+// NewClient 创建连接，之后调用 Register 开始接收事件
+func NewClient(hub *Hub, sub Subscriber, opts ClientOptions) *Client {
+	if opts.Rooms == nil {
+		opts.Rooms = make(map[int]bool)
+	}
+	if opts.Blocked == nil {
+		opts.Blocked = make(map[int]bool)
+	}
+	return &Client{
+		hub:        hub,
+		sub:        sub,
+		RoomID:     opts.RoomID,
+		UserID:     opts.UserID,
+		Username:   opts.Username,
+		rooms:      opts.Rooms,
+		blocked:    opts.Blocked,
+		catchingUp: opts.CatchingUp,
+		out:        make(chan Event, hub.sendBuffer),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// HandleMessages 广播事件直到 ctx 取消，退出时关闭所有连接，
+// 使各连接的读循环结束并释放它们占用的数据库请求。
+func (h *Hub) HandleMessages(ctx context.Context) {
+	defer close(h.done)
+	if h.Broker != nil {
+		go func() {
+			if err := h.Broker.Subscribe(ctx, h.receive); err != nil {
+				slog.Error("broker subscription ended", "error", err)
+			}
+		}()
+	}
+	for !h.broadcastLoop(ctx) {
+	}
+}
+
+// broadcastLoop 广播事件直到 ctx 取消（返回 true）。发生 panic 时记录日志并返回 false，
+// 由 HandleMessages 重新启动，一个异常事件不会让整个进程停止广播。
+func (h *Hub) broadcastLoop(ctx context.Context) (done bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("broadcast loop panic, restarting", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+		}
+	}()
+	for {
+		var event Event
+		select {
+		case event = <-h.broadcast:
+		case <-ctx.Done():
+			h.closeAll()
+			return true
+		}
+		h.deliver(event)
+	}
+}
+
+// deliver 把事件写给所有应该收到它的连接
+func (h *Hub) deliver(event Event) {
+	start := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if !event.deliverTo(client) {
+			continue
+		}
+		if client.catchingUp {
+			if !client.queue(event) {
+				h.dropSlowCatchUp(client)
+			}
+			continue
+		}
+		if client.skipCaughtUp(event) {
+			continue
+		}
+		h.sendLocked(client, event)
+	}
+
+	h.metrics.latency.Observe(time.Since(start).Seconds())
+	if event.Type == EventMessage {
+		h.metrics.broadcast.Inc()
+	}
+}
+
+// closeAll 服务关闭时断开所有连接
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		h.closeLocked(client, websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// CloseRoom 聊天室删除后通知所有加入了它的连接并停止对它的订阅，单独订阅该聊天室的连接会被关闭
+func (h *Hub) CloseRoom(roomID int, reason string) {
+	if h.Broker != nil && h.send(hubMessage{Kind: hubMessageCloseRoom, RoomID: roomID, Reason: reason}) {
+		return
+	}
+	h.applyCloseRoom(roomID, reason)
+}
+
+func (h *Hub) applyCloseRoom(roomID int, reason string) {
+	event := Event{Type: EventRoomDeleted, RoomID: roomID, Data: RoomDeletedEvent{RoomID: roomID, Reason: reason}}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if client.RoomID != roomID && !client.rooms[roomID] {
+			continue
+		}
+		delete(client.rooms, roomID)
+		if h.sendLocked(client, event) && client.RoomID == roomID {
+			h.closeLocked(client, closeRoomDeleted, reason)
+		}
+	}
+}
+
+// RemoveFromRoom 用户被踢出或封禁后停止其所有连接对该聊天室的订阅并发送通知，
+// 单独订阅该聊天室的连接会被关闭
+func (h *Hub) RemoveFromRoom(userID, roomID int, action, reason string) {
+	if h.Broker != nil && h.send(hubMessage{Kind: hubMessageRemoveMember, UserID: userID, RoomID: roomID, Action: action, Reason: reason}) {
+		return
+	}
+	h.applyRemoveFromRoom(userID, roomID, action, reason)
+}
+
+func (h *Hub) applyRemoveFromRoom(userID, roomID int, action, reason string) {
+	event := Event{Type: EventRemovedFromRoom, RoomID: roomID, Data: RemovedFromRoomEvent{RoomID: roomID, Action: action, Reason: reason}}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range h.byUser[userID] {
+		delete(client.rooms, roomID)
+		if h.sendLocked(client, event) && client.RoomID == roomID {
+			h.closeLocked(client, closeRemovedFromRoom, action)
+		}
+	}
+}
+
+// DisconnectUser 账号删除或停用后关闭该用户在所有实例上的连接
+func (h *Hub) DisconnectUser(userID int, reason string) {
+	if h.Broker != nil && h.send(hubMessage{Kind: hubMessageDisconnect, UserID: userID, Reason: reason}) {
+		return
+	}
+	h.applyDisconnectUser(userID, reason)
+}
+
+func (h *Hub) applyDisconnectUser(userID int, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range h.byUser[userID] {
+		h.closeLocked(client, closeAccountDeleted, reason)
+	}
+}
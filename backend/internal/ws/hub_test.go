@@ -0,0 +1,284 @@
+package ws
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// recorder 记录收到的事件和关闭码的 Subscriber
+type recorder struct {
+	events chan Event
+
+	mu        sync.Mutex
+	closeCode int
+	closed    chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{events: make(chan Event, 100), closed: make(chan struct{})}
+}
+
+func (r *recorder) Send(event Event) error {
+	r.events <- event
+	return nil
+}
+
+func (r *recorder) Close(code int, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.closed:
+		return
+	default:
+	}
+	r.closeCode = code
+	close(r.closed)
+}
+
+// waitClosed 等待连接被关闭，返回关闭码
+func (r *recorder) waitClosed(t testing.TB) int {
+	t.Helper()
+	select {
+	case <-r.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the connection to be closed")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeCode
+}
+
+// next 等待下一个事件
+func (r *recorder) next(t testing.TB) Event {
+	t.Helper()
+	select {
+	case event := <-r.events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+// newTestHub 启动一个 Hub，测试结束时停止。broker 为 nil 时只在进程内广播
+func newTestHub(t testing.TB, broker Broker) *Hub {
+	t.Helper()
+	h := NewHub(64, 16, 5)
+	h.Broker = broker
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.HandleMessages(ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-h.done
+	})
+	return h
+}
+
+// subscribe 以 userID 的身份注册连接，rooms 为已加入的聊天室
+func subscribe(h *Hub, userID, roomID int, rooms ...int) (*Client, *recorder) {
+	rec := newRecorder()
+	joined := make(map[int]bool)
+	for _, id := range rooms {
+		joined[id] = true
+	}
+	c := NewClient(h, rec, ClientOptions{RoomID: roomID, UserID: userID, Rooms: joined})
+	h.Register(c)
+	return c, rec
+}
+
+// TestCloseRoom 删除聊天室时所有加入了它的连接都收到通知，单独订阅该聊天室的连接被关闭，
+// 订阅所有聊天室的连接保持打开但不再收到它的事件
+func TestCloseRoom(t *testing.T) {
+	h := newTestHub(t, nil)
+	_, single := subscribe(h, 1, 1, 1)
+	_, all := subscribe(h, 2, 0, 1, 2)
+	_, other := subscribe(h, 3, 0, 2)
+
+	h.CloseRoom(1, "deleted by owner")
+	for name, rec := range map[string]*recorder{"room connection": single, "all-rooms connection": all} {
+		if event := rec.next(t); event.Type != EventRoomDeleted || event.RoomID != 1 {
+			t.Fatalf("%s received %+v, want room_deleted", name, event)
+		}
+	}
+	if code := single.waitClosed(t); code != closeRoomDeleted {
+		t.Fatalf("close code = %d, want %d", code, closeRoomDeleted)
+	}
+
+	h.Publish(Event{Type: EventMessage, RoomID: 1, Data: "late"})
+	h.Publish(Event{Type: EventMessage, RoomID: 2, Data: "still here"})
+	if event := all.next(t); event.RoomID != 2 {
+		t.Fatalf("all-rooms connection received %+v after the room was deleted", event)
+	}
+	if event := other.next(t); event.RoomID != 2 {
+		t.Fatalf("connection outside the room received %+v", event)
+	}
+	select {
+	case <-all.closed:
+		t.Fatal("all-rooms connection was closed")
+	default:
+	}
+}
+
+// TestRemoveFromRoom 被踢出的用户的所有连接停止接收该聊天室的事件
+func TestRemoveFromRoom(t *testing.T) {
+	h := newTestHub(t, nil)
+	_, single := subscribe(h, 1, 1, 1)
+	_, all := subscribe(h, 1, 0, 1, 2)
+
+	h.RemoveFromRoom(1, 1, "ban", "spam")
+	for _, rec := range []*recorder{single, all} {
+		if event := rec.next(t); event.Type != EventRemovedFromRoom {
+			t.Fatalf("received %+v, want removed_from_room", event)
+		}
+	}
+	if code := single.waitClosed(t); code != closeRemovedFromRoom {
+		t.Fatalf("close code = %d, want %d", code, closeRemovedFromRoom)
+	}
+	h.Publish(Event{Type: EventMessage, RoomID: 1, Data: "hidden"})
+	h.Publish(Event{Type: EventMessage, RoomID: 2, Data: "visible"})
+	if event := all.next(t); event.RoomID != 2 {
+		t.Fatalf("received %+v from the room the user was removed from", event)
+	}
+}
+
+// TestPublishDropsWhenBufferFull 广播缓冲区满时 Publish 不等待，丢弃事件并计数
+func TestPublishDropsWhenBufferFull(t *testing.T) {
+	// 没有运行 HandleMessages，缓冲区不会被消费
+	h := NewHub(2, 16, 5)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			h.Publish(Event{Type: EventMessage, RoomID: 1})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full buffer")
+	}
+	if got := testutil.ToFloat64(h.metrics.dropped); got != 3 {
+		t.Fatalf("dropped = %v, want 3", got)
+	}
+}
+
+// stuckSubscriber 写入一直阻塞到 release 关闭，Close 与 SSESubscriber 一样要等待写入返回
+type stuckSubscriber struct {
+	mu      sync.Mutex
+	writing chan struct{}
+	release chan struct{}
+	code    chan int
+}
+
+func (s *stuckSubscriber) Send(Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.writing:
+	default:
+		close(s.writing)
+	}
+	<-s.release
+	return nil
+}
+
+func (s *stuckSubscriber) Close(code int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code <- code
+}
+
+// TestSlowClientEvicted 发送队列满的连接被断开，关闭它时不持有 hub.mu，其他连接照常收到事件
+func TestSlowClientEvicted(t *testing.T) {
+	h := newTestHub(t, nil)
+	slow := &stuckSubscriber{writing: make(chan struct{}), release: make(chan struct{}), code: make(chan int, 1)}
+	h.Register(NewClient(h, slow, ClientOptions{UserID: 1, Rooms: map[int]bool{1: true}}))
+	_, fast := subscribe(h, 2, 0, 1)
+
+	h.Publish(Event{Type: EventMessage, RoomID: 1})
+	<-slow.writing
+	fast.next(t)
+	// 写 goroutine 阻塞在第一个事件上，之后的事件填满发送队列
+	for i := 0; i <= h.sendBuffer; i++ {
+		h.Publish(Event{Type: EventMessage, RoomID: 1})
+		fast.next(t)
+	}
+
+	if got := h.ConnectionCount(); got != 1 {
+		t.Fatalf("connections = %d, want the slow client removed", got)
+	}
+	if got := testutil.ToFloat64(h.metrics.evicted); got != 1 {
+		t.Fatalf("evicted = %v, want 1", got)
+	}
+	// Close 仍在等待写入返回，hub 不能因此停止投递
+	h.Publish(Event{Type: EventMessage, RoomID: 1, Data: "after eviction"})
+	if event := fast.next(t); event.Data != "after eviction" {
+		t.Fatalf("received %+v, want the event published after the eviction", event)
+	}
+
+	close(slow.release)
+	select {
+	case code := <-slow.code:
+		if code != websocket.CloseTryAgainLater {
+			t.Fatalf("close code = %d, want %d", code, websocket.CloseTryAgainLater)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the slow client was never closed")
+	}
+}
+
+// discard 直接丢弃事件的 Subscriber，sleep 不为 0 时模拟写得慢的连接
+type discard struct{ sleep time.Duration }
+
+func (d discard) Send(Event) error {
+	time.Sleep(d.sleep)
+	return nil
+}
+
+func (discard) Close(int, string) {}
+
+// benchmarkHub 注册 n 个连接，平均分布在 rooms 个聊天室中
+func benchmarkHub(b *testing.B, n, rooms int, slow time.Duration) *Hub {
+	h := newTestHub(b, nil)
+	h.sendBuffer = 1024
+	for i := 0; i < n; i++ {
+		sub := discard{}
+		if i == 0 {
+			sub.sleep = slow
+		}
+		roomID := i%rooms + 1
+		h.Register(NewClient(h, sub, ClientOptions{RoomID: roomID, UserID: i + 1, Rooms: map[int]bool{roomID: true}}))
+	}
+	return h
+}
+
+// BenchmarkPublish1kClients HTTP 处理函数调用 Publish 的耗时，与连接数和最慢的连接无关
+func BenchmarkPublish1kClients(b *testing.B) {
+	for _, slow := range []time.Duration{0, 10 * time.Millisecond} {
+		b.Run("slow="+slow.String(), func(b *testing.B) {
+			h := benchmarkHub(b, 1000, 10, slow)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Publish(Event{Type: EventMessage, RoomID: i%10 + 1})
+			}
+		})
+	}
+}
+
+// BenchmarkFanOut1kClients 一个事件投递给 1000 个连接的耗时，投递只放入各连接的发送队列，不等待写入
+func BenchmarkFanOut1kClients(b *testing.B) {
+	for _, slow := range []time.Duration{0, 10 * time.Millisecond} {
+		b.Run("slow="+slow.String(), func(b *testing.B) {
+			h := benchmarkHub(b, 1000, 1, slow)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.deliver(Event{Type: EventMessage, RoomID: 1})
+			}
+		})
+	}
+}
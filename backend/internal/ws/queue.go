@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Send 把事件放入连接的发送队列。由连接自己的 goroutine 调用（回复、补发），队列满时等待，
+// 不会阻塞其他连接；连接已关闭时丢弃事件
+func (c *Client) Send(event Event) {
+	select {
+	case c.out <- event:
+	case <-c.done:
+	}
+}
+
+// sendLocked 广播时把事件放入发送队列，不等待。队列已满说明连接写得太慢，断开它，
+// 避免一个连接拖慢其他连接。返回 false 表示连接已经关闭。调用方持有 hub.mu
+func (h *Hub) sendLocked(c *Client, event Event) bool {
+	if c.closed {
+		return false
+	}
+	select {
+	case c.out <- event:
+		return true
+	default:
+	}
+	h.metrics.evicted.Inc()
+	slog.Warn("send queue is full, disconnecting slow client", "user_id", c.UserID, "queued", len(c.out))
+	// 写 goroutine 可能正阻塞在写入上，直接关闭连接让它返回，剩余事件不再写出。
+	// Subscriber.Close 可能要等待写入完成（例如 SSESubscriber 持有自己的锁），不能在持有 hub.mu 时调用
+	h.closeLocked(c, 0, "")
+	go c.sub.Close(websocket.CloseTryAgainLater, "client too slow")
+	return false
+}
+
+// CloseClient 移除连接，写完已经排队的事件后以关闭码断开，用于服务端主动断开异常客户端
+func (h *Hub) CloseClient(c *Client, code int, reason string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeLocked(c, code, reason)
+	return len(h.clients)
+}
+
+// WaitClosed 等待写 goroutine 退出，最多等待 timeout。连接关闭前调用，避免排队的事件和关闭帧丢失
+func (c *Client) WaitClosed(timeout time.Duration) bool {
+	select {
+	case <-c.stopped:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// closeLocked 移除连接并通知写 goroutine 退出。code 不为 0 时写完已经排队的事件后以它关闭连接。
+// 调用方持有 hub.mu
+func (h *Hub) closeLocked(c *Client, code int, reason string) {
+	delete(h.clients, c)
+	if c.closed {
+		return
+	}
+	h.removeUserConn(c)
+	c.closed = true
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.done)
+}
+
+// removeUserConn 从 byUser 中移除连接，调用方持有 hub.mu
+func (h *Hub) removeUserConn(c *Client) {
+	if c.UserID == 0 {
+		return
+	}
+	conns := h.byUser[c.UserID]
+	for i, conn := range conns {
+		if conn == c {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(h.byUser, c.UserID)
+		return
+	}
+	h.byUser[c.UserID] = conns
+}
+
+// writePump 把发送队列中的事件依次写入连接，每个连接一个。写入失败后移除连接，
+// 之后的事件直接丢弃
+func (c *Client) writePump() {
+	defer close(c.stopped)
+	failed := false
+	write := func(event Event) {
+		if failed {
+			return
+		}
+		if err := c.sub.Send(event); err != nil {
+			slog.Warn("subscriber write failed", "user_id", c.UserID, "error", err)
+			failed = true
+			c.hub.mu.Lock()
+			c.hub.closeLocked(c, websocket.CloseInternalServerErr, "write failed")
+			c.hub.mu.Unlock()
+		}
+	}
+
+	for {
+		select {
+		case event := <-c.out:
+			write(event)
+		case <-c.done:
+			// 先写完已经排队的事件，关闭前的通知（例如 room_deleted）不会丢失
+			for {
+				select {
+				case event := <-c.out:
+					write(event)
+				default:
+					if c.closeCode != 0 {
+						c.sub.Close(c.closeCode, c.closeReason)
+					}
+					return
+				}
+			}
+		}
+	}
+}
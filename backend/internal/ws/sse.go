@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errSSEClosed = errors.New("event stream closed")
+
+// SSESubscriber 把事件写成 text/event-stream。每个事件都带有 id，格式为“序号-消息 ID”：
+// 序号在同一个事件流中单调递增，消息 ID 是目前为止送达的最大消息 ID。
+// 浏览器重连时通过 Last-Event-ID 带回最后收到的 id，服务端从中取出消息 ID 补发断线期间的消息。
+type SSESubscriber struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// mu 保护写入和 seq、lastMessageID：事件由连接的 writePump 写入（不持有 hub.mu），
+	// 心跳由请求的 goroutine 写入，mu 保证两者的写入不会交错
+	mu            sync.Mutex
+	seq           int
+	lastMessageID int
+	closed        chan struct{}
+	once          sync.Once
+}
+
+// NewSSESubscriber 调用方负责在之前写好响应头。lastMessageID 为客户端已经收到的最大消息 ID，
+// 在收到新消息之前写入每个事件的 id
+func NewSSESubscriber(w http.ResponseWriter, flusher http.Flusher, lastMessageID int) *SSESubscriber {
+	return &SSESubscriber{w: w, flusher: flusher, lastMessageID: lastMessageID, closed: make(chan struct{})}
+}
+
+// SSEEventID 返回事件流中的事件 id
+func SSEEventID(seq, lastMessageID int) string {
+	return fmt.Sprintf("%d-%d", seq, lastMessageID)
+}
+
+// ParseSSEEventID 从 Last-Event-ID 中取出消息 ID，也接受只有消息 ID 的旧格式
+func ParseSSEEventID(id string) (lastMessageID int, err error) {
+	if seq, after, ok := strings.Cut(id, "-"); ok {
+		if n, err := strconv.Atoi(seq); err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid event sequence %q", seq)
+		}
+		id = after
+	}
+	lastMessageID, err = strconv.Atoi(id)
+	if err == nil && lastMessageID < 0 {
+		err = fmt.Errorf("negative message ID %d", lastMessageID)
+	}
+	return lastMessageID, err
+}
+
+func (s *SSESubscriber) Send(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return errSSEClosed
+	default:
+	}
+	if id := EventMessageID(event); event.Type == EventMessage && id > s.lastMessageID {
+		s.lastMessageID = id
+	}
+	s.seq++
+	if _, err := fmt.Fprintf(s.w, "id: %s\nevent: %s\ndata: %s\n\n", SSEEventID(s.seq, s.lastMessageID), event.Type, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// Close 结束事件流，SSE 没有关闭码，关闭原因由之前发送的事件说明。
+// 返回后不会再写入 ResponseWriter，请求的 goroutine 在 handler 返回之前调用
+func (s *SSESubscriber) Close(code int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.once.Do(func() { close(s.closed) })
+}
+
+// Closed Hub 关闭该连接后返回的 channel 被关闭
+func (s *SSESubscriber) Closed() <-chan struct{} {
+	return s.closed
+}
+
+// KeepAlive 写一行注释，防止代理因为连接空闲而断开
+func (s *SSESubscriber) KeepAlive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,55 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chatapp/internal/store"
+)
+
+// TestSSESubscriberEventIDs 每个事件都带有 id，序号单调递增，消息 ID 只在收到更新的消息时前进
+func TestSSESubscriberEventIDs(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sub := NewSSESubscriber(rec, rec, 5)
+
+	events := []Event{
+		{Type: EventMemberJoined, RoomID: 1},
+		{Type: EventMessage, RoomID: 1, Data: store.Message{ID: 7}},
+		// 补发期间缓存的较早消息不会让消息 ID 后退
+		{Type: EventMessage, RoomID: 1, Data: store.Message{ID: 6}},
+		{Type: EventMessagePinned, RoomID: 1, Data: store.Message{ID: 9}},
+	}
+	for _, event := range events {
+		if err := sub.Send(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sub.KeepAlive(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	want := []string{"id: 1-5", "id: 2-7", "id: 3-7", "id: 4-7", ": keep-alive"}
+	if len(frames) != len(want) {
+		t.Fatalf("%d frames, want %d: %q", len(frames), len(want), rec.Body.String())
+	}
+	for i, frame := range frames {
+		if first := strings.SplitN(frame, "\n", 2)[0]; first != want[i] {
+			t.Errorf("frame %d starts with %q, want %q", i, first, want[i])
+		}
+	}
+}
+
+func TestParseSSEEventID(t *testing.T) {
+	for id, want := range map[string]int{"3-42": 42, "1-0": 0, "42": 42} {
+		if got, err := ParseSSEEventID(id); err != nil || got != want {
+			t.Errorf("ParseSSEEventID(%q) = %d, %v, want %d", id, got, err, want)
+		}
+	}
+	for _, id := range []string{"", "x", "3-", "3-x", "-1", "3--1"} {
+		if _, err := ParseSSEEventID(id); err == nil {
+			t.Errorf("ParseSSEEventID(%q) succeeded", id)
+		}
+	}
+}
@@ -1,430 +1,185 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/gorilla/mux"
-	"github.com/gorilla/websocket"
-	_ "github.com/lib/pq"
-	"github.com/rs/cors"
-	"golang.org/x/crypto/bcrypt"
-)
+	"chatapp/internal/auth"
+	"chatapp/internal/config"
+	"chatapp/internal/httpapi"
+	"chatapp/internal/store/postgres"
+	"chatapp/internal/ws"
 
-var (
-	db        *sql.DB
-	jwtSecret []byte
-	upgrader  = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
-	}
-	clients   = make(map[*websocket.Conn]bool)
-	broadcast = make(chan Message)
-	mutex     = &sync.Mutex{}
+	"github.com/XSAM/otelsql"
+	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-type Message struct {
-	ID        int       `json:"id"`
-	RoomID    int       `json:"room_id"`
-	UserID    int       `json:"user_id"`
-	Username  string    `json:"username"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"-"` // 不返回密码
-}
-
-type ChatRoom struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-}
-
-type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type AuthResponse struct {
-	Token    string `json:"token"`
-	User     User   `json:"user"`
-	Message  string `json:"message"`
-}
-
-type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	jwt.RegisteredClaims
-}
-
 func main() {
-	var err error
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
-	}
-
-	jwtSecretEnv := os.Getenv("JWT_SECRET")
-	if jwtSecretEnv == "" {
-		jwtSecretEnv = "your-secret-key-change-in-production"
-	}
-	jwtSecret = []byte(jwtSecretEnv)
-
-	db, err = sql.Open("postgres", dbURL)
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer db.Close()
-
-	if err = db.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
-	}
-	log.Println("✅ Connected to PostgreSQL database")
-
-	go handleMessages()
-
-	router := mux.NewRouter()
-
-	// 公开路由（不需要认证）
-	router.HandleFunc("/api/health", healthCheck).Methods("GET")
-	router.HandleFunc("/api/auth/register", register).Methods("POST")
-	router.HandleFunc("/api/auth/login", login).Methods("POST")
-	router.HandleFunc("/api/rooms", getRooms).Methods("GET")
-	router.HandleFunc("/api/rooms/{id}/messages", getRoomMessages).Methods("GET")
-	
-	// 需要认证的路由
-	router.HandleFunc("/api/messages", authMiddleware(createMessage)).Methods("POST")
-	router.HandleFunc("/ws", handleWebSocket)
-
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
-	})
-
-	handler := c.Handler(router)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("🚀 Server starting on port %s\n", port)
-	if err := http.ListenAndServe(":"+port, handler); err != nil {
-		log.Fatal(err)
-	}
-}
-
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Server is running"})
-}
-
-func register(w http.ResponseWriter, r *http.Request) {
-	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// 验证输入
-	if req.Username == "" || req.Email == "" || req.Password == "" {
-		http.Error(w, "All fields are required", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.Password) < 6 {
-		http.Error(w, "Password must be at least 6 characters", http.StatusBadRequest)
-		return
-	}
-
-	// 检查用户是否已存在
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 OR username = $2)", 
-		req.Email, req.Username).Scan(&exists)
+	cfg, err := config.LoadConfig()
+	slog.SetDefault(newLogger(cfg.LogFormat, cfg.LogLevel))
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
-	}
-	if exists {
-		http.Error(w, "User already exists", http.StatusConflict)
-		return
+		// 逐条记录所有配置问题，一次修正后再启动
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			for _, problem := range invalid.Problems {
+				slog.Error("invalid configuration", "problem", problem)
+			}
+			os.Exit(1)
+		}
+		fatal("failed to load configuration", "error", err)
 	}
-
-	// 密码加密 bcrypt
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
-		return
+	if cfg.DevMode && cfg.JWT.Secret == config.DevJWTSecret {
+		slog.Warn("JWT_SECRET is not set, using the insecure development secret")
 	}
 
-	// 创建用户
-	var user User
-	err = db.QueryRow(
-		"INSERT INTO users (username, email, password_hash) VALUES ($1, $2, $3) RETURNING id, username, email",
-		req.Username, req.Email, string(hashedPassword),
-	).Scan(&user.ID, &user.Username, &user.Email)
-
+	shutdownTracing, err := initTracing(context.Background())
 	if err != nil {
-		// 如果数据库插入失败，直接返回错误
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
-		return   // 不会执行到生成 token 的步骤
+		fatal("failed to initialize tracing", "error", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
 
-	// 生成 JWT token
-	token, err := generateJWT(user)
+	jwtKeys, err := auth.LoadKeys(cfg.JWT)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
-	}
-
-	// 返回 token 和用户信息给前端
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AuthResponse{
-		Token:   token,
-		User:    user,
-		Message: "Registration successful",
-	})
-}
-
-func login(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+		fatal("failed to load JWT keys", "error", err)
 	}
 
-	// 查找用户
-	var user User
-	var hashedPassword string
-	err := db.QueryRow(
-		"SELECT id, username, email, password_hash FROM users WHERE email = $1",
-		req.Email,
-	).Scan(&user.ID, &user.Username, &user.Email, &hashedPassword)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
-		return
-	}
+	// 使用 otelsql 包装驱动，每条 SQL 都会产生 span
+	db, err := otelsql.Open("postgres", cfg.DatabaseURL, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+		fatal("failed to connect to database", "error", err)
 	}
+	defer db.Close()
 
-	// 登录时验证密码
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password))
-	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
-		return
-	}
+	configureDBPool(db, cfg.DB)
+	slog.Info("database pool configured",
+		"max_open_conns", cfg.DB.MaxOpenConns,
+		"max_idle_conns", cfg.DB.MaxIdleConns,
+		"conn_max_lifetime", cfg.DB.ConnMaxLifetime,
+	)
 
-	// 生成 JWT token
-	token, err := generateJWT(user)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
+	if err = waitForDB(context.Background(), db, cfg.DB.ConnectTimeout); err != nil {
+		fatal("failed to connect to database", "error", err)
 	}
+	slog.Info("connected to PostgreSQL database")
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AuthResponse{
-		Token:   token,
-		User:    user,
-		Message: "Login successful",
-	})
-}
-
-func generateJWT(user User) (string, error) {
-	claims := Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	if err = runMigrations(db); err != nil {
+		fatal("failed to run migrations", "error", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
+	// 收到退出信号时取消 ctx，正在执行的请求和数据库操作随之取消
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-//验证JWT Token
-func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
+	pg := postgres.New(db)
+	pg.QueryTimeout = cfg.DB.QueryTimeout
+	pg.ErrorHook = httpapi.DBErrorHook
+	httpapi.PromoteAdmin(ctx, pg, cfg.AdminEmail)
 
-		tokenString := authHeader
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenString = authHeader[7:]
+	hub := ws.NewHub(cfg.WS.BroadcastBuffer, cfg.WS.SendBuffer, cfg.WS.MaxConnsPerUser)
+	// 配置 REDIS_URL 时通过 Redis 在多个实例之间转发事件
+	if cfg.Redis.URL != "" {
+		broker, err := ws.NewRedisBroker(cfg.Redis.URL, cfg.Redis.Channel)
+		if err != nil {
+			fatal("invalid REDIS_URL", "error", err)
 		}
-
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+		if err := broker.Ping(ctx); err != nil {
+			fatal("failed to connect to Redis", "error", err)
 		}
-
-		// 将用户信息添加到请求上下文
-		r.Header.Set("X-User-ID", string(rune(claims.UserID)))
-		r.Header.Set("X-Username", claims.Username)
-        
-		//验证通过，执行下一个处理器
-		next(w, r)
-	}
-}
-
-func getRooms(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT id, name, description, created_at FROM chat_rooms ORDER BY created_at DESC")
+		defer broker.Close()
+		hub.Broker = broker
+		slog.Info("using Redis for WebSocket fan-out")
+	}
+	go hub.HandleMessages(ctx)
+
+	srv, err := httpapi.NewServer(db, httpapi.Stores{
+		Users:         pg,
+		Resets:        pg,
+		Rooms:         pg,
+		Members:       pg,
+		Messages:      pg,
+		Conversations: pg,
+		Reactions:     pg,
+		Reads:         pg,
+		Moderation:    pg,
+		Admin:         pg,
+		Schedule:      pg,
+		Webhooks:      pg,
+		Stats:         pg,
+		Push:          pg,
+		Blocks:        pg,
+		Export:        pg,
+		Pins:          pg,
+		Stars:         pg,
+		Notifications: pg,
+		Attachments:   pg,
+		Invites:       pg,
+		Polls:         pg,
+		Bots:          pg,
+	}, hub, jwtKeys, cfg)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	rooms := []ChatRoom{}
-	for rows.Next() {
-		var room ChatRoom
-		if err := rows.Scan(&room.ID, &room.Name, &room.Description, &room.CreatedAt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		rooms = append(rooms, room)
+		fatal("invalid configuration", "error", err)
 	}
+	srv.Start(ctx)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
-}
-
-func getRoomMessages(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	roomID := vars["id"]
+	port := strconv.Itoa(cfg.Port)
 
-	query := `
-		SELECT m.id, m.room_id, m.user_id, u.username, m.content, m.created_at
-		FROM messages m
-		JOIN users u ON m.user_id = u.id
-		WHERE m.room_id = $1
-		ORDER BY m.created_at ASC
-		LIMIT 100
-	`
-
-	rows, err := db.Query(query, roomID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	server := &http.Server{
+		Addr:        ":" + port,
+		Handler:     srv.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
-	defer rows.Close()
 
-	messages := []Message{}
-	for rows.Next() {
-		var msg Message
-		if err := rows.Scan(&msg.ID, &msg.RoomID, &msg.UserID, &msg.Username, &msg.Content, &msg.CreatedAt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		slog.Info("shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("server shutdown failed", "error", err)
 		}
-		messages = append(messages, msg)
-	}
+	}()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(messages)
-}
-
-func createMessage(w http.ResponseWriter, r *http.Request) {
-	var msg Message
-	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	query := `
-		INSERT INTO messages (room_id, user_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at
-	`
-
-	err := db.QueryRow(query, msg.RoomID, msg.UserID, msg.Content).Scan(&msg.ID, &msg.CreatedAt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	slog.Info("server starting", "port", port)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("server stopped", "error", err)
 	}
+	<-shutdownDone
+}
 
-	db.QueryRow("SELECT username FROM users WHERE id = $1", msg.UserID).Scan(&msg.Username)
-
-	broadcast <- msg
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(msg)
+// fatal 记录错误日志后退出
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("WebSocket upgrade error:", err)
-		return
+// newLogger 根据 LOG_FORMAT（json/text）和 LOG_LEVEL（debug/info/warn/error）创建日志器
+func newLogger(format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
 	}
-	defer conn.Close()
-
-	mutex.Lock()
-	clients[conn] = true
-	mutex.Unlock()
-
-	log.Println("✅ New WebSocket client connected")
 
-	for {
-		var msg Message
-		err := conn.ReadJSON(&msg)
-		if err != nil {
-			log.Println("WebSocket read error:", err)
-			mutex.Lock()
-			delete(clients, conn)
-			mutex.Unlock()
-			break
-		}
-		broadcast <- msg
+	opts := &slog.HandlerOptions{Level: lvl}
+	if strings.EqualFold(format, "json") {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
 	}
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
 }
-
-func handleMessages() {
-	for {
-		msg := <-broadcast
-		mutex.Lock()
-		for client := range clients {
-			err := client.WriteJSON(msg)
-			if err != nil {
-				log.Println("WebSocket write error:", err)
-				client.Close()
-				delete(clients, client)
-			}
-		}
-		mutex.Unlock()
-	}
-}
\ No newline at end of file
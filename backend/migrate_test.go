@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"regexp"
+	"testing"
+)
+
+// testDB 连接 TEST_DATABASE_URL 指定的数据库，未设置时跳过测试。
+// 测试会修改其中的表结构，只能使用专门的测试数据库
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	return db
+}
+
+// TestMigrationFileNames 迁移按文件名排序执行，编号必须唯一且位数相同
+func TestMigrationFileNames(t *testing.T) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+	pattern := regexp.MustCompile(`^migrations/(\d{3})_[a-z0-9_]+\.sql$`)
+	seen := make(map[string]string)
+	for _, name := range names {
+		m := pattern.FindStringSubmatch(name)
+		if m == nil {
+			t.Errorf("migration %s does not match NNN_description.sql", name)
+			continue
+		}
+		if other, ok := seen[m[1]]; ok {
+			t.Errorf("migrations %s and %s have the same number", other, name)
+		}
+		seen[m[1]] = name
+	}
+}
+
+// TestRunMigrationsIdempotent 第二次执行时跳过所有已应用的迁移
+func TestRunMigrationsIdempotent(t *testing.T) {
+	db := testDB(t)
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(names) {
+		t.Fatalf("%d migrations recorded, want %d", applied, len(names))
+	}
+}
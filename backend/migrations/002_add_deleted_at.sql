@@ -0,0 +1,2 @@
+-- 消息软删除
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- 消息回复，父消息被删除时回复保留
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS parent_message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent_message_id ON messages(parent_message_id);
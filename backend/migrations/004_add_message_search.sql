@@ -0,0 +1,5 @@
+-- 消息全文搜索，使用 simple 配置以同时支持中英文等不同语言的分词结果
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
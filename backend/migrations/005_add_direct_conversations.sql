@@ -0,0 +1,25 @@
+-- 私信会话，user_a_id 始终小于 user_b_id，保证同一对用户只有一个会话
+CREATE TABLE IF NOT EXISTS direct_conversations (
+    id SERIAL PRIMARY KEY,
+    user_a_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_b_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (user_a_id < user_b_id),
+    UNIQUE(user_a_id, user_b_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_direct_conversations_user_b_id ON direct_conversations(user_b_id);
+
+-- 私信复用 messages 表，每条消息属于一个聊天室或一个会话
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id INTEGER REFERENCES direct_conversations(id) ON DELETE CASCADE;
+ALTER TABLE messages ADD CONSTRAINT messages_room_or_conversation CHECK (num_nonnulls(room_id, conversation_id) = 1);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id_id ON messages(conversation_id, id);
+
+CREATE TABLE IF NOT EXISTS conversation_read_positions (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER NOT NULL REFERENCES direct_conversations(id) ON DELETE CASCADE,
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, conversation_id)
+);
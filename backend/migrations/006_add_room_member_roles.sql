@@ -0,0 +1,12 @@
+-- 聊天室成员角色
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member'
+    CHECK (role IN ('member', 'admin'));
+
+-- 已有数据：创建者成为管理员，发过消息的用户成为成员
+INSERT INTO room_members (room_id, user_id, role)
+SELECT id, created_by, 'admin' FROM chat_rooms WHERE created_by IS NOT NULL
+ON CONFLICT (room_id, user_id) DO UPDATE SET role = 'admin';
+
+INSERT INTO room_members (room_id, user_id)
+SELECT DISTINCT room_id, user_id FROM messages WHERE room_id IS NOT NULL AND user_id IS NOT NULL
+ON CONFLICT (room_id, user_id) DO NOTHING;
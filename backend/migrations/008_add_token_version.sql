@@ -0,0 +1,2 @@
+-- 修改密码后递增，旧版本的 token 全部失效
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
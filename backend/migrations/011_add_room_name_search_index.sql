@@ -0,0 +1,5 @@
+-- 聊天室名称和描述的模糊搜索（ILIKE）使用 trigram 索引
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_chat_rooms_name_trgm ON chat_rooms USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_chat_rooms_description_trgm ON chat_rooms USING GIN (description gin_trgm_ops);
@@ -0,0 +1,5 @@
+-- 图片附件的原始尺寸和缩略图
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS height INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS thumbnail_key VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS thumbnail_content_type VARCHAR(100) NOT NULL DEFAULT '';
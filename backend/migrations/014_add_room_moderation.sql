@@ -0,0 +1,34 @@
+-- 聊天室角色改为 owner / moderator / member，原来的 admin 中创建者成为 owner，其余成为 moderator
+ALTER TABLE room_members DROP CONSTRAINT IF EXISTS room_members_role_check;
+
+UPDATE room_members SET role = 'moderator' WHERE role = 'admin';
+
+UPDATE room_members rm SET role = 'owner'
+FROM chat_rooms c
+WHERE c.id = rm.room_id AND c.created_by = rm.user_id;
+
+ALTER TABLE room_members ADD CONSTRAINT room_members_role_check
+    CHECK (role IN ('owner', 'moderator', 'member'));
+
+-- 被封禁的用户不能重新加入聊天室
+CREATE TABLE IF NOT EXISTS room_bans (
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    banned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, user_id)
+);
+
+-- 管理操作审计日志
+CREATE TABLE IF NOT EXISTS moderation_audit (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    target_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_audit_room_created ON moderation_audit(room_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- 慢速模式：同一用户在聊天室中两次发言之间至少间隔的秒数，0 表示关闭
+ALTER TABLE chat_rooms ADD COLUMN IF NOT EXISTS slow_mode_seconds INTEGER NOT NULL DEFAULT 0
+    CHECK (slow_mode_seconds >= 0);
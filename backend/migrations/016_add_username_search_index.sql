@@ -0,0 +1,4 @@
+-- 按用户名前缀搜索用户（ILIKE）使用 trigram 索引
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING GIN (username gin_trgm_ops);
@@ -0,0 +1,4 @@
+-- 聊天室脏话过滤：block 拒绝消息，redact 用 * 替换命中的词
+ALTER TABLE room_settings ADD COLUMN IF NOT EXISTS profanity_filter BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE room_settings ADD COLUMN IF NOT EXISTS profanity_action VARCHAR(10) NOT NULL DEFAULT 'block'
+    CHECK (profanity_action IN ('block', 'redact'));
@@ -0,0 +1,3 @@
+-- 上传的头像保存在文件存储中，avatar_url 指向 /api/users/{id}/avatar
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_content_type VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- 客户端生成的幂等键，重试发送时返回已保存的消息。过期后置为 NULL，唯一索引只覆盖非空值
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS client_msg_id UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS messages_client_msg_id_key ON messages (user_id, client_msg_id) WHERE client_msg_id IS NOT NULL;
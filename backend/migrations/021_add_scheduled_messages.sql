@@ -0,0 +1,6 @@
+-- 定时消息：status 为 scheduled 的消息到 scheduled_at 后才发送，发送前只有作者可见
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'sent'
+    CHECK (status IN ('scheduled', 'sent'));
+
+CREATE INDEX IF NOT EXISTS idx_messages_scheduled ON messages (scheduled_at) WHERE status = 'scheduled';
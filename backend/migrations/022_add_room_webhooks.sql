@@ -0,0 +1,13 @@
+-- 聊天室的出站 webhook，新消息以 JSON POST 到 url，并用 secret 计算 HMAC-SHA256 签名
+CREATE TABLE IF NOT EXISTS room_webhooks (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_room_webhooks_room_id ON room_webhooks(room_id);
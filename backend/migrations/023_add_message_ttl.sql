@@ -0,0 +1,7 @@
+-- 阅后即焚：ttl_seconds 大于 0 的消息在 expires_at 之后被物理删除。
+-- 定时消息发送时 created_at 会更新，expires_at 随之重新计算
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS ttl_seconds INTEGER NOT NULL DEFAULT 0 CHECK (ttl_seconds >= 0);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP
+    GENERATED ALWAYS AS (CASE WHEN ttl_seconds > 0 THEN created_at + ttl_seconds * INTERVAL '1 second' END) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL;
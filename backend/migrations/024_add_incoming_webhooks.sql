@@ -0,0 +1,17 @@
+-- 入站 webhook：外部服务凭令牌向聊天室发送消息，只保存令牌的 SHA-256 哈希
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_room_id ON incoming_webhooks(room_id);
+
+-- webhook 发送的消息没有 user_id，发送者名称保存在 sender_name
+ALTER TABLE messages ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender_name VARCHAR(50);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS incoming_webhook_id INTEGER REFERENCES incoming_webhooks(id) ON DELETE SET NULL;
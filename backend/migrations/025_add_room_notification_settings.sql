@@ -0,0 +1,8 @@
+-- 每个用户在聊天室中的通知级别，没有记录时为 all
+CREATE TABLE IF NOT EXISTS room_notification_settings (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    room_id INTEGER NOT NULL REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    level VARCHAR(20) NOT NULL CHECK (level IN ('all', 'mentions_only', 'muted')),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, room_id)
+);
@@ -0,0 +1,12 @@
+-- 推送通知的设备：FCM 注册令牌或 Web Push 订阅（token 为 endpoint）
+CREATE TABLE IF NOT EXISTS push_devices (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(20) NOT NULL CHECK (platform IN ('fcm', 'webpush')),
+    token TEXT NOT NULL UNIQUE,
+    p256dh TEXT,
+    auth TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_devices_user_id ON push_devices(user_id);
@@ -0,0 +1,12 @@
+-- 全局内容过滤的处理记录，只保存命中的词，不保存消息内容，供管理员调整词表
+CREATE TABLE IF NOT EXISTS content_filter_audit (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
+    conversation_id INTEGER REFERENCES direct_conversations(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('mask', 'reject')),
+    matched_terms TEXT[] NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_filter_audit_created ON content_filter_audit(created_at DESC);
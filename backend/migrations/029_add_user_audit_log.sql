@@ -0,0 +1,9 @@
+-- 用户账号相关操作的审计记录（数据导出等），用户删除后保留记录
+CREATE TABLE IF NOT EXISTS user_audit_log (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_audit_log_user ON user_audit_log(user_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- 删除账号时清空邮箱，UNIQUE 约束允许多个 NULL
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
@@ -0,0 +1,2 @@
+-- 出站 webhook 订阅的事件类型，已有的 webhook 继续接收新消息
+ALTER TABLE room_webhooks ADD COLUMN IF NOT EXISTS events TEXT[] NOT NULL DEFAULT '{message}';
@@ -0,0 +1,8 @@
+-- 通知不再只来自 @提及：离线时收到的私信和针对自己的管理操作也会产生通知。
+-- 管理操作没有消息，聊天室、执行者、操作和原因直接保存在通知中
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS action VARCHAR(30);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id) WHERE NOT read;
@@ -0,0 +1,2 @@
+-- 聊天室共享密钥的入站 webhook，只保存密钥的 SHA-256 哈希，为空表示未启用
+ALTER TABLE room_settings ADD COLUMN IF NOT EXISTS incoming_webhook_secret_hash VARCHAR(64);
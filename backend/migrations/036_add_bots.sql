@@ -0,0 +1,11 @@
+-- 机器人令牌：自动化脚本使用的长期令牌，代表所有者调用 API，只保存令牌的 SHA-256 哈希
+CREATE TABLE IF NOT EXISTS bots (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    owner_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bots_owner ON bots(owner_user_id);
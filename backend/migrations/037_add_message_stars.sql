@@ -0,0 +1,9 @@
+-- 用户收藏的消息，只对收藏者本人可见
+CREATE TABLE IF NOT EXISTS stars (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stars_user_created ON stars(user_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- 用户最后一次调用需要认证的接口或建立/断开 WebSocket 连接的时间
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- 系统消息（成员加入、离开、聊天室改名、管理操作等）没有发送者，metadata 保存事件的结构化数据
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS message_type VARCHAR(10) NOT NULL DEFAULT 'user'
+    CHECK (message_type IN ('user', 'system'));
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSONB;
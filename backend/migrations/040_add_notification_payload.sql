@@ -0,0 +1,3 @@
+-- payload 保存通知的结构化数据（消息、聊天室、表情、管理操作等），客户端按 type 渲染。
+-- 已有的通知没有结构化数据，保留为空对象
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS payload JSONB NOT NULL DEFAULT '{}';
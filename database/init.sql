@@ -1,56 +0,0 @@
--- 创建用户表
-CREATE TABLE IF NOT EXISTS users (
-    id SERIAL PRIMARY KEY,
-    username VARCHAR(50) UNIQUE NOT NULL,
-    email VARCHAR(100) UNIQUE NOT NULL,
-    password_hash VARCHAR(255) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
--- 创建聊天室表
-CREATE TABLE IF NOT EXISTS chat_rooms (
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL,
-    description TEXT,
-    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
--- 创建聊天室成员关系表
-CREATE TABLE IF NOT EXISTS room_members (
-    id SERIAL PRIMARY KEY,
-    room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
-    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(room_id, user_id)
-);
-
--- 创建消息表
-CREATE TABLE IF NOT EXISTS messages (
-    id SERIAL PRIMARY KEY,
-    room_id INTEGER REFERENCES chat_rooms(id) ON DELETE CASCADE,
-    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-    content TEXT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
--- 创建索引以提高查询性能
-CREATE INDEX idx_messages_room_id ON messages(room_id);
-CREATE INDEX idx_messages_created_at ON messages(created_at);
-CREATE INDEX idx_room_members_user_id ON room_members(user_id);
-CREATE INDEX idx_room_members_room_id ON room_members(room_id);
-
--- 插入测试数据（可选）
--- 插入测试用户
-INSERT INTO users (username, email, password_hash) VALUES 
-('testuser1', 'test1@example.com', '$2a$10$placeholder'),
-('testuser2', 'test2@example.com', '$2a$10$placeholder')
-ON CONFLICT DO NOTHING;
-
--- 插入测试聊天室
-INSERT INTO chat_rooms (name, description, created_by) VALUES 
-('General', 'General discussion room', 1),
-('Random', 'Random chatter', 1)
-ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -24,8 +24,8 @@ export default function LoginPage() {
       });
 
       if (!response.ok) {
-        const data = await response.text();
-        throw new Error(data || 'Login failed');
+        const data = await response.json().catch(() => null);
+        throw new Error(data?.message || 'Login failed');
       }
 
       const data = await response.json();
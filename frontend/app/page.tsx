@@ -13,6 +13,7 @@ interface Message {
   room_id: number;
   user_id: number;
   username: string;
+  display_name?: string;
   content: string;
   created_at: string;
 }
@@ -20,6 +21,7 @@ interface Message {
 export default function ChatApp() {
   const [rooms, setRooms] = useState<Room[]>([]);
   const [selectedRoom, setSelectedRoom] = useState<Room | null>(null);
+  const [joinedRoomId, setJoinedRoomId] = useState<number | null>(null);
   const [messages, setMessages] = useState<Message[]>([]);
   const [newMessage, setNewMessage] = useState('');
   const [username, setUsername] = useState('');
@@ -32,51 +34,91 @@ export default function ChatApp() {
     fetch('http://localhost:8080/api/rooms')
       .then(res => res.json())
       .then(data => {
-        setRooms(data || []);
-        if (data && data.length > 0) {
-          setSelectedRoom(data[0]);
+        const list: Room[] = data?.rooms || [];
+        setRooms(list);
+        if (list.length > 0) {
+          setSelectedRoom(list[0]);
         }
       })
       .catch(err => console.error('Failed to fetch rooms:', err));
   }, []);
 
-  // 获取选中聊天室的消息
+  // 加入选中的聊天室（重复加入没有副作用）后获取消息
   useEffect(() => {
     if (!selectedRoom) return;
 
-    fetch(`http://localhost:8080/api/rooms/${selectedRoom.id}/messages`)
+    const headers = { Authorization: `Bearer ${localStorage.getItem('token') || ''}` };
+    fetch(`http://localhost:8080/api/rooms/${selectedRoom.id}/join`, { method: 'POST', headers })
+      .then(() => {
+        setJoinedRoomId(selectedRoom.id);
+        return fetch(`http://localhost:8080/api/rooms/${selectedRoom.id}/messages`, { headers });
+      })
       .then(res => res.json())
-      .then(data => setMessages(data || []))
+      .then(data => setMessages(Array.isArray(data?.messages) ? data.messages : []))
       .catch(err => console.error('Failed to fetch messages:', err));
   }, [selectedRoom]);
 
-  // WebSocket 连接
+  // WebSocket 连接（只订阅当前聊天室，需要先加入），断线后按服务器给出的提示退避重连
   useEffect(() => {
-    const ws = new WebSocket('ws://localhost:8080/ws');
-    
-    ws.onopen = () => {
-      console.log('✅ WebSocket connected');
-    };
-
-    ws.onmessage = (event) => {
-      const message = JSON.parse(event.data);
-      setMessages(prev => [...prev, message]);
-    };
-
-    ws.onerror = (error) => {
-      console.error('❌ WebSocket error:', error);
-    };
+    if (!selectedRoom || joinedRoomId !== selectedRoom.id) return;
+
+    let closed = false;
+    let attempt = 0;
+    let resumeToken = '';
+    let timer: ReturnType<typeof setTimeout> | undefined;
+
+    const connect = () => {
+      const params = new URLSearchParams({ room_id: String(selectedRoom.id) });
+      const token = localStorage.getItem('token');
+      if (token) params.set('token', token);
+      if (resumeToken) params.set('resume_token', resumeToken);
+
+      const ws = new WebSocket(`ws://localhost:8080/ws?${params}`);
+
+      ws.onopen = () => {
+        console.log('✅ WebSocket connected');
+      };
+
+      ws.onmessage = (event) => {
+        const data = JSON.parse(event.data);
+        if (data.type === 'welcome') {
+          attempt = 0;
+          resumeToken = data.data.resume_token;
+        } else if (data.type === 'message') {
+          setMessages(prev => [...prev, data.data]);
+        }
+      };
+
+      ws.onerror = (error) => {
+        console.error('❌ WebSocket error:', error);
+      };
+
+      ws.onclose = (event) => {
+        console.log('WebSocket disconnected');
+        if (closed || event.code === 4000) return;
+
+        // 4429: 服务器繁忙，关闭原因里带有 retry_after_ms
+        let delay = Math.min(30000, 1000 * 2 ** attempt) * (0.5 + Math.random());
+        if (event.code === 4429) {
+          try {
+            delay = JSON.parse(event.reason).retry_after_ms;
+          } catch {}
+        }
+        attempt++;
+        timer = setTimeout(connect, delay);
+      };
 
-    ws.onclose = () => {
-      console.log('WebSocket disconnected');
+      wsRef.current = ws;
     };
 
-    wsRef.current = ws;
+    connect();
 
     return () => {
-      ws.close();
+      closed = true;
+      clearTimeout(timer);
+      wsRef.current?.close();
     };
-  }, []);
+  }, [selectedRoom, joinedRoomId]);
 
   // 自动滚动到底部
   useEffect(() => {
@@ -89,14 +131,16 @@ export default function ChatApp() {
 
     const messageData = {
       room_id: selectedRoom.id,
-      user_id: 1, // 临时用户 ID
       content: newMessage
     };
 
     try {
       await fetch('http://localhost:8080/api/messages', {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: {
+          'Content-Type': 'application/json',
+          Authorization: `Bearer ${localStorage.getItem('token') || ''}`,
+        },
         body: JSON.stringify(messageData)
       });
       setNewMessage('');
@@ -154,7 +198,7 @@ export default function ChatApp() {
                 }`}
               >
                 <p className="text-xs font-semibold mb-1 opacity-75">
-                  {msg.username}
+                  {msg.display_name || msg.username}
                 </p>
                 <p className="break-words">{msg.content}</p>
                 <p className="text-xs mt-1 opacity-60">
@@ -38,8 +38,8 @@ export default function RegisterPage() {
       });
 
       if (!response.ok) {
-        const data = await response.text();
-        throw new Error(data || 'Registration failed');
+        const data = await response.json().catch(() => null);
+        throw new Error(data?.message || 'Registration failed');
       }
 
       const data = await response.json();